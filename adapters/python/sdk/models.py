@@ -37,6 +37,9 @@ class TagValue:
         text: String value
         flag: Boolean value
         unit: Unit (e.g., °C, %, mm/s)
+        timestamp: Per-tag source timestamp (milliseconds, epoch);
+            defaults to the AssetData timestamp when omitted
+        status_code: OPC-UA style status code; takes precedence over quality
     """
 
     name: str
@@ -45,6 +48,8 @@ class TagValue:
     text: str | None = None
     flag: bool | None = None
     unit: str = ""
+    timestamp: int | None = None
+    status_code: int | None = None
 
     def to_dict(self) -> dict[str, Any]:
         """Convert to dictionary for JSON serialization - exclude None values"""
@@ -58,6 +63,10 @@ class TagValue:
             result["flag"] = self.flag
         if self.unit:
             result["unit"] = self.unit
+        if self.timestamp is not None:
+            result["timestamp"] = self.timestamp
+        if self.status_code is not None:
+            result["status_code"] = self.status_code
 
         return result
 
@@ -99,11 +108,13 @@ class RelationType:
     - PART_OF: ssn:isPartOf (hierarchical relationship)
     - CONNECTED_TO: sosa:isHostedBy (peer/network connection)
     - LOCATED_IN: schema:containedInPlace (spatial containment)
+    - MEASURES: sosa:observes (sensor-to-property link)
     """
 
     PART_OF = "partOf"
     CONNECTED_TO = "connectedTo"
     LOCATED_IN = "locatedIn"
+    MEASURES = "measures"
 
 
 @dataclass
@@ -0,0 +1,397 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Response is a common response structure
+type Response struct {
+	Success   bool        `json:"success"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	ErrorCode string      `json:"error_code,omitempty"`
+}
+
+// Error codes set in Response.ErrorCode. Unlike Error, which is a
+// human-readable message, the codes are stable and safe to branch on.
+const (
+	ErrCodeBadRequest = "ERR_BAD_REQUEST" // malformed request or missing required field
+	ErrCodeValidation = "ERR_VALIDATION"  // field value rejected, e.g. an unknown relation type or a cycle
+	ErrCodeNotFound   = "ERR_NOT_FOUND"   // referenced asset, relation or template does not exist
+	ErrCodeDuplicate  = "ERR_DUPLICATE"   // asset name or relation already exists
+	ErrCodeInternal   = "ERR_INTERNAL"    // storage or encoding failure
+)
+
+// CreateAssetRequest is a request to create an asset
+type CreateAssetRequest struct {
+	Name         string            `json:"name"`
+	TemplateName string            `json:"template_name,omitempty"`
+	Labels       []string          `json:"labels,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	ExternalIDs  map[string]string `json:"external_ids,omitempty"`
+
+	// ExternalKey derives the asset ID from the key instead of choosing a
+	// random one, so repeating the create with the same key fails with
+	// ErrCodeDuplicate rather than adding another asset
+	ExternalKey string `json:"external_key,omitempty"`
+
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+// BatchCreateAssetsRequest is a request to create many assets atomically
+type BatchCreateAssetsRequest struct {
+	Assets []CreateAssetRequest `json:"assets"`
+}
+
+// BatchCreateAssetsResponse reports the assets created by a batch
+type BatchCreateAssetsResponse struct {
+	Created int      `json:"created"`
+	Assets  []*Asset `json:"assets"`
+}
+
+// BatchItemError identifies the batch entry that caused a rejection
+type BatchItemError struct {
+	Index int    `json:"index"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+// GetAssetRequest is a request to get an asset
+type GetAssetRequest struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+
+	// ExternalID looks the asset up by its identifier in another system,
+	// named by Scheme
+	Scheme     string `json:"scheme,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+
+	// IncludeDeleted also returns a soft-deleted asset; only applies to ID lookups
+	IncludeDeleted bool `json:"include_deleted,omitempty"`
+
+	// IncludeDegree adds the asset's relation counts to the reply
+	IncludeDegree bool `json:"include_degree,omitempty"`
+}
+
+// AssetWithDegree is the reply to a GetAssetRequest with IncludeDegree
+type AssetWithDegree struct {
+	*Asset
+	Degree RelationCount `json:"degree"`
+}
+
+// ListAssetsRequest is a request to list assets with pagination and filters
+type ListAssetsRequest struct {
+	Limit        int    `json:"limit,omitempty"`
+	Offset       int    `json:"offset,omitempty"`
+	Label        string `json:"label,omitempty"`
+	TemplateName string `json:"template_name,omitempty"`
+
+	IncludeDeleted bool `json:"include_deleted,omitempty"`
+
+	// CreatedAfter and CreatedBefore (RFC 3339) bound the creation time to
+	// [after, before); either may be omitted
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+
+	// OrderBy is created_at (default), name or template_name; OrderDir is
+	// asc or desc; by default created_at sorts newest first and the
+	// others ascending
+	OrderBy  string `json:"order_by,omitempty"`
+	OrderDir string `json:"order_dir,omitempty"`
+}
+
+// ListAssetsResponse is a page of assets with the total match count
+type ListAssetsResponse struct {
+	Assets []*Asset `json:"assets"`
+	Total  int      `json:"total"`
+	Limit  int      `json:"limit"`
+	Offset int      `json:"offset"`
+}
+
+// SearchAssetsRequest is a request to find assets by labels. LabelKey
+// matches "key:value" labels with that key, restricted to LabelValue when
+// set; combined with Labels both must match.
+type SearchAssetsRequest struct {
+	Labels []string `json:"labels"`
+	Match  string   `json:"match,omitempty"` // "all" (default) or "any"
+
+	LabelKey   string `json:"label_key,omitempty"`
+	LabelValue string `json:"label_value,omitempty"`
+
+	// NamePrefix matches names starting with it, ignoring case; results are
+	// then ordered by name. Limit caps those results, after the label
+	// filters, default 100.
+	NamePrefix string `json:"name_prefix,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// StaleAssetsRequest is a request for assets that have gone silent
+type StaleAssetsRequest struct {
+	Threshold string `json:"threshold"` // Go duration, e.g. "15m"
+}
+
+// DeleteAssetRequest is a request to delete an asset. Assets are soft-deleted
+// unless Hard is set, which removes the asset and its relations for good.
+type DeleteAssetRequest struct {
+	ID   string `json:"id"`
+	Hard bool   `json:"hard,omitempty"`
+}
+
+// RestoreAssetRequest is a request to undo a soft delete
+type RestoreAssetRequest struct {
+	ID string `json:"id"`
+}
+
+// CloneAssetRequest is a request to create a new asset with the template,
+// labels and attributes of an existing one
+type CloneAssetRequest struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	CopyRelations bool   `json:"copy_relations,omitempty"` // copy the outgoing relations, starting at the clone
+}
+
+// UpdateAssetRequest is a request to update an asset; only non-nil
+// fields are applied
+type UpdateAssetRequest struct {
+	ID           string             `json:"id"`
+	Name         *string            `json:"name,omitempty"`
+	TemplateName *string            `json:"template_name,omitempty"`
+	Labels       *[]string          `json:"labels,omitempty"`
+	Attributes   *map[string]string `json:"attributes,omitempty"`
+	ExternalIDs  *map[string]string `json:"external_ids,omitempty"` // replaces every scheme
+
+	// Setting latitude and longitude replaces the whole location, so an
+	// omitted altitude is cleared
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+// ValidateDataRequest is a request to check data against a template
+type ValidateDataRequest struct {
+	TemplateName string     `json:"template_name"`
+	Data         *AssetData `json:"data"`
+}
+
+// ValidateDataResponse reports a successful dry-run validation
+type ValidateDataResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// CheckRequest is a request to check the store's referential integrity
+type CheckRequest struct {
+	Limit int `json:"limit,omitempty"` // IDs listed per category, default 20
+}
+
+// SchemaRequest is a request for the JSON Schema of an API type
+type SchemaRequest struct {
+	Type string `json:"type,omitempty"` // Go type name, e.g. CreateAssetRequest; empty returns every schema
+}
+
+// LatestDataRequest is a request for an asset's most recent reading
+type LatestDataRequest struct {
+	AssetID string `json:"asset_id"`
+}
+
+// AggregateDataRequest is a request for per-bucket aggregates of a NUMBER
+// tag over [From, To), in unix milliseconds
+type AggregateDataRequest struct {
+	AssetID string `json:"asset_id"`
+	Tag     string `json:"tag"`
+	From    int64  `json:"from"`
+	To      int64  `json:"to"`
+	Bucket  string `json:"bucket"` // Go duration, e.g. "5m"
+}
+
+// CreateRelationRequest is a request to create a relation
+type CreateRelationRequest struct {
+	SourceAssetID string            `json:"source_asset_id"`
+	TargetAssetID string            `json:"target_asset_id"`
+	RelationType  RelationType      `json:"relation_type"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Attributes    map[string]any    `json:"attributes,omitempty"`
+	Weight        *float64          `json:"weight,omitempty"`
+}
+
+// BatchCreateRelationsRequest is a request to create many relations atomically
+type BatchCreateRelationsRequest struct {
+	Relations []CreateRelationRequest `json:"relations"`
+}
+
+// BatchCreateRelationsResponse reports the relations created by a batch
+type BatchCreateRelationsResponse struct {
+	Created   int              `json:"created"`
+	Relations []*AssetRelation `json:"relations"`
+}
+
+// GetRelationRequest is a request to get a relation
+type GetRelationRequest struct {
+	ID string `json:"id"`
+}
+
+// RelationExistsRequest asks whether a relation between two assets exists
+type RelationExistsRequest struct {
+	SourceAssetID string       `json:"source_asset_id"`
+	TargetAssetID string       `json:"target_asset_id"`
+	RelationType  RelationType `json:"relation_type"`
+}
+
+// RelationExistsResponse is the response to a RelationExistsRequest. ID is
+// the existing relation, which for symmetric types may run the other way.
+type RelationExistsResponse struct {
+	Exists bool   `json:"exists"`
+	ID     string `json:"id,omitempty"`
+}
+
+// RelationsBetweenRequest asks for the relations between two assets
+type RelationsBetweenRequest struct {
+	AssetID      string `json:"asset_id"`
+	OtherAssetID string `json:"other_asset_id"`
+}
+
+// RelationCountRequest asks for the number of relations of an asset
+type RelationCountRequest struct {
+	AssetID string `json:"asset_id"`
+}
+
+// RelationCount is the degree of an asset in the relation graph
+type RelationCount struct {
+	Incoming int `json:"incoming"`
+	Outgoing int `json:"outgoing"`
+	Total    int `json:"total"`
+}
+
+// ListRelationsRequest is a request to list relations
+type ListRelationsRequest struct {
+	AssetID       string       `json:"asset_id,omitempty"`
+	RelationType  RelationType `json:"relation_type,omitempty"`
+	Direction     string       `json:"direction,omitempty"`      // "outgoing", "incoming", "both"
+	CreatedAfter  int64        `json:"created_after,omitempty"`  // unix seconds
+	CreatedBefore int64        `json:"created_before,omitempty"` // unix seconds
+}
+
+// ListAllRelationsRequest is a request for a page of every relation
+type ListAllRelationsRequest struct {
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset,omitempty"`
+}
+
+// ListAllRelationsResponse is a page of relations with the total count
+type ListAllRelationsResponse struct {
+	Relations []*AssetRelation `json:"relations"`
+	Total     int              `json:"total"`
+	Limit     int              `json:"limit"`
+	Offset    int              `json:"offset"`
+}
+
+// DeleteRelationRequest is a request to delete a relation
+type DeleteRelationRequest struct {
+	ID string `json:"id"`
+}
+
+// UpdateRelationRequest is a request to replace a relation's metadata.
+// Attributes and weight are replaced only when present; an empty attributes
+// object clears them, and ClearWeight clears the weight.
+type UpdateRelationRequest struct {
+	ID          string            `json:"id"`
+	Metadata    map[string]string `json:"metadata"`
+	Attributes  map[string]any    `json:"attributes,omitempty"`
+	Weight      *float64          `json:"weight,omitempty"`
+	ClearWeight bool              `json:"clear_weight,omitempty"`
+}
+
+// RelationTreeRequest is a request to walk the relation hierarchy of an asset
+type RelationTreeRequest struct {
+	AssetID      string       `json:"asset_id"`
+	RelationType RelationType `json:"relation_type,omitempty"` // default: partOf
+	Direction    string       `json:"direction,omitempty"`     // "descendants" (default) or "ancestors"
+	Depth        int          `json:"depth,omitempty"`         // 0 means unlimited
+}
+
+// ImportSnapshotRequest is a request to restore a snapshot
+type ImportSnapshotRequest struct {
+	Mode     string          `json:"mode,omitempty"` // "merge" (default) or "replace"
+	Snapshot json.RawMessage `json:"snapshot"`
+}
+
+// TemplateReloadResult reports a reload of the template directory
+type TemplateReloadResult struct {
+	Count  int      `json:"count"`            // templates loaded after the reload
+	Errors []string `json:"errors,omitempty"` // files that failed to load
+}
+
+// StoreStats contains store statistics
+type StoreStats struct {
+	TotalAssets      int            `json:"total_assets"`
+	AssetsByTemplate map[string]int `json:"assets_by_template"` // "" counts assets without a template
+	TotalRelations   int            `json:"total_relations"`
+	RelationsByType  map[string]int `json:"relations_by_type"`
+	LastUpdated      time.Time      `json:"last_updated"`
+}
+
+// DataBucket aggregates the values of one tag over [Start, Start+bucket).
+// A bucket without values has Count 0 and no Min, Max or Avg.
+type DataBucket struct {
+	Start int64    `json:"start"`
+	Count int      `json:"count"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	Avg   *float64 `json:"avg,omitempty"`
+}
+
+// JSONSchema is the subset of JSON Schema used to describe the API types
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+// IntegrityIssues counts one kind of problem and lists the first offending
+// IDs in ID order
+type IntegrityIssues struct {
+	Count int      `json:"count"`
+	IDs   []string `json:"ids,omitempty"`
+}
+
+// IntegrityReport lists the inconsistencies found by an integrity check
+type IntegrityReport struct {
+	DanglingRelations    IntegrityIssues `json:"dangling_relations"`     // relations whose source or target asset is missing
+	UnknownRelationTypes IntegrityIssues `json:"unknown_relation_types"` // relations of a type no longer defined
+	RelationCycles       IntegrityIssues `json:"relation_cycles"`        // hierarchical relations closing a cycle
+	UnknownTemplates     IntegrityIssues `json:"unknown_templates"`      // assets whose template does not exist
+}
+
+// OK reports whether no issue was found
+func (r *IntegrityReport) OK() bool {
+	return r.DanglingRelations.Count == 0 && r.UnknownRelationTypes.Count == 0 &&
+		r.RelationCycles.Count == 0 && r.UnknownTemplates.Count == 0
+}
+
+// Snapshot import modes
+const (
+	// SnapshotMerge upserts the snapshot's assets and relations by ID and
+	// keeps everything else in the store
+	SnapshotMerge = "merge"
+	// SnapshotReplace deletes every asset and relation before importing
+	SnapshotReplace = "replace"
+)
+
+// Snapshot is a dump of the metadata graph: every asset, soft-deleted ones
+// included, and every relation. Stored asset data is not part of it.
+type Snapshot struct {
+	Version   int              `json:"version"`
+	CreatedAt time.Time        `json:"created_at"`
+	Assets    []*Asset         `json:"assets"`
+	Relations []*AssetRelation `json:"relations"`
+}
@@ -0,0 +1,91 @@
+// Package api defines the messages exchanged with the EDG platform over
+// NATS: subjects, request and reply types, the data envelope and error
+// codes. It has no dependency on the core service, so clients can share
+// the types without linking the store.
+package api
+
+import "strings"
+
+// DefaultSubjectPrefix is the first token of every subject constant. An
+// instance started with another prefix serves the same subjects under it,
+// so several instances can share one NATS cluster.
+const DefaultSubjectPrefix = "platform"
+
+// PrefixSubject moves subject, one of the subject constants, under prefix,
+// e.g. platform.data.asset becomes site-a.data.asset. An empty prefix keeps
+// the default.
+func PrefixSubject(prefix, subject string) string {
+	if prefix == "" || prefix == DefaultSubjectPrefix {
+		return subject
+	}
+	return prefix + strings.TrimPrefix(subject, DefaultSubjectPrefix)
+}
+
+// Data subjects
+const (
+	SubjectDataAsset        = "platform.data.asset"
+	SubjectDataBatch        = "platform.data.batch"
+	SubjectDataValidated    = "platform.data.validated"
+	SubjectDataRejected     = "platform.data.rejected"
+	SubjectDataDeadLetter   = "platform.data.deadletter"
+	SubjectDataUnregistered = "platform.data.unregistered"
+	SubjectDataEvents       = "platform.data.events"
+)
+
+// NATS subjects
+const (
+	SubjectAssetCreate    = "platform.meta.asset.create"
+	SubjectAssetGet       = "platform.meta.asset.get"
+	SubjectAssetList      = "platform.meta.asset.list"
+	SubjectAssetDelete    = "platform.meta.asset.delete"
+	SubjectAssetUpdate    = "platform.meta.asset.update"
+	SubjectAssetRestore   = "platform.meta.asset.restore"
+	SubjectAssetClone     = "platform.meta.asset.clone"
+	SubjectAssetBatch     = "platform.meta.asset.batch_create"
+	SubjectAssetSearch    = "platform.meta.asset.search"
+	SubjectAssetStale     = "platform.meta.asset.stale"
+	SubjectTemplateList   = "platform.meta.template.list"
+	SubjectTemplateReload = "platform.meta.template.reload"
+	SubjectValidate       = "platform.meta.validate"
+	SubjectStats          = "platform.meta.stats"
+	SubjectSchema         = "platform.meta.schema"
+	SubjectCheck          = "platform.meta.check"
+
+	// Stored data query subjects live under platform.meta: requests under
+	// platform.data.> would be captured by the data stream, whose publish
+	// ack would race the reply
+	SubjectDataLatest    = "platform.meta.data.latest"
+	SubjectDataAggregate = "platform.meta.data.aggregate"
+
+	// Relation subjects
+	SubjectRelationCreate  = "platform.meta.relation.create"
+	SubjectRelationGet     = "platform.meta.relation.get"
+	SubjectRelationExists  = "platform.meta.relation.exists"
+	SubjectRelationCount   = "platform.meta.relation.count"
+	SubjectRelationBetween = "platform.meta.relation.between"
+	SubjectRelationList    = "platform.meta.relation.list"
+	SubjectRelationDelete  = "platform.meta.relation.delete"
+	SubjectRelationUpdate  = "platform.meta.relation.update"
+	SubjectRelationTree    = "platform.meta.relation.tree"
+	SubjectRelationBatch   = "platform.meta.relation.batch_create"
+
+	SubjectRelationListAll = "platform.meta.relation.list_all"
+
+	// Export subjects
+	SubjectExportJSONLD   = "platform.meta.export.jsonld"
+	SubjectSnapshotExport = "platform.meta.snapshot.export"
+	SubjectSnapshotImport = "platform.meta.snapshot.import"
+)
+
+// Lifecycle event subjects. The meta handlers publish an event after each
+// successful change made through them; changes made by snapshot imports or
+// cascading from a hard asset delete are not announced.
+const (
+	SubjectEventAssetCreated = "platform.events.asset.created"
+	SubjectEventAssetUpdated = "platform.events.asset.updated"
+	SubjectEventAssetDeleted = "platform.events.asset.deleted"
+
+	SubjectEventRelationCreated = "platform.events.relation.created"
+	SubjectEventRelationUpdated = "platform.events.relation.updated"
+	SubjectEventRelationDeleted = "platform.events.relation.deleted"
+)
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"strings"
+	"time"
+)
+
+// AssetData represents data collected from an asset
+type AssetData struct {
+	SchemaVersion int               `json:"schema_version,omitempty"` // envelope schema; unset means 1
+	AssetID       string            `json:"asset_id"`
+	Timestamp     int64             `json:"timestamp"`
+	Values        []TagValue        `json:"values"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// CurrentSchemaVersion is the newest AssetData schema this build understands
+const CurrentSchemaVersion = 1
+
+// EffectiveSchemaVersion returns the schema the message was written in,
+// treating an unset version as 1 for senders predating the field
+func (d *AssetData) EffectiveSchemaVersion() int {
+	if d.SchemaVersion == 0 {
+		return 1
+	}
+	return d.SchemaVersion
+}
+
+// TagValue represents an individual tag value
+type TagValue struct {
+	Name       string   `json:"name"`
+	Number     *float64 `json:"number,omitempty"`
+	Text       *string  `json:"text,omitempty"`
+	Flag       *bool    `json:"flag,omitempty"`
+	Unit       string   `json:"unit,omitempty"`
+	Quality    Quality  `json:"quality"`
+	Timestamp  *int64   `json:"timestamp,omitempty"`   // per-tag source timestamp; falls back to AssetData.Timestamp
+	StatusCode *uint32  `json:"status_code,omitempty"` // OPC-UA style status code; takes precedence over Quality
+}
+
+// Quality is the reliability of a tag value, following OPC-UA status code
+// severity
+type Quality string
+
+// Quality levels, from most to least reliable
+const (
+	QualityGood      Quality = "good"
+	QualityUncertain Quality = "uncertain"
+	QualityBad       Quality = "bad"
+)
+
+// NormalizeQuality maps a quality string to its canonical level, ignoring
+// case and surrounding space. An empty quality is good, as senders omit it
+// for healthy readings. Unknown strings map to uncertain and report false.
+func NormalizeQuality(s string) (Quality, bool) {
+	switch q := Quality(strings.ToLower(strings.TrimSpace(s))); q {
+	case "":
+		return QualityGood, true
+	case QualityGood, QualityUncertain, QualityBad:
+		return q, true
+	default:
+		return QualityUncertain, false
+	}
+}
+
+// rank orders qualities for comparison; higher is more reliable
+func (q Quality) rank() int {
+	switch q {
+	case QualityGood:
+		return 2
+	case QualityUncertain:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AtLeast reports whether q is as reliable as min
+func (q Quality) AtLeast(min Quality) bool {
+	return q.rank() >= min.rank()
+}
+
+// EffectiveQuality returns the tag quality. A StatusCode takes precedence over
+// the Quality string: its two severity bits map to good, uncertain or bad.
+func (v TagValue) EffectiveQuality() Quality {
+	if v.StatusCode == nil {
+		q, _ := NormalizeQuality(string(v.Quality))
+		return q
+	}
+	switch *v.StatusCode >> 30 {
+	case 0:
+		return QualityGood
+	case 1:
+		return QualityUncertain
+	default:
+		return QualityBad
+	}
+}
+
+// Asset represents a registered asset (sensor, equipment, etc.)
+type Asset struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	TemplateName    string            `json:"template_name,omitempty"`
+	TemplateVersion int               `json:"template_version,omitempty"` // template version at creation, 0 if unversioned
+	Labels          []string          `json:"labels,omitempty"`
+	Attributes      map[string]string `json:"attributes,omitempty"`   // structured metadata, e.g. vendor/model
+	ExternalIDs     map[string]string `json:"external_ids,omitempty"` // identifiers in other systems by scheme, e.g. erp or aas
+	CreatedAt       time.Time         `json:"created_at"`
+	DeletedAt       *time.Time        `json:"deleted_at,omitempty"` // set while the asset is soft-deleted
+	LastSeen        *time.Time        `json:"last_seen,omitempty"`  // last time data from the asset was accepted
+
+	// Optional WGS 84 position; latitude and longitude are set together
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Altitude  *float64 `json:"altitude,omitempty"` // meters
+}
+
+// AssetTemplate defines an asset type loaded from YAML
+type AssetTemplate struct {
+	Name      string          `yaml:"name" json:"name"`
+	Resources []AssetResource `yaml:"resources" json:"resources"`
+
+	// Strict rejects data containing tags not defined in Resources
+	Strict bool `yaml:"strict,omitempty" json:"strict,omitempty"`
+
+	// StrictUnits fails loading on units missing from the QUDT allowlist and
+	// rejects data whose tag unit differs from the declared one
+	StrictUnits bool `yaml:"strictUnits,omitempty" json:"strictUnits,omitempty"`
+
+	// Version is bumped on every template change; 0 means unversioned
+	Version int `yaml:"version,omitempty" json:"version,omitempty"`
+	// BreakingVersion is the version of the last breaking change. Assets
+	// recorded with an older version are incompatible.
+	BreakingVersion int `yaml:"breakingVersion,omitempty" json:"breakingVersion,omitempty"`
+	// StrictVersion rejects data from incompatible assets instead of warning
+	StrictVersion bool `yaml:"strictVersion,omitempty" json:"strictVersion,omitempty"`
+}
+
+// AssetResource defines a data point provided by an asset
+type AssetResource struct {
+	Name      string `yaml:"name" json:"name"`           // maps to TagValue.Name
+	ValueType string `yaml:"valueType" json:"valueType"` // NUMBER, TEXT, FLAG
+	Unit      string `yaml:"unit,omitempty" json:"unit,omitempty"`
+
+	// Required resources must be present in every AssetData message
+	Required bool `yaml:"required,omitempty" json:"required,omitempty"`
+
+	// Optional inclusive bounds for NUMBER resources
+	Min *float64 `yaml:"min,omitempty" json:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty" json:"max,omitempty"`
+
+	// Documentation for clients and UIs only; not used in validation
+	DisplayName string `yaml:"displayName,omitempty" json:"displayName,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// ValueType constants
+const (
+	ValueTypeNumber = "NUMBER"
+	ValueTypeText   = "TEXT"
+	ValueTypeFlag   = "FLAG"
+)
+
+// RelationType represents the type of relationship between assets
+type RelationType string
+
+const (
+	// RelationPartOf indicates a hierarchical relationship (ssn:isPartOf)
+	RelationPartOf RelationType = "partOf"
+	// RelationConnectedTo indicates a peer/network connection (sosa:isHostedBy)
+	RelationConnectedTo RelationType = "connectedTo"
+	// RelationLocatedIn indicates spatial containment (schema:containedInPlace)
+	RelationLocatedIn RelationType = "locatedIn"
+	// RelationMeasures links a sensor to the property it observes (sosa:observes)
+	RelationMeasures RelationType = "measures"
+)
+
+// AssetRelation represents a relationship between two assets
+type AssetRelation struct {
+	ID            string            `json:"id"`
+	SourceAssetID string            `json:"source_asset_id"`
+	TargetAssetID string            `json:"target_asset_id"`
+	RelationType  RelationType      `json:"relation_type"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Weight        *float64          `json:"weight,omitempty"` // edge weight for connectivity analysis, e.g. pipe diameter
+
+	// Attributes are typed metadata: each value is a string, number or
+	// boolean and keeps its JSON type, unlike Metadata values
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// RelationBetween is a relation between two assets with its direction as
+// seen from the first: "outgoing" when it points from the first asset to
+// the second, "incoming" otherwise
+type RelationBetween struct {
+	*AssetRelation
+	Direction string `json:"direction"`
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// backfillConfig selects the stored data exported and where it is sent
+type backfillConfig struct {
+	URL     string // remote-write endpoint
+	From    time.Time
+	To      time.Time     // exclusive
+	Chunk   time.Duration // data of one chunk is read, encoded and sent at a time
+	AssetID string        // empty exports every asset
+}
+
+// backfillStats counts what a backfill sent
+type backfillStats struct {
+	Requests int
+	Series   int
+	Samples  int
+}
+
+// dataStore is the part of core.Store the backfill reads
+type dataStore interface {
+	ScanAssetData(assetID string, from, to int64, fn func(*core.AssetData) error) error
+	GetAssetIncludeDeleted(id string) (*core.Asset, error)
+}
+
+// backfiller exports stored readings to a remote-write endpoint
+type backfiller struct {
+	store  dataStore
+	client *http.Client
+	cfg    backfillConfig
+
+	assetLabels map[string][]label // series labels of each asset seen so far
+}
+
+func newBackfiller(store dataStore, client *http.Client, cfg backfillConfig) *backfiller {
+	return &backfiller{
+		store:       store,
+		client:      client,
+		cfg:         cfg,
+		assetLabels: make(map[string][]label),
+	}
+}
+
+// run exports the configured range one chunk at a time, so memory is
+// bounded by the data of a chunk. Empty chunks send nothing. It stops at
+// the first chunk that fails to be read or accepted.
+func (b *backfiller) run(ctx context.Context) (backfillStats, error) {
+	var stats backfillStats
+	for start := b.cfg.From; start.Before(b.cfg.To); start = start.Add(b.cfg.Chunk) {
+		end := start.Add(b.cfg.Chunk)
+		if end.After(b.cfg.To) {
+			end = b.cfg.To
+		}
+
+		all, err := b.collect(start.UnixMilli(), end.UnixMilli())
+		if err != nil {
+			return stats, err
+		}
+		if len(all) == 0 {
+			continue
+		}
+		if err := b.send(ctx, all); err != nil {
+			return stats, fmt.Errorf("chunk starting %s: %w", start.Format(time.RFC3339), err)
+		}
+
+		stats.Requests++
+		stats.Series += len(all)
+		for _, s := range all {
+			stats.Samples += len(s.Samples)
+		}
+	}
+	return stats, nil
+}
+
+// collect reads the readings with from <= timestamp < to into one series
+// per asset and tag. Only NUMBER and FLAG values are exported; a flag is 1
+// or 0. The store keeps timestamps in unix milliseconds, whichever unit
+// they were ingested in, so they are sent as they are read.
+func (b *backfiller) collect(from, to int64) ([]*series, error) {
+	type seriesKey struct{ assetID, tag string }
+	bySeries := make(map[seriesKey]*series)
+	var order []seriesKey
+
+	err := b.store.ScanAssetData(b.cfg.AssetID, from, to, func(data *core.AssetData) error {
+		for _, tv := range data.Values {
+			var value float64
+			switch {
+			case tv.Number != nil:
+				value = *tv.Number
+			case tv.Flag != nil:
+				if *tv.Flag {
+					value = 1
+				}
+			default:
+				continue
+			}
+			ts := data.Timestamp
+			if tv.Timestamp != nil {
+				ts = *tv.Timestamp
+			}
+
+			key := seriesKey{data.AssetID, tv.Name}
+			s, ok := bySeries[key]
+			if !ok {
+				s = &series{}
+				bySeries[key] = s
+				order = append(order, key)
+			}
+			s.Samples = append(s.Samples, sample{Value: value, Timestamp: ts})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Assets are looked up once the scan is done, as an in-memory store has
+	// a single connection
+	all := make([]*series, len(order))
+	for i, key := range order {
+		assetLabels, err := b.labelsOf(key.assetID)
+		if err != nil {
+			return nil, err
+		}
+		s := bySeries[key]
+		s.Labels = append([]label{{Name: "__name__", Value: metricName(key.tag)}}, assetLabels...)
+		sort.Slice(s.Labels, func(i, j int) bool { return s.Labels[i].Name < s.Labels[j].Name })
+		all[i] = s
+	}
+	return all, nil
+}
+
+// labelsOf returns the series labels of an asset: asset_id, plus one label
+// per "key:value" asset label. Plain labels and keys that would replace
+// asset_id or the metric name are left out.
+func (b *backfiller) labelsOf(assetID string) ([]label, error) {
+	if labels, ok := b.assetLabels[assetID]; ok {
+		return labels, nil
+	}
+
+	labels := []label{{Name: "asset_id", Value: assetID}}
+	asset, err := b.store.GetAssetIncludeDeleted(assetID)
+	if err != nil {
+		return nil, err
+	}
+	if asset != nil {
+		seen := map[string]bool{"asset_id": true}
+		for _, l := range asset.Labels {
+			key, value, ok := strings.Cut(l, ":")
+			if !ok {
+				continue
+			}
+			name := labelName(key)
+			if name == "" || strings.HasPrefix(name, "__") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			labels = append(labels, label{Name: name, Value: value})
+		}
+	}
+	b.assetLabels[assetID] = labels
+	return labels, nil
+}
+
+// send posts series to the remote-write endpoint
+func (b *backfiller) send(ctx context.Context, all []*series) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.cfg.URL, bytes.NewReader(encodeWriteRequest(all)))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote write failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote write rejected: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// metricName turns a tag name into a valid metric name: characters other
+// than letters, digits, '_' and ':' become '_', and a leading digit is
+// prefixed with '_'
+func metricName(tag string) string {
+	return sanitize(tag, true)
+}
+
+// labelName is metricName without ':', which label names may not contain
+func labelName(key string) string {
+	return sanitize(key, false)
+}
+
+func sanitize(s string, allowColon bool) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', allowColon && r == ':':
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteByte('_')
+			}
+		default:
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
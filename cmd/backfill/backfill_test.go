@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// remoteWriteServer records the series of every remote-write request
+type remoteWriteServer struct {
+	mu       sync.Mutex
+	requests [][]*series
+	status   int
+}
+
+func (s *remoteWriteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+		http.Error(w, "unexpected headers", http.StatusBadRequest)
+		return
+	}
+	raw, err := snappy.Decode(nil, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	all, err := decodeWriteRequest(raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, all)
+	if s.status != 0 {
+		http.Error(w, "out of disk", s.status)
+	}
+}
+
+// decodeWriteRequest parses an uncompressed WriteRequest
+func decodeWriteRequest(b []byte) ([]*series, error) {
+	var all []*series
+	err := forEachField(b, func(num protowire.Number, v []byte, _ uint64) error {
+		if num != fieldWriteRequestTimeseries {
+			return nil
+		}
+		s := &series{}
+		all = append(all, s)
+		return forEachField(v, func(num protowire.Number, v []byte, _ uint64) error {
+			switch num {
+			case fieldTimeSeriesLabels:
+				var l label
+				err := forEachField(v, func(num protowire.Number, v []byte, _ uint64) error {
+					if num == fieldLabelName {
+						l.Name = string(v)
+					} else {
+						l.Value = string(v)
+					}
+					return nil
+				})
+				s.Labels = append(s.Labels, l)
+				return err
+			case fieldTimeSeriesSamples:
+				var smp sample
+				err := forEachField(v, func(num protowire.Number, _ []byte, n uint64) error {
+					if num == fieldSampleValue {
+						smp.Value = math.Float64frombits(n)
+					} else {
+						smp.Timestamp = int64(n)
+					}
+					return nil
+				})
+				s.Samples = append(s.Samples, smp)
+				return err
+			}
+			return nil
+		})
+	})
+	return all, err
+}
+
+// forEachField calls fn with each field of a message: the bytes of
+// length-delimited fields, the number of the others
+func forEachField(b []byte, fn func(protowire.Number, []byte, uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var bytes []byte
+		var number uint64
+		switch typ {
+		case protowire.BytesType:
+			bytes, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			number, n = protowire.ConsumeFixed64(b)
+		case protowire.VarintType:
+			number, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, bytes, number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newTestData stores readings of two assets an hour apart starting at base
+func newTestData(t *testing.T, base time.Time) *core.Store {
+	t.Helper()
+	store, err := core.NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	assets := []*core.Asset{
+		{ID: "pump", Name: "pump", Labels: []string{"site:plant-1", "critical", "asset_id:spoofed", "line-no:a"}},
+		{ID: "fan", Name: "fan"},
+	}
+	for _, asset := range assets {
+		if err := store.CreateAsset(asset); err != nil {
+			t.Fatalf("failed to create asset: %v", err)
+		}
+	}
+
+	number := func(v float64) *float64 { return &v }
+	flag := func(v bool) *bool { return &v }
+	text := "ok"
+	readings := []*core.AssetData{
+		{AssetID: "pump", Timestamp: base.UnixMilli(), Values: []core.TagValue{
+			{Name: "flow.rate", Number: number(1.5)},
+			{Name: "running", Flag: flag(true)},
+			{Name: "state", Text: &text},
+		}},
+		{AssetID: "pump", Timestamp: base.Add(time.Minute).UnixMilli(), Values: []core.TagValue{
+			{Name: "flow.rate", Number: number(2.5)},
+		}},
+		{AssetID: "fan", Timestamp: base.Add(time.Hour).UnixMilli(), Values: []core.TagValue{
+			{Name: "running", Flag: flag(false)},
+		}},
+	}
+	for _, data := range readings {
+		if err := store.InsertAssetData(data); err != nil {
+			t.Fatalf("failed to store data: %v", err)
+		}
+	}
+	return store
+}
+
+// TestBackfill_ExportsNumericTagsPerChunk tests series labels, value
+// conversion and one request per non-empty chunk
+func TestBackfill_ExportsNumericTagsPerChunk(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := newTestData(t, base)
+	rw := &remoteWriteServer{}
+	srv := httptest.NewServer(rw)
+	defer srv.Close()
+
+	b := newBackfiller(store, srv.Client(), backfillConfig{
+		URL:   srv.URL,
+		From:  base,
+		To:    base.Add(3 * time.Hour),
+		Chunk: time.Hour,
+	})
+	stats, err := b.run(context.Background())
+	if err != nil {
+		t.Fatalf("backfill failed: %v", err)
+	}
+	if stats != (backfillStats{Requests: 2, Series: 3, Samples: 4}) {
+		t.Errorf("stats = %+v", stats)
+	}
+	if len(rw.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(rw.requests))
+	}
+
+	first := rw.requests[0]
+	if len(first) != 2 {
+		t.Fatalf("first chunk has %d series, want 2", len(first))
+	}
+	wantLabels := []label{{"__name__", "flow_rate"}, {"asset_id", "pump"}, {"line_no", "a"}, {"site", "plant-1"}}
+	if !equalLabels(first[0].Labels, wantLabels) {
+		t.Errorf("labels = %v, want %v", first[0].Labels, wantLabels)
+	}
+	wantSamples := []sample{{1.5, base.UnixMilli()}, {2.5, base.Add(time.Minute).UnixMilli()}}
+	if len(first[0].Samples) != 2 || first[0].Samples[0] != wantSamples[0] || first[0].Samples[1] != wantSamples[1] {
+		t.Errorf("samples = %v, want %v", first[0].Samples, wantSamples)
+	}
+	if first[1].Labels[0] != (label{"__name__", "running"}) || first[1].Samples[0].Value != 1 {
+		t.Errorf("flag series = %+v", first[1])
+	}
+
+	second := rw.requests[1]
+	if len(second) != 1 || second[0].Samples[0].Value != 0 {
+		t.Errorf("second chunk = %+v", second)
+	}
+}
+
+// TestBackfill_AssetFilterAndRejection tests the asset filter and that a
+// rejected request stops the backfill
+func TestBackfill_AssetFilterAndRejection(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := newTestData(t, base)
+	rw := &remoteWriteServer{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(rw)
+	defer srv.Close()
+
+	b := newBackfiller(store, srv.Client(), backfillConfig{
+		URL:     srv.URL,
+		From:    base,
+		To:      base.Add(3 * time.Hour),
+		Chunk:   30 * time.Minute,
+		AssetID: "fan",
+	})
+	stats, err := b.run(context.Background())
+	if err == nil {
+		t.Fatal("expected the rejected request to fail the backfill")
+	}
+	if stats.Requests != 0 || len(rw.requests) != 1 {
+		t.Errorf("stats = %+v, requests = %d", stats, len(rw.requests))
+	}
+	if got := rw.requests[0][0].Labels; !equalLabels(got, []label{{"__name__", "running"}, {"asset_id", "fan"}}) {
+		t.Errorf("labels = %v", got)
+	}
+}
+
+// TestBackfill_SecondsTimestamps tests that readings ingested with unix
+// seconds timestamps are exported in their chunk at milliseconds
+func TestBackfill_SecondsTimestamps(t *testing.T) {
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store, err := core.NewStore(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	value := 1.5
+	tagTimestamp := base.Add(time.Second).Unix()
+	for _, data := range []*core.AssetData{
+		{AssetID: "pump", Timestamp: base.Unix(), Values: []core.TagValue{{Name: "flow", Number: &value}}},
+		{AssetID: "pump", Timestamp: base.Unix(), Values: []core.TagValue{{Name: "level", Number: &value, Timestamp: &tagTimestamp}}},
+	} {
+		if err := store.InsertAssetData(data); err != nil {
+			t.Fatalf("failed to store data: %v", err)
+		}
+	}
+
+	rw := &remoteWriteServer{}
+	srv := httptest.NewServer(rw)
+	defer srv.Close()
+
+	b := newBackfiller(store, srv.Client(), backfillConfig{URL: srv.URL, From: base, To: base.Add(time.Hour), Chunk: time.Hour})
+	stats, err := b.run(context.Background())
+	if err != nil {
+		t.Fatalf("backfill failed: %v", err)
+	}
+	if stats.Samples != 2 || len(rw.requests) != 1 {
+		t.Fatalf("stats = %+v, requests = %d", stats, len(rw.requests))
+	}
+	for i, want := range []int64{base.UnixMilli(), base.Add(time.Second).UnixMilli()} {
+		if got := rw.requests[0][i].Samples[0].Timestamp; got != want {
+			t.Errorf("series %d sample timestamp = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func equalLabels(a, b []label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+// Command backfill exports readings stored in the platform's metadata
+// database to a Prometheus remote-write endpoint, such as VictoriaMetrics,
+// so history recorded before metrics were scraped can be graphed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+func main() {
+	dbPath := flag.String("db", "./data/metadata.db", "Metadata database of EDG Core")
+	url := flag.String("url", "", "Remote-write endpoint, e.g. http://localhost:8428/api/v1/write (required)")
+	from := flag.String("from", "", "Export readings at or after this RFC 3339 time (required)")
+	to := flag.String("to", "", "Export readings before this RFC 3339 time (default: now)")
+	chunk := flag.Duration("chunk", time.Hour, "Time range read and sent per request")
+	assetID := flag.String("asset-id", "", "Only export data from this asset")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout of each remote-write request")
+	flag.Parse()
+
+	cfg := backfillConfig{URL: *url, Chunk: *chunk, AssetID: *assetID, To: time.Now()}
+	if *url == "" || *from == "" {
+		fmt.Fprintln(os.Stderr, "-url and -from are required")
+		os.Exit(2)
+	}
+	if *chunk <= 0 {
+		fmt.Fprintln(os.Stderr, "-chunk must be positive")
+		os.Exit(2)
+	}
+	var err error
+	if cfg.From, err = time.Parse(time.RFC3339, *from); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -from: %v\n", err)
+		os.Exit(2)
+	}
+	if *to != "" {
+		if cfg.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -to: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	store, err := core.NewStore(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open store: %v\n", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	b := newBackfiller(store, &http.Client{Timeout: *timeout}, cfg)
+	stats, err := b.run(ctx)
+	fmt.Fprintf(os.Stderr, "Sent %d samples of %d series in %d requests\n", stats.Samples, stats.Series, stats.Requests)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Backfill failed: %v\n", err)
+		os.Exit(1)
+	}
+}
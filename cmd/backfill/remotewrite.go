@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// label is a Prometheus series label
+type label struct {
+	Name, Value string
+}
+
+// sample is one value of a series at a time in unix milliseconds
+type sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// series is a Prometheus time series; Labels are sorted by name
+type series struct {
+	Labels  []label
+	Samples []sample
+}
+
+// Field numbers of the remote-write WriteRequest protobuf
+// (prometheus/prompb/remote.proto and types.proto)
+const (
+	fieldWriteRequestTimeseries = 1
+	fieldTimeSeriesLabels       = 1
+	fieldTimeSeriesSamples      = 2
+	fieldLabelName              = 1
+	fieldLabelValue             = 2
+	fieldSampleValue            = 1
+	fieldSampleTimestamp        = 2
+)
+
+// encodeWriteRequest encodes series as a snappy-compressed remote-write
+// WriteRequest, the body of a remote-write POST. The samples of each series
+// are sorted by time, as receivers require.
+func encodeWriteRequest(all []*series) []byte {
+	var buf []byte
+	for _, s := range all {
+		sort.SliceStable(s.Samples, func(i, j int) bool { return s.Samples[i].Timestamp < s.Samples[j].Timestamp })
+
+		var ts []byte
+		for _, l := range s.Labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, fieldLabelName, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Name)
+			lb = protowire.AppendTag(lb, fieldLabelValue, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.Value)
+			ts = protowire.AppendTag(ts, fieldTimeSeriesLabels, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+		for _, smp := range s.Samples {
+			var sb []byte
+			sb = protowire.AppendTag(sb, fieldSampleValue, protowire.Fixed64Type)
+			sb = protowire.AppendFixed64(sb, math.Float64bits(smp.Value))
+			sb = protowire.AppendTag(sb, fieldSampleTimestamp, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(smp.Timestamp))
+			ts = protowire.AppendTag(ts, fieldTimeSeriesSamples, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, sb)
+		}
+		buf = protowire.AppendTag(buf, fieldWriteRequestTimeseries, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, ts)
+	}
+	return snappy.Encode(nil, buf)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// drainPollInterval is how often drain checks whether NATS finished draining
+const drainPollInterval = 10 * time.Millisecond
+
+// drain stops NATS delivery, letting messages already received be handled,
+// and waits up to timeout for the handlers to finish so the store is not
+// closed under them. It returns the messages completed meanwhile and those
+// still in flight when it gave up.
+func drain(log *slog.Logger, nc *nats.Conn, inFlight *core.InFlight, timeout time.Duration) (completed uint64, abandoned int) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	finished := inFlight.Finished()
+	if err := nc.Drain(); err != nil {
+		log.Warn("failed to drain NATS connection", "error", err)
+	}
+
+	// Messages still queued in the client are delivered while draining
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for !nc.IsClosed() && ctx.Err() == nil {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+	}
+	abandoned = inFlight.Wait(ctx)
+
+	return inFlight.Finished() - finished, abandoned
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// connectTestServer starts a NATS server and connects to it
+func connectTestServer(t *testing.T) *nats.Conn {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{Port: -1})
+	require.NoError(t, err)
+	go ns.Start()
+	t.Cleanup(ns.Shutdown)
+	require.True(t, ns.ReadyForConnections(5*time.Second), "NATS server not ready")
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+func TestDrain_CompletesQueuedMessages(t *testing.T) {
+	nc := connectTestServer(t)
+	inFlight := core.NewInFlight()
+	var handled int
+	_, err := nc.Subscribe("test.drain", inFlight.Track(func(*nats.Msg) {
+		time.Sleep(20 * time.Millisecond)
+		handled++
+	}))
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, nc.Publish("test.drain", nil))
+	}
+	require.NoError(t, nc.Flush())
+
+	completed, abandoned := drain(slog.New(slog.NewTextHandler(io.Discard, nil)), nc, inFlight, 5*time.Second)
+	assert.Equal(t, uint64(5), completed)
+	assert.Equal(t, 0, abandoned)
+	assert.Equal(t, 5, handled)
+	assert.True(t, nc.IsClosed())
+}
+
+func TestDrain_TimesOut(t *testing.T) {
+	nc := connectTestServer(t)
+	inFlight := core.NewInFlight()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	_, err := nc.Subscribe("test.drain", inFlight.Track(func(*nats.Msg) {
+		close(started)
+		<-release
+	}))
+	require.NoError(t, err)
+	defer close(release)
+
+	require.NoError(t, nc.Publish("test.drain", nil))
+	<-started
+
+	completed, abandoned := drain(slog.New(slog.NewTextHandler(io.Discard, nil)), nc, inFlight, 50*time.Millisecond)
+	assert.Equal(t, uint64(0), completed)
+	assert.Equal(t, 1, abandoned)
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/nats-io/nats-server/v2/server"
-	"github.com/nats-io/nats.go"
 
 	"github.com/e7217/edg/internal/core"
+	"github.com/e7217/edg/templates"
 )
 
 var (
@@ -25,6 +32,62 @@ var (
 func main() {
 	// Parse command-line flags
 	versionFlag := flag.Bool("version", false, "Print version information and exit")
+	watchTemplates := flag.Bool("watch-templates", false, "Reload templates from disk when files change")
+	strictTemplates := flag.Bool("strict-templates", false, "Refuse to start when a template fails to load or has invalid resources")
+	metricsPort := flag.Int("metrics-port", 9090, "HTTP port for /metrics, /healthz and /readyz")
+	jsRetention := flag.Duration("js-retention", 7*24*time.Hour, "Maximum age of messages in the JetStream stream")
+	jsMaxBytes := flag.Int64("js-max-bytes", -1, "Maximum size of the JetStream stream in bytes (-1 for unlimited); applies to each stream")
+	jsStreams := flag.String("js-streams", streamModeSingle, "JetStream stream layout: one stream for all data, or separate validated, rejected and events streams (single|split)")
+	var classRetention streamRetention
+	flag.DurationVar(&classRetention.Validated, "js-validated-retention", 0, "Maximum age of validated data with -js-streams split (0 uses -js-retention)")
+	flag.DurationVar(&classRetention.Rejected, "js-rejected-retention", 0, "Maximum age of rejected, unregistered and dead-lettered data with -js-streams split (0 uses -js-retention)")
+	flag.DurationVar(&classRetention.Events, "js-events-retention", 0, "Maximum age of edge events with -js-streams split (0 uses -js-retention)")
+	jsStorage := flag.String("js-storage", "file", "JetStream storage backend (file|memory)")
+	jsStoreDir := flag.String("js-store-dir", "./data/jetstream", "Directory for JetStream file storage")
+	jsStartupTimeout := flag.Duration("js-startup-timeout", 30*time.Second, "How long to retry JetStream setup at startup while JetStream is unavailable")
+	publishAttempts := flag.Int("publish-attempts", 3, "JetStream publish attempts before a message is dead-lettered")
+	dedupWindow := flag.Duration("dedup-window", 0, "Drop unchanged tag values repeated within this window (0 disables)")
+	autoRegister := flag.Bool("auto-register", true, "Create unknown assets from incoming data instead of publishing it to "+core.SubjectDataUnregistered)
+	timestampWindow := flag.Duration("timestamp-window", core.DefaultTimestampWindow, "Reject data whose timestamp differs from server time by more than this (0 disables)")
+	fillTimestamp := flag.Bool("fill-missing-timestamp", false, "Use server time for data with a zero timestamp")
+	coerceValues := flag.Bool("coerce-values", false, "Convert TEXT values of NUMBER and FLAG template tags, e.g. \"25.5\", before validation")
+	idempotent := flag.Bool("idempotent", false, "Drop redelivered data whose content hash is already stored")
+	minQuality := flag.String("min-quality", "", "Reject tag values below this quality to "+core.SubjectDataRejected+" (good|uncertain|bad; empty disables)")
+	assetCacheTTL := flag.Duration("asset-cache-ttl", core.DefaultAssetCacheTTL, "How long asset lookups for incoming data are cached (0 disables the cache)")
+	edgeTags := flag.String("edge-tags", "", "Comma-separated FLAG tag names whose rising edges are published to "+core.SubjectDataEvents)
+	rateLimit := flag.Float64("rate-limit", 0, "Maximum data messages per second per asset (0 for unlimited)")
+	logFormat := flag.String("log-format", core.LogFormatText, "Log output format (text|json)")
+	logLevel := flag.String("log-level", "info", "Minimum log level (debug|info|warn|error)")
+	dataRetention := flag.Duration("data-retention", 0, "Delete persisted data older than this, checked every hour (0 keeps data forever)")
+	lastSeenInterval := flag.Duration("last-seen-interval", core.DefaultLastSeenInterval, "How often assets' last_seen times are written (0 disables tracking)")
+	schemaPolicy := flag.String("unknown-schema", string(core.SchemaPolicyReject), "Handling of data in an unknown schema_version (reject|accept)")
+	maxPayload := flag.Int("max-payload", core.DefaultMaxPayload, "Largest data message or metadata request in bytes; larger ones are refused (0 for unlimited)")
+	drainTimeout := flag.Duration("drain-timeout", core.DefaultDrainTimeout, "How long shutdown waits for in-flight messages before closing the store")
+	defaultLimits := core.DefaultStoreOptions()
+	maxRelationMetadata := flag.Int("max-relation-metadata", defaultLimits.MaxRelationMetadataBytes, "Maximum serialized size of a relation's metadata in bytes (0 for unlimited)")
+	maxAssetLabels := flag.Int("max-asset-labels", defaultLimits.MaxAssetLabels, "Maximum labels per asset (0 for unlimited)")
+	maxLabelLength := flag.Int("max-label-length", defaultLimits.MaxLabelLength, "Maximum length of an asset label in bytes (0 for unlimited)")
+	defaultNames := core.DefaultNamePolicy()
+	maxNameLength := flag.Int("max-name-length", defaultNames.MaxLength, "Maximum length of asset and template names in bytes (0 for unlimited)")
+	relationTypesPath := flag.String("relation-types", "", "YAML file of relation types added to the built-in ones")
+	assetIDNamespace := flag.String("asset-id-namespace", core.DefaultAssetIDNamespace.String(), "UUID namespace asset IDs are derived in from the external_key of create requests")
+	namePattern := flag.String("name-pattern", core.DefaultNamePattern, "Regular expression asset and template names must match (empty allows any)")
+	subjectPrefix := flag.String("subject-prefix", core.DefaultSubjectPrefix, "First token(s) of every NATS subject, to run several instances on one cluster")
+	otelEndpoint := flag.String("otel-endpoint", "", "OTLP/HTTP collector URL for traces, e.g. http://localhost:4318 (empty disables tracing)")
+	var natsCfg natsConfig
+	flag.StringVar(&natsCfg.URL, "nats-url", "", "Connect to this external NATS server instead of starting the embedded one")
+	flag.IntVar(&natsCfg.Port, "nats-port", defaultNATSPort, "Client port of the embedded NATS server")
+	flag.IntVar(&natsCfg.MonitorPort, "nats-monitor-port", defaultMonitorPort, "HTTP monitoring port of the embedded NATS server (0 disables)")
+	flag.StringVar(&natsCfg.ConfigFile, "nats-config", "", "nats-server config file, e.g. for operator/JWT auth (replaces -nats-port, auth and TLS certificate flags)")
+	flag.StringVar(&natsCfg.User, "nats-user", "", "Require this NATS username (with -nats-password)")
+	flag.StringVar(&natsCfg.Password, "nats-password", "", "NATS password for -nats-user")
+	flag.StringVar(&natsCfg.Token, "nats-token", "", "Require this NATS auth token")
+	flag.StringVar(&natsCfg.CredsFile, "nats-creds", "", "NATS credentials file the internal client authenticates with")
+	flag.StringVar(&natsCfg.TLSCert, "nats-tls-cert", "", "TLS certificate for the NATS server (with -nats-tls-key)")
+	flag.StringVar(&natsCfg.TLSKey, "nats-tls-key", "", "TLS private key for the NATS server")
+	flag.StringVar(&natsCfg.TLSCA, "nats-tls-ca", "", "CA the internal client verifies the NATS server certificate with (default system roots)")
+	compressAbove := flag.Int("compress-above", 0, "Gzip validated data payloads larger than this many bytes in JetStream (0 disables; consumers must decode them)")
+	deadLetterFile := flag.String("deadletter-file", "", "Append undeliverable messages to this file instead of "+core.SubjectDataDeadLetter)
 	flag.Parse()
 
 	// Handle version flag
@@ -35,100 +98,292 @@ func main() {
 		fmt.Printf("Git Commit: %s\n", GitCommit)
 		os.Exit(0)
 	}
-	// 1. Embedded NATS Server configuration
-	opts := &server.Options{
-		Port:      4222,
-		HTTPPort:  8222, // for monitoring
-		JetStream: true, // Enable JetStream for message persistence
-		StoreDir:  "./data/jetstream",
-	}
 
-	ns, err := server.NewServer(opts)
+	logger, err := core.NewLogger(os.Stderr, *logFormat, *logLevel)
 	if err != nil {
-		log.Fatalf("Failed to create NATS server: %v", err)
+		fmt.Fprintf(os.Stderr, "Invalid logging flags: %v\n", err)
+		os.Exit(2)
 	}
+	core.SetLogger(logger)
+	log := logger.With("component", "core")
 
-	// 2. Start NATS Server (async)
-	go ns.Start()
-
-	// Wait for server ready
-	if !ns.ReadyForConnections(5 * time.Second) {
-		log.Fatal("NATS server not ready")
+	if err := core.ValidateSubjectPrefix(*subjectPrefix); err != nil {
+		fatal(log, "invalid -subject-prefix", err)
 	}
 
-	log.Println("=================================")
-	log.Println("  EDG Platform Core Started")
-	log.Println("  NATS: nats://localhost:4222")
-	log.Println("  Monitor: http://localhost:8222")
-	log.Println("=================================")
+	storageType, err := parseStorageType(*jsStorage)
+	if err != nil {
+		fatal(log, "invalid -js-storage", err)
+	}
+	classRetention.Data = *jsRetention
+	streamCfgs, err := newStreamConfigs(*subjectPrefix, *jsStreams, storageType, classRetention, *jsMaxBytes)
+	if err != nil {
+		fatal(log, "invalid -js-streams", err)
+	}
 
-	// 3. Connect as internal client
-	nc, err := nats.Connect(nats.DefaultURL)
+	policy, err := core.ParseSchemaPolicy(*schemaPolicy)
 	if err != nil {
-		log.Fatalf("Failed to connect to NATS: %v", err)
+		fatal(log, "invalid -unknown-schema", err)
 	}
-	defer nc.Close()
 
-	// 3.1. Initialize JetStream context
-	js, err := nc.JetStream()
+	names := core.NamePolicy{MaxLength: *maxNameLength}
+	if *namePattern != "" {
+		if names.Pattern, err = regexp.Compile(*namePattern); err != nil {
+			fatal(log, "invalid -name-pattern", err)
+		}
+	}
+
+	idNamespace, err := uuid.Parse(*assetIDNamespace)
 	if err != nil {
-		log.Fatalf("Failed to create JetStream context: %v", err)
+		fatal(log, "invalid -asset-id-namespace", err)
+	}
+
+	if *relationTypesPath != "" {
+		if err := core.LoadRelationTypes(*relationTypesPath); err != nil {
+			fatal(log, "invalid -relation-types", err)
+		}
+	}
+
+	var qualityFloor core.Quality
+	if *minQuality != "" {
+		q, known := core.NormalizeQuality(*minQuality)
+		if !known {
+			fatal(log, "invalid -min-quality", fmt.Errorf("unknown quality %q (expected good, uncertain or bad)", *minQuality))
+		}
+		qualityFloor = q
 	}
 
-	// 3.2. Create JetStream stream for platform data
-	streamName := "PLATFORM_DATA"
-	_, err = js.StreamInfo(streamName)
+	shutdownTracing, err := setupTracing(context.Background(), *otelEndpoint)
 	if err != nil {
-		// Stream doesn't exist, create it
-		_, err = js.AddStream(&nats.StreamConfig{
-			Name:     streamName,
-			Subjects: []string{"platform.data.>"},
-			Storage:  nats.FileStorage,
-			MaxAge:   7 * 24 * time.Hour, // 7 days retention
-		})
+		fatal(log, "invalid -otel-endpoint", err)
+	}
+
+	// 1-2. NATS: start the embedded server (no auth and plaintext unless
+	// configured) or use an external one
+	if err := natsCfg.validate(); err != nil {
+		fatal(log, "invalid NATS configuration", err)
+	}
+
+	var ns *server.Server
+	var endpoint natsEndpoint
+	if natsCfg.URL == "" {
+		ns, endpoint, err = startEmbeddedServer(natsCfg, *jsStoreDir)
 		if err != nil {
-			log.Fatalf("Failed to create JetStream stream: %v", err)
+			fatal(log, "failed to start NATS server", err)
 		}
-		log.Printf("[Core] Created JetStream stream: %s", streamName)
+		log.Info("EDG Platform Core started",
+			"version", Version,
+			"nats_url", endpoint.URL,
+			"nats_tls", endpoint.TLS,
+			"monitor_url", fmt.Sprintf("http://localhost:%d", natsCfg.MonitorPort),
+		)
 	} else {
-		log.Printf("[Core] JetStream stream already exists: %s", streamName)
+		endpoint = natsCfg.externalEndpoint()
+		log.Info("EDG Platform Core started", "version", Version, "nats_url", endpoint.URL, "embedded", false)
+	}
+
+	// 3. Connect as client
+	nc, err := connectNATS(natsCfg, endpoint)
+	if err != nil {
+		fatal(log, "failed to connect to NATS", err)
+	}
+	defer nc.Close()
+
+	// 3.1. Initialize JetStream and create or update the streams for
+	// platform data, waiting up to -js-startup-timeout for JetStream to
+	// become available. Data handlers publish by subject, so JetStream
+	// routes each class to its stream.
+	js, err := setupJetStream(nc, *subjectPrefix, *jsStreams, streamCfgs, *jsStartupTimeout)
+	if err != nil {
+		fatal(log, "failed to set up JetStream stream", err)
 	}
 
 	// 4. Initialize metadata store
-	store, err := core.NewStore("./data/metadata.db")
+	storeOpts := core.DefaultStoreOptions()
+	storeOpts.MaxRelationMetadataBytes = *maxRelationMetadata
+	storeOpts.MaxAssetLabels = *maxAssetLabels
+	storeOpts.MaxLabelLength = *maxLabelLength
+	store, err := core.NewStoreWithOptions("./data/metadata.db", storeOpts)
 	if err != nil {
-		log.Fatalf("Failed to create store: %v", err)
+		fatal(log, "failed to create store", err)
 	}
 	defer store.Close()
+	if *relationTypesPath != "" {
+		// A type may have become symmetric since its relations were stored
+		if n, err := store.CanonicalizeRelations(); err != nil {
+			fatal(log, "failed to canonicalize relations", err)
+		} else if n > 0 {
+			log.Info("canonicalized symmetric relations", "count", n)
+		}
+		log.Info("loaded relation types", "types", core.ValidRelationTypes())
+	}
 
-	// 5. Initialize template loader
+	// 5. Initialize template loader and self-check the templates. Without a
+	// template directory the templates built into the binary are used.
 	loader := core.NewTemplateLoader()
-	if err := loader.LoadFromDir("./templates"); err != nil {
-		log.Printf("[Core] Warning: Failed to load templates: %v", err)
+	loadErr := loader.LoadFromDir("./templates")
+	if errors.Is(loadErr, fs.ErrNotExist) {
+		log.Info("template directory not found, using built-in templates", "dir", "./templates")
+		loadErr = loader.LoadFromFS(templates.FS, ".")
+	}
+	if loadErr != nil {
+		log.Warn("failed to load templates", "error", loadErr)
+	}
+	problems := loader.Validate()
+	for _, err := range problems {
+		log.Warn("invalid template", "error", err)
+	}
+	if *strictTemplates && (loadErr != nil || len(problems) > 0) {
+		fatal(log, "template self-check failed", errors.Join(append([]error{loadErr}, problems...)...))
+	}
+	log.Info("loaded templates", "count", loader.Count())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if *watchTemplates {
+		if err := loader.Watch(ctx); err != nil {
+			log.Warn("failed to watch templates", "error", err)
+		} else {
+			log.Info("watching templates for changes")
+		}
 	}
-	log.Printf("[Core] Loaded %d templates", loader.Count())
 
 	// 6. Create handlers and subscribe
-	dataHandler := core.NewDataHandler(js, store)
+	metrics := core.NewMetrics()
+	inFlight := core.NewInFlight()
+	metrics.RegisterGauge("edg_messages_in_flight", "Data and metadata messages being handled.", func() float64 {
+		return float64(inFlight.Active())
+	})
+
+	var assetStore core.AssetStore = store
+	if *assetCacheTTL > 0 {
+		assetStore = core.NewCachedStore(store, *assetCacheTTL)
+	}
+	dataHandler := core.NewDataHandler(js, assetStore)
+	dataHandler.SetTemplateLoader(loader)
+	dataHandler.SetMetrics(metrics)
+	publishCfg := core.DefaultPublishConfig()
+	publishCfg.Attempts = *publishAttempts
+	publishCfg.DeadLetterFile = *deadLetterFile
+	publishCfg.CompressAbove = *compressAbove
+	dataHandler.SetPublishConfig(publishCfg)
+	dataHandler.SetDedupWindow(*dedupWindow)
+	dataHandler.SetRateLimit(*rateLimit)
+	dataHandler.SetEdgeTags(splitList(*edgeTags))
+	dataHandler.SetAutoRegister(*autoRegister)
+	dataHandler.SetTimestampWindow(*timestampWindow)
+	dataHandler.SetFillMissingTimestamp(*fillTimestamp)
+	dataHandler.SetCoerceValues(*coerceValues)
+	dataHandler.SetIdempotent(*idempotent)
+	dataHandler.SetMinQuality(qualityFloor)
+	dataHandler.SetSubjectPrefix(*subjectPrefix)
+	dataHandler.SetSchemaPolicy(policy)
+	dataHandler.SetInFlight(inFlight)
+	dataHandler.SetMaxPayload(*maxPayload)
+	var lastSeen *core.LastSeenTracker
+	if *lastSeenInterval > 0 {
+		lastSeen = core.NewLastSeenTracker(store, *lastSeenInterval)
+		dataHandler.SetLastSeenTracker(lastSeen)
+		go lastSeen.Run(ctx)
+	}
+	if *dataRetention > 0 {
+		go core.NewDataPruner(store, *dataRetention, core.DefaultPruneInterval).Run(ctx)
+	}
 	metaHandler := core.NewMetaHandler(store, loader)
+	metaHandler.SetMetrics(metrics)
+	metaHandler.SetSubjectPrefix(*subjectPrefix)
+	metaHandler.SetInFlight(inFlight)
+	metaHandler.SetMaxPayload(*maxPayload)
+	metaHandler.SetNamePolicy(names)
+	metaHandler.SetAssetIDNamespace(idNamespace)
+	metaHandler.SetCoerceValues(*coerceValues)
 
-	_, err = nc.Subscribe("platform.data.asset", dataHandler.HandleAssetData)
+	dataSubject := core.PrefixSubject(*subjectPrefix, core.SubjectDataAsset)
+	_, err = nc.Subscribe(dataSubject, dataHandler.HandleAssetData)
+	if err != nil {
+		fatal(log, "failed to subscribe", err)
+	}
+	batchSubject := core.PrefixSubject(*subjectPrefix, core.SubjectDataBatch)
+	_, err = nc.Subscribe(batchSubject, dataHandler.HandleAssetDataBatch)
 	if err != nil {
-		log.Fatalf("Failed to subscribe: %v", err)
+		fatal(log, "failed to subscribe", err)
 	}
 
 	if err := metaHandler.RegisterHandlers(nc); err != nil {
-		log.Fatalf("Failed to register meta handlers: %v", err)
+		fatal(log, "failed to register meta handlers", err)
 	}
 
-	log.Println("[Core] Subscribed to: platform.data.asset")
+	log.Info("subscribed", "subject", dataSubject, "batch_subject", batchSubject)
+
+	// 7. Start HTTP server for application metrics and health probes
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", core.HealthzHandler())
+	mux.Handle("/query", core.QueryHandler(store))
+	mux.Handle("/stream", core.StreamHandler(nc, core.PrefixSubject(*subjectPrefix, core.SubjectDataValidated), metrics))
+	mux.Handle("/readyz", core.ReadyzHandler(
+		core.NATSCheck(nc),
+		core.JetStreamCheck(js),
+		core.StoreCheck(store),
+	))
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", *metricsPort),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error("HTTP server error", "error", err)
+		}
+	}()
+	log.Info("HTTP endpoints", "url", fmt.Sprintf("http://localhost:%d", *metricsPort), "paths", "/metrics /healthz /readyz /stream /query")
 
-	// 7. Graceful shutdown
+	// 8. Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("[Core] Shutting down...")
-	nc.Drain()
-	ns.Shutdown()
+	log.Info("shutting down")
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	httpServer.Shutdown(shutdownCtx)
+	completed, abandoned := drain(log, nc, inFlight, *drainTimeout)
+	if abandoned > 0 {
+		log.Warn("drain timed out", "completed", completed, "abandoned", abandoned, "timeout", *drainTimeout)
+	} else {
+		log.Info("drained", "completed", completed)
+	}
+	if lastSeen != nil {
+		if err := lastSeen.Flush(); err != nil {
+			log.Warn("failed to update last seen", "error", err)
+		}
+	}
+	if ns != nil {
+		ns.Shutdown()
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Warn("failed to flush traces", "error", err)
+	}
+}
+
+// fatal logs msg with err at error level and exits
+func fatal(log *slog.Logger, msg string, err error) {
+	if err != nil {
+		log.Error(msg, "error", err)
+	} else {
+		log.Error(msg)
+	}
+	os.Exit(1)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// defaultNATSPort is the client port of the embedded NATS server
+const defaultNATSPort = 4222
+
+// defaultMonitorPort is the HTTP monitoring port of the embedded NATS server
+const defaultMonitorPort = 8222
+
+// serverReadyTimeout bounds how long the embedded server may take to start
+const serverReadyTimeout = 5 * time.Second
+
+// internalTLSServerName is the name the internal client verifies the server
+// certificate against; it connects over loopback
+const internalTLSServerName = "localhost"
+
+// natsConfig is the listen, auth and TLS configuration of the embedded NATS
+// server, or the address of an external one. The zero value (apart from
+// Port) is the no-auth plaintext embedded default.
+type natsConfig struct {
+	// URL connects to an existing NATS server or cluster instead of starting
+	// the embedded one. The auth flags, CredsFile and TLSCA then configure
+	// the client connection.
+	URL string
+
+	Port        int
+	MonitorPort int // HTTP monitoring port; 0 disables monitoring
+
+	// ConfigFile is a nats-server configuration file, for setups the flags
+	// do not cover such as operator/JWT auth. It replaces the port, auth and
+	// TLS server flags; CredsFile and TLSCA still configure the internal
+	// client.
+	ConfigFile string
+
+	User     string
+	Password string
+	Token    string
+
+	CredsFile string // credentials (JWT and seed) used by the internal client
+
+	TLSCert string
+	TLSKey  string
+	TLSCA   string // CA the internal client trusts for the server certificate
+}
+
+// validate rejects contradictory settings and missing or unreadable files
+func (c natsConfig) validate() error {
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid NATS URL %q (expected e.g. nats://nats.example:4222)", c.URL)
+		}
+		if c.ConfigFile != "" || c.TLSCert != "" || c.TLSKey != "" {
+			return errors.New("-nats-url uses an external server; -nats-config and the TLS certificate flags only apply to the embedded one")
+		}
+	}
+
+	if c.ConfigFile != "" {
+		if c.User != "" || c.Password != "" || c.Token != "" || c.TLSCert != "" || c.TLSKey != "" {
+			return errors.New("-nats-config cannot be combined with the auth and TLS certificate flags")
+		}
+	} else if err := c.validateFlags(); err != nil {
+		return err
+	}
+
+	for _, f := range []struct{ what, path string }{
+		{"NATS config", c.ConfigFile},
+		{"TLS certificate", c.TLSCert},
+		{"TLS key", c.TLSKey},
+		{"TLS CA", c.TLSCA},
+		{"NATS credentials", c.CredsFile},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if err := checkReadable(f.what, f.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateFlags checks the port, auth and TLS flag combinations
+func (c natsConfig) validateFlags() error {
+	if c.URL == "" && (c.Port < 1 || c.Port > 65535) {
+		return fmt.Errorf("invalid NATS port %d", c.Port)
+	}
+	if c.Token != "" && (c.User != "" || c.Password != "") {
+		return errors.New("use either a token or a username and password, not both")
+	}
+	if c.Password != "" && c.User == "" {
+		return errors.New("a NATS password requires a username")
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return errors.New("TLS needs both a certificate and a key")
+	}
+	if c.URL == "" && c.TLSCA != "" && c.TLSCert == "" {
+		return errors.New("a TLS CA requires a certificate and key")
+	}
+	return nil
+}
+
+// checkReadable reports a missing or unreadable file with what it is for
+func checkReadable(what, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s file: %w", what, err)
+	}
+	return f.Close()
+}
+
+// serverOptions builds the embedded server options, without JetStream or
+// monitoring settings
+func (c natsConfig) serverOptions() (*server.Options, error) {
+	if c.ConfigFile != "" {
+		opts, err := server.ProcessConfigFile(c.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load NATS config: %w", err)
+		}
+		if opts.Port == 0 {
+			opts.Port = defaultNATSPort
+		}
+		return opts, nil
+	}
+
+	opts := &server.Options{
+		Port:          c.Port,
+		Username:      c.User,
+		Password:      c.Password,
+		Authorization: c.Token,
+	}
+	if c.TLSCert != "" {
+		tlsConfig, err := server.GenTLSConfig(&server.TLSConfigOpts{
+			CertFile: c.TLSCert,
+			KeyFile:  c.TLSKey,
+			CaFile:   c.TLSCA,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS files: %w", err)
+		}
+		opts.TLSConfig = tlsConfig
+		opts.TLS = true
+	}
+	return opts, nil
+}
+
+// natsEndpoint is the server the core's own client connects to
+type natsEndpoint struct {
+	URL        string
+	TLS        bool
+	ServerName string // name to verify the server certificate against; empty for the URL host
+}
+
+// startEmbeddedServer starts the embedded NATS server with JetStream storing
+// under storeDir and waits until it accepts connections
+func startEmbeddedServer(cfg natsConfig, storeDir string) (*server.Server, natsEndpoint, error) {
+	opts, err := cfg.serverOptions()
+	if err != nil {
+		return nil, natsEndpoint{}, err
+	}
+	opts.HTTPPort = cfg.MonitorPort
+	opts.JetStream = true // Enable JetStream for message persistence
+	opts.StoreDir = storeDir
+
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		return nil, natsEndpoint{}, fmt.Errorf("failed to create NATS server: %w", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(serverReadyTimeout) {
+		ns.Shutdown()
+		return nil, natsEndpoint{}, errors.New("NATS server not ready")
+	}
+
+	return ns, natsEndpoint{
+		URL:        ns.ClientURL(),
+		TLS:        opts.TLSConfig != nil,
+		ServerName: internalTLSServerName,
+	}, nil
+}
+
+// externalEndpoint is the external server named by URL. TLS is used for a
+// tls:// URL or when a CA is given.
+func (c natsConfig) externalEndpoint() natsEndpoint {
+	u, _ := url.Parse(c.URL)
+	return natsEndpoint{URL: c.URL, TLS: c.TLSCA != "" || (u != nil && u.Scheme == "tls")}
+}
+
+// connectNATS connects the core's own client to ep with the configured
+// credentials
+func connectNATS(cfg natsConfig, ep natsEndpoint) (*nats.Conn, error) {
+	opts, err := cfg.clientOptions(ep)
+	if err != nil {
+		return nil, err
+	}
+	nc, err := nats.Connect(ep.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", ep.URL, err)
+	}
+	return nc, nil
+}
+
+// clientOptions authenticates the core's client the same way other clients
+// must, using TLS when ep requires it
+func (c natsConfig) clientOptions(ep natsEndpoint) ([]nats.Option, error) {
+	var opts []nats.Option
+	switch {
+	case c.User != "":
+		opts = append(opts, nats.UserInfo(c.User, c.Password))
+	case c.Token != "":
+		opts = append(opts, nats.Token(c.Token))
+	}
+	if c.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(c.CredsFile))
+	}
+
+	if ep.TLS {
+		tlsConfig := &tls.Config{ServerName: ep.ServerName, MinVersion: tls.VersionTLS12}
+		if c.TLSCA != "" {
+			pem, err := os.ReadFile(c.TLSCA)
+			if err != nil {
+				return nil, fmt.Errorf("TLS CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("TLS CA file %s: no certificates found", c.TLSCA)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		opts = append(opts, nats.Secure(tlsConfig))
+	}
+	return opts, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/e7217/edg/internal/core"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a self-signed certificate for localhost and its key
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+// startConfiguredServer starts the embedded server from cfg on a random
+// port without monitoring
+func startConfiguredServer(t *testing.T, cfg natsConfig) natsEndpoint {
+	t.Helper()
+	require.NoError(t, cfg.validate())
+	cfg.Port, cfg.MonitorPort = server.RANDOM_PORT, 0
+
+	ns, endpoint, err := startEmbeddedServer(cfg, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(ns.Shutdown)
+	return endpoint
+}
+
+// TestNATSConfig_Validate tests rejected flag combinations and missing files
+func TestNATSConfig_Validate(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	assert.NoError(t, natsConfig{Port: defaultNATSPort}.validate(), "no-auth default")
+	assert.NoError(t, natsConfig{Port: defaultNATSPort, User: "edg", Password: "secret", TLSCert: certFile, TLSKey: keyFile, TLSCA: certFile}.validate())
+
+	for name, cfg := range map[string]natsConfig{
+		"bad port":           {Port: 0},
+		"token and user":     {Port: defaultNATSPort, User: "edg", Token: "t"},
+		"password only":      {Port: defaultNATSPort, Password: "secret"},
+		"cert without key":   {Port: defaultNATSPort, TLSCert: certFile},
+		"ca without cert":    {Port: defaultNATSPort, TLSCA: certFile},
+		"missing cert":       {Port: defaultNATSPort, TLSCert: missing, TLSKey: keyFile},
+		"missing creds":      {Port: defaultNATSPort, CredsFile: missing},
+		"config and flags":   {ConfigFile: certFile, Token: "t"},
+		"missing config":     {ConfigFile: missing},
+		"missing ca":         {Port: defaultNATSPort, TLSCert: certFile, TLSKey: keyFile, TLSCA: missing},
+		"config and tls key": {ConfigFile: certFile, TLSKey: keyFile},
+	} {
+		assert.Error(t, cfg.validate(), name)
+	}
+
+	err := natsConfig{Port: defaultNATSPort, TLSCert: missing, TLSKey: keyFile}.validate()
+	assert.ErrorContains(t, err, "TLS certificate file")
+}
+
+// TestNATSConfig_UserPassword tests that the internal client uses the
+// configured credentials and others are refused
+func TestNATSConfig_UserPassword(t *testing.T) {
+	cfg := natsConfig{Port: defaultNATSPort, User: "edg", Password: "secret"}
+	endpoint := startConfiguredServer(t, cfg)
+
+	_, err := nats.Connect(endpoint.URL)
+	assert.Error(t, err, "anonymous clients are refused")
+
+	nc, err := connectNATS(cfg, endpoint)
+	require.NoError(t, err)
+	nc.Close()
+}
+
+// TestNATSConfig_TokenTLS tests a token-protected TLS server
+func TestNATSConfig_TokenTLS(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	cfg := natsConfig{Port: defaultNATSPort, Token: "s3cret", TLSCert: certFile, TLSKey: keyFile, TLSCA: certFile}
+	endpoint := startConfiguredServer(t, cfg)
+	assert.True(t, endpoint.TLS)
+
+	nc, err := connectNATS(cfg, endpoint)
+	require.NoError(t, err)
+	defer nc.Close()
+	assert.True(t, nc.TLSRequired())
+
+	// Without the CA the self-signed certificate is not trusted
+	untrusted := cfg
+	untrusted.TLSCA = ""
+	_, err = connectNATS(untrusted, endpoint)
+	assert.Error(t, err)
+}
+
+// TestNATSConfig_ExternalServer tests connecting to a server the core did
+// not start and setting up the data stream there
+func TestNATSConfig_ExternalServer(t *testing.T) {
+	external := startConfiguredServer(t, natsConfig{Port: defaultNATSPort, Token: "cluster"})
+
+	cfg := natsConfig{URL: external.URL, Token: "cluster"}
+	require.NoError(t, cfg.validate())
+	endpoint := cfg.externalEndpoint()
+	assert.False(t, endpoint.TLS)
+
+	nc, err := connectNATS(cfg, endpoint)
+	require.NoError(t, err)
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+	require.NoError(t, ensureStream(js, newStreamConfig(core.DefaultSubjectPrefix, nats.MemoryStorage, time.Hour, -1)))
+
+	assert.True(t, natsConfig{URL: "tls://nats.example:4222"}.externalEndpoint().TLS)
+	certFile, _ := writeTestCert(t)
+	withCA := natsConfig{URL: "nats://nats.example:4222", TLSCA: certFile}
+	assert.NoError(t, withCA.validate(), "a CA alone configures the client")
+	assert.True(t, withCA.externalEndpoint().TLS)
+	assert.Error(t, natsConfig{URL: external.URL, TLSCert: "cert.pem", TLSKey: "key.pem"}.validate())
+	assert.Error(t, natsConfig{URL: "nats-host"}.validate())
+}
+
+// TestNATSConfig_ConfigFile tests loading server options from a config file
+func TestNATSConfig_ConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nats.conf")
+	require.NoError(t, os.WriteFile(path, []byte("authorization { token: \"from-file\" }\n"), 0o600))
+
+	opts, err := natsConfig{ConfigFile: path}.serverOptions()
+	require.NoError(t, err)
+	assert.Equal(t, defaultNATSPort, opts.Port)
+	assert.Equal(t, "from-file", opts.Authorization)
+}
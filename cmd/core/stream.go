@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// parseStorageType converts the -js-storage flag into a JetStream storage type
+func parseStorageType(s string) (nats.StorageType, error) {
+	switch strings.ToLower(s) {
+	case "file":
+		return nats.FileStorage, nil
+	case "memory":
+		return nats.MemoryStorage, nil
+	default:
+		return 0, fmt.Errorf("unknown JetStream storage %q (expected file or memory)", s)
+	}
+}
+
+// Stream layouts selected with -js-streams
+const (
+	streamModeSingle = "single" // one stream captures every data subject
+	streamModeSplit  = "split"  // validated, rejected and events get their own streams
+)
+
+// streamRetention holds the maximum message age of each stream in split
+// mode; zero durations fall back to Data
+type streamRetention struct {
+	Data      time.Duration // raw ingest in the main stream
+	Validated time.Duration
+	Rejected  time.Duration
+	Events    time.Duration
+}
+
+// dataClass is a stream of split mode: a name suffix and the data subjects
+// it captures
+type dataClass struct {
+	suffix   string
+	subjects []string
+}
+
+// splitClasses lists the data class streams of split mode. Rejected,
+// unregistered and dead-lettered data are all data that was not accepted.
+var splitClasses = []dataClass{
+	{"_VALIDATED", []string{core.SubjectDataValidated}},
+	{"_REJECTED", []string{core.SubjectDataRejected, core.SubjectDataUnregistered, core.SubjectDataDeadLetter}},
+	{"_EVENTS", []string{core.SubjectDataEvents}},
+}
+
+// newStreamConfigs builds the stream configurations of mode. The main
+// stream comes first: switching to split mode must shrink its subjects
+// before the class streams can capture them.
+func newStreamConfigs(prefix, mode string, storage nats.StorageType, retention streamRetention, maxBytes int64) ([]*nats.StreamConfig, error) {
+	switch mode {
+	case streamModeSingle:
+		return []*nats.StreamConfig{newStreamConfig(prefix, storage, retention.Data, maxBytes)}, nil
+	case streamModeSplit:
+	default:
+		return nil, fmt.Errorf("unknown stream mode %q (expected single or split)", mode)
+	}
+
+	main := newStreamConfig(prefix, storage, retention.Data, maxBytes)
+	main.Subjects = []string{
+		core.PrefixSubject(prefix, core.SubjectDataAsset),
+		core.PrefixSubject(prefix, core.SubjectDataBatch),
+	}
+	configs := []*nats.StreamConfig{main}
+	for i, maxAge := range []time.Duration{retention.Validated, retention.Rejected, retention.Events} {
+		class := splitClasses[i]
+		if maxAge == 0 {
+			maxAge = retention.Data
+		}
+		cfg := &nats.StreamConfig{
+			Name:     core.DataStreamNameFor(prefix) + class.suffix,
+			Storage:  storage,
+			MaxAge:   maxAge,
+			MaxBytes: maxBytes,
+		}
+		for _, subject := range class.subjects {
+			cfg.Subjects = append(cfg.Subjects, core.PrefixSubject(prefix, subject))
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// checkNoSplitStreams fails when split mode streams remain in single mode;
+// the main stream cannot capture their subjects while they exist. They are
+// left for the operator to delete, as they hold data.
+func checkNoSplitStreams(js nats.JetStreamContext, prefix string) error {
+	for _, class := range splitClasses {
+		name := core.DataStreamNameFor(prefix) + class.suffix
+		_, err := js.StreamInfo(name)
+		if err == nil {
+			return fmt.Errorf("stream %s from -js-streams split still exists; delete it or keep split mode", name)
+		}
+		if !errors.Is(err, nats.ErrStreamNotFound) {
+			return fmt.Errorf("failed to look up stream %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// newStreamConfig builds the configuration of the stream holding the data
+// subjects under prefix
+func newStreamConfig(prefix string, storage nats.StorageType, retention time.Duration, maxBytes int64) *nats.StreamConfig {
+	return &nats.StreamConfig{
+		Name:     core.DataStreamNameFor(prefix),
+		Subjects: []string{prefix + ".data.>"},
+		Storage:  storage,
+		MaxAge:   retention,
+		MaxBytes: maxBytes,
+	}
+}
+
+// streamConfigChanges lists the configurable settings that differ between the
+// existing stream and the desired configuration
+func streamConfigChanges(current, desired *nats.StreamConfig) []string {
+	var changes []string
+	if !slices.Equal(current.Subjects, desired.Subjects) {
+		changes = append(changes, fmt.Sprintf("subjects %v -> %v", current.Subjects, desired.Subjects))
+	}
+	if current.Storage != desired.Storage {
+		changes = append(changes, fmt.Sprintf("storage %s -> %s", current.Storage, desired.Storage))
+	}
+	if current.MaxAge != desired.MaxAge {
+		changes = append(changes, fmt.Sprintf("max_age %s -> %s", current.MaxAge, desired.MaxAge))
+	}
+	if current.MaxBytes != desired.MaxBytes {
+		changes = append(changes, fmt.Sprintf("max_bytes %d -> %d", current.MaxBytes, desired.MaxBytes))
+	}
+	return changes
+}
+
+// ensureStreams ensures each stream in order
+func ensureStreams(js nats.JetStreamContext, configs []*nats.StreamConfig) error {
+	for _, cfg := range configs {
+		if err := ensureStream(js, cfg); err != nil {
+			return fmt.Errorf("stream %s: %w", cfg.Name, err)
+		}
+	}
+	return nil
+}
+
+// ensureStream creates the stream, or updates it when the existing
+// configuration has drifted from the desired one
+func ensureStream(js nats.JetStreamContext, cfg *nats.StreamConfig) error {
+	info, err := js.StreamInfo(cfg.Name)
+	if err != nil {
+		// Stream doesn't exist, create it
+		if _, err := js.AddStream(cfg); err != nil {
+			return fmt.Errorf("failed to create JetStream stream: %w", err)
+		}
+		core.Logger().Info("created JetStream stream", "component", "core", "stream", cfg.Name)
+		return nil
+	}
+
+	changes := streamConfigChanges(&info.Config, cfg)
+	if len(changes) == 0 {
+		core.Logger().Info("JetStream stream already exists", "component", "core", "stream", cfg.Name)
+		return nil
+	}
+
+	updated := info.Config
+	updated.Subjects = cfg.Subjects
+	updated.Storage = cfg.Storage
+	updated.MaxAge = cfg.MaxAge
+	updated.MaxBytes = cfg.MaxBytes
+	if _, err := js.UpdateStream(&updated); err != nil {
+		return fmt.Errorf("failed to update JetStream stream (%s): %w", strings.Join(changes, ", "), err)
+	}
+	core.Logger().Info("updated JetStream stream", "component", "core", "stream", cfg.Name, "changes", changes)
+	return nil
+}
+
+// Backoff between JetStream setup attempts at startup
+const (
+	jetStreamInitialBackoff = 250 * time.Millisecond
+	jetStreamMaxBackoff     = 5 * time.Second
+)
+
+// setupJetStream creates the JetStream context and ensures the streams of
+// mode. A JetStream that is still starting, e.g. recovering its store or
+// waiting for a cluster leader, is retried with backoff until timeout
+// instead of stopping the core; other errors are returned at once.
+func setupJetStream(nc *nats.Conn, prefix, mode string, configs []*nats.StreamConfig, timeout time.Duration) (nats.JetStreamContext, error) {
+	var js nats.JetStreamContext
+	err := retryJetStream(timeout, func() error {
+		var err error
+		if js, err = nc.JetStream(); err != nil {
+			return fmt.Errorf("failed to create JetStream context: %w", err)
+		}
+		if mode == streamModeSingle {
+			if err := checkNoSplitStreams(js, prefix); err != nil {
+				return err
+			}
+		}
+		return ensureStreams(js, configs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return js, nil
+}
+
+// retryJetStream calls fn until it succeeds, fails with an error that is not
+// jetStreamUnavailable, or timeout has passed
+func retryJetStream(timeout time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	backoff := jetStreamInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !jetStreamUnavailable(err) {
+			return err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("JetStream not available after %s (%d attempts): %w", timeout, attempt, err)
+		}
+		wait := min(backoff, remaining)
+		core.Logger().Warn("JetStream not available, retrying", "component", "core", "attempt", attempt, "retry_in", wait, "error", err)
+		time.Sleep(wait)
+		backoff = min(2*backoff, jetStreamMaxBackoff)
+	}
+}
+
+// jetStreamUnavailable reports whether err means JetStream cannot answer
+// yet rather than that the request was refused
+func jetStreamUnavailable(err error) bool {
+	if errors.Is(err, nats.ErrTimeout) || errors.Is(err, nats.ErrNoResponders) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var apiErr *nats.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusServiceUnavailable
+}
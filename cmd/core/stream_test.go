@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/e7217/edg/internal/core"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestParseStorageType(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    nats.StorageType
+		wantErr bool
+	}{
+		{"file", nats.FileStorage, false},
+		{"memory", nats.MemoryStorage, false},
+		{"MEMORY", nats.MemoryStorage, false},
+		{"disk", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStorageType(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStorageType(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseStorageType(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEnsureStream_UpdatesDriftedConfig(t *testing.T) {
+	ns, err := server.NewServer(&server.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %v", err)
+	}
+	go ns.Start()
+	defer ns.Shutdown()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatalf("Failed to create JetStream context: %v", err)
+	}
+
+	// Initial creation
+	if err := ensureStream(js, newStreamConfig(core.DefaultSubjectPrefix, nats.FileStorage, time.Hour, -1)); err != nil {
+		t.Fatalf("ensureStream create: %v", err)
+	}
+
+	// Drifted retention and size are applied to the existing stream
+	if err := ensureStream(js, newStreamConfig(core.DefaultSubjectPrefix, nats.FileStorage, 2*time.Hour, 1<<20)); err != nil {
+		t.Fatalf("ensureStream update: %v", err)
+	}
+
+	info, err := js.StreamInfo(core.DataStreamName)
+	if err != nil {
+		t.Fatalf("StreamInfo: %v", err)
+	}
+	if info.Config.MaxAge != 2*time.Hour {
+		t.Errorf("MaxAge = %v, want %v", info.Config.MaxAge, 2*time.Hour)
+	}
+	if info.Config.MaxBytes != 1<<20 {
+		t.Errorf("MaxBytes = %d, want %d", info.Config.MaxBytes, 1<<20)
+	}
+
+	// Unchanged config is a no-op
+	if err := ensureStream(js, newStreamConfig(core.DefaultSubjectPrefix, nats.FileStorage, 2*time.Hour, 1<<20)); err != nil {
+		t.Fatalf("ensureStream no-op: %v", err)
+	}
+}
+
+func TestStreamConfigChanges(t *testing.T) {
+	current := newStreamConfig(core.DefaultSubjectPrefix, nats.FileStorage, time.Hour, -1)
+
+	if changes := streamConfigChanges(current, newStreamConfig(core.DefaultSubjectPrefix, nats.FileStorage, time.Hour, -1)); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+
+	changes := streamConfigChanges(current, newStreamConfig(core.DefaultSubjectPrefix, nats.MemoryStorage, 2*time.Hour, 1024))
+	if len(changes) != 3 {
+		t.Errorf("expected 3 changes, got %v", changes)
+	}
+}
+
+func TestNewStreamConfig_SubjectPrefix(t *testing.T) {
+	cfg := newStreamConfig(core.DefaultSubjectPrefix, nats.FileStorage, time.Hour, -1)
+	if cfg.Name != core.DataStreamName || cfg.Subjects[0] != "platform.data.>" {
+		t.Errorf("default config = %s %v", cfg.Name, cfg.Subjects)
+	}
+
+	cfg = newStreamConfig("site-a", nats.FileStorage, time.Hour, -1)
+	if cfg.Name != "SITE-A_DATA" || cfg.Subjects[0] != "site-a.data.>" {
+		t.Errorf("prefixed config = %s %v", cfg.Name, cfg.Subjects)
+	}
+}
+
+func TestNewStreamConfigs_Split(t *testing.T) {
+	retention := streamRetention{Data: time.Hour, Validated: 30 * 24 * time.Hour, Events: 24 * time.Hour}
+	configs, err := newStreamConfigs("site-a", streamModeSplit, nats.FileStorage, retention, 1024)
+	if err != nil {
+		t.Fatalf("newStreamConfigs: %v", err)
+	}
+
+	want := []struct {
+		name     string
+		subjects []string
+		maxAge   time.Duration
+	}{
+		{"SITE-A_DATA", []string{"site-a.data.asset", "site-a.data.batch"}, time.Hour},
+		{"SITE-A_DATA_VALIDATED", []string{"site-a.data.validated"}, 30 * 24 * time.Hour},
+		{"SITE-A_DATA_REJECTED", []string{"site-a.data.rejected", "site-a.data.unregistered", "site-a.data.deadletter"}, time.Hour},
+		{"SITE-A_DATA_EVENTS", []string{"site-a.data.events"}, 24 * time.Hour},
+	}
+	if len(configs) != len(want) {
+		t.Fatalf("got %d configs, want %d", len(configs), len(want))
+	}
+	for i, w := range want {
+		cfg := configs[i]
+		if cfg.Name != w.name || !slices.Equal(cfg.Subjects, w.subjects) || cfg.MaxAge != w.maxAge || cfg.MaxBytes != 1024 {
+			t.Errorf("configs[%d] = %s %v %s %d, want %s %v %s", i, cfg.Name, cfg.Subjects, cfg.MaxAge, cfg.MaxBytes, w.name, w.subjects, w.maxAge)
+		}
+	}
+
+	configs, err = newStreamConfigs(core.DefaultSubjectPrefix, streamModeSingle, nats.FileStorage, retention, -1)
+	if err != nil || len(configs) != 1 || configs[0].Subjects[0] != "platform.data.>" {
+		t.Errorf("single mode = %v, %v", configs, err)
+	}
+
+	if _, err := newStreamConfigs(core.DefaultSubjectPrefix, "per-asset", nats.FileStorage, retention, -1); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestEnsureStreams_SwitchToSplit(t *testing.T) {
+	ns, err := server.NewServer(&server.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %v", err)
+	}
+	go ns.Start()
+	defer ns.Shutdown()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatalf("Failed to create JetStream context: %v", err)
+	}
+
+	retention := streamRetention{Data: time.Hour, Rejected: 2 * time.Hour}
+	single, _ := newStreamConfigs(core.DefaultSubjectPrefix, streamModeSingle, nats.FileStorage, retention, -1)
+	if err := ensureStreams(js, single); err != nil {
+		t.Fatalf("ensureStreams single: %v", err)
+	}
+
+	// The main stream gives up the class subjects before the class streams take them
+	split, _ := newStreamConfigs(core.DefaultSubjectPrefix, streamModeSplit, nats.FileStorage, retention, -1)
+	if err := ensureStreams(js, split); err != nil {
+		t.Fatalf("ensureStreams split: %v", err)
+	}
+	if err := ensureStreams(js, split); err != nil {
+		t.Fatalf("ensureStreams split again: %v", err)
+	}
+
+	for subject, stream := range map[string]string{
+		core.SubjectDataValidated:    core.DataStreamName + "_VALIDATED",
+		core.SubjectDataUnregistered: core.DataStreamName + "_REJECTED",
+		core.SubjectDataEvents:       core.DataStreamName + "_EVENTS",
+		core.SubjectDataAsset:        core.DataStreamName,
+	} {
+		ack, err := js.Publish(subject, []byte("{}"))
+		if err != nil {
+			t.Fatalf("Publish %s: %v", subject, err)
+		}
+		if ack.Stream != stream {
+			t.Errorf("%s stored in %s, want %s", subject, ack.Stream, stream)
+		}
+	}
+
+	info, err := js.StreamInfo(core.DataStreamName + "_REJECTED")
+	if err != nil {
+		t.Fatalf("StreamInfo: %v", err)
+	}
+	if info.Config.MaxAge != 2*time.Hour {
+		t.Errorf("rejected MaxAge = %v, want 2h", info.Config.MaxAge)
+	}
+
+	// Going back to single mode is refused while the class streams exist
+	if err := checkNoSplitStreams(js, core.DefaultSubjectPrefix); err == nil {
+		t.Error("expected checkNoSplitStreams to fail")
+	}
+	if err := checkNoSplitStreams(js, "site-b"); err != nil {
+		t.Errorf("checkNoSplitStreams for another prefix: %v", err)
+	}
+}
+
+// startNATSWithoutJetStream starts a NATS server with JetStream disabled and
+// connects to it
+func startNATSWithoutJetStream(t *testing.T) (*server.Server, *nats.Conn) {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{Port: -1})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %v", err)
+	}
+	go ns.Start()
+	t.Cleanup(ns.Shutdown)
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(nc.Close)
+	return ns, nc
+}
+
+func TestSetupJetStream_WaitsForJetStream(t *testing.T) {
+	ns, nc := startNATSWithoutJetStream(t)
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		if err := ns.EnableJetStream(&server.JetStreamConfig{StoreDir: t.TempDir()}); err != nil {
+			t.Errorf("EnableJetStream: %v", err)
+		}
+	}()
+
+	configs, _ := newStreamConfigs(core.DefaultSubjectPrefix, streamModeSingle, nats.MemoryStorage, streamRetention{Data: time.Hour}, -1)
+	js, err := setupJetStream(nc, core.DefaultSubjectPrefix, streamModeSingle, configs, 10*time.Second)
+	if err != nil {
+		t.Fatalf("setupJetStream: %v", err)
+	}
+	if _, err := js.StreamInfo(core.DataStreamName); err != nil {
+		t.Errorf("StreamInfo: %v", err)
+	}
+}
+
+func TestSetupJetStream_Timeout(t *testing.T) {
+	_, nc := startNATSWithoutJetStream(t)
+
+	configs, _ := newStreamConfigs(core.DefaultSubjectPrefix, streamModeSingle, nats.MemoryStorage, streamRetention{Data: time.Hour}, -1)
+	start := time.Now()
+	_, err := setupJetStream(nc, core.DefaultSubjectPrefix, streamModeSingle, configs, 600*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected an error without JetStream")
+	}
+	if elapsed := time.Since(start); elapsed < 600*time.Millisecond {
+		t.Errorf("gave up after %v, want at least the timeout", elapsed)
+	}
+	if !jetStreamUnavailable(err) {
+		t.Errorf("error %v does not wrap the JetStream error", err)
+	}
+}
+
+func TestRetryJetStream_PermanentError(t *testing.T) {
+	calls := 0
+	permanent := errors.New("stream name already in use")
+	err := retryJetStream(10*time.Second, func() error {
+		calls++
+		return permanent
+	})
+	if !errors.Is(err, permanent) {
+		t.Errorf("err = %v, want %v", err, permanent)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+
+	calls = 0
+	err = retryJetStream(10*time.Second, func() error {
+		if calls++; calls < 3 {
+			return nats.ErrTimeout
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("err = %v after %d calls, want nil after 3", err, calls)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+// TestSetupTracing tests the disabled default and endpoint validation
+func TestSetupTracing(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	shutdown, err := setupTracing(context.Background(), "")
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+	assert.Equal(t, previous, otel.GetTracerProvider(), "an empty endpoint keeps the no-op provider")
+
+	for _, endpoint := range []string{"localhost:4318", "ftp://collector:4318", "http://"} {
+		_, err := setupTracing(context.Background(), endpoint)
+		assert.Error(t, err, endpoint)
+	}
+
+	shutdown, err = setupTracing(context.Background(), "http://127.0.0.1:4318")
+	require.NoError(t, err)
+	assert.NotEqual(t, previous, otel.GetTracerProvider())
+	assert.NoError(t, shutdown(context.Background()))
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/e7217/edg/internal/core"
+	"github.com/e7217/edg/sdk"
+)
+
+// usageError reports a malformed command line
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// cli runs edgctl commands against a platform client
+type cli struct {
+	client *sdk.Client
+	in     io.Reader // read by commands given "-" as a file
+	out    io.Writer
+	json   bool
+}
+
+// run dispatches args ("asset list ...") to the matching command
+func (c *cli) run(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "validate":
+			return c.validate(ctx, args[1:])
+		case "export":
+			return c.export(ctx, args[1:])
+		case "import":
+			return c.importSnapshot(ctx, args[1:])
+		case "check":
+			return c.check(ctx, args[1:])
+		}
+	}
+	if len(args) < 2 {
+		return usageError("expected <resource> <action>, e.g. asset list")
+	}
+
+	resource, action, rest := args[0], args[1], args[2:]
+	switch resource + " " + action {
+	case "asset list":
+		return c.assetList(ctx, rest)
+	case "asset get":
+		return c.assetGet(ctx, rest)
+	case "asset create":
+		return c.assetCreate(ctx, rest)
+	case "asset delete":
+		return c.assetDelete(ctx, rest)
+	case "relation list":
+		return c.relationList(ctx, rest)
+	case "relation create":
+		return c.relationCreate(ctx, rest)
+	case "template list":
+		return c.templateList(ctx, rest)
+	default:
+		return usageError(fmt.Sprintf("unknown command: %s %s", resource, action))
+	}
+}
+
+// parseFlags parses command flags, leaving positional arguments in fs.Args()
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return usageError(fmt.Sprintf("%s: %v", fs.Name(), err))
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (c *cli) assetList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("asset list", flag.ContinueOnError)
+	label := fs.String("label", "", "Only list assets with this label")
+	template := fs.String("template", "", "Only list assets of this template")
+	limit := fs.Int("limit", core.DefaultListLimit, "Page size")
+	offset := fs.Int("offset", 0, "Number of assets to skip")
+	includeDeleted := fs.Bool("include-deleted", false, "Also list soft-deleted assets")
+	orderBy := fs.String("order-by", "", "Sort by created_at, name or template_name (default created_at)")
+	orderDir := fs.String("order-dir", "", "Sort direction, asc or desc (default desc for created_at, asc otherwise)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	page, err := c.client.ListAssets(ctx, sdk.ListAssetsRequest{
+		Label:          *label,
+		TemplateName:   *template,
+		Limit:          *limit,
+		Offset:         *offset,
+		IncludeDeleted: *includeDeleted,
+		OrderBy:        *orderBy,
+		OrderDir:       *orderDir,
+	})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, page)
+	}
+	if err := writeAssets(c.out, page.Assets); err != nil {
+		return err
+	}
+	if len(page.Assets) < page.Total {
+		_, err = fmt.Fprintf(c.out, "(%d-%d of %d)\n", page.Offset+1, page.Offset+len(page.Assets), page.Total)
+	}
+	return err
+}
+
+func (c *cli) assetGet(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("asset get", flag.ContinueOnError)
+	name := fs.String("name", "", "Look the asset up by name instead of ID")
+	externalID := fs.String("external-id", "", "Look the asset up by SCHEME=VALUE, e.g. erp=M-100")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var asset *sdk.Asset
+	var err error
+	switch {
+	case *name != "" && *externalID == "" && fs.NArg() == 0:
+		asset, err = c.client.GetAssetByName(ctx, *name)
+	case *externalID != "" && *name == "" && fs.NArg() == 0:
+		scheme, value, ok := strings.Cut(*externalID, "=")
+		if !ok {
+			return usageError("asset get: -external-id expects SCHEME=VALUE")
+		}
+		asset, err = c.client.GetAssetByExternalID(ctx, scheme, value)
+	case *name == "" && *externalID == "" && fs.NArg() == 1:
+		asset, err = c.client.GetAsset(ctx, fs.Arg(0))
+	default:
+		return usageError("asset get: expected <id>, -name or -external-id")
+	}
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, asset)
+	}
+	return writeAssetDetail(c.out, asset)
+}
+
+func (c *cli) assetCreate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("asset create", flag.ContinueOnError)
+	name := fs.String("name", "", "Asset name (required)")
+	template := fs.String("template", "", "Template name")
+	labels := fs.String("labels", "", "Comma-separated labels")
+	key := fs.String("external-key", "", "Derive the asset ID from this key, so repeating the create fails")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return usageError("asset create: -name is required")
+	}
+
+	asset, err := c.client.CreateAsset(ctx, sdk.CreateAssetRequest{
+		Name:         *name,
+		TemplateName: *template,
+		Labels:       splitList(*labels),
+		ExternalKey:  *key,
+	})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, asset)
+	}
+	return writeAssetDetail(c.out, asset)
+}
+
+func (c *cli) assetDelete(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("asset delete", flag.ContinueOnError)
+	hard := fs.Bool("hard", false, "Permanently remove the asset and its relations")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("asset delete: expected <id>")
+	}
+
+	id := fs.Arg(0)
+	var err error
+	if *hard {
+		err = c.client.HardDeleteAsset(ctx, id)
+	} else {
+		err = c.client.DeleteAsset(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, map[string]any{"id": id, "deleted": true, "hard": *hard})
+	}
+	_, err = fmt.Fprintf(c.out, "deleted %s\n", id)
+	return err
+}
+
+func (c *cli) relationList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("relation list", flag.ContinueOnError)
+	assetID := fs.String("asset", "", "List relations of this asset")
+	direction := fs.String("direction", "", "outgoing, incoming or both (default both)")
+	relationType := fs.String("type", "", "Only list relations of this type")
+	limit := fs.Int("limit", core.DefaultListLimit, "Page size when listing every relation")
+	offset := fs.Int("offset", 0, "Number of relations to skip when listing every relation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *assetID == "" && *relationType == "" {
+		return c.relationListAll(ctx, *limit, *offset)
+	}
+
+	relations, err := c.client.ListRelations(ctx, sdk.ListRelationsRequest{
+		AssetID:      *assetID,
+		Direction:    *direction,
+		RelationType: sdk.RelationType(*relationType),
+	})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, relations)
+	}
+	return writeRelations(c.out, relations)
+}
+
+// relationListAll prints a page of every relation
+func (c *cli) relationListAll(ctx context.Context, limit, offset int) error {
+	page, err := c.client.ListAllRelations(ctx, sdk.ListAllRelationsRequest{Limit: limit, Offset: offset})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, page)
+	}
+	if err := writeRelations(c.out, page.Relations); err != nil {
+		return err
+	}
+	if len(page.Relations) < page.Total {
+		_, err = fmt.Fprintf(c.out, "(%d-%d of %d)\n", page.Offset+1, page.Offset+len(page.Relations), page.Total)
+	}
+	return err
+}
+
+func (c *cli) relationCreate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("relation create", flag.ContinueOnError)
+	source := fs.String("source", "", "Source asset ID (required)")
+	target := fs.String("target", "", "Target asset ID (required)")
+	relationType := fs.String("type", "", "Relation type (required)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *source == "" || *target == "" || *relationType == "" {
+		return usageError("relation create: -source, -target and -type are required")
+	}
+
+	relation, err := c.client.CreateRelation(ctx, sdk.CreateRelationRequest{
+		SourceAssetID: *source,
+		TargetAssetID: *target,
+		RelationType:  sdk.RelationType(*relationType),
+	})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, relation)
+	}
+	return writeRelations(c.out, []*sdk.AssetRelation{relation})
+}
+
+func (c *cli) templateList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("template list", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	templates, err := c.client.ListTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, templates)
+	}
+	return writeTemplates(c.out, templates)
+}
+
+// validate checks a data payload against a template without ingesting it
+func (c *cli) validate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	template := fs.String("template", "", "Template to validate against (required)")
+	file := fs.String("file", "-", "AssetData JSON file, or - for stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *template == "" {
+		return usageError("validate: -template is required")
+	}
+
+	raw, err := c.readInput(*file)
+	if err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+	var data sdk.AssetData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("invalid data JSON: %w", err)
+	}
+
+	if err := c.client.ValidateData(ctx, *template, &data); err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, map[string]any{"template_name": *template, "valid": true})
+	}
+	_, err = fmt.Fprintf(c.out, "valid against %s\n", *template)
+	return err
+}
+
+// readInput reads a file, or c.in when file is "-"
+func (c *cli) readInput(file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(c.in)
+	}
+	return os.ReadFile(file)
+}
+
+func (c *cli) export(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	file := fs.String("file", "-", "Snapshot file to write, or - for stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	snapshot, err := c.client.ExportSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, snapshot, "", "  "); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	indented.WriteByte('\n')
+
+	if *file == "-" {
+		_, err = c.out.Write(indented.Bytes())
+		return err
+	}
+	if err := os.WriteFile(*file, indented.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+func (c *cli) importSnapshot(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	mode := fs.String("mode", sdk.SnapshotMerge, "merge upserts by ID; replace deletes every asset and relation first")
+	file := fs.String("file", "-", "Snapshot file, or - for stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *mode != sdk.SnapshotMerge && *mode != sdk.SnapshotReplace {
+		return usageError("import: -mode must be merge or replace")
+	}
+
+	raw, err := c.readInput(*file)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snapshot sdk.Snapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return fmt.Errorf("invalid snapshot JSON: %w", err)
+	}
+
+	if err := c.client.ImportSnapshot(ctx, raw, *mode); err != nil {
+		return err
+	}
+	if c.json {
+		return writeJSON(c.out, map[string]any{"mode": *mode, "assets": len(snapshot.Assets), "relations": len(snapshot.Relations)})
+	}
+	_, err = fmt.Fprintf(c.out, "imported %d assets and %d relations (%s)\n", len(snapshot.Assets), len(snapshot.Relations), *mode)
+	return err
+}
+
+// check prints the integrity report and fails when it lists any issue, so
+// scripts can act on the exit status
+func (c *cli) check(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	limit := fs.Int("limit", 0, "IDs listed per category (0 for the server default)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	report, err := c.client.CheckIntegrity(ctx, *limit)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		err = writeJSON(c.out, report)
+	} else {
+		err = writeIntegrityReport(c.out, report)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.OK() {
+		return errors.New("integrity check found issues")
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+	"github.com/e7217/edg/sdk"
+)
+
+// startTestCLI runs an embedded NATS server with the meta handlers registered
+func startTestCLI(t *testing.T) (*cli, *bytes.Buffer) {
+	t.Helper()
+
+	ns, err := natsserver.NewServer(&natsserver.Options{Port: -1})
+	require.NoError(t, err)
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(5*time.Second), "NATS server not ready")
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+
+	store, err := core.NewStore(":memory:")
+	require.NoError(t, err)
+
+	loader := core.NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("../../internal/core/testdata/valid_template.yaml"))
+	require.NoError(t, core.NewMetaHandler(store, loader).RegisterHandlers(nc))
+
+	t.Cleanup(func() {
+		nc.Close()
+		store.Close()
+		ns.Shutdown()
+	})
+
+	var out bytes.Buffer
+	return &cli{client: sdk.NewClient(nc, sdk.WithTimeout(2*time.Second)), out: &out}, &out
+}
+
+// TestCLI_AssetCommands tests asset create, get, list and delete
+func TestCLI_AssetCommands(t *testing.T) {
+	c, out := startTestCLI(t)
+	ctx := context.Background()
+
+	c.json = true
+	require.NoError(t, c.run(ctx, []string{"asset", "create", "-name", "pump-1", "-template", "test-sensor", "-labels", "line-1, hall-a"}))
+	var created sdk.Asset
+	require.NoError(t, json.Unmarshal(out.Bytes(), &created))
+	assert.Equal(t, []string{"line-1", "hall-a"}, created.Labels)
+
+	c.json = false
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"asset", "get", created.ID}))
+	assert.Contains(t, out.String(), "Name:      pump-1")
+	assert.Contains(t, out.String(), "Template:  test-sensor")
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"asset", "get", "-name", "pump-1"}))
+	assert.Contains(t, out.String(), created.ID)
+
+	_, err := c.client.UpdateAsset(ctx, sdk.UpdateAssetRequest{ID: created.ID, ExternalIDs: &map[string]string{"erp": "M-100"}})
+	require.NoError(t, err)
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"asset", "get", "-external-id", "erp=M-100"}))
+	assert.Contains(t, out.String(), created.ID)
+	assert.Contains(t, out.String(), "erp=M-100")
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"asset", "list", "-label", "hall-a"}))
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.Contains(t, string(lines[0]), "ID")
+	assert.Contains(t, string(lines[1]), "pump-1")
+
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"asset", "delete", created.ID}))
+	assert.Equal(t, "deleted "+created.ID+"\n", out.String())
+
+	err = c.run(ctx, []string{"asset", "get", created.ID})
+	var platformErr *sdk.Error
+	require.ErrorAs(t, err, &platformErr)
+	assert.Equal(t, core.ErrCodeNotFound, platformErr.Code)
+}
+
+// TestCLI_RelationAndTemplateCommands tests relation create/list and template list
+func TestCLI_RelationAndTemplateCommands(t *testing.T) {
+	c, out := startTestCLI(t)
+	ctx := context.Background()
+
+	c.json = true
+	ids := make(map[string]string)
+	for _, name := range []string{"line", "machine"} {
+		out.Reset()
+		require.NoError(t, c.run(ctx, []string{"asset", "create", "-name", name}))
+		var asset sdk.Asset
+		require.NoError(t, json.Unmarshal(out.Bytes(), &asset))
+		ids[name] = asset.ID
+	}
+
+	c.json = false
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"relation", "create", "-source", ids["machine"], "-target", ids["line"], "-type", "partOf"}))
+	assert.Contains(t, out.String(), "partOf")
+
+	c.json = true
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"relation", "list", "-asset", ids["line"], "-direction", "incoming"}))
+	var relations []*sdk.AssetRelation
+	require.NoError(t, json.Unmarshal(out.Bytes(), &relations))
+	require.Len(t, relations, 1)
+	assert.Equal(t, ids["machine"], relations[0].SourceAssetID)
+
+	c.json = false
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"template", "list"}))
+	assert.Contains(t, out.String(), "test-sensor")
+}
+
+// TestCLI_Validate tests validation of a payload from a file and from stdin
+func TestCLI_Validate(t *testing.T) {
+	c, out := startTestCLI(t)
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "data.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"asset_id":"pump-1","values":[{"name":"temperature","number":21.5,"unit":"celsius"}]}`), 0o644))
+	require.NoError(t, c.run(ctx, []string{"validate", "-template", "test-sensor", "-file", path}))
+	assert.Equal(t, "valid against test-sensor\n", out.String())
+
+	c.in = strings.NewReader(`{"values":[{"name":"temperature","text":"hot"}]}`)
+	err := c.run(ctx, []string{"validate", "-template", "test-sensor"})
+	var platformErr *sdk.Error
+	require.ErrorAs(t, err, &platformErr)
+	assert.Equal(t, core.ErrCodeValidation, platformErr.Code)
+
+	c.in = strings.NewReader(`not json`)
+	assert.ErrorContains(t, c.run(ctx, []string{"validate", "-template", "test-sensor"}), "invalid data JSON")
+}
+
+// TestCLI_ExportImport tests a snapshot round trip through a file
+func TestCLI_ExportImport(t *testing.T) {
+	c, out := startTestCLI(t)
+	ctx := context.Background()
+
+	require.NoError(t, c.run(ctx, []string{"asset", "create", "-name", "pump-1"}))
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	require.NoError(t, c.run(ctx, []string{"export", "-file", path}))
+
+	var snapshot sdk.Snapshot
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &snapshot))
+	require.Len(t, snapshot.Assets, 1)
+	assert.Equal(t, "pump-1", snapshot.Assets[0].Name)
+
+	require.NoError(t, c.run(ctx, []string{"asset", "create", "-name", "pump-2"}))
+	out.Reset()
+	c.in = bytes.NewReader(raw)
+	require.NoError(t, c.run(ctx, []string{"import", "-mode", "replace"}))
+	assert.Equal(t, "imported 1 assets and 0 relations (replace)\n", out.String())
+
+	out.Reset()
+	c.json = true
+	require.NoError(t, c.run(ctx, []string{"asset", "list"}))
+	var page sdk.ListAssetsResponse
+	require.NoError(t, json.Unmarshal(out.Bytes(), &page))
+	assert.Equal(t, 1, page.Total)
+}
+
+// TestCLI_Check tests the integrity report and its exit status
+func TestCLI_Check(t *testing.T) {
+	c, out := startTestCLI(t)
+	ctx := context.Background()
+
+	require.NoError(t, c.run(ctx, []string{"asset", "create", "-name", "pump-1", "-template", "test-sensor"}))
+	out.Reset()
+	require.NoError(t, c.run(ctx, []string{"check"}))
+	assert.Contains(t, out.String(), "unknown templates       0      -")
+
+	c.in = strings.NewReader(`{"version":1,"assets":[{"id":"a1","name":"old-pump","template_name":"retired","created_at":"2024-01-01T00:00:00Z"}]}`)
+	require.NoError(t, c.run(ctx, []string{"import"}))
+	out.Reset()
+	assert.ErrorContains(t, c.run(ctx, []string{"check"}), "integrity check found issues")
+	assert.Contains(t, out.String(), "unknown templates       1      a1")
+
+	c.json = true
+	out.Reset()
+	require.Error(t, c.run(ctx, []string{"check", "-limit", "5"}))
+	var report sdk.IntegrityReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, []string{"a1"}, report.UnknownTemplates.IDs)
+}
+
+// TestCLI_UsageErrors tests malformed command lines
+func TestCLI_UsageErrors(t *testing.T) {
+	c, _ := startTestCLI(t)
+	ctx := context.Background()
+
+	for _, args := range [][]string{
+		{"asset"},
+		{"asset", "rename"},
+		{"asset", "get"},
+		{"asset", "get", "-external-id", "M-100"},
+		{"asset", "create"},
+		{"asset", "delete"},
+		{"asset", "list", "-bogus"},
+		{"relation", "create", "-source", "a"},
+		{"validate"},
+		{"import", "-mode", "overwrite"},
+	} {
+		var usageErr usageError
+		assert.ErrorAs(t, c.run(ctx, args), &usageErr, "%v", args)
+	}
+}
@@ -0,0 +1,72 @@
+// Command edgctl inspects and edits the metadata store of a running EDG
+// Core over NATS.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/sdk"
+)
+
+const usage = `Usage: edgctl [flags] <command> [args]
+
+Commands:
+  asset list      [-label L] [-template T] [-limit N] [-offset N] [-include-deleted]
+                  [-order-by created_at|name|template_name] [-order-dir asc|desc]
+  asset get       <id> | -name NAME | -external-id SCHEME=VALUE
+  asset create    -name NAME [-template T] [-labels a,b] [-external-key K]
+  asset delete    <id> [-hard]
+  relation list   -asset ID [-direction outgoing|incoming|both] [-type T]
+                  | -type T | [-limit N] [-offset N]
+  relation create -source ID -target ID -type T
+  template list
+  validate        -template T [-file F]   (F is AssetData JSON; - or omitted reads stdin)
+  export          [-file F]               (snapshot of every asset and relation; stdout by default)
+  import          [-mode merge|replace] [-file F]
+  check           [-limit N]              (referential integrity; exits 1 when issues are found)
+
+Flags:
+`
+
+func main() {
+	natsURL := flag.String("nats-url", nats.DefaultURL, "NATS server URL")
+	timeout := flag.Duration("timeout", sdk.DefaultTimeout, "Request timeout")
+	subjectPrefix := flag.String("subject-prefix", sdk.DefaultSubjectPrefix, "Subject prefix of the EDG Core instance")
+	jsonOut := flag.Bool("json", false, "Print JSON instead of a table")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	nc, err := nats.Connect(*natsURL, nats.Timeout(*timeout))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to NATS: %v\n", err)
+		os.Exit(1)
+	}
+	defer nc.Close()
+
+	cli := &cli{
+		client: sdk.NewClient(nc, sdk.WithTimeout(*timeout), sdk.WithSubjectPrefix(*subjectPrefix)),
+		in:     os.Stdin,
+		out:    os.Stdout,
+		json:   *jsonOut,
+	}
+	if err := cli.run(context.Background(), flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "edgctl: %v\n", err)
+		if _, ok := err.(usageError); ok {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/e7217/edg/sdk"
+)
+
+// writeJSON prints v as indented JSON
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// newTable returns a tabwriter for aligned columns
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
+
+// orDash shows empty table cells as "-"
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+func writeAssets(w io.Writer, assets []*sdk.Asset) error {
+	tw := newTable(w)
+	fmt.Fprintln(tw, "ID\tNAME\tTEMPLATE\tLABELS\tCREATED")
+	for _, a := range assets {
+		name := a.Name
+		if a.DeletedAt != nil {
+			name += " (deleted)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			a.ID, name, orDash(a.TemplateName), orDash(strings.Join(a.Labels, ",")), formatTime(a.CreatedAt))
+	}
+	return tw.Flush()
+}
+
+func writeAssetDetail(w io.Writer, a *sdk.Asset) error {
+	tw := newTable(w)
+	fmt.Fprintf(tw, "ID:\t%s\n", a.ID)
+	fmt.Fprintf(tw, "Name:\t%s\n", a.Name)
+	fmt.Fprintf(tw, "Template:\t%s\n", orDash(a.TemplateName))
+	fmt.Fprintf(tw, "Labels:\t%s\n", orDash(strings.Join(a.Labels, ",")))
+	if len(a.ExternalIDs) > 0 {
+		fmt.Fprintf(tw, "External IDs:\t%s\n", formatPairs(a.ExternalIDs))
+	}
+	if len(a.Attributes) > 0 {
+		fmt.Fprintf(tw, "Attributes:\t%s\n", formatPairs(a.Attributes))
+	}
+	if a.Latitude != nil && a.Longitude != nil {
+		location := fmt.Sprintf("%g,%g", *a.Latitude, *a.Longitude)
+		if a.Altitude != nil {
+			location += fmt.Sprintf(" (%gm)", *a.Altitude)
+		}
+		fmt.Fprintf(tw, "Location:\t%s\n", location)
+	}
+	fmt.Fprintf(tw, "Created:\t%s\n", formatTime(a.CreatedAt))
+	if a.DeletedAt != nil {
+		fmt.Fprintf(tw, "Deleted:\t%s\n", formatTime(*a.DeletedAt))
+	}
+	return tw.Flush()
+}
+
+// formatPairs renders a map as sorted key=value pairs
+func formatPairs(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+m[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func writeRelations(w io.Writer, relations []*sdk.AssetRelation) error {
+	tw := newTable(w)
+	fmt.Fprintln(tw, "ID\tSOURCE\tTYPE\tTARGET\tCREATED")
+	for _, r := range relations {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			r.ID, r.SourceAssetID, r.RelationType, r.TargetAssetID, formatTime(r.CreatedAt))
+	}
+	return tw.Flush()
+}
+
+func writeTemplates(w io.Writer, templates []*sdk.AssetTemplate) error {
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+
+	tw := newTable(w)
+	fmt.Fprintln(tw, "NAME\tRESOURCES\tSTRICT")
+	for _, t := range templates {
+		fmt.Fprintf(tw, "%s\t%d\t%t\n", t.Name, len(t.Resources), t.Strict)
+	}
+	return tw.Flush()
+}
+
+func writeIntegrityReport(w io.Writer, r *sdk.IntegrityReport) error {
+	tw := newTable(w)
+	fmt.Fprintln(tw, "CHECK\tCOUNT\tIDS")
+	for _, row := range []struct {
+		name   string
+		issues sdk.IntegrityIssues
+	}{
+		{"dangling relations", r.DanglingRelations},
+		{"unknown relation types", r.UnknownRelationTypes},
+		{"relation cycles", r.RelationCycles},
+		{"unknown templates", r.UnknownTemplates},
+	} {
+		ids := strings.Join(row.issues.IDs, ",")
+		if row.issues.Count > len(row.issues.IDs) {
+			ids += ",..."
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", row.name, row.issues.Count, orDash(ids))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// Register kinds a tag can be read from
+const (
+	RegisterHolding  = "holding"  // read/write 16-bit registers, function 3
+	RegisterInput    = "input"    // read-only 16-bit registers, function 4
+	RegisterCoil     = "coil"     // read/write bits, function 1
+	RegisterDiscrete = "discrete" // read-only bits, function 2
+)
+
+// Value types of register tags. 32-bit types span two registers, high word
+// first.
+const (
+	TypeInt16   = "int16"
+	TypeUint16  = "uint16"
+	TypeInt32   = "int32"
+	TypeUint32  = "uint32"
+	TypeFloat32 = "float32"
+)
+
+// Config is the adapter configuration file
+type Config struct {
+	Modbus ModbusConfig `yaml:"modbus"`
+	NATS   NATSConfig   `yaml:"nats"`
+
+	// Assets are polled independently, each on its own schedule and
+	// connection
+	Assets []AssetConfig `yaml:"assets"`
+}
+
+// ModbusConfig holds the defaults for every asset
+type ModbusConfig struct {
+	Address      string        `yaml:"address"` // host:port of the device
+	Timeout      time.Duration `yaml:"timeout"` // bounds connecting and each request
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// NATSConfig describes the NATS server data is published to
+type NATSConfig struct {
+	URL string `yaml:"url"`
+
+	// SubjectPrefix must match the -subject-prefix of the EDG Core instance
+	// fed; empty means the default
+	SubjectPrefix string `yaml:"subject_prefix"`
+}
+
+// dataSubject is the subject readings are published to under the prefix
+func (c NATSConfig) dataSubject() string {
+	return core.PrefixSubject(c.SubjectPrefix, core.SubjectDataAsset)
+}
+
+// AssetConfig is a polling group: the tags of one asset, read from one
+// Modbus unit and published together as one AssetData message
+type AssetConfig struct {
+	ID       string        `yaml:"id"`
+	Address  string        `yaml:"address"`  // default modbus.address
+	UnitID   byte          `yaml:"unit_id"`  // default 1
+	Interval time.Duration `yaml:"interval"` // default modbus.poll_interval
+	Tags     []TagConfig   `yaml:"tags"`
+}
+
+// TagConfig maps a register address to a platform tag
+type TagConfig struct {
+	Name     string  `yaml:"name"`
+	Register string  `yaml:"register"` // holding, input, coil or discrete
+	Address  uint16  `yaml:"address"`  // zero-based protocol address
+	Type     string  `yaml:"type"`     // register tags only, default uint16
+	Scale    float64 `yaml:"scale"`    // register tags only, default 1
+	Unit     string  `yaml:"unit"`
+}
+
+// defaultConfig returns the settings used for keys missing from the file
+func defaultConfig() Config {
+	return Config{
+		Modbus: ModbusConfig{
+			Address:      "localhost:502",
+			Timeout:      5 * time.Second,
+			PollInterval: 5 * time.Second,
+		},
+		NATS: NATSConfig{URL: "nats://localhost:4222"},
+	}
+}
+
+// loadConfig reads a YAML configuration file on top of the defaults
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	cfg := defaultConfig()
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validate checks the settings and fills in per-asset and per-tag defaults
+func (c *Config) validate() error {
+	if c.NATS.URL == "" {
+		return fmt.Errorf("nats.url is required")
+	}
+	if c.NATS.SubjectPrefix != "" {
+		if err := core.ValidateSubjectPrefix(c.NATS.SubjectPrefix); err != nil {
+			return fmt.Errorf("nats.subject_prefix: %w", err)
+		}
+	}
+	if c.Modbus.Timeout <= 0 {
+		return fmt.Errorf("modbus.timeout must be positive")
+	}
+	if len(c.Assets) == 0 {
+		return fmt.Errorf("at least one asset is required")
+	}
+
+	seen := make(map[string]bool, len(c.Assets))
+	for i := range c.Assets {
+		asset := &c.Assets[i]
+		if asset.ID == "" {
+			return fmt.Errorf("assets[%d]: id is required", i)
+		}
+		if seen[asset.ID] {
+			return fmt.Errorf("assets[%d]: duplicate id %q", i, asset.ID)
+		}
+		seen[asset.ID] = true
+
+		if asset.Address == "" {
+			asset.Address = c.Modbus.Address
+		}
+		if asset.Address == "" {
+			return fmt.Errorf("asset %s: address is required", asset.ID)
+		}
+		if asset.UnitID == 0 {
+			asset.UnitID = 1
+		}
+		if asset.Interval == 0 {
+			asset.Interval = c.Modbus.PollInterval
+		}
+		if asset.Interval <= 0 {
+			return fmt.Errorf("asset %s: interval must be positive", asset.ID)
+		}
+		if len(asset.Tags) == 0 {
+			return fmt.Errorf("asset %s: at least one tag is required", asset.ID)
+		}
+		for j := range asset.Tags {
+			if err := asset.Tags[j].validate(); err != nil {
+				return fmt.Errorf("asset %s: tags[%d]: %w", asset.ID, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks a tag and fills in its type and scale defaults
+func (t *TagConfig) validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch t.Register {
+	case RegisterCoil, RegisterDiscrete:
+		if t.Type != "" || t.Scale != 0 {
+			return fmt.Errorf("type and scale apply to register tags only")
+		}
+		return nil
+	case RegisterHolding, RegisterInput:
+	default:
+		return fmt.Errorf("unknown register %q (holding|input|coil|discrete)", t.Register)
+	}
+
+	if t.Type == "" {
+		t.Type = TypeUint16
+	}
+	if registerCount(t.Type) == 0 {
+		return fmt.Errorf("unknown type %q (int16|uint16|int32|uint32|float32)", t.Type)
+	}
+	if t.Scale == 0 {
+		t.Scale = 1
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadConfig_Defaults tests that asset and tag defaults are filled in
+func TestLoadConfig_Defaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "modbus.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+modbus:
+  address: plc-1:502
+  poll_interval: 2s
+nats:
+  subject_prefix: site-a
+assets:
+  - id: press-01
+    tags:
+      - {name: temperature, register: holding, address: 100}
+  - id: oven-02
+    address: plc-2:502
+    unit_id: 3
+    interval: 500ms
+    tags:
+      - {name: running, register: coil, address: 0}
+`), 0o644))
+
+	cfg, err := loadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Assets, 2)
+	assert.Equal(t, "nats://localhost:4222", cfg.NATS.URL)
+	assert.Equal(t, "site-a.data.asset", cfg.NATS.dataSubject())
+
+	press := cfg.Assets[0]
+	assert.Equal(t, "plc-1:502", press.Address)
+	assert.Equal(t, byte(1), press.UnitID)
+	assert.Equal(t, 2*time.Second, press.Interval)
+	assert.Equal(t, TypeUint16, press.Tags[0].Type)
+	assert.Equal(t, 1.0, press.Tags[0].Scale)
+
+	oven := cfg.Assets[1]
+	assert.Equal(t, "plc-2:502", oven.Address)
+	assert.Equal(t, byte(3), oven.UnitID)
+	assert.Equal(t, 500*time.Millisecond, oven.Interval)
+}
+
+// TestLoadConfig_Example tests that the shipped example configuration loads
+func TestLoadConfig_Example(t *testing.T) {
+	cfg, err := loadConfig("../../deploy/configs/modbus-adapter/modbus.yaml")
+	require.NoError(t, err)
+	require.Len(t, cfg.Assets, 2)
+	assert.Equal(t, "192.168.1.10:502", cfg.Assets[0].Address)
+	assert.Equal(t, TypeFloat32, cfg.Assets[0].Tags[1].Type)
+	assert.Equal(t, 5*time.Second, cfg.Assets[1].Interval)
+}
+
+// TestConfigValidate_Errors tests rejection of invalid configurations
+func TestConfigValidate_Errors(t *testing.T) {
+	tag := TagConfig{Name: "temperature", Register: RegisterHolding}
+	tests := []struct {
+		name   string
+		assets []AssetConfig
+		want   string
+	}{
+		{"no assets", nil, "at least one asset"},
+		{"missing id", []AssetConfig{{Tags: []TagConfig{tag}}}, "id is required"},
+		{"duplicate id", []AssetConfig{{ID: "a", Tags: []TagConfig{tag}}, {ID: "a", Tags: []TagConfig{tag}}}, "duplicate id"},
+		{"no tags", []AssetConfig{{ID: "a"}}, "at least one tag"},
+		{"unknown register", []AssetConfig{{ID: "a", Tags: []TagConfig{{Name: "x", Register: "memory"}}}}, "unknown register"},
+		{"unknown type", []AssetConfig{{ID: "a", Tags: []TagConfig{{Name: "x", Register: RegisterInput, Type: "int64"}}}}, "unknown type"},
+		{"typed coil", []AssetConfig{{ID: "a", Tags: []TagConfig{{Name: "x", Register: RegisterCoil, Type: TypeInt16}}}}, "register tags only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Assets = tt.assets
+			err := cfg.validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+
+	cfg := defaultConfig()
+	cfg.Assets = []AssetConfig{{ID: "a", Tags: []TagConfig{tag}}}
+	cfg.NATS.SubjectPrefix = "site a"
+	err := cfg.validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nats.subject_prefix")
+}
@@ -0,0 +1,93 @@
+// Command modbus-adapter polls Modbus TCP devices and publishes their
+// registers as asset data on the platform's NATS ingest subject.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+func main() {
+	configPath := flag.String("config", "modbus.yaml", "Path to the adapter configuration file")
+	logFormat := flag.String("log-format", core.LogFormatText, "Log output format (text|json)")
+	logLevel := flag.String("log-level", "info", "Minimum log level (debug|info|warn|error)")
+	flag.Parse()
+
+	logger, err := core.NewLogger(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging flags: %v\n", err)
+		os.Exit(2)
+	}
+	log := logger.With("component", "modbus-adapter")
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fatal(log, "failed to load config", err)
+	}
+
+	nc, err := nats.Connect(cfg.NATS.URL, natsOptions(log)...)
+	if err != nil {
+		fatal(log, "failed to connect to NATS", err)
+	}
+	defer nc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, asset := range cfg.Assets {
+		p := newPoller(asset, cfg.Modbus.Timeout, nc, cfg.NATS.dataSubject(), log)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.run(ctx)
+		}()
+	}
+
+	log.Info("Modbus adapter started",
+		"assets", len(cfg.Assets),
+		"nats_url", cfg.NATS.URL,
+		"subject", cfg.NATS.dataSubject(),
+	)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info("shutting down")
+	cancel()
+	wg.Wait()
+	nc.Drain()
+}
+
+// fatal logs msg with err at error level and exits
+func fatal(log *slog.Logger, msg string, err error) {
+	log.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// natsOptions keep the NATS connection reconnecting indefinitely; publishes
+// made while disconnected are buffered by the client
+func natsOptions(log *slog.Logger) []nats.Option {
+	return []nats.Option{
+		nats.Name("edg-modbus-adapter"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2 * time.Second),
+		nats.RetryOnFailedConnect(true),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			log.Warn("NATS disconnected", "error", err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Info("NATS reconnected", "url", nc.ConnectedUrl())
+		}),
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Modbus function codes used by the adapter
+const (
+	fcReadCoils            byte = 0x01
+	fcReadDiscreteInputs   byte = 0x02
+	fcReadHoldingRegisters byte = 0x03
+	fcReadInputRegisters   byte = 0x04
+)
+
+// Protocol limits on the number of items a single read may request
+const (
+	maxReadBits      = 2000
+	maxReadRegisters = 125
+)
+
+// modbusException is an exception response from the device, e.g. code 2 for
+// an illegal data address. The connection stays usable.
+type modbusException struct {
+	function byte
+	code     byte
+}
+
+func (e *modbusException) Error() string {
+	return fmt.Sprintf("modbus exception %d for function 0x%02x", e.code, e.function)
+}
+
+// modbusClient is a minimal Modbus TCP client. It dials lazily and drops the
+// connection on any transport error, so the next request reconnects. It is
+// not safe for concurrent use.
+type modbusClient struct {
+	address string
+	timeout time.Duration
+
+	conn net.Conn
+	txID uint16
+}
+
+func newModbusClient(address string, timeout time.Duration) *modbusClient {
+	return &modbusClient{address: address, timeout: timeout}
+}
+
+// connected reports whether the client holds an open connection
+func (c *modbusClient) connected() bool {
+	return c.conn != nil
+}
+
+// close drops the connection, if any
+func (c *modbusClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// readRegisters reads count 16-bit registers starting at addr with a holding
+// or input register function code
+func (c *modbusClient) readRegisters(unit, function byte, addr, count uint16) ([]uint16, error) {
+	if count == 0 || count > maxReadRegisters {
+		return nil, fmt.Errorf("register count %d out of range", count)
+	}
+	data, err := c.read(unit, function, addr, count)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != int(count)*2 {
+		c.close()
+		return nil, fmt.Errorf("expected %d register bytes, got %d", count*2, len(data))
+	}
+
+	regs := make([]uint16, count)
+	for i := range regs {
+		regs[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+	return regs, nil
+}
+
+// readBits reads count coils or discrete inputs starting at addr
+func (c *modbusClient) readBits(unit, function byte, addr, count uint16) ([]bool, error) {
+	if count == 0 || count > maxReadBits {
+		return nil, fmt.Errorf("bit count %d out of range", count)
+	}
+	data, err := c.read(unit, function, addr, count)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != (int(count)+7)/8 {
+		c.close()
+		return nil, fmt.Errorf("expected %d bit bytes, got %d", (count+7)/8, len(data))
+	}
+
+	bits := make([]bool, count)
+	for i := range bits {
+		bits[i] = data[i/8]&(1<<(i%8)) != 0
+	}
+	return bits, nil
+}
+
+// read sends a read request and returns the data bytes of the response
+func (c *modbusClient) read(unit, function byte, addr, count uint16) ([]byte, error) {
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", c.address, err)
+		}
+		c.conn = conn
+	}
+
+	c.txID++
+	// MBAP header (transaction, protocol, length, unit) followed by the PDU
+	req := make([]byte, 12)
+	binary.BigEndian.PutUint16(req[0:], c.txID)
+	binary.BigEndian.PutUint16(req[4:], 6)
+	req[6] = unit
+	req[7] = function
+	binary.BigEndian.PutUint16(req[8:], addr)
+	binary.BigEndian.PutUint16(req[10:], count)
+
+	resp, err := c.roundTrip(req)
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+
+	if resp[0] == function|0x80 {
+		if len(resp) < 2 {
+			c.close()
+			return nil, fmt.Errorf("truncated exception response")
+		}
+		return nil, &modbusException{function: function, code: resp[1]}
+	}
+	if resp[0] != function || len(resp) < 2 || int(resp[1]) != len(resp)-2 {
+		c.close()
+		return nil, fmt.Errorf("malformed response to function 0x%02x", function)
+	}
+	return resp[2:], nil
+}
+
+// roundTrip writes a request frame and returns the PDU of the matching
+// response
+func (c *modbusClient) roundTrip(req []byte) ([]byte, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	length := binary.BigEndian.Uint16(header[4:])
+	if length < 2 || length > 254 {
+		return nil, fmt.Errorf("invalid response length %d", length)
+	}
+	pdu := make([]byte, length-1) // the length counts the unit byte
+	if _, err := io.ReadFull(c.conn, pdu); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if txID := binary.BigEndian.Uint16(header[0:]); txID != c.txID {
+		return nil, fmt.Errorf("response transaction %d does not match request %d", txID, c.txID)
+	}
+	return pdu, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// registerCount returns the number of registers a value type spans, or 0
+// for unknown types
+func registerCount(typ string) uint16 {
+	switch typ {
+	case TypeInt16, TypeUint16:
+		return 1
+	case TypeInt32, TypeUint32, TypeFloat32:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// decodeRegisters converts raw registers to a number of the given type
+func decodeRegisters(regs []uint16, typ string) float64 {
+	switch typ {
+	case TypeInt16:
+		return float64(int16(regs[0]))
+	case TypeUint16:
+		return float64(regs[0])
+	}
+
+	raw := uint32(regs[0])<<16 | uint32(regs[1])
+	switch typ {
+	case TypeInt32:
+		return float64(int32(raw))
+	case TypeFloat32:
+		return float64(math.Float32frombits(raw))
+	default:
+		return float64(raw)
+	}
+}
+
+// poller reads the tags of one asset on its interval and publishes them as
+// AssetData on subject
+type poller struct {
+	asset   AssetConfig
+	client  *modbusClient
+	nc      *nats.Conn
+	subject string
+	log     *slog.Logger
+	now     func() time.Time
+
+	lost bool // the last poll failed on the connection
+}
+
+func newPoller(asset AssetConfig, timeout time.Duration, nc *nats.Conn, subject string, log *slog.Logger) *poller {
+	return &poller{
+		asset:   asset,
+		client:  newModbusClient(asset.Address, timeout),
+		nc:      nc,
+		subject: subject,
+		log:     log.With("asset_id", asset.ID, "address", asset.Address),
+		now:     time.Now,
+	}
+}
+
+// run polls immediately and then on every interval until ctx is done
+func (p *poller) run(ctx context.Context) {
+	defer p.client.close()
+
+	ticker := time.NewTicker(p.asset.Interval)
+	defer ticker.Stop()
+	for {
+		p.pollAndPublish()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollAndPublish runs one poll and publishes its result. A failed connection
+// is logged once and retried on the next interval.
+func (p *poller) pollAndPublish() {
+	data, err := p.poll()
+	if err != nil {
+		if !p.lost {
+			p.log.Warn("modbus connection lost, retrying every interval", "error", err)
+			p.lost = true
+		}
+		return
+	}
+	if p.lost {
+		p.log.Info("modbus connection restored")
+		p.lost = false
+	}
+	if len(data.Values) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		p.log.Error("failed to marshal asset data", "error", err)
+		return
+	}
+	if err := p.nc.Publish(p.subject, payload); err != nil {
+		p.log.Error("failed to publish to NATS", "error", err)
+		return
+	}
+	p.log.Debug("published modbus readings", "tag_count", len(data.Values))
+}
+
+// poll reads every tag of the asset. Tags the device rejects with an
+// exception are logged and left out; any other error aborts the poll.
+func (p *poller) poll() (*core.AssetData, error) {
+	data := &core.AssetData{
+		AssetID:   p.asset.ID,
+		Timestamp: p.now().UnixMilli(),
+		Values:    make([]core.TagValue, 0, len(p.asset.Tags)),
+	}
+
+	for _, tag := range p.asset.Tags {
+		value, err := p.readTag(tag)
+		var exc *modbusException
+		if errors.As(err, &exc) {
+			p.log.Warn("failed to read tag", "tag", tag.Name, "register", tag.Register, "address", tag.Address, "error", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data.Values = append(data.Values, value)
+	}
+	return data, nil
+}
+
+// readTag reads one tag: registers become NUMBER values, coils and discrete
+// inputs FLAG values
+func (p *poller) readTag(tag TagConfig) (core.TagValue, error) {
+	value := core.TagValue{Name: tag.Name, Unit: tag.Unit, Quality: core.QualityGood}
+	unit := p.asset.UnitID
+
+	switch tag.Register {
+	case RegisterCoil, RegisterDiscrete:
+		function := fcReadCoils
+		if tag.Register == RegisterDiscrete {
+			function = fcReadDiscreteInputs
+		}
+		bits, err := p.client.readBits(unit, function, tag.Address, 1)
+		if err != nil {
+			return value, err
+		}
+		value.Flag = &bits[0]
+	default:
+		function := fcReadHoldingRegisters
+		if tag.Register == RegisterInput {
+			function = fcReadInputRegisters
+		}
+		regs, err := p.client.readRegisters(unit, function, tag.Address, registerCount(tag.Type))
+		if err != nil {
+			return value, err
+		}
+		number := decodeRegisters(regs, tag.Type) * tag.Scale
+		value.Number = &number
+	}
+	return value, nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// fakeDevice is a Modbus TCP server backed by maps. Reads of unset
+// addresses fail with exception 2 (illegal data address).
+type fakeDevice struct {
+	listener  net.Listener
+	registers map[uint16]uint16 // holding and input registers share one map
+	bits      map[uint16]bool   // coils and discrete inputs share one map
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// startFakeDevice starts a fake device on a random local port
+func startFakeDevice(t *testing.T) *fakeDevice {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	d := &fakeDevice{
+		listener:  l,
+		registers: make(map[uint16]uint16),
+		bits:      make(map[uint16]bool),
+	}
+	go d.serve()
+	t.Cleanup(func() {
+		l.Close()
+		d.dropConnections()
+	})
+	return d
+}
+
+func (d *fakeDevice) address() string {
+	return d.listener.Addr().String()
+}
+
+// dropConnections closes every open client connection
+func (d *fakeDevice) dropConnections() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, conn := range d.conns {
+		conn.Close()
+	}
+	d.conns = nil
+}
+
+func (d *fakeDevice) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		d.mu.Lock()
+		d.conns = append(d.conns, conn)
+		d.mu.Unlock()
+		go d.handle(conn)
+	}
+}
+
+func (d *fakeDevice) handle(conn net.Conn) {
+	req := make([]byte, 12)
+	for {
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		function := req[7]
+		addr := binary.BigEndian.Uint16(req[8:])
+		count := binary.BigEndian.Uint16(req[10:])
+
+		pdu, ok := d.respond(function, addr, count)
+		if !ok {
+			pdu = []byte{function | 0x80, 2}
+		}
+		resp := make([]byte, 7, 7+len(pdu))
+		copy(resp, req[:4])
+		binary.BigEndian.PutUint16(resp[4:], uint16(len(pdu)+1))
+		resp[6] = req[6]
+		if _, err := conn.Write(append(resp, pdu...)); err != nil {
+			return
+		}
+	}
+}
+
+// respond builds the PDU for a read, or reports false for unset addresses
+func (d *fakeDevice) respond(function byte, addr, count uint16) ([]byte, bool) {
+	switch function {
+	case fcReadHoldingRegisters, fcReadInputRegisters:
+		pdu := []byte{function, byte(count * 2)}
+		for i := uint16(0); i < count; i++ {
+			reg, ok := d.registers[addr+i]
+			if !ok {
+				return nil, false
+			}
+			pdu = binary.BigEndian.AppendUint16(pdu, reg)
+		}
+		return pdu, true
+	case fcReadCoils, fcReadDiscreteInputs:
+		data := make([]byte, (count+7)/8)
+		for i := uint16(0); i < count; i++ {
+			bit, ok := d.bits[addr+i]
+			if !ok {
+				return nil, false
+			}
+			if bit {
+				data[i/8] |= 1 << (i % 8)
+			}
+		}
+		return append([]byte{function, byte(len(data))}, data...), true
+	default:
+		return nil, false
+	}
+}
+
+// testPoller creates a poller for asset against device, without NATS
+func testPoller(device *fakeDevice, tags ...TagConfig) *poller {
+	asset := AssetConfig{ID: "press-01", Address: device.address(), UnitID: 1, Interval: time.Second, Tags: tags}
+	return newPoller(asset, time.Second, nil, core.SubjectDataAsset, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// TestDecodeRegisters tests conversion of every value type
+func TestDecodeRegisters(t *testing.T) {
+	f := math.Float32bits(-12.5)
+	tests := []struct {
+		typ  string
+		regs []uint16
+		want float64
+	}{
+		{TypeUint16, []uint16{0xFFFF}, 65535},
+		{TypeInt16, []uint16{0xFFFF}, -1},
+		{TypeUint32, []uint16{0x0001, 0x0000}, 65536},
+		{TypeInt32, []uint16{0xFFFF, 0xFFFE}, -2},
+		{TypeFloat32, []uint16{uint16(f >> 16), uint16(f)}, -12.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			assert.Equal(t, tt.want, decodeRegisters(tt.regs, tt.typ))
+		})
+	}
+}
+
+// TestPoller_Poll tests that registers become NUMBER and bits FLAG values
+func TestPoller_Poll(t *testing.T) {
+	device := startFakeDevice(t)
+	device.registers[100] = 215 // 21.5 °C at scale 0.1
+	f := math.Float32bits(3.25)
+	device.registers[200], device.registers[201] = uint16(f>>16), uint16(f)
+	device.bits[0] = true
+	device.bits[1] = false
+
+	p := testPoller(device,
+		TagConfig{Name: "temperature", Register: RegisterHolding, Address: 100, Type: TypeInt16, Scale: 0.1, Unit: "°C"},
+		TagConfig{Name: "pressure", Register: RegisterInput, Address: 200, Type: TypeFloat32, Scale: 1, Unit: "bar"},
+		TagConfig{Name: "running", Register: RegisterCoil, Address: 0},
+		TagConfig{Name: "fault", Register: RegisterDiscrete, Address: 1},
+		TagConfig{Name: "missing", Register: RegisterHolding, Address: 999, Type: TypeUint16, Scale: 1},
+	)
+	defer p.client.close()
+
+	data, err := p.poll()
+	require.NoError(t, err)
+	assert.Equal(t, "press-01", data.AssetID)
+
+	// The tag the device rejects is left out
+	require.Len(t, data.Values, 4)
+	assert.Equal(t, "temperature", data.Values[0].Name)
+	assert.InDelta(t, 21.5, *data.Values[0].Number, 1e-9)
+	assert.Equal(t, "°C", data.Values[0].Unit)
+	assert.Equal(t, 3.25, *data.Values[1].Number)
+	assert.True(t, *data.Values[2].Flag)
+	assert.False(t, *data.Values[3].Flag)
+	assert.Equal(t, core.QualityGood, data.Values[3].Quality)
+}
+
+// TestPoller_Reconnect tests that a dropped connection is re-dialed on the next poll
+func TestPoller_Reconnect(t *testing.T) {
+	device := startFakeDevice(t)
+	device.registers[0] = 1
+
+	p := testPoller(device, TagConfig{Name: "count", Register: RegisterHolding, Address: 0, Type: TypeUint16, Scale: 1})
+	defer p.client.close()
+
+	_, err := p.poll()
+	require.NoError(t, err)
+	require.True(t, p.client.connected())
+
+	device.dropConnections()
+	_, err = p.poll()
+	require.Error(t, err)
+	assert.False(t, p.client.connected())
+
+	data, err := p.poll()
+	require.NoError(t, err)
+	assert.Len(t, data.Values, 1)
+}
+
+// TestPoller_PublishesToNATS tests that polls are published to platform.data.asset
+func TestPoller_PublishesToNATS(t *testing.T) {
+	ns, err := natsserver.NewServer(&natsserver.Options{Port: -1})
+	require.NoError(t, err)
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(5*time.Second), "NATS server not ready")
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	sub, err := nc.SubscribeSync(core.SubjectDataAsset)
+	require.NoError(t, err)
+
+	device := startFakeDevice(t)
+	device.bits[5] = true
+	p := testPoller(device, TagConfig{Name: "running", Register: RegisterCoil, Address: 5})
+	p.nc = nc
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	var data core.AssetData
+	require.NoError(t, json.Unmarshal(msg.Data, &data))
+	assert.Equal(t, "press-01", data.AssetID)
+	require.Len(t, data.Values, 1)
+	assert.True(t, *data.Values[0].Flag)
+}
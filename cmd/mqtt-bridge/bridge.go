@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/nats-io/nats.go"
+)
+
+// bridge republishes MQTT telemetry as AssetData on the data subject
+type bridge struct {
+	cfg *Config
+	tr  *translator
+	nc  *nats.Conn
+	log *slog.Logger
+}
+
+func newBridge(cfg *Config, nc *nats.Conn, log *slog.Logger) *bridge {
+	return &bridge{
+		cfg: cfg,
+		tr:  &translator{cfg: cfg, now: time.Now},
+		nc:  nc,
+		log: log,
+	}
+}
+
+// mqttOptions builds client options that keep reconnecting and restore the
+// subscription after every (re)connect
+func (b *bridge) mqttOptions() *mqtt.ClientOptions {
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.cfg.MQTT.Broker).
+		SetClientID(b.cfg.MQTT.ClientID).
+		SetUsername(b.cfg.MQTT.Username).
+		SetPassword(b.cfg.MQTT.Password).
+		SetConnectTimeout(b.cfg.MQTT.ConnectTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(30 * time.Second)
+
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		token := c.Subscribe(b.cfg.MQTT.Topic, b.cfg.MQTT.QoS, b.handleMessage)
+		if token.Wait() && token.Error() != nil {
+			b.log.Error("failed to subscribe", "topic", b.cfg.MQTT.Topic, "error", token.Error())
+			return
+		}
+		b.log.Info("subscribed", "broker", b.cfg.MQTT.Broker, "topic", b.cfg.MQTT.Topic)
+	})
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		b.log.Warn("MQTT connection lost", "error", err)
+	})
+	opts.SetReconnectingHandler(func(_ mqtt.Client, _ *mqtt.ClientOptions) {
+		b.log.Info("reconnecting to MQTT broker", "broker", b.cfg.MQTT.Broker)
+	})
+	return opts
+}
+
+// handleMessage translates one MQTT message and publishes it to NATS.
+// Messages that cannot be translated are logged and dropped.
+func (b *bridge) handleMessage(_ mqtt.Client, msg mqtt.Message) {
+	data, err := b.tr.translate(msg.Topic(), msg.Payload())
+	if err != nil {
+		b.log.Warn("dropping MQTT message", "topic", msg.Topic(), "error", err)
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		b.log.Error("failed to marshal asset data", "asset_id", data.AssetID, "error", err)
+		return
+	}
+	if err := b.nc.Publish(b.cfg.NATS.dataSubject(), payload); err != nil {
+		b.log.Error("failed to publish to NATS", "asset_id", data.AssetID, "error", err)
+		return
+	}
+	b.log.Debug("forwarded MQTT message", "topic", msg.Topic(), "asset_id", data.AssetID, "tag_count", len(data.Values))
+}
+
+// natsOptions keep the NATS connection reconnecting indefinitely; publishes
+// made while disconnected are buffered by the client
+func natsOptions(log *slog.Logger) []nats.Option {
+	return []nats.Option{
+		nats.Name("edg-mqtt-bridge"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2 * time.Second),
+		nats.RetryOnFailedConnect(true),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			log.Warn("NATS disconnected", "error", err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Info("NATS reconnected", "url", nc.ConnectedUrl())
+		}),
+	}
+}
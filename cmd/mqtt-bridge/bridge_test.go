@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// startTestBroker starts an embedded NATS server with its MQTT listener
+// enabled, serving as both the MQTT broker and the platform NATS server. It
+// returns the server and the MQTT broker URL.
+func startTestBroker(t *testing.T) (*natsserver.Server, string) {
+	t.Helper()
+
+	// The server does not expose a randomly assigned MQTT port, so pick one
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	mqttPort := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	ns, err := natsserver.NewServer(&natsserver.Options{
+		ServerName: "mqtt-bridge-test",
+		Port:       -1,
+		JetStream:  true, // required by the MQTT listener
+		StoreDir:   t.TempDir(),
+		MQTT:       natsserver.MQTTOpts{Host: "127.0.0.1", Port: mqttPort},
+	})
+	require.NoError(t, err)
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(5*time.Second), "NATS server not ready")
+	t.Cleanup(ns.Shutdown)
+	return ns, fmt.Sprintf("tcp://127.0.0.1:%d", mqttPort)
+}
+
+// TestBridge_ForwardsMQTTToNATS tests the full path from an MQTT publish to platform.data.asset
+func TestBridge_ForwardsMQTTToNATS(t *testing.T) {
+	ns, mqttURL := startTestBroker(t)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+
+	sub, err := nc.SubscribeSync("site-a.data.asset")
+	require.NoError(t, err)
+
+	cfg := defaultConfig()
+	cfg.NATS.SubjectPrefix = "site-a"
+	cfg.MQTT.Broker = mqttURL
+	cfg.Assets = map[string]string{"press-01": "asset-press"}
+	cfg.Tags = map[string]TagMapping{"temp": {Name: "temperature", Unit: "°C"}}
+
+	b := newBridge(&cfg, nc, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	subscribed := make(chan struct{}, 1)
+	opts := b.mqttOptions()
+	onConnect := opts.OnConnect
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		onConnect(c)
+		subscribed <- struct{}{}
+	})
+	client := mqtt.NewClient(opts)
+	require.NoError(t, waitToken(client.Connect()))
+	t.Cleanup(func() { client.Disconnect(100) })
+
+	select {
+	case <-subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("bridge did not subscribe")
+	}
+
+	device := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(mqttURL).SetClientID("device"))
+	require.NoError(t, waitToken(device.Connect()))
+	t.Cleanup(func() { device.Disconnect(100) })
+
+	require.NoError(t, waitToken(device.Publish("plant/press-01/telemetry", 1, false, `{"temp": 21.5}`)))
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+
+	var data core.AssetData
+	require.NoError(t, json.Unmarshal(msg.Data, &data))
+	assert.Equal(t, "asset-press", data.AssetID)
+	assert.NotZero(t, data.Timestamp)
+	require.Len(t, data.Values, 1)
+	assert.Equal(t, "temperature", data.Values[0].Name)
+	assert.Equal(t, 21.5, *data.Values[0].Number)
+	assert.Equal(t, "°C", data.Values[0].Unit)
+
+	// Malformed payloads are dropped without stopping the bridge
+	require.NoError(t, waitToken(device.Publish("plant/press-01/telemetry", 1, false, `not json`)))
+	require.NoError(t, waitToken(device.Publish("plant/oven-02/telemetry", 1, false, `{"temp": 180}`)))
+
+	msg, err = sub.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(msg.Data, &data))
+	assert.Equal(t, "oven-02", data.AssetID)
+}
+
+// waitToken waits for an MQTT operation to complete
+func waitToken(token mqtt.Token) error {
+	if !token.WaitTimeout(5 * time.Second) {
+		return fmt.Errorf("timed out waiting for MQTT operation")
+	}
+	return token.Error()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// Config is the bridge configuration file
+type Config struct {
+	MQTT MQTTConfig `yaml:"mqtt"`
+	NATS NATSConfig `yaml:"nats"`
+
+	// Assets maps the topic segments matched by the wildcards of MQTT.Topic,
+	// joined with "/", to asset IDs. Unmapped topics use the joined segments
+	// as the asset ID.
+	Assets map[string]string `yaml:"assets"`
+
+	// Tags renames device tag names and supplies a unit when the device
+	// sends none
+	Tags map[string]TagMapping `yaml:"tags"`
+
+	// Units translates device unit symbols, e.g. "C" to "°C"
+	Units map[string]string `yaml:"units"`
+}
+
+// MQTTConfig describes the MQTT broker and subscription
+type MQTTConfig struct {
+	Broker   string `yaml:"broker"`
+	ClientID string `yaml:"client_id"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Topic    string `yaml:"topic"` // subscription with + or # wildcards, e.g. plant/+/telemetry
+	QoS      byte   `yaml:"qos"`
+
+	// ConnectTimeout bounds each connection attempt
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+}
+
+// NATSConfig describes the NATS server data is republished to
+type NATSConfig struct {
+	URL string `yaml:"url"`
+
+	// SubjectPrefix must match the -subject-prefix of the EDG Core instance
+	// fed; empty means the default
+	SubjectPrefix string `yaml:"subject_prefix"`
+}
+
+// dataSubject is the subject readings are published to under the prefix
+func (c NATSConfig) dataSubject() string {
+	return core.PrefixSubject(c.SubjectPrefix, core.SubjectDataAsset)
+}
+
+// TagMapping translates one device tag
+type TagMapping struct {
+	Name string `yaml:"name"`
+	Unit string `yaml:"unit"`
+}
+
+// defaultConfig returns the settings used for keys missing from the file
+func defaultConfig() Config {
+	return Config{
+		MQTT: MQTTConfig{
+			Broker:         "tcp://localhost:1883",
+			ClientID:       "edg-mqtt-bridge",
+			Topic:          "plant/+/telemetry",
+			QoS:            1,
+			ConnectTimeout: 10 * time.Second,
+		},
+		NATS: NATSConfig{URL: "nats://localhost:4222"},
+	}
+}
+
+// loadConfig reads a YAML configuration file on top of the defaults
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	cfg := defaultConfig()
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validate checks the settings the bridge cannot run without
+func (c *Config) validate() error {
+	if c.MQTT.Broker == "" {
+		return fmt.Errorf("mqtt.broker is required")
+	}
+	if err := validateTopicFilter(c.MQTT.Topic); err != nil {
+		return fmt.Errorf("mqtt.topic: %w", err)
+	}
+	if c.MQTT.QoS > 2 {
+		return fmt.Errorf("mqtt.qos must be 0, 1 or 2")
+	}
+	if c.NATS.URL == "" {
+		return fmt.Errorf("nats.url is required")
+	}
+	if c.NATS.SubjectPrefix != "" {
+		if err := core.ValidateSubjectPrefix(c.NATS.SubjectPrefix); err != nil {
+			return fmt.Errorf("nats.subject_prefix: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Command mqtt-bridge subscribes to MQTT telemetry and republishes it as
+// asset data on the platform's NATS ingest subject.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+func main() {
+	configPath := flag.String("config", "bridge.yaml", "Path to the bridge configuration file")
+	logFormat := flag.String("log-format", core.LogFormatText, "Log output format (text|json)")
+	logLevel := flag.String("log-level", "info", "Minimum log level (debug|info|warn|error)")
+	flag.Parse()
+
+	logger, err := core.NewLogger(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging flags: %v\n", err)
+		os.Exit(2)
+	}
+	log := logger.With("component", "mqtt-bridge")
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fatal(log, "failed to load config", err)
+	}
+
+	nc, err := nats.Connect(cfg.NATS.URL, natsOptions(log)...)
+	if err != nil {
+		fatal(log, "failed to connect to NATS", err)
+	}
+	defer nc.Close()
+
+	b := newBridge(cfg, nc, log)
+	client := mqtt.NewClient(b.mqttOptions())
+	// Connection attempts are retried in the background until the broker is
+	// reachable; the subscription is made from the connect handler
+	client.Connect()
+
+	log.Info("MQTT bridge started",
+		"broker", cfg.MQTT.Broker,
+		"topic", cfg.MQTT.Topic,
+		"nats_url", cfg.NATS.URL,
+		"subject", cfg.NATS.dataSubject(),
+	)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info("shutting down")
+	client.Disconnect(250)
+	nc.Drain()
+}
+
+// fatal logs msg with err at error level and exits
+func fatal(log *slog.Logger, msg string, err error) {
+	log.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// validateTopicFilter checks MQTT wildcard placement: + must fill a whole
+// level and # must be the last level
+func validateTopicFilter(filter string) error {
+	if filter == "" {
+		return fmt.Errorf("topic is required")
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "+") && level != "+" {
+			return fmt.Errorf("'+' must occupy a whole topic level")
+		}
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return fmt.Errorf("'#' must be the last topic level")
+		}
+	}
+	return nil
+}
+
+// matchTopic matches topic against filter and returns the levels captured by
+// its wildcards; # captures every remaining level
+func matchTopic(filter, topic string) ([]string, bool) {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	var captured []string
+	for i, level := range filterLevels {
+		if level == "#" {
+			return append(captured, topicLevels[i:]...), true
+		}
+		if i >= len(topicLevels) {
+			return nil, false
+		}
+		switch level {
+		case "+":
+			captured = append(captured, topicLevels[i])
+		case topicLevels[i]:
+		default:
+			return nil, false
+		}
+	}
+	if len(topicLevels) != len(filterLevels) {
+		return nil, false
+	}
+	return captured, true
+}
+
+// translator turns MQTT messages into AssetData
+type translator struct {
+	cfg *Config
+	now func() time.Time
+}
+
+// assetID derives the asset ID for a topic
+func (t *translator) assetID(topic string) (string, error) {
+	captured, ok := matchTopic(t.cfg.MQTT.Topic, topic)
+	if !ok {
+		return "", fmt.Errorf("topic %q does not match %q", topic, t.cfg.MQTT.Topic)
+	}
+	key := strings.Join(captured, "/")
+	if id, ok := t.cfg.Assets[key]; ok {
+		return id, nil
+	}
+	if key == "" {
+		return "", fmt.Errorf("topic %q has no wildcard levels and no asset mapping", topic)
+	}
+	return key, nil
+}
+
+// translate parses a payload into AssetData for the asset behind topic.
+// The payload is either AssetData JSON (an object with "values") or a flat
+// object of tag names to numbers, strings or booleans, with an optional
+// "timestamp". Missing timestamps are set to the time of receipt.
+func (t *translator) translate(topic string, payload []byte) (*core.AssetData, error) {
+	assetID, err := t.assetID(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+
+	data := &core.AssetData{}
+	if _, ok := fields["values"]; ok {
+		if err := json.Unmarshal(payload, data); err != nil {
+			return nil, fmt.Errorf("invalid asset data: %w", err)
+		}
+	} else {
+		data, err = parseFlatPayload(fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data.AssetID = assetID
+	if data.Timestamp == 0 {
+		data.Timestamp = t.now().UnixMilli()
+	}
+	for i := range data.Values {
+		t.translateTag(&data.Values[i])
+	}
+	return data, nil
+}
+
+// translateTag applies the tag and unit translation tables
+func (t *translator) translateTag(v *core.TagValue) {
+	if mapping, ok := t.cfg.Tags[v.Name]; ok {
+		if mapping.Name != "" {
+			v.Name = mapping.Name
+		}
+		if v.Unit == "" {
+			v.Unit = mapping.Unit
+		}
+	}
+	if unit, ok := t.cfg.Units[v.Unit]; ok {
+		v.Unit = unit
+	}
+	if v.Quality == "" {
+		v.Quality = core.QualityGood
+	}
+}
+
+// parseFlatPayload converts {"tag": value, ...} into AssetData, sorted by tag
+// name for stable output
+func parseFlatPayload(fields map[string]json.RawMessage) (*core.AssetData, error) {
+	data := &core.AssetData{}
+	for name, raw := range fields {
+		if name == "timestamp" {
+			if err := json.Unmarshal(raw, &data.Timestamp); err != nil {
+				return nil, fmt.Errorf("invalid timestamp: %w", err)
+			}
+			continue
+		}
+
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+		tag := core.TagValue{Name: name}
+		switch v := value.(type) {
+		case float64:
+			tag.Number = &v
+		case string:
+			tag.Text = &v
+		case bool:
+			tag.Flag = &v
+		default:
+			return nil, fmt.Errorf("unsupported value for %s: expected number, string or boolean", name)
+		}
+		data.Values = append(data.Values, tag)
+	}
+
+	sort.Slice(data.Values, func(i, j int) bool { return data.Values[i].Name < data.Values[j].Name })
+	return data, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// TestMatchTopic tests wildcard matching and capture
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		captured      []string
+		ok            bool
+	}{
+		{"plant/+/telemetry", "plant/press-01/telemetry", []string{"press-01"}, true},
+		{"plant/+/telemetry", "plant/press-01/status", nil, false},
+		{"plant/+/telemetry", "plant/press-01/telemetry/extra", nil, false},
+		{"plant/+/+/data", "plant/line-1/press-01/data", []string{"line-1", "press-01"}, true},
+		{"devices/#", "devices/a/b", []string{"a", "b"}, true},
+		{"devices/#", "devices", nil, true}, // # also matches the parent level
+		{"sensors/press", "sensors/press", nil, true},
+	}
+	for _, tt := range tests {
+		captured, ok := matchTopic(tt.filter, tt.topic)
+		assert.Equal(t, tt.ok, ok, "%s ~ %s", tt.filter, tt.topic)
+		assert.Equal(t, tt.captured, captured, "%s ~ %s", tt.filter, tt.topic)
+	}
+
+	assert.NoError(t, validateTopicFilter("plant/+/telemetry"))
+	assert.Error(t, validateTopicFilter("plant/#/telemetry"))
+	assert.Error(t, validateTopicFilter("plant/press+/telemetry"))
+	assert.Error(t, validateTopicFilter(""))
+}
+
+func testTranslator() *translator {
+	now := time.UnixMilli(1768467600000)
+	return &translator{
+		cfg: &Config{
+			MQTT:   MQTTConfig{Topic: "plant/+/telemetry"},
+			Assets: map[string]string{"press-01": "asset-press"},
+			Tags: map[string]TagMapping{
+				"temp": {Name: "temperature", Unit: "°C"},
+				"rh":   {Name: "humidity"},
+			},
+			Units: map[string]string{"pct": "%", "F": "°F"},
+		},
+		now: func() time.Time { return now },
+	}
+}
+
+// TestTranslate_FlatPayload tests flat JSON objects, tag renaming and default units
+func TestTranslate_FlatPayload(t *testing.T) {
+	tr := testTranslator()
+
+	data, err := tr.translate("plant/press-01/telemetry", []byte(`{"temp": 21.5, "rh": 40, "mode": "auto", "running": true}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "asset-press", data.AssetID)
+	assert.Equal(t, int64(1768467600000), data.Timestamp)
+	require.Len(t, data.Values, 4)
+
+	// Sorted by original tag name, then renamed
+	assert.Equal(t, "mode", data.Values[0].Name)
+	assert.Equal(t, "auto", *data.Values[0].Text)
+	assert.Equal(t, "humidity", data.Values[1].Name)
+	assert.Empty(t, data.Values[1].Unit)
+	assert.Equal(t, "running", data.Values[2].Name)
+	assert.True(t, *data.Values[2].Flag)
+	assert.Equal(t, "temperature", data.Values[3].Name)
+	assert.Equal(t, 21.5, *data.Values[3].Number)
+	assert.Equal(t, "°C", data.Values[3].Unit)
+	assert.Equal(t, core.QualityGood, data.Values[3].Quality)
+}
+
+// TestTranslate_AssetDataPayload tests AssetData payloads, unit translation and unmapped topics
+func TestTranslate_AssetDataPayload(t *testing.T) {
+	tr := testTranslator()
+
+	data, err := tr.translate("plant/oven-02/telemetry", []byte(`{
+		"asset_id": "ignored",
+		"timestamp": 1768467000000,
+		"values": [
+			{"name": "rh", "number": 55, "unit": "pct", "quality": "uncertain"},
+			{"name": "temp", "number": 80, "unit": "F"}
+		]
+	}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "oven-02", data.AssetID)
+	assert.Equal(t, int64(1768467000000), data.Timestamp)
+	require.Len(t, data.Values, 2)
+	assert.Equal(t, "humidity", data.Values[0].Name)
+	assert.Equal(t, "%", data.Values[0].Unit)
+	assert.Equal(t, core.QualityUncertain, data.Values[0].Quality)
+	assert.Equal(t, "temperature", data.Values[1].Name)
+	assert.Equal(t, "°F", data.Values[1].Unit)
+}
+
+// TestTranslate_Errors tests rejection of unusable messages
+func TestTranslate_Errors(t *testing.T) {
+	tr := testTranslator()
+
+	_, err := tr.translate("other/press-01", []byte(`{"temp": 1}`))
+	assert.ErrorContains(t, err, "does not match")
+
+	_, err = tr.translate("plant/press-01/telemetry", []byte(`[1, 2]`))
+	assert.ErrorContains(t, err, "not a JSON object")
+
+	_, err = tr.translate("plant/press-01/telemetry", []byte(`{"temp": [1, 2]}`))
+	assert.ErrorContains(t, err, "unsupported value for temp")
+}
+
+// TestLoadConfig tests defaults and validation of the configuration file
+func TestLoadConfig(t *testing.T) {
+	cfg, err := loadConfig("../../deploy/configs/mqtt-bridge/bridge.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "plant/+/telemetry", cfg.MQTT.Topic)
+	assert.Equal(t, "temperature", cfg.Tags["temp"].Name)
+	assert.Equal(t, "°C", cfg.Units["C"])
+
+	path := filepath.Join(t.TempDir(), "bridge.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("mqtt:\n  topic: site/#\n"), 0o644))
+	cfg, err = loadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "tcp://localhost:1883", cfg.MQTT.Broker)
+	assert.Equal(t, byte(1), cfg.MQTT.QoS)
+	assert.Equal(t, 10*time.Second, cfg.MQTT.ConnectTimeout)
+
+	require.NoError(t, os.WriteFile(path, []byte("mqtt:\n  topic: site/#/x\n"), 0o644))
+	_, err = loadConfig(path)
+	assert.ErrorContains(t, err, "mqtt.topic")
+}
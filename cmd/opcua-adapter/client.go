@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+)
+
+// credentials are the application instance certificate and key of the
+// adapter
+type credentials struct {
+	certificate []byte // DER
+	key         *rsa.PrivateKey
+}
+
+// loadCredentials reads the certificate (PEM or DER) and RSA private key
+// (PKCS #1 or PKCS #8 PEM) named in the security configuration
+func loadCredentials(cfg SecurityConfig) (*credentials, error) {
+	certData, err := os.ReadFile(cfg.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	if block, _ := pem.Decode(certData); block != nil {
+		certData = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(certData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	keyData, err := os.ReadFile(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM encoded")
+	}
+	key, err := parseRSAKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, fmt.Errorf("private key does not match the certificate")
+	}
+	return &credentials{certificate: certData, key: key}, nil
+}
+
+func parseRSAKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// loadCertificate reads a PEM or DER certificate and returns it as DER
+func loadCertificate(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server certificate: %w", err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	if _, err := x509.ParseCertificate(data); err != nil {
+		return nil, fmt.Errorf("failed to parse server certificate: %w", err)
+	}
+	return data, nil
+}
+
+// serverTrust decides which server certificates a secured channel is opened
+// to: the pinned certificate, or any certificate issued by one of roots
+type serverTrust struct {
+	pinned []byte // DER
+	roots  *x509.CertPool
+}
+
+// loadServerTrust reads the pinned server certificate or the CA
+// certificates named in the security configuration
+func loadServerTrust(cfg SecurityConfig) (*serverTrust, error) {
+	if cfg.ServerCertificate != "" {
+		cert, err := loadCertificate(cfg.ServerCertificate)
+		if err != nil {
+			return nil, err
+		}
+		return &serverTrust{pinned: cert}, nil
+	}
+
+	data, err := os.ReadFile(cfg.ServerCA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server CA: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("server CA holds no PEM certificate")
+	}
+	return &serverTrust{roots: roots}, nil
+}
+
+// verify checks that der, the certificate a server presents, is trusted
+func (t *serverTrust) verify(der []byte) error {
+	if len(der) == 0 {
+		return fmt.Errorf("server presents no certificate")
+	}
+	if t.pinned != nil {
+		if !bytes.Equal(der, t.pinned) {
+			return fmt.Errorf("server certificate does not match the pinned certificate")
+		}
+		return nil
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("failed to parse server certificate: %w", err)
+	}
+	opts := x509.VerifyOptions{Roots: t.roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+	if _, err := cert.Verify(opts); err != nil {
+		return fmt.Errorf("server certificate is not trusted: %w", err)
+	}
+	return nil
+}
+
+// newClient returns an unconnected client for the endpoint of cfg whose
+// connection state changes are sent to state. The endpoint description,
+// which carries the server certificate and the user token policies, is
+// fetched first. With a security policy the certificate must be trusted:
+// the channel handshake is encrypted to it, so a server without its private
+// key cannot complete the handshake.
+func newClient(ctx context.Context, cfg OPCUAConfig, creds *credentials, trust *serverTrust, state chan<- opcua.ConnState) (*opcua.Client, error) {
+	endpoints, err := opcua.GetEndpoints(ctx, cfg.Endpoint,
+		opcua.DialTimeout(cfg.Timeout), opcua.RequestTimeout(cfg.Timeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoints: %w", err)
+	}
+	policy := securityPolicies[cfg.Security.Policy]
+	endpoint, err := opcua.SelectEndpoint(endpoints, policy, securityModes[cfg.Security.Mode])
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []opcua.Option{
+		opcua.AutoReconnect(false),
+		opcua.DialTimeout(cfg.Timeout),
+		opcua.RequestTimeout(cfg.Timeout),
+		opcua.StateChangedCh(state),
+	}
+	authType := ua.UserTokenTypeAnonymous
+	if cfg.Username != "" {
+		authType = ua.UserTokenTypeUserName
+		opts = append(opts, opcua.AuthUsername(cfg.Username, cfg.Password))
+	} else {
+		opts = append(opts, opcua.AuthAnonymous())
+	}
+	opts = append(opts, opcua.SecurityFromEndpoint(endpoint, authType))
+
+	if policy != ua.SecurityPolicyURINone {
+		if err := trust.verify(endpoint.ServerCertificate); err != nil {
+			return nil, err
+		}
+		opts = append(opts, opcua.Certificate(creds.certificate), opcua.PrivateKey(creds.key))
+	}
+	return opcua.NewClient(cfg.Endpoint, opts...)
+}
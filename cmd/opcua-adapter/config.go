@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"gopkg.in/yaml.v3"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// Security policies accepted in the configuration
+const (
+	PolicyNone           = "None"
+	PolicyBasic256Sha256 = "Basic256Sha256"
+)
+
+// Message security modes accepted in the configuration
+const (
+	ModeNone           = "None"
+	ModeSign           = "Sign"
+	ModeSignAndEncrypt = "SignAndEncrypt"
+)
+
+// securityPolicies maps the policies accepted in the configuration to URIs
+var securityPolicies = map[string]string{
+	PolicyNone:           ua.SecurityPolicyURINone,
+	PolicyBasic256Sha256: ua.SecurityPolicyURIBasic256Sha256,
+}
+
+// securityModes maps the modes accepted in the configuration to modes
+var securityModes = map[string]ua.MessageSecurityMode{
+	ModeNone:           ua.MessageSecurityModeNone,
+	ModeSign:           ua.MessageSecurityModeSign,
+	ModeSignAndEncrypt: ua.MessageSecurityModeSignAndEncrypt,
+}
+
+// Config is the adapter configuration file
+type Config struct {
+	OPCUA OPCUAConfig `yaml:"opcua"`
+	NATS  NATSConfig  `yaml:"nats"`
+
+	// Assets group the subscribed nodes; the changes of one asset reported
+	// together are published as one AssetData message
+	Assets []AssetConfig `yaml:"assets"`
+}
+
+// OPCUAConfig describes the server and the subscription
+type OPCUAConfig struct {
+	Endpoint           string        `yaml:"endpoint"`            // opc.tcp://host[:port][/path]
+	Timeout            time.Duration `yaml:"timeout"`             // bounds connecting and each request
+	PublishingInterval time.Duration `yaml:"publishing_interval"` // how often the server reports changes
+	SamplingInterval   time.Duration `yaml:"sampling_interval"`   // how often the server samples nodes, default publishing_interval
+	ReconnectInterval  time.Duration `yaml:"reconnect_interval"`
+
+	Security SecurityConfig `yaml:"security"`
+
+	// Username and Password select user name authentication; anonymous
+	// otherwise
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// SecurityConfig selects the secure channel security
+type SecurityConfig struct {
+	Policy      string `yaml:"policy"`      // None (default) or Basic256Sha256
+	Mode        string `yaml:"mode"`        // None, Sign or SignAndEncrypt (default with a policy)
+	Certificate string `yaml:"certificate"` // client certificate, PEM or DER
+	PrivateKey  string `yaml:"private_key"` // RSA private key of the certificate, PEM
+
+	// ServerCertificate pins the server certificate, PEM or DER. ServerCA
+	// instead trusts any server certificate issued by one of its PEM
+	// certificates. A security policy requires exactly one of them; the
+	// channel is never opened to a server whose certificate is not trusted.
+	ServerCertificate string `yaml:"server_certificate"`
+	ServerCA          string `yaml:"server_ca"`
+}
+
+// NATSConfig describes the NATS server data is published to
+type NATSConfig struct {
+	URL string `yaml:"url"`
+
+	// SubjectPrefix must match the -subject-prefix of the EDG Core instance
+	// fed; empty means the default
+	SubjectPrefix string `yaml:"subject_prefix"`
+}
+
+// dataSubject is the subject readings are published to under the prefix
+func (c NATSConfig) dataSubject() string {
+	return core.PrefixSubject(c.SubjectPrefix, core.SubjectDataAsset)
+}
+
+// AssetConfig maps the nodes of one asset to its tags
+type AssetConfig struct {
+	ID    string       `yaml:"id"`
+	Nodes []NodeConfig `yaml:"nodes"`
+}
+
+// NodeConfig maps a node to a platform tag
+type NodeConfig struct {
+	NodeID    string `yaml:"node_id"`    // e.g. ns=2;s=Press01.Temperature
+	Name      string `yaml:"name"`       // tag name
+	ValueType string `yaml:"value_type"` // NUMBER, TEXT or FLAG; from the OPC-UA data type when empty
+	Unit      string `yaml:"unit"`
+
+	node *ua.NodeID // parsed NodeID
+}
+
+// defaultConfig returns the settings used for keys missing from the file
+func defaultConfig() Config {
+	return Config{
+		OPCUA: OPCUAConfig{
+			Endpoint:           "opc.tcp://localhost:4840",
+			Timeout:            10 * time.Second,
+			PublishingInterval: time.Second,
+			ReconnectInterval:  5 * time.Second,
+			Security:           SecurityConfig{Policy: PolicyNone},
+		},
+		NATS: NATSConfig{URL: "nats://localhost:4222"},
+	}
+}
+
+// loadConfig reads a YAML configuration file on top of the defaults
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	cfg := defaultConfig()
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validate checks the settings and parses node ids
+func (c *Config) validate() error {
+	if c.NATS.URL == "" {
+		return fmt.Errorf("nats.url is required")
+	}
+	if c.NATS.SubjectPrefix != "" {
+		if err := core.ValidateSubjectPrefix(c.NATS.SubjectPrefix); err != nil {
+			return fmt.Errorf("nats.subject_prefix: %w", err)
+		}
+	}
+	if c.OPCUA.Endpoint == "" {
+		return fmt.Errorf("opcua.endpoint is required")
+	}
+	if c.OPCUA.Timeout <= 0 {
+		return fmt.Errorf("opcua.timeout must be positive")
+	}
+	if c.OPCUA.PublishingInterval <= 0 {
+		return fmt.Errorf("opcua.publishing_interval must be positive")
+	}
+	if c.OPCUA.SamplingInterval < 0 {
+		return fmt.Errorf("opcua.sampling_interval must not be negative")
+	}
+	if c.OPCUA.SamplingInterval == 0 {
+		c.OPCUA.SamplingInterval = c.OPCUA.PublishingInterval
+	}
+	if c.OPCUA.ReconnectInterval <= 0 {
+		return fmt.Errorf("opcua.reconnect_interval must be positive")
+	}
+	if c.OPCUA.Password != "" && c.OPCUA.Username == "" {
+		return fmt.Errorf("opcua.password requires opcua.username")
+	}
+	if err := c.OPCUA.Security.validate(); err != nil {
+		return err
+	}
+	if len(c.Assets) == 0 {
+		return fmt.Errorf("at least one asset is required")
+	}
+
+	seen := make(map[string]bool, len(c.Assets))
+	for i := range c.Assets {
+		asset := &c.Assets[i]
+		if asset.ID == "" {
+			return fmt.Errorf("assets[%d]: id is required", i)
+		}
+		if seen[asset.ID] {
+			return fmt.Errorf("assets[%d]: duplicate id %q", i, asset.ID)
+		}
+		seen[asset.ID] = true
+
+		if len(asset.Nodes) == 0 {
+			return fmt.Errorf("asset %s: at least one node is required", asset.ID)
+		}
+		tags := make(map[string]bool, len(asset.Nodes))
+		for j := range asset.Nodes {
+			node := &asset.Nodes[j]
+			if err := node.validate(); err != nil {
+				return fmt.Errorf("asset %s: nodes[%d]: %w", asset.ID, j, err)
+			}
+			if tags[node.Name] {
+				return fmt.Errorf("asset %s: nodes[%d]: duplicate tag %q", asset.ID, j, node.Name)
+			}
+			tags[node.Name] = true
+		}
+	}
+	return nil
+}
+
+// validate checks the policy and mode and fills in the mode default
+func (s *SecurityConfig) validate() error {
+	if _, ok := securityPolicies[s.Policy]; !ok {
+		return fmt.Errorf("unknown opcua.security.policy %q (None|Basic256Sha256)", s.Policy)
+	}
+	if s.Mode == "" {
+		s.Mode = ModeSignAndEncrypt
+		if s.Policy == PolicyNone {
+			s.Mode = ModeNone
+		}
+	}
+	if _, ok := securityModes[s.Mode]; !ok {
+		return fmt.Errorf("unknown opcua.security.mode %q (None|Sign|SignAndEncrypt)", s.Mode)
+	}
+
+	if s.Policy == PolicyNone {
+		if s.Mode != ModeNone {
+			return fmt.Errorf("opcua.security.mode %s requires a security policy", s.Mode)
+		}
+		return nil
+	}
+	if s.Mode == ModeNone {
+		return fmt.Errorf("opcua.security.policy %s requires mode Sign or SignAndEncrypt", s.Policy)
+	}
+	if s.Certificate == "" || s.PrivateKey == "" {
+		return fmt.Errorf("opcua.security.certificate and private_key are required with policy %s", s.Policy)
+	}
+	if (s.ServerCertificate == "") == (s.ServerCA == "") {
+		return fmt.Errorf("opcua.security requires one of server_certificate and server_ca with policy %s", s.Policy)
+	}
+	return nil
+}
+
+// validate checks a node mapping and parses its node id
+func (n *NodeConfig) validate() error {
+	if n.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch n.ValueType {
+	case "", core.ValueTypeNumber, core.ValueTypeText, core.ValueTypeFlag:
+	default:
+		return fmt.Errorf("unknown value_type %q (NUMBER|TEXT|FLAG)", n.ValueType)
+	}
+	node, err := ua.ParseNodeID(n.NodeID)
+	if err != nil {
+		return fmt.Errorf("invalid node id %q: %w", n.NodeID, err)
+	}
+	n.node = node
+	return nil
+}
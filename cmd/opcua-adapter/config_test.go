@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// TestLoadConfig_Defaults tests that settings missing from the file are defaulted
+func TestLoadConfig_Defaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "opcua.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+opcua:
+  endpoint: opc.tcp://plc-1:4840
+  publishing_interval: 2s
+assets:
+  - id: press-01
+    nodes:
+      - {node_id: "ns=2;s=Press01.Temperature", name: temperature}
+`), 0o644))
+
+	cfg, err := loadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, cfg.OPCUA.Timeout)
+	assert.Equal(t, 2*time.Second, cfg.OPCUA.SamplingInterval)
+	assert.Equal(t, 5*time.Second, cfg.OPCUA.ReconnectInterval)
+	assert.Equal(t, PolicyNone, cfg.OPCUA.Security.Policy)
+	assert.Equal(t, ModeNone, cfg.OPCUA.Security.Mode)
+	assert.Equal(t, "nats://localhost:4222", cfg.NATS.URL)
+	assert.Equal(t, core.SubjectDataAsset, cfg.NATS.dataSubject())
+
+	node := cfg.Assets[0].Nodes[0].node
+	assert.Equal(t, uint16(2), node.Namespace())
+	assert.Equal(t, "Press01.Temperature", node.StringID())
+}
+
+// TestLoadConfig_Example tests that the shipped example configuration loads
+func TestLoadConfig_Example(t *testing.T) {
+	cfg, err := loadConfig("../../deploy/configs/opcua-adapter/opcua.yaml")
+	require.NoError(t, err)
+	require.Len(t, cfg.Assets, 2)
+	assert.Equal(t, PolicyBasic256Sha256, cfg.OPCUA.Security.Policy)
+	assert.Equal(t, 500*time.Millisecond, cfg.OPCUA.SamplingInterval)
+	assert.Equal(t, uint32(1002), cfg.Assets[1].Nodes[1].node.IntID())
+}
+
+// TestConfigValidate_Errors tests rejection of invalid configurations
+func TestConfigValidate_Errors(t *testing.T) {
+	node := NodeConfig{NodeID: "i=2258", Name: "time"}
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"no assets", func(c *Config) { c.Assets = nil }, "at least one asset"},
+		{"missing id", func(c *Config) { c.Assets[0].ID = "" }, "id is required"},
+		{"duplicate id", func(c *Config) { c.Assets = append(c.Assets, c.Assets[0]) }, "duplicate id"},
+		{"no nodes", func(c *Config) { c.Assets[0].Nodes = nil }, "at least one node"},
+		{"duplicate tag", func(c *Config) { c.Assets[0].Nodes = []NodeConfig{node, node} }, "duplicate tag"},
+		{"missing name", func(c *Config) { c.Assets[0].Nodes[0].Name = "" }, "name is required"},
+		{"bad node id", func(c *Config) { c.Assets[0].Nodes[0].NodeID = "ns=x;i=1" }, "node id"},
+		{"unknown value type", func(c *Config) { c.Assets[0].Nodes[0].ValueType = "BLOB" }, "unknown value_type"},
+		{"password without user", func(c *Config) { c.OPCUA.Password = "secret" }, "requires opcua.username"},
+		{"unknown policy", func(c *Config) { c.OPCUA.Security.Policy = "Basic128Rsa15" }, "unknown opcua.security.policy"},
+		{"mode without policy", func(c *Config) { c.OPCUA.Security.Mode = ModeSign }, "requires a security policy"},
+		{"policy without certificate", func(c *Config) { c.OPCUA.Security.Policy = PolicyBasic256Sha256 }, "certificate and private_key are required"},
+		{"policy without server trust", func(c *Config) {
+			c.OPCUA.Security = SecurityConfig{Policy: PolicyBasic256Sha256, Certificate: "client.pem", PrivateKey: "client.key"}
+		}, "one of server_certificate and server_ca"},
+		{"pinned certificate and CA", func(c *Config) {
+			c.OPCUA.Security = SecurityConfig{Policy: PolicyBasic256Sha256, Certificate: "client.pem", PrivateKey: "client.key",
+				ServerCertificate: "server.der", ServerCA: "ca.pem"}
+		}, "one of server_certificate and server_ca"},
+		{"zero publishing interval", func(c *Config) { c.OPCUA.PublishingInterval = 0 }, "publishing_interval"},
+		{"wildcard subject prefix", func(c *Config) { c.NATS.SubjectPrefix = "site.*" }, "nats.subject_prefix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Assets = []AssetConfig{{ID: "press-01", Nodes: []NodeConfig{node}}}
+			tt.modify(&cfg)
+			err := cfg.validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
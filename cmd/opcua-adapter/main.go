@@ -0,0 +1,105 @@
+// Command opcua-adapter subscribes to nodes of an OPC-UA server and
+// publishes their value changes as asset data on the platform's NATS ingest
+// subject.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+func main() {
+	configPath := flag.String("config", "opcua.yaml", "Path to the adapter configuration file")
+	logFormat := flag.String("log-format", core.LogFormatText, "Log output format (text|json)")
+	logLevel := flag.String("log-level", "info", "Minimum log level (debug|info|warn|error)")
+	flag.Parse()
+
+	logger, err := core.NewLogger(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging flags: %v\n", err)
+		os.Exit(2)
+	}
+	log := logger.With("component", "opcua-adapter")
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fatal(log, "failed to load config", err)
+	}
+
+	var creds *credentials
+	var trust *serverTrust
+	security := cfg.OPCUA.Security
+	if security.Policy != PolicyNone {
+		if creds, err = loadCredentials(security); err != nil {
+			fatal(log, "failed to load client certificate", err)
+		}
+		if trust, err = loadServerTrust(security); err != nil {
+			fatal(log, "failed to load server trust", err)
+		}
+	}
+
+	nc, err := nats.Connect(cfg.NATS.URL, natsOptions(log)...)
+	if err != nil {
+		fatal(log, "failed to connect to NATS", err)
+	}
+	defer nc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newSubscriber(cfg, creds, trust, nc, log)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.run(ctx)
+	}()
+
+	log.Info("OPC-UA adapter started",
+		"endpoint", cfg.OPCUA.Endpoint,
+		"security_policy", security.Policy,
+		"security_mode", security.Mode,
+		"nodes", len(s.nodes),
+		"nats_url", cfg.NATS.URL,
+		"subject", cfg.NATS.dataSubject(),
+	)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info("shutting down")
+	cancel()
+	<-done
+	nc.Drain()
+}
+
+// fatal logs msg with err at error level and exits
+func fatal(log *slog.Logger, msg string, err error) {
+	log.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// natsOptions keep the NATS connection reconnecting indefinitely; publishes
+// made while disconnected are buffered by the client
+func natsOptions(log *slog.Logger) []nats.Option {
+	return []nats.Option{
+		nats.Name("edg-opcua-adapter"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2 * time.Second),
+		nats.RetryOnFailedConnect(true),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			log.Warn("NATS disconnected", "error", err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			log.Info("NATS reconnected", "url", nc.ConnectedUrl())
+		}),
+	}
+}
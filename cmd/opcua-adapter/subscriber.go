@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// valueTypeOf returns the platform value type of a built-in OPC-UA type, or
+// "" for types without one
+func valueTypeOf(typeID ua.TypeID) string {
+	switch typeID {
+	case ua.TypeIDBoolean:
+		return core.ValueTypeFlag
+	case ua.TypeIDSByte, ua.TypeIDByte, ua.TypeIDInt16, ua.TypeIDUint16, ua.TypeIDInt32, ua.TypeIDUint32,
+		ua.TypeIDInt64, ua.TypeIDUint64, ua.TypeIDFloat, ua.TypeIDDouble:
+		return core.ValueTypeNumber
+	case ua.TypeIDString, ua.TypeIDLocalizedText, ua.TypeIDDateTime:
+		return core.ValueTypeText
+	default:
+		return ""
+	}
+}
+
+// scalarValue returns the value of a variant of a type valueTypeOf knows as
+// float64, bool, string or time.Time
+func scalarValue(v *ua.Variant) interface{} {
+	switch x := v.Value().(type) {
+	case int8:
+		return float64(x)
+	case uint8:
+		return float64(x)
+	case int16:
+		return float64(x)
+	case uint16:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case uint32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case *ua.LocalizedText:
+		if x == nil {
+			return ""
+		}
+		return x.Text
+	default:
+		return x
+	}
+}
+
+// tagValue converts a value change of node to a tag value. The status code
+// and source timestamp are carried over; the value is converted to the
+// configured value type where that is lossless enough: numbers and flags
+// convert into each other, and anything converts to TEXT.
+func tagValue(node NodeConfig, dv *ua.DataValue) (core.TagValue, error) {
+	status := uint32(dv.Status)
+	value := core.TagValue{Name: node.Name, Unit: node.Unit, StatusCode: &status}
+	value.Quality = value.EffectiveQuality()
+	if !dv.SourceTimestamp.IsZero() {
+		ts := dv.SourceTimestamp.UnixMilli()
+		value.Timestamp = &ts
+	}
+
+	v := dv.Value
+	switch {
+	case v == nil || v.Type() == ua.TypeIDNull:
+		return value, fmt.Errorf("no value, status %s", dv.Status.Error())
+	case v.Has(ua.VariantArrayValues):
+		return value, fmt.Errorf("array values are not supported")
+	case valueTypeOf(v.Type()) == "":
+		return value, fmt.Errorf("unsupported data type %s", strings.TrimPrefix(v.Type().String(), "TypeID"))
+	}
+
+	valueType := node.ValueType
+	if valueType == "" {
+		valueType = valueTypeOf(v.Type())
+	}
+	switch valueType {
+	case core.ValueTypeNumber:
+		var number float64
+		switch x := scalarValue(v).(type) {
+		case float64:
+			number = x
+		case bool:
+			if x {
+				number = 1
+			}
+		default:
+			return value, fmt.Errorf("cannot convert %s value to NUMBER", valueTypeOf(v.Type()))
+		}
+		if math.IsNaN(number) || math.IsInf(number, 0) {
+			return value, fmt.Errorf("value %g is not finite", number)
+		}
+		value.Number = &number
+	case core.ValueTypeFlag:
+		var flag bool
+		switch x := scalarValue(v).(type) {
+		case bool:
+			flag = x
+		case float64:
+			flag = x != 0
+		default:
+			return value, fmt.Errorf("cannot convert %s value to FLAG", valueTypeOf(v.Type()))
+		}
+		value.Flag = &flag
+	default:
+		var text string
+		switch x := scalarValue(v).(type) {
+		case string:
+			text = x
+		case float64:
+			text = strconv.FormatFloat(x, 'g', -1, 64)
+		case bool:
+			text = strconv.FormatBool(x)
+		case time.Time:
+			text = x.Format(time.RFC3339Nano)
+		}
+		value.Text = &text
+	}
+	return value, nil
+}
+
+// boundNode is a configured node with the asset it belongs to
+type boundNode struct {
+	assetID string
+	node    NodeConfig
+}
+
+// subscriber keeps a session with the server and publishes the value
+// changes of the configured nodes as AssetData on the data subject
+type subscriber struct {
+	cfg   *Config
+	creds *credentials
+	trust *serverTrust
+	nc    *nats.Conn
+	log   *slog.Logger
+	now   func() time.Time
+
+	nodes []boundNode // the client handle of a node is its index + 1
+	lost  bool        // the last session failed
+}
+
+func newSubscriber(cfg *Config, creds *credentials, trust *serverTrust, nc *nats.Conn, log *slog.Logger) *subscriber {
+	s := &subscriber{
+		cfg:   cfg,
+		creds: creds,
+		trust: trust,
+		nc:    nc,
+		log:   log.With("endpoint", cfg.OPCUA.Endpoint),
+		now:   time.Now,
+	}
+	for _, asset := range cfg.Assets {
+		for _, node := range asset.Nodes {
+			s.nodes = append(s.nodes, boundNode{assetID: asset.ID, node: node})
+		}
+	}
+	return s
+}
+
+// run keeps a session until ctx is done. A failed session is logged once
+// and re-established every reconnect interval with a new subscription, so
+// the server reports the current values again.
+func (s *subscriber) run(ctx context.Context) {
+	for {
+		err := s.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if !s.lost {
+			s.log.Warn("opc-ua connection lost, reconnecting", "error", err, "interval", s.cfg.OPCUA.ReconnectInterval)
+			s.lost = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.OPCUA.ReconnectInterval):
+		}
+	}
+}
+
+// errDisconnected is returned by session when the connection drops
+var errDisconnected = errors.New("connection closed")
+
+// session connects, subscribes and publishes value changes until the
+// connection fails or ctx is done. The session is closed on return.
+func (s *subscriber) session(ctx context.Context) error {
+	state := make(chan opcua.ConnState, 8)
+	c, err := s.connect(ctx, state)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), s.cfg.OPCUA.Timeout)
+		defer cancel()
+		c.Close(closeCtx)
+	}()
+
+	notifications := make(chan *opcua.PublishNotificationData, 16)
+	monitored, err := s.subscribe(ctx, c, notifications)
+	if err != nil {
+		return err
+	}
+	if s.lost {
+		s.log.Info("opc-ua connection restored", "monitored_nodes", monitored)
+		s.lost = false
+	} else {
+		s.log.Info("opc-ua subscription created", "monitored_nodes", monitored)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case st := <-state:
+			if st == opcua.Disconnected || st == opcua.Closed {
+				return errDisconnected
+			}
+		case n := <-notifications:
+			if n.Error != nil {
+				return n.Error
+			}
+			if changes, ok := n.Value.(*ua.DataChangeNotification); ok {
+				s.publishChanges(changes.MonitoredItems)
+			}
+		}
+	}
+}
+
+// connect returns a client connected to the server within the configured
+// timeout
+func (s *subscriber) connect(ctx context.Context, state chan<- opcua.ConnState) (*opcua.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.OPCUA.Timeout)
+	defer cancel()
+
+	c, err := newClient(ctx, s.cfg.OPCUA, s.creds, s.trust, state)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// subscribe creates the subscription and its monitored items and returns
+// the number of nodes monitored. Nodes the server rejects are logged and
+// skipped.
+func (s *subscriber) subscribe(ctx context.Context, c *opcua.Client, notifications chan<- *opcua.PublishNotificationData) (int, error) {
+	sub, err := c.Subscribe(ctx, &opcua.SubscriptionParameters{Interval: s.cfg.OPCUA.PublishingInterval}, notifications)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	items := make([]*ua.MonitoredItemCreateRequest, len(s.nodes))
+	for i, bound := range s.nodes {
+		item := opcua.NewMonitoredItemCreateRequestWithDefaults(bound.node.node, ua.AttributeIDValue, uint32(i+1))
+		item.RequestedParameters.SamplingInterval = float64(s.cfg.OPCUA.SamplingInterval.Milliseconds())
+		items[i] = item
+	}
+	res, err := sub.Monitor(ctx, ua.TimestampsToReturnSource, items...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to monitor nodes: %w", err)
+	}
+
+	monitored := 0
+	for i, result := range res.Results {
+		if i >= len(s.nodes) {
+			break
+		}
+		if isBad(result.StatusCode) {
+			bound := s.nodes[i]
+			s.log.Warn("failed to monitor node", "asset_id", bound.assetID, "tag", bound.node.Name,
+				"node_id", bound.node.NodeID, "status", result.StatusCode.Error())
+			continue
+		}
+		monitored++
+	}
+	return monitored, nil
+}
+
+// isBad reports whether status has bad severity
+func isBad(status ua.StatusCode) bool {
+	return status&0x80000000 != 0
+}
+
+// publishChanges publishes one AssetData per asset with the changes
+// reported for it. A tag changed more than once starts another message, so
+// each message holds one value per tag.
+func (s *subscriber) publishChanges(changes []*ua.MonitoredItemNotification) {
+	var order []string
+	pending := make(map[string][]*core.AssetData)
+	for _, change := range changes {
+		if change.ClientHandle == 0 || int(change.ClientHandle) > len(s.nodes) || change.Value == nil {
+			continue
+		}
+		bound := s.nodes[change.ClientHandle-1]
+		value, err := tagValue(bound.node, change.Value)
+		if err != nil {
+			s.log.Warn("dropped value change", "asset_id", bound.assetID, "tag", bound.node.Name, "error", err)
+			continue
+		}
+
+		messages := pending[bound.assetID]
+		if len(messages) == 0 {
+			order = append(order, bound.assetID)
+		}
+		if len(messages) == 0 || hasTag(messages[len(messages)-1], value.Name) {
+			messages = append(messages, &core.AssetData{
+				AssetID:   bound.assetID,
+				Timestamp: s.now().UnixMilli(),
+			})
+		}
+		last := messages[len(messages)-1]
+		last.Values = append(last.Values, value)
+		pending[bound.assetID] = messages
+	}
+
+	for _, assetID := range order {
+		for _, data := range pending[assetID] {
+			s.publish(data)
+		}
+	}
+}
+
+// hasTag reports whether data already holds a value of tag
+func hasTag(data *core.AssetData, tag string) bool {
+	for _, v := range data.Values {
+		if v.Name == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *subscriber) publish(data *core.AssetData) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		s.log.Error("failed to marshal asset data", "error", err)
+		return
+	}
+	if err := s.nc.Publish(s.cfg.NATS.dataSubject(), payload); err != nil {
+		s.log.Error("failed to publish to NATS", "error", err)
+		return
+	}
+	s.log.Debug("published opc-ua changes", "asset_id", data.AssetID, "tag_count", len(data.Values))
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/server/attrs"
+	"github.com/gopcua/opcua/ua"
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// testServer is an OPC-UA server with one namespace whose variables are set
+// by the test. A new subscription reports the current values of its nodes.
+type testServer struct {
+	endpoint string
+
+	mu  sync.Mutex
+	srv *server.Server
+	ns  *server.NodeNameSpace
+}
+
+// startTestServer starts an unsecured server on a free local port
+func startTestServer(t *testing.T, opts ...server.Option) *testServer {
+	t.Helper()
+	return startSecureTestServer(t, append(opts,
+		server.EnableSecurity(ua.SecurityPolicyURINone, ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)...)
+}
+
+// startSecureTestServer starts a server with the given security options
+func startSecureTestServer(t *testing.T, opts ...server.Option) *testServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	srv := server.New(append([]server.Option{server.EndPoint("127.0.0.1", port)}, opts...)...)
+	ns := server.NewNodeNameSpace(srv, "urn:edg:test")
+	srv.AddNamespace(ns)
+	require.NoError(t, srv.Start(context.Background()))
+	t.Cleanup(func() { srv.Close() })
+	return &testServer{endpoint: fmt.Sprintf("opc.tcp://127.0.0.1:%d", port), srv: srv, ns: ns}
+}
+
+// set sets the value of variable ns=1;s=<name> and reports it to the
+// subscriptions monitoring it
+func (s *testServer) set(name string, value interface{}, status ua.StatusCode, source time.Time) {
+	dv := &ua.DataValue{Value: ua.MustVariant(value), Status: status, SourceTimestamp: source}
+	dv.UpdateMask()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := ua.NewStringNodeID(s.ns.ID(), name)
+	if node := s.ns.Node(id); node != nil {
+		node.SetAttribute(ua.AttributeIDValue, dv)
+	} else {
+		s.ns.AddNode(server.NewNode(
+			id,
+			map[ua.AttributeID]*ua.DataValue{
+				ua.AttributeIDNodeClass:  server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+				ua.AttributeIDBrowseName: server.DataValueFromValue(attrs.BrowseName(name)),
+			},
+			nil,
+			func() *ua.DataValue { return dv },
+		))
+	}
+	s.srv.ChangeNotification(id)
+}
+
+// testProxy forwards connections to a server until they are dropped
+type testProxy struct {
+	listener net.Listener
+	target   string // host:port, set before the first connection
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// startTestProxy starts a proxy on a free local port
+func startTestProxy(t *testing.T) *testProxy {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := &testProxy{listener: l}
+	go p.serve()
+	t.Cleanup(func() {
+		l.Close()
+		p.drop()
+	})
+	return p
+}
+
+func (p *testProxy) endpoint() string {
+	return "opc.tcp://" + p.listener.Addr().String()
+}
+
+// serverEndpoint returns the server option naming the proxy as an endpoint
+// of the server, which endpoint discovery requires
+func (p *testProxy) serverEndpoint() server.Option {
+	return server.EndPoint("127.0.0.1", p.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// forward sets the server connections are forwarded to
+func (p *testProxy) forward(endpoint string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.target = strings.TrimPrefix(endpoint, "opc.tcp://")
+}
+
+func (p *testProxy) serve() {
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		target := p.target
+		p.mu.Unlock()
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, client, upstream)
+		p.mu.Unlock()
+		go io.Copy(upstream, client)
+		go io.Copy(client, upstream)
+	}
+}
+
+// drop closes every proxied connection
+func (p *testProxy) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+// testCredentials creates a certificate and key for uri, issued by issuer
+// or self-signed when issuer is nil
+func testCredentials(t *testing.T, uri string, issuer *credentials) *credentials {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	appURI, err := url.Parse(uri)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: uri},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		URIs:                  []*url.URL{appURI},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageDataEncipherment | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  issuer == nil,
+	}
+	parent, signer := template, key
+	if issuer != nil {
+		parent, err = x509.ParseCertificate(issuer.certificate)
+		require.NoError(t, err)
+		signer = issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	return &credentials{certificate: der, key: key}
+}
+
+// secureTestServer starts a server with a Basic256Sha256 endpoint in mode
+// with user name authentication and the given certificate. Like real
+// servers, it also accepts unsecured channels for endpoint discovery.
+func secureTestServer(t *testing.T, mode ua.MessageSecurityMode, creds *credentials) *testServer {
+	t.Helper()
+	return startSecureTestServer(t,
+		server.EnableSecurity(ua.SecurityPolicyURINone, ua.MessageSecurityModeNone),
+		server.EnableSecurity(ua.SecurityPolicyURIBasic256Sha256, mode),
+		server.EnableAuthMode(ua.UserTokenTypeUserName),
+		server.Certificate(creds.certificate),
+		server.PrivateKey(creds.key),
+	)
+}
+
+// testConfig returns a configuration subscribing asset press-01 to nodes
+func testConfig(t *testing.T, endpoint string, nodes ...NodeConfig) *Config {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.OPCUA.Endpoint = endpoint
+	cfg.OPCUA.Timeout = 2 * time.Second
+	cfg.OPCUA.PublishingInterval = 50 * time.Millisecond
+	cfg.OPCUA.ReconnectInterval = 50 * time.Millisecond
+	cfg.Assets = []AssetConfig{{ID: "press-01", Nodes: nodes}}
+	require.NoError(t, cfg.validate())
+	return &cfg
+}
+
+// startNATS starts an embedded NATS server and subscribes to the ingest subject
+func startNATS(t *testing.T) (*nats.Conn, *nats.Subscription) {
+	t.Helper()
+	ns, err := natsserver.NewServer(&natsserver.Options{Port: -1})
+	require.NoError(t, err)
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(5*time.Second), "NATS server not ready")
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	sub, err := nc.SubscribeSync(core.SubjectDataAsset)
+	require.NoError(t, err)
+	return nc, sub
+}
+
+// runSubscriber runs s until the test ends
+func runSubscriber(t *testing.T, s *subscriber) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// nextData waits for the next AssetData message
+func nextData(t *testing.T, sub *nats.Subscription) core.AssetData {
+	t.Helper()
+	msg, err := sub.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	var data core.AssetData
+	require.NoError(t, json.Unmarshal(msg.Data, &data))
+	return data
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// TestTagValue tests the mapping of OPC-UA values to tag values
+func TestTagValue(t *testing.T) {
+	source := time.UnixMilli(1768464000123).UTC()
+	scalar := func(value interface{}) *ua.DataValue {
+		return &ua.DataValue{Value: ua.MustVariant(value), SourceTimestamp: source}
+	}
+
+	tests := []struct {
+		name      string
+		valueType string
+		value     *ua.DataValue
+		want      string // JSON of the converted value fields
+		wantErr   string
+	}{
+		{"double", "", scalar(21.5), `"number":21.5`, ""},
+		{"int32", "", scalar(int32(-4)), `"number":-4`, ""},
+		{"float", "", scalar(float32(0.5)), `"number":0.5`, ""},
+		{"boolean", "", scalar(true), `"flag":true`, ""},
+		{"string", "", scalar("auto"), `"text":"auto"`, ""},
+		{"localized text", "", scalar(ua.NewLocalizedText("Betrieb")), `"text":"Betrieb"`, ""},
+		{"date time", "", scalar(source), `"text":"2026-01-15T08:00:00.123Z"`, ""},
+		{"boolean as number", core.ValueTypeNumber, scalar(true), `"number":1`, ""},
+		{"number as flag", core.ValueTypeFlag, scalar(uint16(0)), `"flag":false`, ""},
+		{"number as text", core.ValueTypeText, scalar(int64(42)), `"text":"42"`, ""},
+		{"text as number", core.ValueTypeNumber, scalar("42"), "", "cannot convert TEXT value to NUMBER"},
+		{"array", "", scalar([]float64{1, 2}), "", "array values"},
+		{"unsupported", "", scalar(ua.NewStringNodeID(1, "x")), "", "unsupported data type NodeID"},
+		{"no value", "", &ua.DataValue{Value: &ua.Variant{}, Status: ua.StatusBadNodeIDUnknown}, "", "no value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := NodeConfig{Name: "tag", ValueType: tt.valueType}
+			value, err := tagValue(node, tt.value)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			encoded, err := json.Marshal(value)
+			require.NoError(t, err)
+			assert.Contains(t, string(encoded), tt.want)
+			assert.Equal(t, int64(1768464000123), *value.Timestamp)
+		})
+	}
+}
+
+// TestTagValue_StatusCode tests that the status code sets the quality
+func TestTagValue_StatusCode(t *testing.T) {
+	value, err := tagValue(NodeConfig{Name: "temperature"}, &ua.DataValue{
+		Value:  ua.MustVariant(20.0),
+		Status: ua.StatusUncertain,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, value.StatusCode)
+	assert.Equal(t, uint32(0x40000000), *value.StatusCode)
+	assert.Equal(t, core.QualityUncertain, value.Quality)
+	assert.Nil(t, value.Timestamp)
+}
+
+// TestSubscriber_PublishesChanges tests that value changes are published as AssetData
+func TestSubscriber_PublishesChanges(t *testing.T) {
+	srv := startTestServer(t)
+	source := time.UnixMilli(1768464000000).UTC()
+	srv.set("Press01.Temperature", 21.5, ua.StatusOK, source)
+	srv.set("Press01.Running", true, ua.StatusOK, time.Time{})
+
+	nc, sub := startNATS(t)
+	cfg := testConfig(t, srv.endpoint,
+		NodeConfig{NodeID: "ns=1;s=Press01.Temperature", Name: "temperature", Unit: "°C"},
+		NodeConfig{NodeID: "ns=1;s=Press01.Running", Name: "running"},
+		NodeConfig{NodeID: "ns=1;s=Press01.Missing", Name: "missing"},
+	)
+	runSubscriber(t, newSubscriber(cfg, nil, nil, nc, discardLogger()))
+
+	// The subscription reports the current values first; the unknown node
+	// reports no value and is dropped
+	values := make(map[string]core.TagValue)
+	for len(values) < 2 {
+		data := nextData(t, sub)
+		assert.Equal(t, "press-01", data.AssetID)
+		for _, v := range data.Values {
+			values[v.Name] = v
+		}
+	}
+	require.Len(t, values, 2)
+	assert.Equal(t, 21.5, *values["temperature"].Number)
+	assert.Equal(t, "°C", values["temperature"].Unit)
+	assert.Equal(t, source.UnixMilli(), *values["temperature"].Timestamp)
+	assert.Equal(t, uint32(0), *values["temperature"].StatusCode)
+	assert.True(t, *values["running"].Flag)
+
+	srv.set("Press01.Running", false, ua.StatusBad, time.Time{})
+	data := nextData(t, sub)
+	require.Len(t, data.Values, 1)
+	assert.False(t, *data.Values[0].Flag)
+	assert.Equal(t, core.QualityBad, data.Values[0].Quality)
+}
+
+// TestSubscriber_Reconnect tests that a dropped connection is re-established with a new subscription
+func TestSubscriber_Reconnect(t *testing.T) {
+	proxy := startTestProxy(t)
+	srv := startTestServer(t, proxy.serverEndpoint())
+	srv.set("State", "running", ua.StatusOK, time.Time{})
+	proxy.forward(srv.endpoint)
+
+	nc, sub := startNATS(t)
+	cfg := testConfig(t, proxy.endpoint(), NodeConfig{NodeID: "ns=1;s=State", Name: "state"})
+	runSubscriber(t, newSubscriber(cfg, nil, nil, nc, discardLogger()))
+
+	data := nextData(t, sub)
+	assert.Equal(t, "running", *data.Values[0].Text)
+
+	proxy.drop()
+	data = nextData(t, sub)
+	assert.Equal(t, "running", *data.Values[0].Text)
+}
+
+// TestNewClient_ServerTrust tests that a secured client is only created for
+// a server whose certificate is pinned or issued by the configured CA. The
+// test server cannot complete secured handshakes, so connecting is left out.
+func TestNewClient_ServerTrust(t *testing.T) {
+	ca := testCredentials(t, "urn:edg:test-ca", nil)
+	otherCA := testCredentials(t, "urn:edg:other-ca", nil)
+	clientCreds := testCredentials(t, "urn:edg:test-client", nil)
+	serverCreds := testCredentials(t, "urn:edg:test-server", ca)
+	roots := func(issuer *credentials) *x509.CertPool {
+		cert, err := x509.ParseCertificate(issuer.certificate)
+		require.NoError(t, err)
+		pool := x509.NewCertPool()
+		pool.AddCert(cert)
+		return pool
+	}
+
+	tests := []struct {
+		name  string
+		trust *serverTrust
+		want  string // error, "" when trusted
+	}{
+		{"pinned certificate", &serverTrust{pinned: serverCreds.certificate}, ""},
+		{"issuing CA", &serverTrust{roots: roots(ca)}, ""},
+		{"other pinned certificate", &serverTrust{pinned: testCredentials(t, "urn:edg:test-server", ca).certificate}, "does not match the pinned certificate"},
+		{"other CA", &serverTrust{roots: roots(otherCA)}, "not trusted"},
+	}
+	for _, mode := range []string{ModeSign, ModeSignAndEncrypt} {
+		srv := secureTestServer(t, securityModes[mode], serverCreds)
+		for _, tt := range tests {
+			t.Run(mode+"/"+tt.name, func(t *testing.T) {
+				cfg := testConfig(t, srv.endpoint, NodeConfig{NodeID: "ns=1;s=Count", Name: "count"})
+				cfg.OPCUA.Security = SecurityConfig{Policy: PolicyBasic256Sha256, Mode: mode}
+				cfg.OPCUA.Username, cfg.OPCUA.Password = "operator", "s3cret"
+
+				c, err := newClient(context.Background(), cfg.OPCUA, clientCreds, tt.trust, nil)
+				if tt.want != "" {
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), tt.want)
+					return
+				}
+				require.NoError(t, err)
+				assert.NotNil(t, c)
+			})
+		}
+	}
+}
+
+// TestNewClient_NoMatchingEndpoint tests that a security the server does not offer is refused
+func TestNewClient_NoMatchingEndpoint(t *testing.T) {
+	srv := startTestServer(t)
+	cfg := testConfig(t, srv.endpoint, NodeConfig{NodeID: "ns=1;s=Count", Name: "count"})
+	cfg.OPCUA.Security = SecurityConfig{Policy: PolicyBasic256Sha256, Mode: ModeSignAndEncrypt}
+
+	_, err := newClient(context.Background(), cfg.OPCUA, nil, &serverTrust{}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no matching endpoint")
+}
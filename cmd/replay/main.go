@@ -0,0 +1,61 @@
+// Command replay prints validated asset data stored in the platform's
+// JetStream stream, starting from a point in time.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+func main() {
+	natsURL := flag.String("nats-url", nats.DefaultURL, "NATS server URL")
+	stream := flag.String("stream", "", "JetStream stream to read from (default: the stream capturing -subject)")
+	subject := flag.String("subject", core.SubjectDataValidated, "Subject filter for the consumer")
+	durable := flag.String("durable", "edg-replay", "Durable consumer name; reruns resume where the last run stopped")
+	startTime := flag.String("start-time", "", "Replay messages stored at or after this RFC 3339 time (default: start of stream); ignored when the durable consumer exists")
+	assetID := flag.String("asset-id", "", "Only print data from this asset")
+	count := flag.Int("count", 0, "Stop after printing this many messages (0 for all available)")
+	wait := flag.Duration("wait", 2*time.Second, "Stop when no message arrives within this time")
+	flag.Parse()
+
+	cfg := replayConfig{
+		Stream:  *stream,
+		Subject: *subject,
+		Durable: *durable,
+		AssetID: *assetID,
+		Count:   *count,
+		Wait:    *wait,
+	}
+	if *startTime != "" {
+		t, err := time.Parse(time.RFC3339, *startTime)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -start-time: %v\n", err)
+			os.Exit(2)
+		}
+		cfg.StartTime = t
+	}
+
+	nc, err := nats.Connect(*natsURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to NATS: %v\n", err)
+		os.Exit(1)
+	}
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create JetStream context: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := replay(js, cfg, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Replay failed: %v\n", err)
+		os.Exit(1)
+	}
+}
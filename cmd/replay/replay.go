@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// fetchBatch caps the number of messages requested per pull
+const fetchBatch = 100
+
+// replayConfig selects what is replayed and from where
+type replayConfig struct {
+	Stream    string // empty looks up the stream capturing Subject
+	Subject   string
+	Durable   string
+	StartTime time.Time     // zero replays from the start of the stream
+	AssetID   string        // empty matches every asset
+	Count     int           // stop after this many matching messages; 0 means no limit
+	Wait      time.Duration // give up when no message arrives within this time
+}
+
+// replayedMessage is one line of replay output
+type replayedMessage struct {
+	Sequence uint64          `json:"sequence"`
+	Time     time.Time       `json:"time"`
+	Subject  string          `json:"subject"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// replay pulls messages through a durable consumer and writes the matching
+// ones to w, one JSON object per line. It returns the number written and
+// stops once Count is reached or the consumer has caught up.
+func replay(js nats.JetStreamContext, cfg replayConfig, w io.Writer) (int, error) {
+	if cfg.Stream == "" {
+		// Split stream layouts keep validated data in its own stream
+		stream, err := js.StreamNameBySubject(cfg.Subject)
+		if err != nil {
+			return 0, fmt.Errorf("failed to find the stream of %s: %w", cfg.Subject, err)
+		}
+		cfg.Stream = stream
+	}
+	if err := ensureConsumer(js, cfg); err != nil {
+		return 0, err
+	}
+
+	// Binding to the existing consumer keeps it (and its position) when the
+	// subscription is closed
+	sub, err := js.PullSubscribe(cfg.Subject, cfg.Durable, nats.Bind(cfg.Stream, cfg.Durable))
+	if err != nil {
+		return 0, fmt.Errorf("failed to subscribe: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	enc := json.NewEncoder(w)
+	written := 0
+	for cfg.Count == 0 || written < cfg.Count {
+		batch := fetchBatch
+		if cfg.Count > 0 && cfg.Count-written < batch {
+			batch = cfg.Count - written
+		}
+
+		msgs, err := sub.Fetch(batch, nats.MaxWait(cfg.Wait))
+		if errors.Is(err, nats.ErrTimeout) {
+			return written, nil
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to fetch messages: %w", err)
+		}
+
+		for _, msg := range msgs {
+			data, err := core.DecodePayload(msg)
+			if err != nil {
+				return written, fmt.Errorf("failed to decode message: %w", err)
+			}
+			if matchesAsset(data, cfg.AssetID) {
+				meta, err := msg.Metadata()
+				if err != nil {
+					return written, fmt.Errorf("failed to read message metadata: %w", err)
+				}
+				if err := enc.Encode(replayedMessage{
+					Sequence: meta.Sequence.Stream,
+					Time:     meta.Timestamp,
+					Subject:  msg.Subject,
+					Data:     data,
+				}); err != nil {
+					return written, fmt.Errorf("failed to write message: %w", err)
+				}
+				written++
+			}
+			if err := msg.Ack(); err != nil {
+				return written, fmt.Errorf("failed to ack message: %w", err)
+			}
+		}
+	}
+	return written, nil
+}
+
+// ensureConsumer creates the durable pull consumer unless it already exists.
+// An existing consumer resumes from its last acknowledged message, so the
+// start time only applies to the first run.
+func ensureConsumer(js nats.JetStreamContext, cfg replayConfig) error {
+	_, err := js.ConsumerInfo(cfg.Stream, cfg.Durable)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return fmt.Errorf("failed to look up consumer: %w", err)
+	}
+
+	consumer := &nats.ConsumerConfig{
+		Durable:       cfg.Durable,
+		FilterSubject: cfg.Subject,
+		AckPolicy:     nats.AckExplicitPolicy,
+		DeliverPolicy: nats.DeliverAllPolicy,
+	}
+	if !cfg.StartTime.IsZero() {
+		start := cfg.StartTime
+		consumer.DeliverPolicy = nats.DeliverByStartTimePolicy
+		consumer.OptStartTime = &start
+	}
+	if _, err := js.AddConsumer(cfg.Stream, consumer); err != nil {
+		return fmt.Errorf("failed to create consumer: %w", err)
+	}
+	return nil
+}
+
+// matchesAsset reports whether data is an AssetData payload from assetID.
+// An empty assetID matches everything, including non-AssetData payloads.
+func matchesAsset(data []byte, assetID string) bool {
+	if assetID == "" {
+		return true
+	}
+	var payload core.AssetData
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return false
+	}
+	return payload.AssetID == assetID
+}
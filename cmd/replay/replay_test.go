@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// startTestStream starts an embedded JetStream server with the platform data stream
+func startTestStream(t *testing.T) nats.JetStreamContext {
+	t.Helper()
+	ns, err := natsserver.NewServer(&natsserver.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	require.NoError(t, err)
+	go ns.Start()
+	require.True(t, ns.ReadyForConnections(5*time.Second), "NATS server not ready")
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		nc.Close()
+		ns.Shutdown()
+	})
+
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     core.DataStreamName,
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+	return js
+}
+
+// publishData stores one AssetData message per asset ID on subject
+func publishData(t *testing.T, js nats.JetStreamContext, subject string, assetIDs ...string) {
+	t.Helper()
+	for i, id := range assetIDs {
+		data, err := json.Marshal(core.AssetData{AssetID: id, Timestamp: int64(i)})
+		require.NoError(t, err)
+		_, err = js.Publish(subject, data)
+		require.NoError(t, err)
+	}
+}
+
+// decodeOutput parses replay output lines
+func decodeOutput(t *testing.T, out *bytes.Buffer) []replayedMessage {
+	t.Helper()
+	var msgs []replayedMessage
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var msg replayedMessage
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &msg))
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func testConfig(durable string) replayConfig {
+	return replayConfig{
+		Stream:  core.DataStreamName,
+		Subject: core.SubjectDataValidated,
+		Durable: durable,
+		Wait:    200 * time.Millisecond,
+	}
+}
+
+// TestReplay_FiltersAndResumes tests subject and asset filtering, count and durable resumption
+func TestReplay_FiltersAndResumes(t *testing.T) {
+	js := startTestStream(t)
+	publishData(t, js, core.SubjectDataValidated, "a", "b", "a", "a")
+	publishData(t, js, core.SubjectDataRejected, "a")
+
+	cfg := testConfig("test-replay")
+	cfg.AssetID = "a"
+	cfg.Count = 2
+
+	var out bytes.Buffer
+	n, err := replay(js, cfg, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	msgs := decodeOutput(t, &out)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, []uint64{1, 3}, []uint64{msgs[0].Sequence, msgs[1].Sequence})
+	assert.Equal(t, core.SubjectDataValidated, msgs[0].Subject)
+	assert.JSONEq(t, `{"asset_id":"a","timestamp":0,"values":null}`, string(msgs[0].Data))
+
+	// A second run continues after the last acknowledged message
+	out.Reset()
+	n, err = replay(js, cfg, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	msgs = decodeOutput(t, &out)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, uint64(4), msgs[0].Sequence)
+}
+
+// TestReplay_StartTime tests that only messages stored after the start time are replayed
+func TestReplay_StartTime(t *testing.T) {
+	js := startTestStream(t)
+	publishData(t, js, core.SubjectDataValidated, "old-1", "old-2")
+
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	publishData(t, js, core.SubjectDataValidated, "new-1", "new-2")
+
+	cfg := testConfig("test-start-time")
+	cfg.StartTime = start
+
+	var out bytes.Buffer
+	n, err := replay(js, cfg, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	var ids []string
+	for _, msg := range decodeOutput(t, &out) {
+		var data core.AssetData
+		require.NoError(t, json.Unmarshal(msg.Data, &data))
+		ids = append(ids, data.AssetID)
+		assert.False(t, msg.Time.Before(start), fmt.Sprintf("message %d stored before start", msg.Sequence))
+	}
+	assert.Equal(t, []string{"new-1", "new-2"}, ids)
+}
+
+// TestReplay_CustomStream tests that the stream name and subject filter are configurable
+func TestReplay_CustomStream(t *testing.T) {
+	js := startTestStream(t)
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "SITE_DATA",
+		Subjects: []string{"site.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+	publishData(t, js, "site.data.validated", "x")
+
+	cfg := testConfig("test-custom")
+	cfg.Stream = "SITE_DATA"
+	cfg.Subject = "site.data.validated"
+
+	var out bytes.Buffer
+	n, err := replay(js, cfg, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	cfg.Stream = "MISSING"
+	_, err = replay(js, cfg, &out)
+	assert.Error(t, err)
+
+	// Without a stream name the stream capturing the subject is used
+	cfg = testConfig("test-lookup")
+	cfg.Stream = ""
+	cfg.Subject = "site.data.validated"
+	out.Reset()
+	n, err = replay(js, cfg, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	cfg.Subject = "nowhere.data.validated"
+	_, err = replay(js, cfg, &out)
+	assert.ErrorContains(t, err, "failed to find the stream")
+}
+
+// TestReplay_Compressed tests that gzip payloads are printed decompressed
+func TestReplay_Compressed(t *testing.T) {
+	js := startTestStream(t)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(`{"asset_id":"a","timestamp":1}`))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	_, err = js.PublishMsg(&nats.Msg{
+		Subject: core.SubjectDataValidated,
+		Data:    buf.Bytes(),
+		Header:  nats.Header{core.HeaderContentEncoding: []string{core.EncodingGzip}},
+	})
+	require.NoError(t, err)
+
+	cfg := testConfig("test-compressed")
+	cfg.AssetID = "a"
+	var out bytes.Buffer
+	n, err := replay(js, cfg, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	msgs := decodeOutput(t, &out)
+	require.Len(t, msgs, 1)
+	assert.JSONEq(t, `{"asset_id":"a","timestamp":1}`, string(msgs[0].Data))
+}
@@ -0,0 +1,140 @@
+package core
+
+import "github.com/e7217/edg/api"
+
+// The platform's message types and subjects are defined in package api,
+// which clients import without the store; the aliases keep them part of
+// the core API.
+type (
+	AssetData                    = api.AssetData
+	TagValue                     = api.TagValue
+	Quality                      = api.Quality
+	Asset                        = api.Asset
+	AssetTemplate                = api.AssetTemplate
+	AssetResource                = api.AssetResource
+	RelationType                 = api.RelationType
+	AssetRelation                = api.AssetRelation
+	RelationBetween              = api.RelationBetween
+	Response                     = api.Response
+	CreateAssetRequest           = api.CreateAssetRequest
+	BatchCreateAssetsRequest     = api.BatchCreateAssetsRequest
+	BatchCreateAssetsResponse    = api.BatchCreateAssetsResponse
+	BatchItemError               = api.BatchItemError
+	GetAssetRequest              = api.GetAssetRequest
+	AssetWithDegree              = api.AssetWithDegree
+	ListAssetsRequest            = api.ListAssetsRequest
+	ListAssetsResponse           = api.ListAssetsResponse
+	SearchAssetsRequest          = api.SearchAssetsRequest
+	StaleAssetsRequest           = api.StaleAssetsRequest
+	DeleteAssetRequest           = api.DeleteAssetRequest
+	RestoreAssetRequest          = api.RestoreAssetRequest
+	CloneAssetRequest            = api.CloneAssetRequest
+	UpdateAssetRequest           = api.UpdateAssetRequest
+	ValidateDataRequest          = api.ValidateDataRequest
+	ValidateDataResponse         = api.ValidateDataResponse
+	CheckRequest                 = api.CheckRequest
+	SchemaRequest                = api.SchemaRequest
+	LatestDataRequest            = api.LatestDataRequest
+	AggregateDataRequest         = api.AggregateDataRequest
+	CreateRelationRequest        = api.CreateRelationRequest
+	BatchCreateRelationsRequest  = api.BatchCreateRelationsRequest
+	BatchCreateRelationsResponse = api.BatchCreateRelationsResponse
+	GetRelationRequest           = api.GetRelationRequest
+	RelationExistsRequest        = api.RelationExistsRequest
+	RelationExistsResponse       = api.RelationExistsResponse
+	RelationsBetweenRequest      = api.RelationsBetweenRequest
+	RelationCountRequest         = api.RelationCountRequest
+	RelationCount                = api.RelationCount
+	ListRelationsRequest         = api.ListRelationsRequest
+	ListAllRelationsRequest      = api.ListAllRelationsRequest
+	ListAllRelationsResponse     = api.ListAllRelationsResponse
+	DeleteRelationRequest        = api.DeleteRelationRequest
+	UpdateRelationRequest        = api.UpdateRelationRequest
+	RelationTreeRequest          = api.RelationTreeRequest
+	ImportSnapshotRequest        = api.ImportSnapshotRequest
+	TemplateReloadResult         = api.TemplateReloadResult
+	StoreStats                   = api.StoreStats
+	DataBucket                   = api.DataBucket
+	JSONSchema                   = api.JSONSchema
+	IntegrityIssues              = api.IntegrityIssues
+	IntegrityReport              = api.IntegrityReport
+	Snapshot                     = api.Snapshot
+)
+
+const (
+	CurrentSchemaVersion        = api.CurrentSchemaVersion
+	QualityGood                 = api.QualityGood
+	QualityUncertain            = api.QualityUncertain
+	QualityBad                  = api.QualityBad
+	ValueTypeNumber             = api.ValueTypeNumber
+	ValueTypeText               = api.ValueTypeText
+	ValueTypeFlag               = api.ValueTypeFlag
+	RelationPartOf              = api.RelationPartOf
+	RelationConnectedTo         = api.RelationConnectedTo
+	RelationLocatedIn           = api.RelationLocatedIn
+	RelationMeasures            = api.RelationMeasures
+	ErrCodeBadRequest           = api.ErrCodeBadRequest
+	ErrCodeValidation           = api.ErrCodeValidation
+	ErrCodeNotFound             = api.ErrCodeNotFound
+	ErrCodeDuplicate            = api.ErrCodeDuplicate
+	ErrCodeInternal             = api.ErrCodeInternal
+	SnapshotMerge               = api.SnapshotMerge
+	SnapshotReplace             = api.SnapshotReplace
+	DefaultSubjectPrefix        = api.DefaultSubjectPrefix
+	SubjectDataAsset            = api.SubjectDataAsset
+	SubjectDataBatch            = api.SubjectDataBatch
+	SubjectDataValidated        = api.SubjectDataValidated
+	SubjectDataRejected         = api.SubjectDataRejected
+	SubjectDataDeadLetter       = api.SubjectDataDeadLetter
+	SubjectDataUnregistered     = api.SubjectDataUnregistered
+	SubjectDataEvents           = api.SubjectDataEvents
+	SubjectAssetCreate          = api.SubjectAssetCreate
+	SubjectAssetGet             = api.SubjectAssetGet
+	SubjectAssetList            = api.SubjectAssetList
+	SubjectAssetDelete          = api.SubjectAssetDelete
+	SubjectAssetUpdate          = api.SubjectAssetUpdate
+	SubjectAssetRestore         = api.SubjectAssetRestore
+	SubjectAssetClone           = api.SubjectAssetClone
+	SubjectAssetBatch           = api.SubjectAssetBatch
+	SubjectAssetSearch          = api.SubjectAssetSearch
+	SubjectAssetStale           = api.SubjectAssetStale
+	SubjectTemplateList         = api.SubjectTemplateList
+	SubjectTemplateReload       = api.SubjectTemplateReload
+	SubjectValidate             = api.SubjectValidate
+	SubjectStats                = api.SubjectStats
+	SubjectSchema               = api.SubjectSchema
+	SubjectCheck                = api.SubjectCheck
+	SubjectDataLatest           = api.SubjectDataLatest
+	SubjectDataAggregate        = api.SubjectDataAggregate
+	SubjectRelationCreate       = api.SubjectRelationCreate
+	SubjectRelationGet          = api.SubjectRelationGet
+	SubjectRelationExists       = api.SubjectRelationExists
+	SubjectRelationCount        = api.SubjectRelationCount
+	SubjectRelationBetween      = api.SubjectRelationBetween
+	SubjectRelationList         = api.SubjectRelationList
+	SubjectRelationDelete       = api.SubjectRelationDelete
+	SubjectRelationUpdate       = api.SubjectRelationUpdate
+	SubjectRelationTree         = api.SubjectRelationTree
+	SubjectRelationBatch        = api.SubjectRelationBatch
+	SubjectRelationListAll      = api.SubjectRelationListAll
+	SubjectExportJSONLD         = api.SubjectExportJSONLD
+	SubjectSnapshotExport       = api.SubjectSnapshotExport
+	SubjectSnapshotImport       = api.SubjectSnapshotImport
+	SubjectEventAssetCreated    = api.SubjectEventAssetCreated
+	SubjectEventAssetUpdated    = api.SubjectEventAssetUpdated
+	SubjectEventAssetDeleted    = api.SubjectEventAssetDeleted
+	SubjectEventRelationCreated = api.SubjectEventRelationCreated
+	SubjectEventRelationUpdated = api.SubjectEventRelationUpdated
+	SubjectEventRelationDeleted = api.SubjectEventRelationDeleted
+)
+
+// NormalizeQuality maps a quality string to its canonical level, see
+// api.NormalizeQuality
+func NormalizeQuality(s string) (Quality, bool) {
+	return api.NormalizeQuality(s)
+}
+
+// PrefixSubject moves subject under prefix, see api.PrefixSubject
+func PrefixSubject(prefix, subject string) string {
+	return api.PrefixSubject(prefix, subject)
+}
@@ -0,0 +1,16 @@
+package core
+
+import "github.com/google/uuid"
+
+// DefaultAssetIDNamespace is the UUID namespace asset IDs are derived in
+// from an external key unless configured otherwise. Installations that
+// must not share IDs for equal keys configure their own.
+var DefaultAssetIDNamespace = uuid.MustParse("05dd5339-b4ea-4553-959d-3fbdeb9033ee")
+
+// AssetIDForKey returns the asset ID derived from an external key: the
+// name-based (version 5) UUID of key in namespace. Creating an asset twice
+// with the same key yields the same ID, so the second create fails as a
+// duplicate instead of adding another asset.
+func AssetIDForKey(namespace uuid.UUID, key string) string {
+	return uuid.NewSHA1(namespace, []byte(key)).String()
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAssetIDForKey tests that IDs are stable per key and namespace
+func TestAssetIDForKey(t *testing.T) {
+	id := AssetIDForKey(DefaultAssetIDNamespace, "erp:4711")
+	assert.Equal(t, id, AssetIDForKey(DefaultAssetIDNamespace, "erp:4711"))
+	assert.NotEqual(t, id, AssetIDForKey(DefaultAssetIDNamespace, "erp:4712"))
+	assert.NotEqual(t, id, AssetIDForKey(uuid.New(), "erp:4711"))
+
+	parsed, err := uuid.Parse(id)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(5), parsed.Version())
+}
+
+// TestHandleAssetCreate_ExternalKey tests that creates with the same
+// external key collide on the derived ID
+func TestHandleAssetCreate_ExternalKey(t *testing.T) {
+	_, nc := newTestMetaHandler(t)
+	wantID := AssetIDForKey(DefaultAssetIDNamespace, "erp:4711")
+
+	resp := request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-1", ExternalKey: "erp:4711"})
+	require.True(t, resp.Success, resp.Error)
+	var asset Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &asset))
+	assert.Equal(t, wantID, asset.ID)
+
+	// Re-running onboarding under another name still collides
+	resp = request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-1-renamed", ExternalKey: "erp:4711"})
+	assert.Equal(t, ErrCodeDuplicate, resp.ErrorCode)
+	assert.Contains(t, resp.Error, wantID)
+
+	resp = request(t, nc, SubjectAssetBatch, BatchCreateAssetsRequest{Assets: []CreateAssetRequest{
+		{Name: "pump-2", ExternalKey: "erp:4712"},
+		{Name: "pump-3", ExternalKey: "erp:4712"},
+	}})
+	assert.Equal(t, ErrCodeDuplicate, resp.ErrorCode)
+	assert.Contains(t, resp.Error, "duplicate external_key in batch")
+
+	resp = request(t, nc, SubjectAssetBatch, BatchCreateAssetsRequest{Assets: []CreateAssetRequest{
+		{Name: "pump-2", ExternalKey: "erp:4711"},
+	}})
+	assert.Equal(t, ErrCodeDuplicate, resp.ErrorCode)
+
+	// Without a key IDs stay random
+	resp = request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-4"})
+	require.True(t, resp.Success, resp.Error)
+	require.NoError(t, json.Unmarshal(resp.Data, &asset))
+	parsed, err := uuid.Parse(asset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(4), parsed.Version())
+}
+
+// TestCreateAsset_DuplicateID tests that an ID collision is reported as such
+func TestCreateAsset_DuplicateID(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "pump")
+
+	err := store.CreateAsset(&Asset{ID: "pump", Name: "other"})
+	assert.ErrorIs(t, err, ErrDuplicateAssetID)
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultAssetCacheTTL is how long CachedStore keeps an asset lookup
+const DefaultAssetCacheTTL = 30 * time.Second
+
+// maxCachedAssets bounds the cache; lookups of unknown IDs are cached too,
+// so a stream of random IDs must not grow it without limit
+const maxCachedAssets = 10000
+
+// AssetStore is the part of Store the DataHandler uses. Both *Store and
+// *CachedStore satisfy it.
+type AssetStore interface {
+	GetAsset(id string) (*Asset, error)
+	EnsureAsset(asset *Asset) (bool, error)
+	AdoptAssetTemplate(id, templateName string, version int) (bool, error)
+	InsertAssetData(data *AssetData) error
+	InsertAssetDataOnce(data *AssetData, hash string) (bool, error)
+	CountAssetData() (int, error)
+}
+
+// cachedAsset is a cached GetAsset result; asset is nil for IDs that were
+// not found
+type cachedAsset struct {
+	asset   *Asset
+	expires time.Time
+}
+
+// CachedStore is a Store with a read-through cache in front of asset
+// lookups, which the data path does for every message. The asset carries
+// its template name and version, so template checks are served from the
+// cache as well. Entries expire after the TTL and are dropped as soon as the
+// asset is created, updated or deleted through the underlying Store.
+//
+// Cached assets are shared between callers and must not be modified.
+type CachedStore struct {
+	*Store
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex
+	assets map[string]cachedAsset
+	gen    uint64 // bumped by every invalidation
+}
+
+// NewCachedStore wraps store with an asset cache whose entries live for ttl.
+// It must be called before store is shared with other goroutines.
+func NewCachedStore(store *Store, ttl time.Duration) *CachedStore {
+	c := &CachedStore{
+		Store:  store,
+		ttl:    ttl,
+		now:    time.Now,
+		assets: make(map[string]cachedAsset),
+	}
+	store.assetHooks = append(store.assetHooks, c.invalidate)
+	return c
+}
+
+// GetAsset retrieves an asset by ID, from the cache when possible
+func (c *CachedStore) GetAsset(id string) (*Asset, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.assets[id]
+	gen := c.gen
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.asset, nil
+	}
+
+	asset, err := c.Store.GetAsset(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A write during the lookup may have made the result stale already
+	if c.gen != gen {
+		return asset, nil
+	}
+	if len(c.assets) >= maxCachedAssets {
+		c.evictExpired(now)
+	}
+	if len(c.assets) < maxCachedAssets {
+		c.assets[id] = cachedAsset{asset: asset, expires: now.Add(c.ttl)}
+	}
+	return asset, nil
+}
+
+// AssetExists checks if an asset exists and is not soft-deleted, from the
+// cache when possible
+func (c *CachedStore) AssetExists(id string) (bool, error) {
+	asset, err := c.GetAsset(id)
+	if err != nil {
+		return false, err
+	}
+	return asset != nil, nil
+}
+
+// invalidate drops the cached lookups of ids, or every lookup when no IDs
+// are given
+func (c *CachedStore) invalidate(ids ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if len(ids) == 0 {
+		c.assets = make(map[string]cachedAsset)
+		return
+	}
+	for _, id := range ids {
+		delete(c.assets, id)
+	}
+}
+
+// evictExpired removes entries that expired before now; callers hold c.mu
+func (c *CachedStore) evictExpired(now time.Time) {
+	for id, entry := range c.assets {
+		if !now.Before(entry.expires) {
+			delete(c.assets, id)
+		}
+	}
+}
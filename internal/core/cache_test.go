@@ -0,0 +1,133 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCachedStore creates a cached in-memory store with a controllable clock
+func newTestCachedStore(t *testing.T) (*CachedStore, *time.Time) {
+	t.Helper()
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now()
+	cached := NewCachedStore(store, time.Minute)
+	cached.now = func() time.Time { return now }
+	return cached, &now
+}
+
+// TestCachedStore_ServesFromCache tests that a cached lookup survives until the TTL
+func TestCachedStore_ServesFromCache(t *testing.T) {
+	cached, now := newTestCachedStore(t)
+	createTestAssets(t, cached.Store, "sensor-001")
+
+	asset, err := cached.GetAsset("sensor-001")
+	require.NoError(t, err)
+	require.NotNil(t, asset)
+
+	// A change behind the store's back is not seen until the entry expires
+	_, err = cached.db.Exec(`UPDATE assets SET name = 'renamed' WHERE id = 'sensor-001'`)
+	require.NoError(t, err)
+
+	asset, err = cached.GetAsset("sensor-001")
+	require.NoError(t, err)
+	assert.Equal(t, "sensor-001", asset.Name)
+
+	*now = now.Add(time.Minute)
+	asset, err = cached.GetAsset("sensor-001")
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", asset.Name)
+}
+
+// TestCachedStore_Invalidation tests that store writes drop cached lookups
+func TestCachedStore_Invalidation(t *testing.T) {
+	cached, _ := newTestCachedStore(t)
+
+	// Unknown IDs are cached until the asset is created
+	exists, err := cached.AssetExists("sensor-001")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	createTestAssets(t, cached.Store, "sensor-001")
+	exists, err = cached.AssetExists("sensor-001")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, cached.UpdateAsset(&Asset{ID: "sensor-001", TemplateName: "temperature-sensor"}, []string{AssetFieldTemplateName}))
+	asset, err := cached.GetAsset("sensor-001")
+	require.NoError(t, err)
+	assert.Equal(t, "temperature-sensor", asset.TemplateName)
+
+	require.NoError(t, cached.SoftDeleteAsset("sensor-001"))
+	exists, err = cached.AssetExists("sensor-001")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, cached.RestoreAsset("sensor-001"))
+	exists, err = cached.AssetExists("sensor-001")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, cached.CreateAssetsBatch([]*Asset{{ID: "sensor-002", Name: "sensor-002", CreatedAt: time.Now()}}))
+	exists, err = cached.AssetExists("sensor-002")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// A replace import drops every cached lookup
+	require.NoError(t, cached.ImportSnapshot([]byte(`{"version": 1}`), SnapshotReplace))
+	exists, err = cached.AssetExists("sensor-002")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+// TestHandleAssetData_CachedStore tests auto-registration through the cache
+func TestHandleAssetData_CachedStore(t *testing.T) {
+	cached, _ := newTestCachedStore(t)
+	handler := NewDataHandler(nil, cached)
+
+	handler.HandleAssetData(dataMsg(t, "sensor-001", 21.5, QualityGood))
+	handler.HandleAssetData(dataMsg(t, "sensor-001", 22.5, QualityGood))
+
+	assert.Equal(t, uint64(1), handler.metrics.AssetsAutoRegistered.Value())
+	assert.Equal(t, 2, handler.GetDataCount())
+}
+
+// BenchmarkAssetExists compares cached and uncached lookups from parallel callers
+func BenchmarkAssetExists(b *testing.B) {
+	store, err := NewStore(filepath.Join(b.TempDir(), "bench.db"))
+	require.NoError(b, err)
+	defer store.Close()
+
+	ids := make([]string, 100)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("sensor-%03d", i)
+		require.NoError(b, store.CreateAsset(&Asset{ID: ids[i], Name: ids[i], CreatedAt: time.Now()}))
+	}
+
+	run := func(b *testing.B, exists func(id string) (bool, error)) {
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				if _, err := exists(ids[i%len(ids)]); err != nil {
+					b.Error(err)
+					return
+				}
+				i++
+			}
+		})
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		run(b, store.AssetExists)
+	})
+	b.Run("cached", func(b *testing.B) {
+		run(b, NewCachedStore(store, time.Minute).AssetExists)
+	})
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CoerceAssetData rewrites TEXT values of tags the template declares as
+// NUMBER or FLAG into that type, for adapters that send every value as a
+// string. NUMBER accepts a decimal float such as "25.5"; FLAG accepts "true"
+// or "false" in any case. It returns how many values were rewritten. A value
+// that cannot be converted is an error, so the message is rejected as it
+// would be by validation. Tags of unknown templates are left alone.
+func (l *TemplateLoader) CoerceAssetData(templateName string, data *AssetData) (int, error) {
+	template := l.Get(templateName)
+	if template == nil {
+		return 0, nil
+	}
+
+	valueTypes := make(map[string]string, len(template.Resources))
+	for _, res := range template.Resources {
+		valueTypes[res.Name] = res.ValueType
+	}
+
+	coerced := 0
+	for i := range data.Values {
+		tv := &data.Values[i]
+		if tv.Text == nil {
+			continue
+		}
+		text := strings.TrimSpace(*tv.Text)
+
+		switch valueTypes[tv.Name] {
+		case ValueTypeNumber:
+			if tv.Number != nil {
+				continue
+			}
+			number, err := strconv.ParseFloat(text, 64)
+			if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+				return coerced, fmt.Errorf("tag '%s' value %q cannot be coerced to NUMBER", tv.Name, *tv.Text)
+			}
+			tv.Number = &number
+		case ValueTypeFlag:
+			if tv.Flag != nil {
+				continue
+			}
+			var flag bool
+			switch {
+			case strings.EqualFold(text, "true"):
+				flag = true
+			case strings.EqualFold(text, "false"):
+			default:
+				return coerced, fmt.Errorf("tag '%s' value %q cannot be coerced to FLAG", tv.Name, *tv.Text)
+			}
+			tv.Flag = &flag
+		default:
+			continue
+		}
+		tv.Text = nil
+		coerced++
+	}
+	return coerced, nil
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCoerceAssetData tests conversion of TEXT values to the declared type
+func TestCoerceAssetData(t *testing.T) {
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+
+	text := func(s string) *string { return &s }
+	data := &AssetData{Values: []TagValue{
+		{Name: "temperature", Text: text(" 25.5 ")},
+		{Name: "enabled", Text: text("TRUE")},
+		{Name: "status", Text: text("42")},
+		{Name: "undeclared", Text: text("1")},
+	}}
+
+	n, err := loader.CoerceAssetData("test-sensor", data)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.NotNil(t, data.Values[0].Number)
+	assert.Equal(t, 25.5, *data.Values[0].Number)
+	assert.Nil(t, data.Values[0].Text)
+	require.NotNil(t, data.Values[1].Flag)
+	assert.True(t, *data.Values[1].Flag)
+	assert.Equal(t, "42", *data.Values[2].Text)
+	assert.Equal(t, "1", *data.Values[3].Text)
+	require.NoError(t, loader.ValidateAssetData("test-sensor", data))
+
+	for _, tv := range []TagValue{
+		{Name: "temperature", Text: text("warm")},
+		{Name: "temperature", Text: text("NaN")},
+		{Name: "enabled", Text: text("1")},
+	} {
+		_, err := loader.CoerceAssetData("test-sensor", &AssetData{Values: []TagValue{tv}})
+		assert.Error(t, err, *tv.Text)
+	}
+}
+
+// TestHandleAssetData_CoerceValues tests that coercion is opt-in, counted
+// and still rejects values that do not convert
+func TestHandleAssetData_CoerceValues(t *testing.T) {
+	store := newTestStore(t)
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+	createTestAssets(t, store, "sensor")
+	require.NoError(t, store.UpdateAssetTemplate("sensor", "test-sensor"))
+
+	handler := NewDataHandler(nil, store)
+	handler.SetTemplateLoader(loader)
+
+	send := func(value string) {
+		jsonData, err := json.Marshal(&AssetData{
+			AssetID: "sensor",
+			Values:  []TagValue{{Name: "temperature", Text: &value}},
+		})
+		require.NoError(t, err)
+		handler.HandleAssetData(&nats.Msg{Data: jsonData})
+	}
+
+	// Strict by default
+	send("25.5")
+	assert.Equal(t, 0, handler.GetDataCount())
+	assert.Equal(t, uint64(1), handler.metrics.ValidationFailures.Value())
+
+	handler.SetCoerceValues(true)
+	send("25.5")
+	send("warm")
+	assert.Equal(t, 1, handler.GetDataCount())
+	assert.Equal(t, uint64(2), handler.metrics.ValidationFailures.Value())
+	assert.Equal(t, uint64(1), handler.metrics.ValuesCoerced.Value())
+}
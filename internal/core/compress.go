@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/nats-io/nats.go"
+)
+
+// HeaderContentEncoding names the encoding of a compressed message payload.
+// Messages without it are uncompressed.
+const HeaderContentEncoding = "Content-Encoding"
+
+// EncodingGzip is the HeaderContentEncoding value of gzip payloads
+const EncodingGzip = "gzip"
+
+// compressPayload gzips data
+func compressPayload(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodePayload returns the payload of msg, decompressed according to its
+// HeaderContentEncoding header. Consumers of SubjectDataValidated use it to
+// read messages published with PublishConfig.CompressAbove set.
+func DecodePayload(msg *nats.Msg) ([]byte, error) {
+	if msg.Header == nil {
+		return msg.Data, nil
+	}
+	switch encoding := msg.Header.Get(HeaderContentEncoding); encoding {
+	case "":
+		return msg.Data, nil
+	case EncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(msg.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		defer zr.Close()
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecodePayload tests decoding plain, gzip and unknown payload encodings
+func TestDecodePayload(t *testing.T) {
+	payload := []byte(`{"asset_id":"sensor-001"}`)
+
+	data, err := DecodePayload(&nats.Msg{Data: payload})
+	require.NoError(t, err)
+	assert.Equal(t, payload, data)
+
+	compressed, err := compressPayload(payload)
+	require.NoError(t, err)
+	data, err = DecodePayload(&nats.Msg{Data: compressed, Header: nats.Header{HeaderContentEncoding: []string{EncodingGzip}}})
+	require.NoError(t, err)
+	assert.Equal(t, payload, data)
+
+	_, err = DecodePayload(&nats.Msg{Data: payload, Header: nats.Header{HeaderContentEncoding: []string{EncodingGzip}}})
+	assert.Error(t, err)
+	_, err = DecodePayload(&nats.Msg{Data: payload, Header: nats.Header{HeaderContentEncoding: []string{"br"}}})
+	assert.ErrorContains(t, err, "unsupported content encoding")
+}
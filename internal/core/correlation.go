@@ -0,0 +1,39 @@
+package core
+
+import (
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+// HeaderCorrelationID ties the log lines and published messages of one data
+// message together. It is taken from the incoming message, or generated
+// when missing, and set on every message published for it.
+const HeaderCorrelationID = "X-Correlation-ID"
+
+// maxCorrelationIDLength bounds a correlation ID taken from a message; a
+// longer one is replaced, as it is copied into every log line
+const maxCorrelationIDLength = 128
+
+// msgScope carries the correlation ID of the data message being processed
+// and a logger that adds it to every line
+type msgScope struct {
+	correlationID string
+	log           *slog.Logger
+}
+
+// newMsgScope returns the scope of msg, generating a correlation ID when
+// msg has none
+func newMsgScope(msg *nats.Msg) *msgScope {
+	id := msg.Header.Get(HeaderCorrelationID)
+	if id == "" || len(id) > maxCorrelationIDLength {
+		id = uuid.New().String()
+	}
+	return &msgScope{correlationID: id, log: coreLog().With("correlation_id", id)}
+}
+
+// header returns the headers of a message published for the scope
+func (s *msgScope) header() nats.Header {
+	return nats.Header{HeaderCorrelationID: []string{s.correlationID}}
+}
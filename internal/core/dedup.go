@@ -0,0 +1,88 @@
+package core
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// dedupKey identifies a tag stream
+type dedupKey struct {
+	assetID string
+	tag     string
+}
+
+// lastReading is the last value forwarded for a tag stream
+type lastReading struct {
+	value   any
+	quality Quality
+	at      time.Time
+}
+
+// dedupFilter suppresses readings that repeat the last forwarded value of a
+// tag within a time window. Readings older than the window no longer
+// suppress anything and are swept out once per window.
+type dedupFilter struct {
+	window time.Duration
+
+	mu    sync.Mutex
+	last  map[dedupKey]lastReading
+	swept time.Time
+}
+
+// newDedupFilter creates a filter with the given window
+func newDedupFilter(window time.Duration) *dedupFilter {
+	return &dedupFilter{
+		window: window,
+		last:   make(map[dedupKey]lastReading),
+	}
+}
+
+// tagValue returns the comparable value of a tag, or nil if it has none
+func tagValue(v TagValue) any {
+	switch {
+	case v.Number != nil:
+		return *v.Number
+	case v.Text != nil:
+		return *v.Text
+	case v.Flag != nil:
+		return *v.Flag
+	default:
+		return nil
+	}
+}
+
+// filter returns the values of data that should be forwarded and the number
+// suppressed. A value is suppressed when it equals the last forwarded value
+// of its tag within the window and its quality is unchanged; the window is
+// measured from the last forwarded reading so unchanged values still
+// re-emit once per window.
+func (f *dedupFilter) filter(data *AssetData, now time.Time) ([]TagValue, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if now.Sub(f.swept) >= f.window {
+		maps.DeleteFunc(f.last, func(_ dedupKey, r lastReading) bool {
+			return now.Sub(r.at) >= f.window
+		})
+		f.swept = now
+	}
+
+	kept := make([]TagValue, 0, len(data.Values))
+	suppressed := 0
+	for _, v := range data.Values {
+		value := tagValue(v)
+		key := dedupKey{assetID: data.AssetID, tag: v.Name}
+		quality := v.EffectiveQuality()
+
+		if prev, ok := f.last[key]; ok && value != nil &&
+			prev.value == value && prev.quality == quality && now.Sub(prev.at) < f.window {
+			suppressed++
+			continue
+		}
+
+		f.last[key] = lastReading{value: value, quality: quality, at: now}
+		kept = append(kept, v)
+	}
+	return kept, suppressed
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dataMsg builds a data message with a single NUMBER tag
+func dataMsg(t *testing.T, assetID string, value float64, quality Quality) *nats.Msg {
+	t.Helper()
+	payload, err := json.Marshal(AssetData{
+		AssetID:   assetID,
+		Timestamp: time.Now().UnixMilli(),
+		Values:    []TagValue{{Name: "temperature", Number: &value, Quality: quality}},
+	})
+	require.NoError(t, err)
+	return &nats.Msg{Subject: SubjectDataAsset, Data: payload}
+}
+
+// TestHandleAssetData_DedupDisabledByDefault tests that every message is kept without a window
+func TestHandleAssetData_DedupDisabledByDefault(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+
+	for i := 0; i < 3; i++ {
+		handler.HandleAssetData(dataMsg(t, "sensor-001", 21.5, "good"))
+	}
+
+	assert.Equal(t, 3, handler.GetDataCount())
+	assert.Equal(t, uint64(0), handler.metrics.DedupSuppressed.Value())
+}
+
+// TestHandleAssetData_DedupSuppressesRepeats tests suppression of unchanged readings
+func TestHandleAssetData_DedupSuppressesRepeats(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+	handler.SetDedupWindow(time.Minute)
+
+	handler.HandleAssetData(dataMsg(t, "sensor-001", 21.5, "good"))
+	handler.HandleAssetData(dataMsg(t, "sensor-001", 21.5, "good")) // repeat: dropped
+	handler.HandleAssetData(dataMsg(t, "sensor-002", 21.5, "good")) // other asset: kept
+	handler.HandleAssetData(dataMsg(t, "sensor-001", 21.5, "bad"))  // quality changed: kept
+	handler.HandleAssetData(dataMsg(t, "sensor-001", 22.0, "bad"))  // value changed: kept
+
+	assert.Equal(t, 4, handler.GetDataCount())
+	assert.Equal(t, uint64(1), handler.metrics.DedupSuppressed.Value())
+}
+
+// TestHandleAssetData_DedupPartialMessage tests that only the repeated tags of a message are dropped
+func TestHandleAssetData_DedupPartialMessage(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+	handler.SetDedupWindow(time.Minute)
+
+	temperature, humidity := 21.5, 40.0
+	send := func() {
+		payload, err := json.Marshal(AssetData{
+			AssetID: "sensor-001",
+			Values: []TagValue{
+				{Name: "temperature", Number: &temperature},
+				{Name: "humidity", Number: &humidity},
+			},
+		})
+		require.NoError(t, err)
+		handler.HandleAssetData(&nats.Msg{Data: payload})
+	}
+
+	send()
+	humidity = 41.0
+	send()
+
+	require.Equal(t, 2, handler.GetDataCount())
+	second := handler.data[1]
+	require.Len(t, second.Values, 1)
+	assert.Equal(t, "humidity", second.Values[0].Name)
+}
+
+// TestDedupFilter_WindowExpiry tests that unchanged values re-emit once the window has passed
+func TestDedupFilter_WindowExpiry(t *testing.T) {
+	f := newDedupFilter(time.Second)
+	value := 1.0
+	data := &AssetData{AssetID: "a", Values: []TagValue{{Name: "t", Number: &value}}}
+	start := time.Now()
+
+	kept, suppressed := f.filter(data, start)
+	assert.Len(t, kept, 1)
+	assert.Equal(t, 0, suppressed)
+
+	kept, suppressed = f.filter(data, start.Add(500*time.Millisecond))
+	assert.Empty(t, kept)
+	assert.Equal(t, 1, suppressed)
+
+	kept, _ = f.filter(data, start.Add(1500*time.Millisecond))
+	assert.Len(t, kept, 1)
+}
+
+// TestDedupFilter_SweepsExpired tests that readings older than the window
+// are dropped from memory
+func TestDedupFilter_SweepsExpired(t *testing.T) {
+	f := newDedupFilter(time.Second)
+	value := 1.0
+	start := time.Now()
+
+	for i := 0; i < 100; i++ {
+		f.filter(&AssetData{AssetID: fmt.Sprintf("random-%d", i), Values: []TagValue{{Name: "t", Number: &value}}}, start)
+	}
+	data := &AssetData{AssetID: "a", Values: []TagValue{{Name: "t", Number: &value}}}
+	f.filter(data, start.Add(1500*time.Millisecond))
+	assert.Len(t, f.last, 1)
+
+	// A reading still inside its window is kept and keeps suppressing
+	_, suppressed := f.filter(data, start.Add(2*time.Second))
+	assert.Equal(t, 1, suppressed)
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// EdgeRising is the EdgeEvent.Edge of a false to true transition
+const EdgeRising = "rising"
+
+// EdgeEvent is published on SubjectDataEvents when a FLAG tag configured
+// for edge detection changes from false to true
+type EdgeEvent struct {
+	AssetID   string `json:"asset_id"`
+	Tag       string `json:"tag"`
+	Edge      string `json:"edge"`
+	Timestamp int64  `json:"timestamp"` // timestamp of the reading that completed the edge
+	Count     uint64 `json:"count"`     // rising edges of this tag since EDG Core started or the tag was last idle for a day
+}
+
+// edgeStateIdle is how long a tag stream may go without flag readings
+// before its state is dropped
+const edgeStateIdle = 24 * time.Hour
+
+// edgeState is the last flag value and edge count of a tag stream
+type edgeState struct {
+	value bool
+	count uint64
+	at    time.Time // when the last flag reading arrived
+}
+
+// edgeDetector tracks FLAG tags by name and reports their rising edges.
+// State lives in memory only, so the first reading after a restart sets the
+// baseline without producing an event. The state of streams idle for
+// edgeStateIdle is dropped, so deleted and one-off assets do not pile up;
+// their next reading sets a new baseline as after a restart.
+type edgeDetector struct {
+	tags map[string]bool
+
+	mu    sync.Mutex
+	state map[dedupKey]edgeState
+	swept time.Time
+}
+
+// newEdgeDetector creates a detector for the named tags
+func newEdgeDetector(tags []string) *edgeDetector {
+	d := &edgeDetector{
+		tags:  make(map[string]bool, len(tags)),
+		state: make(map[dedupKey]edgeState),
+	}
+	for _, tag := range tags {
+		d.tags[tag] = true
+	}
+	return d
+}
+
+// detect updates the state with the flag values of data received at now and
+// returns the rising edges they complete. Bad readings are ignored.
+func (d *edgeDetector) detect(data *AssetData, now time.Time) []EdgeEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.swept) >= edgeStateIdle {
+		maps.DeleteFunc(d.state, func(_ dedupKey, s edgeState) bool {
+			return now.Sub(s.at) >= edgeStateIdle
+		})
+		d.swept = now
+	}
+
+	var events []EdgeEvent
+	for _, v := range data.Values {
+		if v.Flag == nil || !d.tags[v.Name] || v.EffectiveQuality() == QualityBad {
+			continue
+		}
+
+		key := dedupKey{assetID: data.AssetID, tag: v.Name}
+		prev, seen := d.state[key]
+		next := edgeState{value: *v.Flag, count: prev.count, at: now}
+		if seen && !prev.value && next.value {
+			next.count++
+			ts := data.Timestamp
+			if v.Timestamp != nil {
+				ts = *v.Timestamp
+			}
+			events = append(events, EdgeEvent{
+				AssetID:   data.AssetID,
+				Tag:       v.Name,
+				Edge:      EdgeRising,
+				Timestamp: ts,
+				Count:     next.count,
+			})
+		}
+		d.state[key] = next
+	}
+	return events
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flagData builds a reading of one FLAG tag
+func flagData(assetID, tag string, value bool, quality Quality) *AssetData {
+	return &AssetData{
+		AssetID:   assetID,
+		Timestamp: time.Now().UnixMilli(),
+		Values:    []TagValue{{Name: tag, Flag: &value, Quality: quality}},
+	}
+}
+
+// TestEdgeDetector tests that only false to true transitions produce events
+func TestEdgeDetector(t *testing.T) {
+	d := newEdgeDetector([]string{"door_open"})
+	now := time.Now()
+
+	// The first reading only sets the baseline
+	assert.Empty(t, d.detect(flagData("door-1", "door_open", true, QualityGood), now))
+	assert.Empty(t, d.detect(flagData("door-1", "door_open", false, QualityGood), now))
+
+	events := d.detect(flagData("door-1", "door_open", true, QualityGood), now)
+	require.Len(t, events, 1)
+	assert.Equal(t, "door-1", events[0].AssetID)
+	assert.Equal(t, "door_open", events[0].Tag)
+	assert.Equal(t, EdgeRising, events[0].Edge)
+	assert.Equal(t, uint64(1), events[0].Count)
+
+	// Staying true is not an edge; a bad reading does not change the state
+	assert.Empty(t, d.detect(flagData("door-1", "door_open", true, QualityGood), now))
+	assert.Empty(t, d.detect(flagData("door-1", "door_open", false, QualityBad), now))
+	assert.Empty(t, d.detect(flagData("door-1", "door_open", true, QualityGood), now))
+
+	assert.Empty(t, d.detect(flagData("door-1", "door_open", false, QualityGood), now))
+	events = d.detect(flagData("door-1", "door_open", true, QualityGood), now)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(2), events[0].Count)
+
+	// Assets are tracked separately and unconfigured tags are ignored
+	assert.Empty(t, d.detect(flagData("door-2", "door_open", false, QualityGood), now))
+	assert.Len(t, d.detect(flagData("door-2", "door_open", true, QualityGood), now), 1)
+	assert.Empty(t, d.detect(flagData("door-1", "alarm", false, QualityGood), now))
+	assert.Empty(t, d.detect(flagData("door-1", "alarm", true, QualityGood), now))
+}
+
+// TestHandleAssetData_EdgeEvents tests publishing rising edges to the events subject
+func TestHandleAssetData_EdgeEvents(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TEST_STREAM",
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	received := make(chan *nats.Msg, 4)
+	sub, err := nc.Subscribe(SubjectDataEvents, func(msg *nats.Msg) {
+		received <- msg
+	})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	handler := NewDataHandler(js, nil)
+	handler.SetEdgeTags([]string{"door_open"})
+	for _, value := range []bool{false, true, true} {
+		payload, err := json.Marshal(flagData("door-1", "door_open", value, QualityGood))
+		require.NoError(t, err)
+		handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: payload})
+	}
+
+	select {
+	case msg := <-received:
+		var event EdgeEvent
+		require.NoError(t, json.Unmarshal(msg.Data, &event))
+		assert.Equal(t, "door_open", event.Tag)
+		assert.Equal(t, uint64(1), event.Count)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for edge event")
+	}
+	assert.Equal(t, uint64(1), handler.metrics.EdgeEvents.Value())
+}
+
+// TestEdgeDetector_DropsIdleState tests that streams idle for a day are
+// forgotten and start over from a new baseline
+func TestEdgeDetector_DropsIdleState(t *testing.T) {
+	d := newEdgeDetector([]string{"door_open"})
+	now := time.Now()
+
+	d.detect(flagData("door-1", "door_open", false, QualityGood), now)
+	require.Len(t, d.detect(flagData("door-1", "door_open", true, QualityGood), now), 1)
+	d.detect(flagData("door-1", "door_open", false, QualityGood), now)
+	d.detect(flagData("door-2", "door_open", false, QualityGood), now.Add(edgeStateIdle/2))
+
+	later := now.Add(edgeStateIdle)
+	assert.Empty(t, d.detect(flagData("door-3", "door_open", false, QualityGood), later))
+	assert.Len(t, d.state, 2)
+
+	// door-1 sets a new baseline, door-2 still completes its edge
+	assert.Empty(t, d.detect(flagData("door-1", "door_open", true, QualityGood), later))
+	events := d.detect(flagData("door-2", "door_open", true, QualityGood), later)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(1), events[0].Count)
+}
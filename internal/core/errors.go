@@ -0,0 +1,53 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds returned by Store methods, matched with errors.Is. The meta
+// handler maps each kind to an error code.
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("duplicate")
+	ErrInvalid   = errors.New("invalid")
+)
+
+// Specific errors, matched with errors.Is. Each one also matches its kind,
+// so callers that only care about the kind need not list them.
+var (
+	ErrAssetNotFound       = &kindError{kind: ErrNotFound, msg: "asset not found"}
+	ErrRelationNotFound    = &kindError{kind: ErrNotFound, msg: "relation not found"}
+	ErrDuplicateName       = &kindError{kind: ErrDuplicate, msg: "asset name already exists"}
+	ErrDuplicateAssetID    = &kindError{kind: ErrDuplicate, msg: "asset id already exists"}
+	ErrDuplicateExternalID = &kindError{kind: ErrDuplicate, msg: "external id already in use"}
+	ErrRelationExists      = &kindError{kind: ErrDuplicate, msg: "relation already exists"}
+	ErrRelationCycle       = &kindError{kind: ErrInvalid, msg: "relation would create a cycle"}
+	ErrInvalidRelationType = &kindError{kind: ErrInvalid, msg: "invalid relation type"}
+)
+
+// kindError is a specific error belonging to one of the error kinds
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Unwrap() error { return e.kind }
+
+// storeError is an error message classified by one of the error kinds or
+// specific errors
+type storeError struct {
+	kind error
+	msg  string
+}
+
+func (e *storeError) Error() string { return e.msg }
+
+func (e *storeError) Unwrap() error { return e.kind }
+
+// errorf formats an error of the given kind or specific error
+func errorf(kind error, format string, args ...any) error {
+	return &storeError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AssetEvent is published on the asset lifecycle subjects
+type AssetEvent struct {
+	AssetID   string   `json:"asset_id"`
+	Name      string   `json:"name,omitempty"`
+	Fields    []string `json:"fields,omitempty"` // updated fields; restored assets have none
+	Hard      bool     `json:"hard,omitempty"`   // deleted permanently rather than soft-deleted
+	Timestamp int64    `json:"timestamp"`        // unix milliseconds
+}
+
+// RelationEvent is published on the relation lifecycle subjects. Deletions
+// only carry the relation ID.
+type RelationEvent struct {
+	RelationID    string       `json:"relation_id"`
+	SourceAssetID string       `json:"source_asset_id,omitempty"`
+	TargetAssetID string       `json:"target_asset_id,omitempty"`
+	RelationType  RelationType `json:"relation_type,omitempty"`
+	Timestamp     int64        `json:"timestamp"` // unix milliseconds
+}
+
+// assetEvent returns the lifecycle event of asset
+func assetEvent(asset *Asset, fields ...string) AssetEvent {
+	return AssetEvent{AssetID: asset.ID, Name: asset.Name, Fields: fields, Timestamp: time.Now().UnixMilli()}
+}
+
+// relationEvent returns the lifecycle event of relation
+func relationEvent(relation *AssetRelation) RelationEvent {
+	return RelationEvent{
+		RelationID:    relation.ID,
+		SourceAssetID: relation.SourceAssetID,
+		TargetAssetID: relation.TargetAssetID,
+		RelationType:  relation.RelationType,
+		Timestamp:     time.Now().UnixMilli(),
+	}
+}
+
+// publishEvent publishes a lifecycle event on subject. Events are best
+// effort: a failed publish is logged and does not fail the request.
+func (h *MetaHandler) publishEvent(subject string, event any) {
+	if h.nc == nil {
+		return
+	}
+	subject = PrefixSubject(h.subjectPrefix, subject)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		metaLog().Warn("failed to marshal event", "subject", subject, "error", err)
+		return
+	}
+	if err := h.nc.Publish(subject, payload); err != nil {
+		metaLog().Warn("failed to publish event", "subject", subject, "error", err)
+	}
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextEvent waits for the next lifecycle event on sub and decodes it into v
+func nextEvent(t *testing.T, sub *nats.Subscription, subject string, v any) {
+	t.Helper()
+	msg, err := sub.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, subject, msg.Subject)
+	require.NoError(t, json.Unmarshal(msg.Data, v))
+}
+
+// TestAssetLifecycleEvents tests that asset CRUD through the handler publishes events
+func TestAssetLifecycleEvents(t *testing.T) {
+	_, nc := newTestMetaHandler(t)
+	sub, err := nc.SubscribeSync("platform.events.asset.>")
+	require.NoError(t, err)
+	require.NoError(t, nc.Flush())
+
+	resp := request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-1"})
+	require.True(t, resp.Success, resp.Error)
+	var asset Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &asset))
+
+	var event AssetEvent
+	nextEvent(t, sub, SubjectEventAssetCreated, &event)
+	assert.Equal(t, asset.ID, event.AssetID)
+	assert.Equal(t, "pump-1", event.Name)
+	assert.NotZero(t, event.Timestamp)
+
+	labels := []string{"hall-a"}
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: asset.ID, Labels: &labels})
+	require.True(t, resp.Success, resp.Error)
+	event = AssetEvent{}
+	nextEvent(t, sub, SubjectEventAssetUpdated, &event)
+	assert.Equal(t, asset.ID, event.AssetID)
+	assert.Equal(t, []string{"labels"}, event.Fields)
+
+	resp = request(t, nc, SubjectAssetDelete, DeleteAssetRequest{ID: asset.ID, Hard: true})
+	require.True(t, resp.Success, resp.Error)
+	event = AssetEvent{}
+	nextEvent(t, sub, SubjectEventAssetDeleted, &event)
+	assert.Equal(t, asset.ID, event.AssetID)
+	assert.True(t, event.Hard)
+
+	// A failed operation publishes nothing
+	resp = request(t, nc, SubjectAssetDelete, DeleteAssetRequest{ID: asset.ID})
+	require.False(t, resp.Success)
+	_, err = sub.NextMsg(100 * time.Millisecond)
+	assert.ErrorIs(t, err, nats.ErrTimeout)
+}
+
+// TestRelationLifecycleEvents tests that relation CRUD through the handler publishes events
+func TestRelationLifecycleEvents(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "line", "machine")
+	sub, err := nc.SubscribeSync("platform.events.relation.>")
+	require.NoError(t, err)
+	require.NoError(t, nc.Flush())
+
+	resp := request(t, nc, SubjectRelationCreate, CreateRelationRequest{
+		SourceAssetID: "machine",
+		TargetAssetID: "line",
+		RelationType:  RelationPartOf,
+	})
+	require.True(t, resp.Success, resp.Error)
+	var relation AssetRelation
+	require.NoError(t, json.Unmarshal(resp.Data, &relation))
+
+	var event RelationEvent
+	nextEvent(t, sub, SubjectEventRelationCreated, &event)
+	assert.Equal(t, relation.ID, event.RelationID)
+	assert.Equal(t, "machine", event.SourceAssetID)
+	assert.Equal(t, "line", event.TargetAssetID)
+	assert.Equal(t, RelationPartOf, event.RelationType)
+
+	resp = request(t, nc, SubjectRelationUpdate, UpdateRelationRequest{ID: relation.ID, Metadata: map[string]string{"slot": "3"}})
+	require.True(t, resp.Success, resp.Error)
+	event = RelationEvent{}
+	nextEvent(t, sub, SubjectEventRelationUpdated, &event)
+	assert.Equal(t, relation.ID, event.RelationID)
+
+	resp = request(t, nc, SubjectRelationDelete, DeleteRelationRequest{ID: relation.ID})
+	require.True(t, resp.Success, resp.Error)
+	event = RelationEvent{}
+	nextEvent(t, sub, SubjectEventRelationDeleted, &event)
+	assert.Equal(t, relation.ID, event.RelationID)
+}
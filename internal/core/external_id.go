@@ -0,0 +1,82 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// externalIDSchemePattern limits schemes to lowercase names that are safe to
+// embed in a JSON path and an index name
+var externalIDSchemePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// externalIDIndexPrefix names the partial unique index created per scheme
+const externalIDIndexPrefix = "idx_assets_external_id_"
+
+// externalIDExpr selects the value of scheme from the external_ids column.
+// Lookups must use the same expression text for SQLite to use the scheme's
+// index.
+func externalIDExpr(scheme string) string {
+	return `json_extract(external_ids, '$.` + scheme + `')`
+}
+
+// validateExternalIDScheme checks that scheme can be stored and looked up
+func validateExternalIDScheme(scheme string) error {
+	if !externalIDSchemePattern.MatchString(scheme) {
+		return errorf(ErrInvalid, "invalid external id scheme %q: use lowercase letters, digits and underscores", scheme)
+	}
+	return nil
+}
+
+// validateExternalIDs checks every scheme and rejects empty values
+func validateExternalIDs(ids map[string]string) error {
+	for scheme, value := range ids {
+		if err := validateExternalIDScheme(scheme); err != nil {
+			return err
+		}
+		if value == "" {
+			return errorf(ErrInvalid, "external id for scheme %q must not be empty", scheme)
+		}
+	}
+	return nil
+}
+
+// prepareExternalIDs validates ids and returns the external_ids column
+// value, NULL when there are none. Each scheme gets a partial unique index
+// the first time it is written, so a value belongs to at most one asset,
+// soft-deleted ones included, just like names.
+func prepareExternalIDs(q querier, ids map[string]string) (any, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := validateExternalIDs(ids); err != nil {
+		return nil, err
+	}
+
+	for scheme := range ids {
+		expr := externalIDExpr(scheme)
+		if _, err := q.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS ` + externalIDIndexPrefix + scheme +
+			` ON assets(` + expr + `) WHERE ` + expr + ` IS NOT NULL`); err != nil {
+			return nil, fmt.Errorf("failed to index external id scheme %s: %w", scheme, err)
+		}
+	}
+
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal external ids: %w", err)
+	}
+	return string(data), nil
+}
+
+// duplicateAssetError converts a UNIQUE violation writing asset into an
+// ErrDuplicate naming the field that collided
+func duplicateAssetError(err error, asset *Asset) error {
+	if strings.Contains(err.Error(), externalIDIndexPrefix) {
+		return errorf(ErrDuplicateExternalID, "external id already in use: %s", asset.ID)
+	}
+	if strings.Contains(err.Error(), "assets.id") {
+		return errorf(ErrDuplicateAssetID, "asset id already exists: %s", asset.ID)
+	}
+	return errorf(ErrDuplicateName, "asset name already exists: %s", asset.Name)
+}
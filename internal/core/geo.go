@@ -0,0 +1,55 @@
+package core
+
+import "math"
+
+// earthRadiusMeters is the mean Earth radius used for distances
+const earthRadiusMeters = 6371008.8
+
+// validateLocation checks that latitude and longitude are set together and
+// within range; an asset without coordinates is valid
+func validateLocation(lat, lon *float64) error {
+	if (lat == nil) != (lon == nil) {
+		return errorf(ErrInvalid, "latitude and longitude must be set together")
+	}
+	if lat == nil {
+		return nil
+	}
+	if *lat < -90 || *lat > 90 {
+		return errorf(ErrInvalid, "latitude out of range: %g", *lat)
+	}
+	if *lon < -180 || *lon > 180 {
+		return errorf(ErrInvalid, "longitude out of range: %g", *lon)
+	}
+	return nil
+}
+
+// haversineMeters returns the great-circle distance between two points
+func haversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// boundingBox returns the latitude and longitude ranges containing every
+// point within radius of (lat, lon). ok is false for the longitude range
+// when the circle reaches a pole or wraps the antimeridian, in which case
+// longitude must not be prefiltered.
+func boundingBox(lat, lon, radius float64) (minLat, maxLat, minLon, maxLon float64, ok bool) {
+	angular := radius / earthRadiusMeters
+	dLat := angular * 180 / math.Pi
+	minLat, maxLat = math.Max(lat-dLat, -90), math.Min(lat+dLat, 90)
+
+	sinRatio := math.Sin(angular) / math.Cos(lat*math.Pi/180)
+	if angular >= math.Pi/2 || sinRatio >= 1 {
+		return minLat, maxLat, 0, 0, false
+	}
+	dLon := math.Asin(sinRatio) * 180 / math.Pi
+	minLon, maxLon = lon-dLon, lon+dLon
+	if minLon < -180 || maxLon > 180 {
+		return minLat, maxLat, 0, 0, false
+	}
+	return minLat, maxLat, minLon, maxLon, true
+}
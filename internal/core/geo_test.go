@@ -0,0 +1,54 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHaversineMeters tests distances against known values
+func TestHaversineMeters(t *testing.T) {
+	assert.Zero(t, haversineMeters(37.5665, 126.978, 37.5665, 126.978))
+	// One degree of latitude is about 111.2 km
+	assert.InDelta(t, 111195, haversineMeters(0, 0, 1, 0), 10)
+	// Seoul to Busan is about 325 km
+	assert.InDelta(t, 325000, haversineMeters(37.5665, 126.978, 35.1796, 129.0756), 5000)
+	// Antipodal points are half the circumference apart
+	assert.InDelta(t, 20015114, haversineMeters(0, 0, 0, 180), 10)
+}
+
+// TestValidateLocation tests pairing and range checks
+func TestValidateLocation(t *testing.T) {
+	lat, lon := 37.5, 127.0
+	badLat, badLon := -90.5, 180.5
+
+	assert.NoError(t, validateLocation(nil, nil))
+	assert.NoError(t, validateLocation(&lat, &lon))
+	assert.ErrorIs(t, validateLocation(&lat, nil), ErrInvalid)
+	assert.ErrorIs(t, validateLocation(nil, &lon), ErrInvalid)
+	assert.ErrorContains(t, validateLocation(&badLat, &lon), "latitude out of range")
+	assert.ErrorContains(t, validateLocation(&lat, &badLon), "longitude out of range")
+}
+
+// TestBoundingBox tests that the box contains the circle and gives up on
+// longitude near the poles and antimeridian
+func TestBoundingBox(t *testing.T) {
+	minLat, maxLat, minLon, maxLon, ok := boundingBox(37.5, 127.0, 10000)
+	assert.True(t, ok)
+	assert.InDelta(t, 37.41, minLat, 0.01)
+	assert.InDelta(t, 37.59, maxLat, 0.01)
+	assert.Less(t, minLon, 127.0-0.09)
+	assert.Greater(t, maxLon, 127.0+0.09)
+	assert.LessOrEqual(t, haversineMeters(37.5, 127.0, 37.5, maxLon), 10000.0+1)
+
+	_, _, _, _, ok = boundingBox(89.95, 0, 10000)
+	assert.False(t, ok, "circle reaching the pole")
+
+	_, _, _, _, ok = boundingBox(0, 179.99, 10000)
+	assert.False(t, ok, "circle crossing the antimeridian")
+
+	minLat, maxLat, _, _, ok = boundingBox(0, 0, 30000000)
+	assert.False(t, ok)
+	assert.Equal(t, -90.0, minLat)
+	assert.Equal(t, 90.0, maxLat)
+}
@@ -2,78 +2,863 @@ package core
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// DataStreamName is the JetStream stream that captures every data subject
+const DataStreamName = "PLATFORM_DATA"
+
+// MetadataTemplate is the AssetData.Metadata key naming the template to use
+// when the sending asset is auto-registered or has no template yet
+const MetadataTemplate = "template"
+
+// DefaultTimestampWindow is the clock skew tolerated by cmd/core unless
+// configured otherwise
+const DefaultTimestampWindow = 24 * time.Hour
+
+// DefaultMaxPayload is the largest data message or metadata request, in
+// bytes, that cmd/core parses unless configured otherwise. It matches the
+// default max_payload of the NATS server.
+const DefaultMaxPayload = 1 << 20
+
+// errTimestampOutOfRange is the rejection reason for readings outside the
+// timestamp window
+var errTimestampOutOfRange = errors.New("timestamp out of range")
+
+// unixSecondsLimit separates the two accepted timestamp units: smaller
+// magnitudes are unix seconds (until the year 5138), larger ones unix
+// milliseconds (from 1973)
+const unixSecondsLimit = 1e11
+
+// timestampTime converts an AssetData timestamp in unix seconds or
+// milliseconds to a time
+func timestampTime(ts int64) time.Time {
+	if ts > -unixSecondsLimit && ts < unixSecondsLimit {
+		return time.Unix(ts, 0)
+	}
+	return time.UnixMilli(ts)
+}
+
+// timestampMillis converts an AssetData timestamp in unix seconds or
+// milliseconds to unix milliseconds
+func timestampMillis(ts int64) int64 {
+	return timestampTime(ts).UnixMilli()
+}
+
+// PublishConfig controls how JetStream publishes are retried
+type PublishConfig struct {
+	Attempts int           // total publish attempts (minimum 1)
+	Backoff  time.Duration // delay before the first retry, doubled on each retry
+
+	// DeadLetterFile receives messages whose publish failed on every attempt,
+	// one JSON object per line. When empty they go to SubjectDataDeadLetter.
+	DeadLetterFile string
+
+	// CompressAbove gzips validated data payloads larger than this many
+	// bytes, marked with HeaderContentEncoding; 0 disables compression
+	CompressAbove int
+}
+
+// DefaultPublishConfig returns the publish settings used by NewDataHandler
+func DefaultPublishConfig() PublishConfig {
+	return PublishConfig{
+		Attempts: 3,
+		Backoff:  100 * time.Millisecond,
+	}
+}
+
+// DeadLetter is a message that could not be published to JetStream
+type DeadLetter struct {
+	Subject       string          `json:"subject"`
+	Error         string          `json:"error"`
+	Data          json.RawMessage `json:"data"`
+	CorrelationID string          `json:"correlation_id,omitempty"` // see HeaderCorrelationID
+}
+
+// RejectedData is published on SubjectDataRejected when a message fails validation
+type RejectedData struct {
+	AssetID string          `json:"asset_id"`
+	Error   string          `json:"error"`
+	Data    json.RawMessage `json:"data"`
+
+	// Index is the position of the rejected item in a batch received on
+	// SubjectDataBatch; absent for single messages
+	Index *int `json:"index,omitempty"`
+}
+
+// AssetDataBatch is the payload of SubjectDataBatch: readings buffered by an
+// adapter and sent in one message. Each item is an AssetData handled as if
+// it had been sent on SubjectDataAsset.
+type AssetDataBatch struct {
+	Items []json.RawMessage `json:"items"`
+}
+
 // DataHandler handles NATS messages for asset data
 type DataHandler struct {
-	mu    sync.Mutex
-	data  []AssetData            // in-memory storage (PoC)
-	store *Store                 // for auto-registration
-	js    nats.JetStreamContext  // for publishing to JetStream
+	mu      sync.Mutex
+	data    []AssetData           // in-memory fallback when store is nil
+	store   AssetStore            // for auto-registration and persistence
+	js      nats.JetStreamContext // for publishing to JetStream
+	loader  *TemplateLoader       // for template validation (optional)
+	metrics *Metrics
+	publish PublishConfig
+	dlMu    sync.Mutex   // serializes dead-letter file appends
+	dedup   *dedupFilter // nil when deduplication is disabled
+	limiter *rateLimiter // nil when rate limiting is disabled
+
+	lastSeen *LastSeenTracker // nil when last-seen tracking is disabled
+	inFlight *InFlight        // nil when messages are not tracked
+	edges    *edgeDetector    // nil when edge detection is disabled
+
+	autoRegister bool // create unknown assets instead of diverting their data
+
+	timestampWindow      time.Duration // zero disables the timestamp range check
+	fillMissingTimestamp bool          // replace a zero timestamp with server time
+
+	idempotent bool                // drop messages whose content hash was already handled
+	hashes     map[string]struct{} // seen content hashes for the in-memory fallback
+
+	minQuality Quality // tag values below this are rejected; empty disables the filter
+
+	coerceValues bool // convert TEXT values of NUMBER and FLAG tags before validation
+
+	subjectPrefix string // replaces DefaultSubjectPrefix in published subjects
+
+	schemaPolicy SchemaPolicy // handling of unknown schema versions
+
+	maxPayload int // larger messages are dropped unparsed; zero disables the limit
+}
+
+func NewDataHandler(js nats.JetStreamContext, store AssetStore) *DataHandler {
+	h := &DataHandler{
+		data:         make([]AssetData, 0),
+		store:        store,
+		js:           js,
+		publish:      DefaultPublishConfig(),
+		autoRegister: true,
+		schemaPolicy: SchemaPolicyReject,
+		maxPayload:   DefaultMaxPayload,
+	}
+	h.SetMetrics(NewMetrics())
+	return h
+}
+
+// SetMetrics replaces the handler's metrics and registers the stored data gauge
+func (h *DataHandler) SetMetrics(m *Metrics) {
+	h.metrics = m
+	m.RegisterGauge("edg_data_stored", "Asset data entries currently stored.", func() float64 {
+		return float64(h.GetDataCount())
+	})
+}
+
+// SetTemplateLoader enables validation of incoming data against the
+// template of the sending asset
+func (h *DataHandler) SetTemplateLoader(loader *TemplateLoader) {
+	h.loader = loader
+}
+
+// SetPublishConfig replaces the JetStream publish retry settings
+func (h *DataHandler) SetPublishConfig(cfg PublishConfig) {
+	if cfg.Attempts < 1 {
+		cfg.Attempts = 1
+	}
+	h.publish = cfg
+}
+
+// SetDedupWindow enables suppression of unchanged readings: a tag value equal
+// to the last forwarded value of the same asset and tag, with the same
+// quality, is dropped if it arrives within window. Zero disables it.
+func (h *DataHandler) SetDedupWindow(window time.Duration) {
+	if window <= 0 {
+		h.dedup = nil
+		return
+	}
+	h.dedup = newDedupFilter(window)
+}
+
+// SetRateLimit limits each asset to perSecond messages per second; excess
+// messages are dropped. Zero or less means unlimited.
+func (h *DataHandler) SetRateLimit(perSecond float64) {
+	if perSecond <= 0 {
+		h.limiter = nil
+		return
+	}
+	h.limiter = newRateLimiter(perSecond)
+}
+
+// SetEdgeTags enables rising edge detection for FLAG tags with the given
+// names, on every asset: each false to true transition of an accepted
+// reading publishes an EdgeEvent to SubjectDataEvents. Empty disables it.
+func (h *DataHandler) SetEdgeTags(tags []string) {
+	if len(tags) == 0 {
+		h.edges = nil
+		return
+	}
+	h.edges = newEdgeDetector(tags)
+}
+
+// SetLastSeenTracker records every asset whose data is accepted in tracker.
+// Rejected, diverted and rate-limited messages do not count. Nil disables it.
+func (h *DataHandler) SetLastSeenTracker(tracker *LastSeenTracker) {
+	h.lastSeen = tracker
+}
+
+// SetInFlight counts every message handled by HandleAssetData in tracker
+func (h *DataHandler) SetInFlight(tracker *InFlight) {
+	h.inFlight = tracker
+}
+
+// SetAutoRegister controls what happens to data from assets missing in the
+// store. When enabled (the default) the asset is created; otherwise the
+// message is published to SubjectDataUnregistered and not stored.
+func (h *DataHandler) SetAutoRegister(enabled bool) {
+	h.autoRegister = enabled
+}
+
+// SetTimestampWindow rejects data whose timestamp is more than window away
+// from the server clock, in either direction. Zero or less disables the check.
+func (h *DataHandler) SetTimestampWindow(window time.Duration) {
+	if window < 0 {
+		window = 0
+	}
+	h.timestampWindow = window
+}
+
+// SetFillMissingTimestamp makes a zero AssetData timestamp mean "now": it is
+// replaced with the server time in unix milliseconds before validation
+func (h *DataHandler) SetFillMissingTimestamp(enabled bool) {
+	h.fillMissingTimestamp = enabled
+}
+
+// SetMaxPayload drops data and batch messages larger than n bytes before
+// they are parsed. Zero or less disables the limit.
+func (h *DataHandler) SetMaxPayload(n int) {
+	if n < 0 {
+		n = 0
+	}
+	h.maxPayload = n
+}
+
+// oversized reports whether msg exceeds the payload limit, logging and
+// counting it if so
+func (h *DataHandler) oversized(sc *msgScope, msg *nats.Msg) bool {
+	if h.maxPayload == 0 || len(msg.Data) <= h.maxPayload {
+		return false
+	}
+	h.metrics.OversizedMessages.Inc()
+	sc.log.Warn("dropped oversized message", "subject", msg.Subject, "size", len(msg.Data), "max_payload", h.maxPayload)
+	return true
+}
+
+// SetCoerceValues enables lenient ingest: TEXT values of tags that the
+// asset's template declares as NUMBER or FLAG are converted before
+// validation, and values that do not convert are rejected. Off by default.
+func (h *DataHandler) SetCoerceValues(enabled bool) {
+	h.coerceValues = enabled
 }
 
-func NewDataHandler(js nats.JetStreamContext, store *Store) *DataHandler {
-	return &DataHandler{
-		data:  make([]AssetData, 0),
-		store: store,
-		js:    js,
+// SetIdempotent makes redelivered readings no-ops: each message's
+// ContentHash is stored with it, and a message whose hash is already stored
+// is dropped without being counted or republished. Off by default.
+func (h *DataHandler) SetIdempotent(enabled bool) {
+	h.idempotent = enabled
+	if enabled && h.hashes == nil {
+		h.hashes = make(map[string]struct{})
 	}
 }
 
-// HandleAssetData processes incoming NATS messages
+// SetMinQuality rejects tag values whose effective quality is below min:
+// they are removed from the message and published to SubjectDataRejected,
+// while the remaining values are processed as usual. Empty disables it.
+func (h *DataHandler) SetMinQuality(min Quality) {
+	h.minQuality = min
+}
+
+// SetSchemaPolicy sets what happens to data whose schema version this build
+// does not know. The default is SchemaPolicyReject.
+func (h *DataHandler) SetSchemaPolicy(policy SchemaPolicy) {
+	h.schemaPolicy = policy
+}
+
+// SetSubjectPrefix publishes validated, rejected, unregistered and
+// dead-lettered data under prefix instead of DefaultSubjectPrefix
+func (h *DataHandler) SetSubjectPrefix(prefix string) {
+	h.subjectPrefix = prefix
+}
+
+// HandleAssetData processes incoming NATS messages. The message's
+// HeaderCorrelationID, or a generated one, is logged with every line about
+// it and set on the messages published for it.
 func (h *DataHandler) HandleAssetData(msg *nats.Msg) {
+	if h.inFlight != nil {
+		h.inFlight.begin()
+		defer h.inFlight.done()
+	}
+	h.metrics.MessagesReceived.Inc()
+	sc := newMsgScope(msg)
+	if h.oversized(sc, msg) {
+		return
+	}
+
+	_, span := startMessageSpan(msg, trace.SpanKindConsumer)
+	defer span.End()
+
 	var data AssetData
 	if err := json.Unmarshal(msg.Data, &data); err != nil {
-		log.Printf("[Core] Error parsing message: %v", err)
+		sc.log.Warn("failed to parse message", "subject", msg.Subject, "error", err)
+		span.SetStatus(codes.Error, "failed to parse message")
 		return
 	}
+	span.SetAttributes(attrAssetID.String(data.AssetID), attrTagCount.Int(len(data.Values)))
+
+	if err := h.process(sc, msg.Data, &data); err != nil {
+		h.reject(sc, RejectedData{AssetID: data.AssetID, Error: err.Error(), Data: msg.Data})
+	}
+}
+
+// HandleAssetDataBatch processes a batch of readings received on
+// SubjectDataBatch. Every item goes through the same pipeline as a message
+// on SubjectDataAsset; an item that cannot be parsed or fails validation is
+// rejected with its index without affecting the others. All items share
+// the correlation ID of the batch message.
+func (h *DataHandler) HandleAssetDataBatch(msg *nats.Msg) {
+	if h.inFlight != nil {
+		h.inFlight.begin()
+		defer h.inFlight.done()
+	}
+	sc := newMsgScope(msg)
+	if h.oversized(sc, msg) {
+		return
+	}
+
+	_, span := startMessageSpan(msg, trace.SpanKindConsumer)
+	defer span.End()
+
+	var batch AssetDataBatch
+	if err := json.Unmarshal(msg.Data, &batch); err != nil {
+		sc.log.Warn("failed to parse batch", "subject", msg.Subject, "error", err)
+		span.SetStatus(codes.Error, "failed to parse batch")
+		return
+	}
+	span.SetAttributes(attrBatchSize.Int(len(batch.Items)))
+
+	rejected := 0
+	for i, item := range batch.Items {
+		h.metrics.MessagesReceived.Inc()
+
+		var data AssetData
+		err := json.Unmarshal(item, &data)
+		if err == nil {
+			err = h.process(sc, item, &data)
+		}
+		if err != nil {
+			index := i
+			h.reject(sc, RejectedData{AssetID: data.AssetID, Error: err.Error(), Data: item, Index: &index})
+			rejected++
+		}
+	}
+	if rejected > 0 {
+		span.SetStatus(codes.Error, "batch items rejected")
+	}
+	sc.log.Debug("asset data batch received", "items", len(batch.Items), "rejected", rejected)
+}
+
+// process validates, persists and publishes one reading whose raw form is
+// raw, logging and publishing under sc. It returns the reason when the
+// reading is rejected; readings that are dropped, diverted or only partly
+// accepted are not rejections.
+func (h *DataHandler) process(sc *msgScope, raw []byte, data *AssetData) error {
+	if err := h.checkSchemaVersion(sc, data); err != nil {
+		return err
+	}
+
+	if h.limiter != nil && !h.limiter.allow(data.AssetID, time.Now()) {
+		h.metrics.RateLimited.Inc()
+		sc.log.Warn("rate limit exceeded, dropping message", "asset_id", data.AssetID)
+		return nil
+	}
+
+	payload := raw
+	rewritten := h.normalizeQualities(sc, data)
+	if data.Timestamp == 0 && h.fillMissingTimestamp {
+		data.Timestamp = time.Now().UnixMilli()
+		rewritten = true
+	}
+	if rewritten {
+		normalized, err := json.Marshal(data)
+		if err != nil {
+			sc.log.Error("failed to marshal data", "asset_id", data.AssetID, "error", err)
+			return nil
+		}
+		payload = normalized
+	}
+
+	// Reject readings from devices with a badly skewed clock
+	if h.timestampWindow > 0 {
+		skew := time.Since(timestampTime(data.Timestamp))
+		if skew > h.timestampWindow || skew < -h.timestampWindow {
+			return errTimestampOutOfRange
+		}
+	}
+
+	// Hash the reading as received, before deduplication alters its values
+	var hash string
+	if h.idempotent {
+		var err error
+		if hash, err = ContentHash(data); err != nil {
+			sc.log.Error("failed to hash data", "asset_id", data.AssetID, "error", err)
+			return nil
+		}
+	}
 
-	// Auto-register asset if not exists
 	if h.store != nil {
-		if exists, _ := h.store.AssetExists(data.AssetID); !exists {
-			asset := &Asset{
-				ID:        data.AssetID,
-				Name:      data.AssetID,
-				CreatedAt: time.Now(),
+		asset, err := h.store.GetAsset(data.AssetID)
+		if err != nil {
+			sc.log.Error("failed to look up asset", "asset_id", data.AssetID, "error", err)
+		} else if asset == nil {
+			if !h.autoRegister {
+				h.divertUnregistered(sc, raw, data.AssetID)
+				return nil
 			}
-			if err := h.store.CreateAsset(asset); err == nil {
-				log.Printf("[Core] Auto-registered asset: %s", data.AssetID)
+			asset = h.autoRegisterAsset(sc, data)
+		} else if h.autoRegister && asset.TemplateName == "" {
+			asset = h.adoptTemplate(sc, asset, data)
+		}
+
+		// Validate against the asset's template; assets without one pass through
+		if asset != nil && asset.TemplateName != "" && h.loader != nil {
+			n, err := h.loader.checkAssetData(asset, data, h.coerceValues)
+			h.metrics.ValuesCoerced.Add(uint64(n))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				coerced, err := json.Marshal(data)
+				if err != nil {
+					sc.log.Error("failed to marshal coerced data", "asset_id", data.AssetID, "error", err)
+					return nil
+				}
+				payload = coerced
 			}
 		}
 	}
 
-	h.mu.Lock()
-	h.data = append(h.data, data)
-	h.mu.Unlock()
+	// Split off readings below the minimum quality
+	if h.minQuality != "" {
+		kept, dropped := filterQuality(data.Values, h.minQuality)
+		if len(dropped) > 0 {
+			h.rejectQuality(sc, data, dropped)
+			if len(kept) == 0 {
+				return nil
+			}
+			data.Values = kept
+			filtered, err := json.Marshal(data)
+			if err != nil {
+				sc.log.Error("failed to marshal quality-filtered data", "asset_id", data.AssetID, "error", err)
+				return nil
+			}
+			payload = filtered
+		}
+	}
+
+	// The data is accepted from here on, even if deduplication drops it all
+	if h.lastSeen != nil {
+		h.lastSeen.Seen(data.AssetID, time.Now())
+	}
+
+	// Drop readings that repeat the last forwarded value
+	if h.dedup != nil {
+		kept, suppressed := h.dedup.filter(data, time.Now())
+		if suppressed > 0 {
+			h.metrics.DedupSuppressed.Add(uint64(suppressed))
+			if len(kept) == 0 {
+				sc.log.Debug("suppressed unchanged data", "asset_id", data.AssetID, "tag_count", suppressed)
+				return nil
+			}
+			data.Values = kept
+			filtered, err := json.Marshal(data)
+			if err != nil {
+				sc.log.Error("failed to marshal deduplicated data", "asset_id", data.AssetID, "error", err)
+				return nil
+			}
+			payload = filtered
+		}
+	}
+
+	// Tags without their own timestamp inherit the envelope timestamp
+	for i := range data.Values {
+		if data.Values[i].Timestamp == nil {
+			ts := data.Timestamp
+			data.Values[i].Timestamp = &ts
+		}
+	}
+
+	// Persist through the store when configured, otherwise keep in memory
+	if !h.persist(sc, data, hash) {
+		h.metrics.DuplicatesSkipped.Inc()
+		sc.log.Debug("skipped duplicate data", "asset_id", data.AssetID, "content_hash", hash)
+		return nil
+	}
 
 	// Publish validated data to JetStream for persistence
 	if h.js != nil {
-		if _, err := h.js.Publish("platform.data.validated", msg.Data); err != nil {
-			log.Printf("[Core] Failed to publish to JetStream: %v", err)
-		}
+		h.publishValidated(sc, payload)
+	}
+
+	if h.edges != nil {
+		h.publishEdges(sc, h.edges.detect(data, time.Now()))
 	}
 
-	// Log output
-	log.Printf("[Core] Asset: %s, Tags: %d", data.AssetID, len(data.Values))
+	// Log output; individual tag values are only emitted at debug level
+	log := sc.log.With("asset_id", data.AssetID)
+	log.Info("asset data received", "tag_count", len(data.Values))
 	for _, v := range data.Values {
+		var value any
 		switch {
 		case v.Number != nil:
-			log.Printf("       ├─ %s = %.2f %s [%s]", v.Name, *v.Number, v.Unit, v.Quality)
+			value = *v.Number
 		case v.Text != nil:
-			log.Printf("       ├─ %s = %q [%s]", v.Name, *v.Text, v.Quality)
+			value = *v.Text
 		case v.Flag != nil:
-			log.Printf("       ├─ %s = %v [%s]", v.Name, *v.Flag, v.Quality)
+			value = *v.Flag
+		default:
+			continue
+		}
+		log.Debug("tag value", "tag", v.Name, "value", value, "unit", v.Unit, "quality", v.EffectiveQuality())
+	}
+	return nil
+}
+
+// checkSchemaVersion returns an error when data, in its schema version,
+// cannot be processed. Unknown versions are counted and handled per the
+// schema policy.
+func (h *DataHandler) checkSchemaVersion(sc *msgScope, data *AssetData) error {
+	switch version := data.EffectiveSchemaVersion(); version {
+	case 1:
+		return nil
+	default:
+		h.metrics.UnknownSchema.Inc()
+		if h.schemaPolicy == SchemaPolicyAccept {
+			sc.log.Warn("unknown schema version, processing as current", "asset_id", data.AssetID,
+				"schema_version", version, "current", CurrentSchemaVersion)
+			return nil
+		}
+		return fmt.Errorf("unsupported schema version %d (newest known %d)", version, CurrentSchemaVersion)
+	}
+}
+
+// autoRegisterAsset creates an asset for data from an unknown sender. The
+// template named in the data's metadata is used when the loader knows it.
+func (h *DataHandler) autoRegisterAsset(sc *msgScope, data *AssetData) *Asset {
+	asset := &Asset{
+		ID:        data.AssetID,
+		Name:      data.AssetID,
+		CreatedAt: time.Now(),
+	}
+	if name := data.Metadata[MetadataTemplate]; name != "" {
+		if h.loader != nil && h.loader.Exists(name) {
+			asset.TemplateName = name
+			asset.TemplateVersion = h.loader.GetVersion(name)
+		} else {
+			sc.log.Warn("ignoring unknown template for auto-registered asset", "asset_id", data.AssetID, "template", name)
+		}
+	}
+	created, err := h.store.EnsureAsset(asset)
+	if err != nil {
+		sc.log.Warn("failed to auto-register asset", "asset_id", data.AssetID, "error", err)
+		return nil
+	}
+	if !created {
+		// Registered concurrently by another reading, or soft-deleted, in
+		// which case the ID stays taken and nil is returned
+		existing, err := h.store.GetAsset(data.AssetID)
+		if err != nil {
+			sc.log.Error("failed to look up asset", "asset_id", data.AssetID, "error", err)
+		} else if existing == nil {
+			sc.log.Warn("not auto-registering soft-deleted asset", "asset_id", data.AssetID)
+		}
+		return existing
+	}
+	h.metrics.AssetsAutoRegistered.Inc()
+	sc.log.Info("auto-registered asset", "asset_id", data.AssetID, "template", asset.TemplateName)
+	return asset
+}
+
+// adoptTemplate assigns the template named in the data's metadata to an
+// asset registered without one, so data from it is validated from then on.
+// It returns the asset as it should be validated.
+func (h *DataHandler) adoptTemplate(sc *msgScope, asset *Asset, data *AssetData) *Asset {
+	name := data.Metadata[MetadataTemplate]
+	if name == "" || h.loader == nil || !h.loader.Exists(name) {
+		return asset
+	}
+	version := h.loader.GetVersion(name)
+	adopted, err := h.store.AdoptAssetTemplate(asset.ID, name, version)
+	if err != nil {
+		sc.log.Warn("failed to assign template to asset", "asset_id", asset.ID, "template", name, "error", err)
+		return asset
+	}
+	if !adopted {
+		// Assigned meanwhile, possibly by an operator, whose choice stands
+		if current, err := h.store.GetAsset(asset.ID); err == nil && current != nil {
+			return current
+		}
+		return asset
+	}
+	sc.log.Info("assigned template to asset", "asset_id", asset.ID, "template", name)
+
+	// The looked up asset may be shared with the cache
+	updated := *asset
+	updated.TemplateName = name
+	updated.TemplateVersion = version
+	return &updated
+}
+
+// divertUnregistered routes data from an unknown asset to
+// SubjectDataUnregistered when auto-registration is disabled
+func (h *DataHandler) divertUnregistered(sc *msgScope, raw []byte, assetID string) {
+	h.metrics.UnregisteredData.Inc()
+	sc.log.Warn("data from unregistered asset", "asset_id", assetID)
+
+	if h.js == nil {
+		return
+	}
+	h.publishWithRetry(sc, PrefixSubject(h.subjectPrefix, SubjectDataUnregistered), raw)
+}
+
+// normalizeQualities rewrites every tag quality to its canonical level and
+// reports whether any changed. Unknown qualities become uncertain.
+func (h *DataHandler) normalizeQualities(sc *msgScope, data *AssetData) bool {
+	changed := false
+	for i := range data.Values {
+		v := &data.Values[i]
+		quality, known := NormalizeQuality(string(v.Quality))
+		if !known {
+			h.metrics.UnknownQuality.Inc()
+			sc.log.Warn("unknown tag quality, treating as uncertain", "asset_id", data.AssetID, "tag", v.Name, "quality", v.Quality)
+		}
+		if quality != v.Quality {
+			v.Quality = quality
+			changed = true
+		}
+	}
+	return changed
+}
+
+// filterQuality splits values into those at or above min and those below,
+// judged by their effective quality
+func filterQuality(values []TagValue, min Quality) (kept, dropped []TagValue) {
+	kept = make([]TagValue, 0, len(values))
+	for _, v := range values {
+		if v.EffectiveQuality().AtLeast(min) {
+			kept = append(kept, v)
+		} else {
+			dropped = append(dropped, v)
+		}
+	}
+	return kept, dropped
+}
+
+// rejectQuality routes tag values below the minimum quality to
+// SubjectDataRejected as a message holding only those values
+func (h *DataHandler) rejectQuality(sc *msgScope, data *AssetData, dropped []TagValue) {
+	h.metrics.QualityRejected.Add(uint64(len(dropped)))
+	sc.log.Debug("rejected low quality data", "asset_id", data.AssetID, "tag_count", len(dropped))
+
+	rejected := *data
+	rejected.Values = dropped
+	raw, err := json.Marshal(&rejected)
+	if err != nil {
+		sc.log.Error("failed to marshal rejected data", "asset_id", data.AssetID, "error", err)
+		return
+	}
+	h.publishRejected(sc, RejectedData{AssetID: data.AssetID, Error: "quality below " + string(h.minQuality), Data: raw})
+}
+
+// publishEdges publishes derived edge events when JetStream is configured
+func (h *DataHandler) publishEdges(sc *msgScope, events []EdgeEvent) {
+	for _, event := range events {
+		h.metrics.EdgeEvents.Inc()
+		sc.log.Debug("edge detected", "asset_id", event.AssetID, "tag", event.Tag, "count", event.Count)
+		if h.js == nil {
+			continue
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			sc.log.Error("failed to marshal edge event", "asset_id", event.AssetID, "error", err)
+			continue
+		}
+		h.publishWithRetry(sc, PrefixSubject(h.subjectPrefix, SubjectDataEvents), payload)
+	}
+}
+
+// reject routes a message that failed validation to SubjectDataRejected
+func (h *DataHandler) reject(sc *msgScope, rejected RejectedData) {
+	h.metrics.ValidationFailures.Inc()
+	log := sc.log.With("asset_id", rejected.AssetID)
+	if rejected.Index != nil {
+		log = log.With("batch_index", *rejected.Index)
+	}
+	log.Warn("rejected data", "error", rejected.Error)
+	h.publishRejected(sc, rejected)
+}
+
+// publishRejected publishes a RejectedData envelope when JetStream is configured
+func (h *DataHandler) publishRejected(sc *msgScope, rejected RejectedData) {
+	if h.js == nil {
+		return
+	}
+
+	payload, err := json.Marshal(rejected)
+	if err != nil {
+		sc.log.Error("failed to marshal rejected data", "asset_id", rejected.AssetID, "error", err)
+		return
+	}
+	h.publishWithRetry(sc, PrefixSubject(h.subjectPrefix, SubjectDataRejected), payload)
+}
+
+// persist stores data, or keeps it in memory without a store. With a
+// non-empty content hash it reports false, storing nothing, when the hash
+// was already seen.
+func (h *DataHandler) persist(sc *msgScope, data *AssetData, hash string) bool {
+	if h.store != nil {
+		if hash == "" {
+			if err := h.store.InsertAssetData(data); err != nil {
+				sc.log.Error("failed to persist data", "asset_id", data.AssetID, "error", err)
+			}
+			return true
 		}
+		inserted, err := h.store.InsertAssetDataOnce(data, hash)
+		if err != nil {
+			sc.log.Error("failed to persist data", "asset_id", data.AssetID, "error", err)
+			return true
+		}
+		return inserted
 	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hash != "" {
+		if _, seen := h.hashes[hash]; seen {
+			return false
+		}
+		h.hashes[hash] = struct{}{}
+	}
+	h.data = append(h.data, *data)
+	return true
+}
+
+// publishWithRetry publishes to JetStream with the correlation ID of sc,
+// waiting for the ack and retrying with exponential backoff. Messages that
+// fail every attempt are dead-lettered.
+func (h *DataHandler) publishWithRetry(sc *msgScope, subject string, data []byte) {
+	h.publishMsgWithRetry(sc, &nats.Msg{Subject: subject, Data: data, Header: sc.header()}, data)
+}
+
+// publishValidated publishes accepted data to SubjectDataValidated,
+// compressed when it is larger than the configured threshold
+func (h *DataHandler) publishValidated(sc *msgScope, payload []byte) {
+	msg := &nats.Msg{Subject: PrefixSubject(h.subjectPrefix, SubjectDataValidated), Data: payload, Header: sc.header()}
+	if h.publish.CompressAbove > 0 && len(payload) > h.publish.CompressAbove {
+		compressed, err := compressPayload(payload)
+		if err != nil {
+			sc.log.Error("failed to compress data, publishing uncompressed", "error", err)
+		} else {
+			msg.Data = compressed
+			msg.Header.Set(HeaderContentEncoding, EncodingGzip)
+		}
+	}
+	h.publishMsgWithRetry(sc, msg, payload)
+}
+
+// publishMsgWithRetry implements publishWithRetry for a message that may
+// carry headers. A message failing every attempt is dead-lettered with
+// data, its uncompressed payload.
+func (h *DataHandler) publishMsgWithRetry(sc *msgScope, msg *nats.Msg, data []byte) {
+	subject := msg.Subject
+	backoff := h.publish.Backoff
+	var err error
+	for attempt := 1; attempt <= h.publish.Attempts; attempt++ {
+		if _, err = h.js.PublishMsg(msg); err == nil {
+			return
+		}
+		if attempt < h.publish.Attempts {
+			h.metrics.PublishRetries.Inc()
+			sc.log.Warn("publish failed, retrying", "subject", subject, "attempt", attempt, "max_attempts", h.publish.Attempts, "error", err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	h.metrics.PublishErrors.Inc()
+	sc.log.Error("failed to publish to JetStream", "subject", subject, "attempts", h.publish.Attempts, "error", err)
+	h.deadLetter(sc, subject, data, err)
+}
+
+// deadLetter records a message that could not be published, either in the
+// configured dead-letter file or on SubjectDataDeadLetter
+func (h *DataHandler) deadLetter(sc *msgScope, subject string, data []byte, reason error) {
+	payload, err := json.Marshal(DeadLetter{
+		Subject:       subject,
+		Error:         reason.Error(),
+		Data:          data,
+		CorrelationID: sc.correlationID,
+	})
+	if err != nil {
+		sc.log.Error("failed to marshal dead letter", "subject", subject, "error", err)
+		return
+	}
+
+	if h.publish.DeadLetterFile != "" {
+		err = h.appendDeadLetter(payload)
+	} else {
+		_, err = h.js.PublishMsg(&nats.Msg{Subject: PrefixSubject(h.subjectPrefix, SubjectDataDeadLetter), Data: payload, Header: sc.header()})
+	}
+	if err != nil {
+		sc.log.Error("failed to dead-letter message", "subject", subject, "error", err)
+		return
+	}
+	h.metrics.DeadLetters.Inc()
+}
+
+// appendDeadLetter appends one JSON line to the dead-letter file
+func (h *DataHandler) appendDeadLetter(payload []byte) error {
+	h.dlMu.Lock()
+	defer h.dlMu.Unlock()
+
+	f, err := os.OpenFile(h.publish.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(payload, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close dead-letter file: %w", err)
+	}
+	return nil
 }
 
 // GetDataCount returns the number of stored data entries
 func (h *DataHandler) GetDataCount() int {
+	if h.store != nil {
+		count, err := h.store.CountAssetData()
+		if err != nil {
+			coreLog().Error("failed to count stored data", "error", err)
+			return 0
+		}
+		return count
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	return len(h.data)
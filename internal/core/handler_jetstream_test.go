@@ -1,7 +1,11 @@
 package core
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -149,6 +153,163 @@ func TestHandleAssetData_WithJetStreamAndStore(t *testing.T) {
 	assert.Equal(t, 1, handler.GetDataCount())
 }
 
+// TestHandleAssetData_UnregisteredSubject tests that data from unknown assets is published to the unregistered subject when auto-registration is off
+func TestHandleAssetData_UnregisteredSubject(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TEST_STREAM",
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	handler := NewDataHandler(js, store)
+	handler.SetAutoRegister(false)
+
+	unregistered, err := nc.SubscribeSync(SubjectDataUnregistered)
+	require.NoError(t, err)
+	validated, err := nc.SubscribeSync(SubjectDataValidated)
+	require.NoError(t, err)
+
+	jsonData := []byte(`{"asset_id":"new-sensor","timestamp":1234567890,"values":[]}`)
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: jsonData})
+
+	msg, err := unregistered.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(jsonData), string(msg.Data))
+
+	_, err = validated.NextMsg(100 * time.Millisecond)
+	assert.ErrorIs(t, err, nats.ErrTimeout)
+
+	assets, err := store.ListAssets()
+	require.NoError(t, err)
+	assert.Empty(t, assets)
+	assert.Equal(t, 0, handler.GetDataCount())
+}
+
+// TestHandleAssetData_TimestampRejected tests that skewed timestamps are rejected and filled ones are published
+func TestHandleAssetData_TimestampRejected(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TEST_STREAM",
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	handler := NewDataHandler(js, nil)
+	handler.SetTimestampWindow(DefaultTimestampWindow)
+	handler.SetFillMissingTimestamp(true)
+
+	rejected, err := nc.SubscribeSync(SubjectDataRejected)
+	require.NoError(t, err)
+	validated, err := nc.SubscribeSync(SubjectDataValidated)
+	require.NoError(t, err)
+
+	future := []byte(`{"asset_id":"sensor-001","timestamp":4102444800000,"values":[]}`)
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: future})
+
+	msg, err := rejected.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	var rej RejectedData
+	require.NoError(t, json.Unmarshal(msg.Data, &rej))
+	assert.Equal(t, "timestamp out of range", rej.Error)
+	assert.JSONEq(t, string(future), string(rej.Data))
+
+	// The filled timestamp is part of the published payload
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: []byte(`{"asset_id":"sensor-001","values":[]}`)})
+	msg, err = validated.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	var data AssetData
+	require.NoError(t, json.Unmarshal(msg.Data, &data))
+	assert.NotZero(t, data.Timestamp)
+}
+
+// TestHandleAssetData_QualityRejected tests that low quality values are published as rejected
+func TestHandleAssetData_QualityRejected(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TEST_STREAM",
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	handler := NewDataHandler(js, nil)
+	handler.SetMinQuality(QualityGood)
+
+	rejected, err := nc.SubscribeSync(SubjectDataRejected)
+	require.NoError(t, err)
+	validated, err := nc.SubscribeSync(SubjectDataValidated)
+	require.NoError(t, err)
+
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: []byte(`{"asset_id":"sensor-001","timestamp":1768467600000,"values":[
+		{"name":"a","number":1,"quality":"good"},{"name":"b","number":2,"quality":"uncertain"}]}`)})
+
+	msg, err := rejected.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	var rej RejectedData
+	require.NoError(t, json.Unmarshal(msg.Data, &rej))
+	assert.Equal(t, "sensor-001", rej.AssetID)
+	assert.Equal(t, "quality below good", rej.Error)
+	var dropped AssetData
+	require.NoError(t, json.Unmarshal(rej.Data, &dropped))
+	require.Len(t, dropped.Values, 1)
+	assert.Equal(t, "b", dropped.Values[0].Name)
+
+	msg, err = validated.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	var kept AssetData
+	require.NoError(t, json.Unmarshal(msg.Data, &kept))
+	require.Len(t, kept.Values, 1)
+	assert.Equal(t, "a", kept.Values[0].Name)
+}
+
+// TestHandleAssetData_CoercedPublished tests that coerced values are
+// published in their converted form
+func TestHandleAssetData_CoercedPublished(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TEST_STREAM",
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	store := newTestStore(t)
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+	createTestAssets(t, store, "sensor")
+	require.NoError(t, store.UpdateAssetTemplate("sensor", "test-sensor"))
+
+	handler := NewDataHandler(js, store)
+	handler.SetTemplateLoader(loader)
+	handler.SetCoerceValues(true)
+
+	validated, err := nc.SubscribeSync(SubjectDataValidated)
+	require.NoError(t, err)
+
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: []byte(`{"asset_id":"sensor","timestamp":1768467600000,"values":[
+		{"name":"temperature","text":"25.5"}]}`)})
+
+	msg, err := validated.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	var published AssetData
+	require.NoError(t, json.Unmarshal(msg.Data, &published))
+	require.Len(t, published.Values, 1)
+	require.NotNil(t, published.Values[0].Number)
+	assert.Equal(t, 25.5, *published.Values[0].Number)
+	assert.Nil(t, published.Values[0].Text)
+}
+
 // TestJetStreamPublish_MessagePersistence tests message persistence in JetStream
 func TestJetStreamPublish_MessagePersistence(t *testing.T) {
 	_, _, js := startTestNATSServer(t, true)
@@ -232,6 +393,7 @@ func TestHandleAssetData_JetStreamPublishError(t *testing.T) {
 	require.NoError(t, err)
 
 	handler := NewDataHandler(js, nil)
+	handler.SetPublishConfig(PublishConfig{Attempts: 1})
 
 	data := &AssetData{
 		AssetID:   "sensor-001",
@@ -254,3 +416,325 @@ func TestHandleAssetData_JetStreamPublishError(t *testing.T) {
 	// Data should still be stored in memory
 	assert.Equal(t, 1, handler.GetDataCount())
 }
+
+// TestHandleAssetData_ValidationRejected tests that invalid data is routed to the rejected subject
+func TestHandleAssetData_ValidationRejected(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TEST_STREAM",
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+
+	require.NoError(t, store.CreateAsset(&Asset{
+		ID:           "sensor-001",
+		Name:         "sensor-001",
+		TemplateName: "test-sensor",
+		CreatedAt:    time.Now(),
+	}))
+
+	handler := NewDataHandler(js, store)
+	handler.SetTemplateLoader(loader)
+
+	rejected := make(chan *nats.Msg, 1)
+	rejSub, err := nc.Subscribe(SubjectDataRejected, func(msg *nats.Msg) { rejected <- msg })
+	require.NoError(t, err)
+	defer rejSub.Unsubscribe()
+
+	validated := make(chan *nats.Msg, 1)
+	valSub, err := nc.Subscribe(SubjectDataValidated, func(msg *nats.Msg) { validated <- msg })
+	require.NoError(t, err)
+	defer valSub.Unsubscribe()
+
+	// temperature must be NUMBER according to the template
+	text := "hot"
+	jsonData, err := json.Marshal(&AssetData{
+		AssetID:   "sensor-001",
+		Timestamp: 1234567890,
+		Values:    []TagValue{{Name: "temperature", Text: &text}},
+	})
+	require.NoError(t, err)
+
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: jsonData})
+
+	select {
+	case msg := <-rejected:
+		var rej RejectedData
+		require.NoError(t, json.Unmarshal(msg.Data, &rej))
+		assert.Equal(t, "sensor-001", rej.AssetID)
+		assert.Contains(t, rej.Error, "must be NUMBER type")
+		assert.JSONEq(t, string(jsonData), string(rej.Data))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for rejected message")
+	}
+
+	select {
+	case <-validated:
+		t.Fatal("rejected data must not be published as validated")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.Equal(t, 0, handler.GetDataCount())
+}
+
+// TestHandleAssetData_ValidationPassThrough tests that valid and template-less data is stored
+func TestHandleAssetData_ValidationPassThrough(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+
+	require.NoError(t, store.CreateAsset(&Asset{
+		ID:           "sensor-001",
+		Name:         "sensor-001",
+		TemplateName: "test-sensor",
+		CreatedAt:    time.Now(),
+	}))
+
+	handler := NewDataHandler(nil, store)
+	handler.SetTemplateLoader(loader)
+
+	temp := 21.0
+	valid, err := json.Marshal(&AssetData{AssetID: "sensor-001", Values: []TagValue{{Name: "temperature", Number: &temp}}})
+	require.NoError(t, err)
+	handler.HandleAssetData(&nats.Msg{Data: valid})
+
+	// Auto-registered asset has no template, so anything passes
+	text := "anything"
+	untyped, err := json.Marshal(&AssetData{AssetID: "sensor-002", Values: []TagValue{{Name: "temperature", Text: &text}}})
+	require.NoError(t, err)
+	handler.HandleAssetData(&nats.Msg{Data: untyped})
+
+	assert.Equal(t, 2, handler.GetDataCount())
+}
+
+// TestHandleAssetData_PublishRetryDeadLetterFile tests that failed publishes are retried and appended to the dead-letter file
+func TestHandleAssetData_PublishRetryDeadLetterFile(t *testing.T) {
+	_, _, js := startTestNATSServer(t, true)
+
+	// No stream captures platform.data.validated, so every publish fails
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "LIMITED_STREAM",
+		Subjects: []string{"allowed.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	deadLetterFile := filepath.Join(t.TempDir(), "deadletter.jsonl")
+	handler := NewDataHandler(js, nil)
+	handler.SetPublishConfig(PublishConfig{
+		Attempts:       3,
+		Backoff:        time.Millisecond,
+		DeadLetterFile: deadLetterFile,
+	})
+
+	jsonData := []byte(`{"asset_id":"sensor-001","timestamp":1234567890,"values":[]}`)
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: jsonData})
+
+	// In-memory write is preserved regardless of publish outcome
+	assert.Equal(t, 1, handler.GetDataCount())
+	assert.Equal(t, uint64(2), handler.metrics.PublishRetries.Value())
+	assert.Equal(t, uint64(1), handler.metrics.PublishErrors.Value())
+	assert.Equal(t, uint64(1), handler.metrics.DeadLetters.Value())
+
+	content, err := os.ReadFile(deadLetterFile)
+	require.NoError(t, err)
+
+	var dl DeadLetter
+	require.NoError(t, json.Unmarshal(bytes.TrimSpace(content), &dl))
+	assert.Equal(t, SubjectDataValidated, dl.Subject)
+	assert.NotEmpty(t, dl.Error)
+	assert.JSONEq(t, string(jsonData), string(dl.Data))
+}
+
+// TestHandleAssetData_PublishDeadLetterSubject tests that failed publishes fall back to the dead-letter subject
+func TestHandleAssetData_PublishDeadLetterSubject(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+
+	// Only the dead-letter subject is captured by a stream
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "DEADLETTER_STREAM",
+		Subjects: []string{SubjectDataDeadLetter},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	sub, err := nc.SubscribeSync(SubjectDataDeadLetter)
+	require.NoError(t, err)
+
+	handler := NewDataHandler(js, nil)
+	handler.SetPublishConfig(PublishConfig{Attempts: 1})
+
+	jsonData := []byte(`{"asset_id":"sensor-001","timestamp":1234567890,"values":[]}`)
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: jsonData})
+
+	received, err := sub.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+
+	var dl DeadLetter
+	require.NoError(t, json.Unmarshal(received.Data, &dl))
+	assert.Equal(t, SubjectDataValidated, dl.Subject)
+	assert.JSONEq(t, string(jsonData), string(dl.Data))
+	assert.Equal(t, uint64(0), handler.metrics.PublishRetries.Value())
+	assert.Equal(t, 1, handler.GetDataCount())
+}
+
+// TestHandleAssetDataBatch tests that batch items are processed independently and rejections carry their index
+func TestHandleAssetDataBatch(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TEST_STREAM",
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	handler := NewDataHandler(js, nil)
+	handler.SetTimestampWindow(DefaultTimestampWindow)
+
+	rejected, err := nc.SubscribeSync(SubjectDataRejected)
+	require.NoError(t, err)
+	validated, err := nc.SubscribeSync(SubjectDataValidated)
+	require.NoError(t, err)
+
+	now := time.Now().UnixMilli()
+	batch := fmt.Sprintf(`{"items":[
+		{"asset_id":"sensor-001","timestamp":%d,"values":[{"name":"a","number":1}]},
+		{"asset_id":"sensor-002","timestamp":4102444800000,"values":[]},
+		"not a reading",
+		{"asset_id":"sensor-003","timestamp":%d,"values":[{"name":"a","number":3}]}]}`, now, now)
+	handler.HandleAssetDataBatch(&nats.Msg{Subject: SubjectDataBatch, Data: []byte(batch)})
+
+	for _, want := range []string{"sensor-001", "sensor-003"} {
+		msg, err := validated.NextMsg(2 * time.Second)
+		require.NoError(t, err)
+		var data AssetData
+		require.NoError(t, json.Unmarshal(msg.Data, &data))
+		assert.Equal(t, want, data.AssetID)
+	}
+
+	for _, want := range []struct {
+		index   int
+		assetID string
+	}{{1, "sensor-002"}, {2, ""}} {
+		msg, err := rejected.NextMsg(2 * time.Second)
+		require.NoError(t, err)
+		var rej RejectedData
+		require.NoError(t, json.Unmarshal(msg.Data, &rej))
+		require.NotNil(t, rej.Index)
+		assert.Equal(t, want.index, *rej.Index)
+		assert.Equal(t, want.assetID, rej.AssetID)
+		assert.NotEmpty(t, rej.Error)
+	}
+
+	assert.Equal(t, 2, handler.GetDataCount())
+	assert.Equal(t, uint64(4), handler.metrics.MessagesReceived.Value())
+}
+
+// TestHandleAssetData_CompressAbove tests that only payloads over the threshold are published gzipped
+func TestHandleAssetData_CompressAbove(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TEST_STREAM",
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	handler := NewDataHandler(js, nil)
+	cfg := DefaultPublishConfig()
+	cfg.CompressAbove = 200
+	handler.SetPublishConfig(cfg)
+
+	validated, err := nc.SubscribeSync(SubjectDataValidated)
+	require.NoError(t, err)
+
+	small := []byte(`{"asset_id":"sensor-001","timestamp":1768467600000,"values":[{"name":"a","number":1,"quality":"good"}]}`)
+	large := []byte(`{"asset_id":"sensor-001","timestamp":1768467600000,"values":[` +
+		`{"name":"temperature","number":1,"quality":"good"},{"name":"humidity","number":2,"quality":"good"},{"name":"pressure","number":3,"quality":"good"},` +
+		`{"name":"vibration","number":4,"quality":"good"},{"name":"current","number":5,"quality":"good"},{"name":"voltage","number":6,"quality":"good"}]}`)
+	require.Greater(t, len(large), cfg.CompressAbove)
+
+	for _, payload := range [][]byte{small, large} {
+		handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: payload})
+	}
+
+	msg, err := validated.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	assert.Empty(t, msg.Header.Get(HeaderContentEncoding))
+	assert.JSONEq(t, string(small), string(msg.Data))
+
+	msg, err = validated.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, EncodingGzip, msg.Header.Get(HeaderContentEncoding))
+	assert.NotEmpty(t, msg.Header.Get(HeaderCorrelationID))
+	assert.Less(t, len(msg.Data), len(large))
+	data, err := DecodePayload(msg)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(large), string(data))
+}
+
+// TestHandleAssetData_CorrelationID tests that the correlation ID of a
+// message reaches its validated and rejected publishes and its log lines
+func TestHandleAssetData_CorrelationID(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     "TEST_STREAM",
+		Subjects: []string{"platform.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	handler := NewDataHandler(js, nil)
+	handler.SetTimestampWindow(time.Hour)
+
+	validated, err := nc.SubscribeSync(SubjectDataValidated)
+	require.NoError(t, err)
+	rejected, err := nc.SubscribeSync(SubjectDataRejected)
+	require.NoError(t, err)
+	logs := captureLogs(t)
+
+	payload := []byte(fmt.Sprintf(`{"asset_id":"sensor-001","timestamp":%d,"values":[{"name":"a","number":1,"quality":"good"}]}`, time.Now().UnixMilli()))
+	msg := nats.NewMsg(SubjectDataAsset)
+	msg.Data = payload
+	msg.Header.Set(HeaderCorrelationID, "trace-42")
+	handler.HandleAssetData(msg)
+
+	out, err := validated.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "trace-42", out.Header.Get(HeaderCorrelationID))
+
+	records := logRecords(t, logs)
+	require.NotEmpty(t, records)
+	for _, record := range records {
+		assert.Equal(t, "trace-42", record["correlation_id"], record["msg"])
+	}
+
+	// Without a header an ID is generated and used for the rejection
+	logs.Reset()
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: []byte(`{"asset_id":"sensor-001","timestamp":1,"values":[]}`)})
+
+	out, err = rejected.NextMsg(2 * time.Second)
+	require.NoError(t, err)
+	generated := out.Header.Get(HeaderCorrelationID)
+	assert.NotEmpty(t, generated)
+	records = logRecords(t, logs)
+	require.NotEmpty(t, records)
+	for _, record := range records {
+		assert.Equal(t, generated, record["correlation_id"], record["msg"])
+	}
+}
@@ -2,7 +2,12 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
@@ -55,6 +60,26 @@ func TestHandleAssetData_InvalidJSON(t *testing.T) {
 	assert.Equal(t, 0, handler.GetDataCount())
 }
 
+// TestHandleAssetData_Oversized tests that messages over the payload limit
+// are dropped before they are parsed
+func TestHandleAssetData_Oversized(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+	handler.SetMaxPayload(128)
+
+	tempValue := 25.5
+	small, err := json.Marshal(&AssetData{AssetID: "s", Values: []TagValue{{Name: "t", Number: &tempValue}}})
+	require.NoError(t, err)
+	large, err := json.Marshal(&AssetData{AssetID: strings.Repeat("x", 200), Values: []TagValue{{Name: "t", Number: &tempValue}}})
+	require.NoError(t, err)
+
+	handler.HandleAssetData(&nats.Msg{Data: small})
+	handler.HandleAssetData(&nats.Msg{Data: large})
+	handler.HandleAssetDataBatch(&nats.Msg{Data: []byte(`{"items": [` + string(large) + `]}`)})
+
+	assert.Equal(t, 1, handler.GetDataCount())
+	assert.Equal(t, uint64(2), handler.metrics.OversizedMessages.Value())
+}
+
 // TestHandleAssetData_AutoRegister tests auto-registration of unknown assets
 func TestHandleAssetData_AutoRegister(t *testing.T) {
 	store, err := NewStore(":memory:")
@@ -91,6 +116,314 @@ func TestHandleAssetData_AutoRegister(t *testing.T) {
 	assert.Equal(t, "new-sensor", asset.Name)
 }
 
+// TestHandleAssetData_AutoRegisterConcurrent tests that simultaneous first
+// readings of an asset register it once and all get stored
+func TestHandleAssetData_AutoRegisterConcurrent(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "edg.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	handler := NewDataHandler(nil, store)
+
+	tempValue := 25.5
+	jsonData, err := json.Marshal(&AssetData{
+		AssetID: "racing-sensor",
+		Values:  []TagValue{{Name: "temperature", Number: &tempValue}},
+	})
+	require.NoError(t, err)
+
+	const readings = 20
+	var wg sync.WaitGroup
+	for range readings {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.HandleAssetData(&nats.Msg{Data: jsonData})
+		}()
+	}
+	wg.Wait()
+
+	asset, err := store.GetAsset("racing-sensor")
+	require.NoError(t, err)
+	require.NotNil(t, asset)
+	assert.Equal(t, uint64(1), handler.metrics.AssetsAutoRegistered.Value())
+	assert.Equal(t, readings, handler.GetDataCount())
+}
+
+// TestHandleAssetData_AutoRegisterWithTemplate tests that a known template in the metadata is assigned to the new asset
+func TestHandleAssetData_AutoRegisterWithTemplate(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+
+	handler := NewDataHandler(nil, store)
+	handler.SetTemplateLoader(loader)
+
+	tempValue := 25.5
+	for id, template := range map[string]string{"known-sensor": "test-sensor", "unknown-sensor": "no-such-template"} {
+		jsonData, err := json.Marshal(&AssetData{
+			AssetID:  id,
+			Values:   []TagValue{{Name: "temperature", Number: &tempValue}},
+			Metadata: map[string]string{MetadataTemplate: template},
+		})
+		require.NoError(t, err)
+		handler.HandleAssetData(&nats.Msg{Data: jsonData})
+	}
+
+	asset, err := store.GetAsset("known-sensor")
+	require.NoError(t, err)
+	require.NotNil(t, asset)
+	assert.Equal(t, "test-sensor", asset.TemplateName)
+
+	// Unknown templates are ignored rather than recorded
+	asset, err = store.GetAsset("unknown-sensor")
+	require.NoError(t, err)
+	require.NotNil(t, asset)
+	assert.Empty(t, asset.TemplateName)
+
+	assert.Equal(t, uint64(2), handler.metrics.AssetsAutoRegistered.Value())
+	assert.Equal(t, 2, handler.GetDataCount())
+}
+
+// TestHandleAssetData_AdoptTemplate tests that a template hint is persisted on
+// an asset without a template and then validated against, while a template
+// set by an operator is kept
+func TestHandleAssetData_AdoptTemplate(t *testing.T) {
+	store := newTestStore(t)
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+
+	require.NoError(t, store.CreateAsset(&Asset{ID: "bare", Name: "bare", CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateAsset(&Asset{ID: "managed", Name: "managed", TemplateName: "other", CreatedAt: time.Now()}))
+
+	handler := NewDataHandler(nil, store)
+	handler.SetTemplateLoader(loader)
+
+	send := func(id string, value TagValue) {
+		jsonData, err := json.Marshal(&AssetData{
+			AssetID:  id,
+			Values:   []TagValue{value},
+			Metadata: map[string]string{MetadataTemplate: "test-sensor"},
+		})
+		require.NoError(t, err)
+		handler.HandleAssetData(&nats.Msg{Data: jsonData})
+	}
+	tempValue := 25.5
+	text := "hot"
+	send("bare", TagValue{Name: "temperature", Number: &tempValue})
+	send("bare", TagValue{Name: "temperature", Text: &text})
+	send("managed", TagValue{Name: "temperature", Number: &tempValue})
+
+	asset, err := store.GetAsset("bare")
+	require.NoError(t, err)
+	assert.Equal(t, "test-sensor", asset.TemplateName)
+	asset, err = store.GetAsset("managed")
+	require.NoError(t, err)
+	assert.Equal(t, "other", asset.TemplateName)
+
+	assert.Equal(t, 2, handler.GetDataCount())
+	assert.Equal(t, uint64(1), handler.metrics.ValidationFailures.Value())
+}
+
+// TestHandleAssetData_StrictTemplateVersion tests that data from assets created
+// against a version older than the template's breaking change is rejected
+func TestHandleAssetData_StrictTemplateVersion(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `name: pump
+version: 2
+breakingVersion: 2
+strictVersion: true
+resources:
+  - name: flow
+    valueType: NUMBER
+`)))
+	require.NoError(t, store.CreateAsset(&Asset{ID: "old", Name: "old", TemplateName: "pump", TemplateVersion: 1, CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateAsset(&Asset{ID: "new", Name: "new", TemplateName: "pump", TemplateVersion: 2, CreatedAt: time.Now()}))
+
+	handler := NewDataHandler(nil, store)
+	handler.SetTemplateLoader(loader)
+
+	flow := 1.5
+	for _, id := range []string{"old", "new"} {
+		jsonData, err := json.Marshal(&AssetData{AssetID: id, Values: []TagValue{{Name: "flow", Number: &flow}}})
+		require.NoError(t, err)
+		handler.HandleAssetData(&nats.Msg{Data: jsonData})
+	}
+
+	assert.Equal(t, 1, handler.GetDataCount())
+	assert.Equal(t, uint64(1), handler.metrics.ValidationFailures.Value())
+}
+
+// TestHandleAssetData_AutoRegisterDisabled tests that data from unknown assets is dropped without creating rows
+func TestHandleAssetData_AutoRegisterDisabled(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.CreateAsset(&Asset{ID: "known-sensor", Name: "known-sensor"}))
+
+	handler := NewDataHandler(nil, store)
+	handler.SetAutoRegister(false)
+
+	tempValue := 25.5
+	for _, id := range []string{"known-sensor", "new-sensor"} {
+		jsonData, err := json.Marshal(&AssetData{AssetID: id, Values: []TagValue{{Name: "temperature", Number: &tempValue}}})
+		require.NoError(t, err)
+		handler.HandleAssetData(&nats.Msg{Data: jsonData})
+	}
+
+	asset, err := store.GetAsset("new-sensor")
+	require.NoError(t, err)
+	assert.Nil(t, asset)
+
+	assert.Equal(t, 1, handler.GetDataCount())
+	assert.Equal(t, uint64(0), handler.metrics.AssetsAutoRegistered.Value())
+	assert.Equal(t, uint64(1), handler.metrics.UnregisteredData.Value())
+}
+
+// TestHandleAssetData_Idempotent tests that redelivered messages are dropped
+// in idempotent mode, with and without a store
+func TestHandleAssetData_Idempotent(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	for name, handler := range map[string]*DataHandler{
+		"store":  NewDataHandler(nil, store),
+		"memory": NewDataHandler(nil, nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler.SetIdempotent(true)
+
+			first := []byte(`{"asset_id":"sensor-001","timestamp":1768467600000,"values":[{"name":"a","number":1},{"name":"b","number":2}]}`)
+			redelivered := []byte(`{"asset_id":"sensor-001","timestamp":1768467600000,"values":[{"name":"b","number":2},{"name":"a","number":1}]}`)
+			next := []byte(`{"asset_id":"sensor-001","timestamp":1768467601000,"values":[{"name":"a","number":1},{"name":"b","number":2}]}`)
+			for _, payload := range [][]byte{first, redelivered, next, first} {
+				handler.HandleAssetData(&nats.Msg{Data: payload})
+			}
+
+			assert.Equal(t, 2, handler.GetDataCount())
+			assert.Equal(t, uint64(2), handler.metrics.DuplicatesSkipped.Value())
+		})
+	}
+}
+
+// TestHandleAssetData_NotIdempotentByDefault tests that repeats are stored when the mode is off
+func TestHandleAssetData_NotIdempotentByDefault(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+	payload := []byte(`{"asset_id":"sensor-001","timestamp":1768467600000,"values":[]}`)
+	handler.HandleAssetData(&nats.Msg{Data: payload})
+	handler.HandleAssetData(&nats.Msg{Data: payload})
+
+	assert.Equal(t, 2, handler.GetDataCount())
+	assert.Equal(t, uint64(0), handler.metrics.DuplicatesSkipped.Value())
+}
+
+// TestHandleAssetData_NormalizesQuality tests that stored values carry
+// canonical qualities and unknown ones are counted
+func TestHandleAssetData_NormalizesQuality(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+	payload := []byte(`{"asset_id":"sensor-001","timestamp":1768467600000,"values":[
+		{"name":"a","number":1,"quality":"GOOD"},
+		{"name":"b","number":2},
+		{"name":"c","number":3,"quality":"questionable"}]}`)
+	handler.HandleAssetData(&nats.Msg{Data: payload})
+
+	require.Equal(t, 1, handler.GetDataCount())
+	values := handler.data[0].Values
+	assert.Equal(t, QualityGood, values[0].Quality)
+	assert.Equal(t, QualityGood, values[1].Quality)
+	assert.Equal(t, QualityUncertain, values[2].Quality)
+	assert.Equal(t, uint64(1), handler.metrics.UnknownQuality.Value())
+}
+
+// TestHandleAssetData_MinQuality tests that values below the minimum are
+// dropped while the rest of the message is kept
+func TestHandleAssetData_MinQuality(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+	handler.SetMinQuality(QualityUncertain)
+
+	mixed := []byte(`{"asset_id":"sensor-001","timestamp":1768467600000,"values":[
+		{"name":"a","number":1,"quality":"good"},
+		{"name":"b","number":2,"quality":"bad"},
+		{"name":"c","number":3,"quality":"good","status_code":2150891520}]}`)
+	handler.HandleAssetData(&nats.Msg{Data: mixed})
+
+	require.Equal(t, 1, handler.GetDataCount())
+	require.Len(t, handler.data[0].Values, 1)
+	assert.Equal(t, "a", handler.data[0].Values[0].Name)
+	assert.Equal(t, uint64(2), handler.metrics.QualityRejected.Value())
+
+	allBad := []byte(`{"asset_id":"sensor-001","timestamp":1768467601000,"values":[{"name":"b","number":2,"quality":"BAD"}]}`)
+	handler.HandleAssetData(&nats.Msg{Data: allBad})
+	assert.Equal(t, 1, handler.GetDataCount(), "a message with no values left is not stored")
+	assert.Equal(t, uint64(3), handler.metrics.QualityRejected.Value())
+	assert.Zero(t, handler.metrics.ValidationFailures.Value())
+}
+
+// TestHandleAssetData_PersistsToStore tests that data is written through the store
+func TestHandleAssetData_PersistsToStore(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	handler := NewDataHandler(nil, store)
+
+	tempValue := 25.5
+	data := &AssetData{
+		AssetID:   "sensor-001",
+		Timestamp: 1234567890,
+		Values:    []TagValue{{Name: "temperature", Number: &tempValue}},
+	}
+	jsonData, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	handler.HandleAssetData(&nats.Msg{Data: jsonData})
+
+	// Nothing is kept in memory when a store is configured
+	assert.Empty(t, handler.data)
+	assert.Equal(t, 1, handler.GetDataCount())
+
+	stored, err := store.QueryAssetData("sensor-001", 1234567890000, 1234567890000)
+	require.NoError(t, err)
+	require.Len(t, stored, 1, "the unix seconds timestamp is stored in milliseconds")
+	assert.Equal(t, tempValue, *stored[0].Values[0].Number)
+}
+
+// TestHandleAssetData_TagTimestampFallback tests that tags without a timestamp inherit the envelope timestamp
+func TestHandleAssetData_TagTimestampFallback(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+
+	tempValue := 25.5
+	tagTimestamp := int64(1234567800)
+	data := &AssetData{
+		AssetID:   "sensor-001",
+		Timestamp: 1234567890,
+		Values: []TagValue{
+			{Name: "temperature", Number: &tempValue},
+			{Name: "pressure", Number: &tempValue, Timestamp: &tagTimestamp},
+		},
+	}
+	jsonData, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	handler.HandleAssetData(&nats.Msg{Data: jsonData})
+
+	require.Len(t, handler.data, 1)
+	values := handler.data[0].Values
+	require.NotNil(t, values[0].Timestamp)
+	assert.Equal(t, int64(1234567890), *values[0].Timestamp)
+	require.NotNil(t, values[1].Timestamp)
+	assert.Equal(t, tagTimestamp, *values[1].Timestamp)
+}
+
 // TestGetDataCount tests thread-safe data count
 func TestGetDataCount(t *testing.T) {
 	handler := NewDataHandler(nil, nil)
@@ -114,3 +447,79 @@ func TestGetDataCount(t *testing.T) {
 
 	assert.Equal(t, 5, handler.GetDataCount())
 }
+
+// TestTimestampTime tests the unix seconds and milliseconds heuristic
+func TestTimestampTime(t *testing.T) {
+	assert.Equal(t, time.Unix(1768467600, 0), timestampTime(1768467600))
+	assert.Equal(t, time.UnixMilli(1768467600123), timestampTime(1768467600123))
+	assert.Equal(t, time.Unix(0, 0), timestampTime(0))
+}
+
+// TestHandleAssetData_TimestampWindow tests that readings far from server time are rejected
+func TestHandleAssetData_TimestampWindow(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+	handler.SetTimestampWindow(time.Hour)
+
+	now := time.Now()
+	for _, ts := range []int64{
+		now.Unix(),      // seconds
+		now.UnixMilli(), // milliseconds
+		now.Add(-30 * time.Minute).UnixMilli(),
+	} {
+		handler.HandleAssetData(&nats.Msg{Data: []byte(fmt.Sprintf(`{"asset_id":"sensor-001","timestamp":%d,"values":[]}`, ts))})
+	}
+	assert.Equal(t, 3, handler.GetDataCount())
+
+	for _, ts := range []int64{
+		0,
+		86400, // 1970 in seconds
+		now.Add(-2 * time.Hour).Unix(),
+		now.Add(2 * time.Hour).UnixMilli(),
+	} {
+		handler.HandleAssetData(&nats.Msg{Data: []byte(fmt.Sprintf(`{"asset_id":"sensor-001","timestamp":%d,"values":[]}`, ts))})
+	}
+	assert.Equal(t, 3, handler.GetDataCount())
+	assert.Equal(t, uint64(4), handler.metrics.ValidationFailures.Value())
+}
+
+// TestHandleAssetData_SchemaVersion tests that unknown schema versions follow the policy
+func TestHandleAssetData_SchemaVersion(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+
+	for _, version := range []string{"", `"schema_version":1,`} {
+		handler.HandleAssetData(&nats.Msg{Data: []byte(`{` + version + `"asset_id":"sensor-001","values":[]}`)})
+	}
+	assert.Equal(t, 2, handler.GetDataCount())
+
+	future := &nats.Msg{Data: []byte(`{"schema_version":2,"asset_id":"sensor-001","values":[]}`)}
+	handler.HandleAssetData(future)
+	handler.HandleAssetData(&nats.Msg{Data: []byte(`{"schema_version":-1,"asset_id":"sensor-001","values":[]}`)})
+	assert.Equal(t, 2, handler.GetDataCount())
+	assert.Equal(t, uint64(2), handler.metrics.ValidationFailures.Value())
+	assert.Equal(t, uint64(2), handler.metrics.UnknownSchema.Value())
+
+	handler.SetSchemaPolicy(SchemaPolicyAccept)
+	handler.HandleAssetData(future)
+	assert.Equal(t, 3, handler.GetDataCount())
+	assert.Equal(t, uint64(3), handler.metrics.UnknownSchema.Value())
+
+	_, err := ParseSchemaPolicy("ignore")
+	assert.Error(t, err)
+}
+
+// TestHandleAssetData_FillMissingTimestamp tests that a zero timestamp is replaced with server time
+func TestHandleAssetData_FillMissingTimestamp(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+	handler.SetTimestampWindow(time.Hour)
+	handler.SetFillMissingTimestamp(true)
+
+	before := time.Now().UnixMilli()
+	handler.HandleAssetData(&nats.Msg{Data: []byte(`{"asset_id":"sensor-001","values":[{"name":"temperature","number":1}]}`)})
+
+	require.Len(t, handler.data, 1)
+	stored := handler.data[0]
+	assert.GreaterOrEqual(t, stored.Timestamp, before)
+	assert.LessOrEqual(t, stored.Timestamp, time.Now().UnixMilli())
+	require.NotNil(t, stored.Values[0].Timestamp)
+	assert.Equal(t, stored.Timestamp, *stored.Values[0].Timestamp)
+}
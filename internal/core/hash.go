@@ -0,0 +1,31 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// ContentHash returns a deterministic hex SHA-256 of a reading's asset ID,
+// timestamp and values. Values are hashed in name order so a redelivered
+// message with reordered tags hashes the same; metadata is not included.
+func ContentHash(data *AssetData) (string, error) {
+	values := make([]TagValue, len(data.Values))
+	copy(values, data.Values)
+	sort.SliceStable(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(data.AssetID))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(data.Timestamp, 10)))
+	h.Write([]byte{0})
+	h.Write(encoded)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
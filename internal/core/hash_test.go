@@ -0,0 +1,47 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestContentHash tests that the hash ignores tag order and metadata but not values
+func TestContentHash(t *testing.T) {
+	temp, pressure := 21.5, 1.2
+	data := &AssetData{
+		AssetID:   "sensor-001",
+		Timestamp: 1768467600000,
+		Values: []TagValue{
+			{Name: "temperature", Number: &temp},
+			{Name: "pressure", Number: &pressure},
+		},
+	}
+
+	hash, err := ContentHash(data)
+	require.NoError(t, err)
+	assert.Len(t, hash, 64)
+
+	reordered := &AssetData{
+		AssetID:   data.AssetID,
+		Timestamp: data.Timestamp,
+		Values:    []TagValue{data.Values[1], data.Values[0]},
+		Metadata:  map[string]string{"adapter": "modbus"},
+	}
+	same, err := ContentHash(reordered)
+	require.NoError(t, err)
+	assert.Equal(t, hash, same)
+	assert.Equal(t, "temperature", data.Values[0].Name, "input order must not change")
+
+	changed := 21.6
+	for name, other := range map[string]*AssetData{
+		"asset":     {AssetID: "sensor-002", Timestamp: data.Timestamp, Values: data.Values},
+		"timestamp": {AssetID: data.AssetID, Timestamp: data.Timestamp + 1, Values: data.Values},
+		"value":     {AssetID: data.AssetID, Timestamp: data.Timestamp, Values: []TagValue{{Name: "temperature", Number: &changed}, data.Values[1]}},
+	} {
+		h, err := ContentHash(other)
+		require.NoError(t, err)
+		assert.NotEqual(t, hash, h, name)
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nats-io/nats.go"
+)
+
+// HealthCheck is a named dependency check used by the readiness probe
+type HealthCheck struct {
+	Name  string
+	Check func() error
+}
+
+// HealthStatus is the JSON body returned by /healthz and /readyz
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Health status values
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// NATSCheck reports whether the client connection is up
+func NATSCheck(nc *nats.Conn) HealthCheck {
+	return HealthCheck{Name: "nats", Check: func() error {
+		if !nc.IsConnected() {
+			return errors.New("not connected: " + nc.Status().String())
+		}
+		return nil
+	}}
+}
+
+// JetStreamCheck reports whether the JetStream API answers
+func JetStreamCheck(js nats.JetStreamContext) HealthCheck {
+	return HealthCheck{Name: "jetstream", Check: func() error {
+		_, err := js.AccountInfo()
+		return err
+	}}
+}
+
+// StoreCheck reports whether the metadata store answers queries
+func StoreCheck(store *Store) HealthCheck {
+	return HealthCheck{Name: "store", Check: store.Ping}
+}
+
+// HealthzHandler serves the liveness probe: the process is up
+func HealthzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, HealthStatus{Status: HealthStatusOK})
+	})
+}
+
+// ReadyzHandler serves the readiness probe. It runs every check and returns
+// 503 naming the failed dependencies if any check fails.
+func ReadyzHandler(checks ...HealthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := HealthStatus{Status: HealthStatusOK, Checks: make(map[string]string, len(checks))}
+		code := http.StatusOK
+
+		for _, c := range checks {
+			if err := c.Check(); err != nil {
+				status.Status = HealthStatusUnavailable
+				status.Checks[c.Name] = err.Error()
+				code = http.StatusServiceUnavailable
+			} else {
+				status.Checks[c.Name] = HealthStatusOK
+			}
+		}
+
+		writeHealth(w, code, status)
+	})
+}
+
+// writeHealth writes a health status as JSON
+func writeHealth(w http.ResponseWriter, code int, status HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		coreLog().Error("failed to write health response", "error", err)
+	}
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveHealth runs a health handler and decodes the response body
+func serveHealth(t *testing.T, h http.Handler) (int, HealthStatus) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var status HealthStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	return rec.Code, status
+}
+
+// TestHealthzHandler tests the liveness probe
+func TestHealthzHandler(t *testing.T) {
+	code, status := serveHealth(t, HealthzHandler())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthStatusOK, status.Status)
+}
+
+// TestReadyzHandler_AllReady tests readiness with real dependencies
+func TestReadyzHandler_AllReady(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	code, status := serveHealth(t, ReadyzHandler(NATSCheck(nc), JetStreamCheck(js), StoreCheck(store)))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthStatusOK, status.Status)
+	assert.Equal(t, map[string]string{"nats": "ok", "jetstream": "ok", "store": "ok"}, status.Checks)
+}
+
+// TestReadyzHandler_DependencyDown tests that failed dependencies return 503 and are named
+func TestReadyzHandler_DependencyDown(t *testing.T) {
+	_, nc, _ := startTestNATSServer(t, false)
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	code, status := serveHealth(t, ReadyzHandler(
+		NATSCheck(nc),
+		StoreCheck(store),
+		HealthCheck{Name: "custom", Check: func() error { return errors.New("boom") }},
+	))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthStatusUnavailable, status.Status)
+	assert.Equal(t, "ok", status.Checks["nats"])
+	assert.Contains(t, status.Checks["store"], "store ping failed")
+	assert.Equal(t, "boom", status.Checks["custom"])
+}
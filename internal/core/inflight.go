@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultDrainTimeout bounds how long cmd/core waits for in-flight messages
+// on shutdown unless configured otherwise
+const DefaultDrainTimeout = 10 * time.Second
+
+// InFlight counts messages being handled so shutdown can wait for them
+type InFlight struct {
+	mu       sync.Mutex
+	active   int
+	finished uint64
+	idle     chan struct{} // closed while active is zero
+}
+
+// NewInFlight creates an idle tracker
+func NewInFlight() *InFlight {
+	idle := make(chan struct{})
+	close(idle)
+	return &InFlight{idle: idle}
+}
+
+// begin marks a message as in flight
+func (f *InFlight) begin() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.active++
+}
+
+// done marks a message begun with begin as finished
+func (f *InFlight) done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active--
+	f.finished++
+	if f.active == 0 {
+		close(f.idle)
+	}
+}
+
+// Track wraps handler so its messages are counted
+func (f *InFlight) Track(handler nats.MsgHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		f.begin()
+		defer f.done()
+		handler(msg)
+	}
+}
+
+// Active returns the number of messages being handled
+func (f *InFlight) Active() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+// Finished returns the number of messages handled to completion so far
+func (f *InFlight) Finished() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.finished
+}
+
+// Wait blocks until no message is in flight or ctx is done, and returns the
+// number still in flight
+func (f *InFlight) Wait(ctx context.Context) int {
+	for {
+		f.mu.Lock()
+		active, idle := f.active, f.idle
+		f.mu.Unlock()
+		if active == 0 {
+			return 0
+		}
+
+		select {
+		case <-idle:
+			// a new message may have begun meanwhile; check again
+		case <-ctx.Done():
+			return f.Active()
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInFlight_Wait tests waiting for tracked handlers to finish
+func TestInFlight_Wait(t *testing.T) {
+	inFlight := NewInFlight()
+	assert.Equal(t, 0, inFlight.Wait(context.Background()))
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	handler := inFlight.Track(func(*nats.Msg) {
+		started <- struct{}{}
+		<-release
+	})
+	go handler(&nats.Msg{})
+	go handler(&nats.Msg{})
+	<-started
+	<-started
+	assert.Equal(t, 2, inFlight.Active())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, 2, inFlight.Wait(ctx))
+
+	close(release)
+	assert.Equal(t, 0, inFlight.Wait(context.Background()))
+	assert.Equal(t, uint64(2), inFlight.Finished())
+}
+
+// TestHandleAssetData_InFlight tests that data messages are counted
+func TestHandleAssetData_InFlight(t *testing.T) {
+	inFlight := NewInFlight()
+	handler := NewDataHandler(nil, nil)
+	handler.SetInFlight(inFlight)
+
+	handler.HandleAssetData(dataMsg(t, "sensor-001", 21.5, QualityGood))
+	handler.HandleAssetData(&nats.Msg{Data: []byte("not json")})
+
+	assert.Equal(t, 0, inFlight.Active())
+	assert.Equal(t, uint64(2), inFlight.Finished())
+}
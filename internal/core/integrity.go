@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultIntegrityIDLimit is how many offending IDs an integrity report
+// lists per category when the request does not say
+const DefaultIntegrityIDLimit = 20
+
+// addIssue records an offending id in i, listing it while fewer than limit are listed
+func addIssue(i *IntegrityIssues, id string, limit int) {
+	i.Count++
+	if len(i.IDs) < limit {
+		i.IDs = append(i.IDs, id)
+	}
+}
+
+// CheckIntegrity looks for data that the store's own checks would have
+// refused, left behind by older versions, direct edits of the database or
+// configuration changes. Templates are checked against templateExists,
+// skipped when nil; soft-deleted assets are not checked. At most limit IDs
+// are listed per category. The check only reads.
+func (s *Store) CheckIntegrity(templateExists func(name string) bool, limit int) (*IntegrityReport, error) {
+	report := &IntegrityReport{}
+
+	rows, err := s.db.Query(
+		`SELECT r.id, r.relation_type, s.id IS NULL OR t.id IS NULL
+		 FROM asset_relations r
+		 LEFT JOIN assets s ON s.id = r.source_asset_id
+		 LEFT JOIN assets t ON t.id = r.target_asset_id
+		 ORDER BY r.id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check relations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var rt RelationType
+		var dangling bool
+		if err := rows.Scan(&id, &rt, &dangling); err != nil {
+			return nil, fmt.Errorf("failed to scan relation: %w", err)
+		}
+		if dangling {
+			addIssue(&report.DanglingRelations, id, limit)
+		}
+		if !IsValidRelationType(rt) {
+			addIssue(&report.UnknownRelationTypes, id, limit)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var cyclic []string
+	for _, rt := range ValidRelationTypes() {
+		if !IsHierarchicalRelationType(rt) {
+			continue
+		}
+		ids, err := s.cyclicRelations(rt)
+		if err != nil {
+			return nil, err
+		}
+		cyclic = append(cyclic, ids...)
+	}
+	sort.Strings(cyclic)
+	for _, id := range cyclic {
+		addIssue(&report.RelationCycles, id, limit)
+	}
+
+	if templateExists != nil {
+		rows, err := s.db.Query(
+			`SELECT id, template_name FROM assets
+			 WHERE template_name IS NOT NULL AND template_name != '' AND deleted_at IS NULL
+			 ORDER BY id`,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check templates: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id, template string
+			if err := rows.Scan(&id, &template); err != nil {
+				return nil, fmt.Errorf("failed to scan asset: %w", err)
+			}
+			if !templateExists(template) {
+				addIssue(&report.UnknownTemplates, id, limit)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// cyclicRelations returns the IDs of rt relations that close a cycle: the
+// back edges of a depth-first search, which visits assets in ID order and
+// follows relations in creation order. Removing them leaves the rt graph
+// acyclic.
+func (s *Store) cyclicRelations(rt RelationType) ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT id, source_asset_id, target_asset_id FROM asset_relations
+		 WHERE relation_type = ? ORDER BY created_at, rowid`, rt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s relations: %w", rt, err)
+	}
+	defer rows.Close()
+
+	type edge struct{ id, target string }
+	graph := make(map[string][]edge)
+	for rows.Next() {
+		var id, source, target string
+		if err := rows.Scan(&id, &source, &target); err != nil {
+			return nil, fmt.Errorf("failed to scan relation: %w", err)
+		}
+		graph[source] = append(graph[source], edge{id, target})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sources := make([]string, 0, len(graph))
+	for source := range graph {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[string]int)
+	var cyclic []string
+	var visit func(asset string)
+	visit = func(asset string) {
+		state[asset] = onPath
+		for _, e := range graph[asset] {
+			switch state[e.target] {
+			case onPath:
+				cyclic = append(cyclic, e.id)
+			case unvisited:
+				visit(e.target)
+			}
+		}
+		state[asset] = done
+	}
+	for _, source := range sources {
+		if state[source] == unvisited {
+			visit(source)
+		}
+	}
+	return cyclic, nil
+}
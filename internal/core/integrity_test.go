@@ -0,0 +1,82 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// insertRawRelation writes a relation around the store's checks
+func insertRawRelation(t *testing.T, store *Store, id, source, target string, rt RelationType) {
+	t.Helper()
+	_, err := store.db.Exec(
+		`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at) VALUES (?, ?, ?, ?, ?)`,
+		id, source, target, rt, time.Now(),
+	)
+	require.NoError(t, err)
+}
+
+// TestCheckIntegrity_Clean tests that a consistent store reports no issues
+func TestCheckIntegrity_Clean(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "line", "machine", "sensor")
+	require.NoError(t, createTestRelation(t, store, "machine", "line", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "sensor", "machine", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "line", "sensor", RelationConnectedTo))
+
+	report, err := store.CheckIntegrity(func(string) bool { return true }, DefaultIntegrityIDLimit)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%+v", report)
+}
+
+// TestCheckIntegrity_Issues tests every category and the ID limit
+func TestCheckIntegrity_Issues(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "a", "b", "c", "d")
+	require.NoError(t, store.CreateAsset(&Asset{ID: "e", Name: "e", TemplateName: "removed", CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateAsset(&Asset{ID: "f", Name: "f", TemplateName: "kept", CreatedAt: time.Now()}))
+
+	// a -> b -> c -> a closes one cycle; d -> d is a cycle of its own
+	require.NoError(t, createTestRelation(t, store, "a", "b", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "b", "c", RelationPartOf))
+	insertRawRelation(t, store, "c-partOf-a", "c", "a", RelationPartOf)
+	insertRawRelation(t, store, "d-locatedIn-d", "d", "d", RelationLocatedIn)
+	insertRawRelation(t, store, "a-feeds-b", "a", "b", "feeds")
+
+	_, err := store.db.Exec(`PRAGMA foreign_keys = OFF`)
+	require.NoError(t, err)
+	insertRawRelation(t, store, "orphan-1", "a", "ghost", RelationMeasures)
+	insertRawRelation(t, store, "orphan-2", "ghost", "b", RelationMeasures)
+	insertRawRelation(t, store, "orphan-3", "ghost", "ghost", RelationMeasures)
+
+	report, err := store.CheckIntegrity(func(name string) bool { return name == "kept" }, 2)
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, IntegrityIssues{Count: 3, IDs: []string{"orphan-1", "orphan-2"}}, report.DanglingRelations)
+	assert.Equal(t, IntegrityIssues{Count: 1, IDs: []string{"a-feeds-b"}}, report.UnknownRelationTypes)
+	assert.Equal(t, IntegrityIssues{Count: 2, IDs: []string{"c-partOf-a", "d-locatedIn-d"}}, report.RelationCycles)
+	assert.Equal(t, IntegrityIssues{Count: 1, IDs: []string{"e"}}, report.UnknownTemplates)
+
+	// Without a template lookup the template check is skipped
+	report, err = store.CheckIntegrity(nil, 2)
+	require.NoError(t, err)
+	assert.Zero(t, report.UnknownTemplates.Count)
+}
+
+// TestHandleCheck tests the integrity check over NATS
+func TestHandleCheck(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "pump", Name: "pump", TemplateName: "gone", CreatedAt: time.Now()}))
+
+	resp := request(t, nc, SubjectCheck, nil)
+	require.True(t, resp.Success, resp.Error)
+	var report IntegrityReport
+	require.NoError(t, json.Unmarshal(resp.Data, &report))
+	assert.Equal(t, IntegrityIssues{Count: 1, IDs: []string{"pump"}}, report.UnknownTemplates)
+
+	resp = request(t, nc, SubjectCheck, CheckRequest{Limit: -1})
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+}
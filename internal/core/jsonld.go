@@ -0,0 +1,94 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// JSONLDContextURL is the published location of contexts/edg-context.jsonld
+const JSONLDContextURL = "https://edg.e7217.io/contexts/edg-context.jsonld"
+
+// assetIRIPrefix turns asset IDs into absolute node identifiers
+const assetIRIPrefix = "urn:edg:asset:"
+
+// JSONLDDocument is an exported asset graph
+type JSONLDDocument struct {
+	Context string           `json:"@context"`
+	Graph   []map[string]any `json:"@graph"`
+}
+
+// AssetIRI returns the JSON-LD node identifier for an asset ID
+func AssetIRI(assetID string) string {
+	return assetIRIPrefix + assetID
+}
+
+// ExportAssetGraph serializes every asset as a sosa:Platform node and every
+// relation as an edge on its source node, referencing the shipped context.
+// A relation weight is a "weight" literal on the edge. A symmetric relation
+// stored in both directions is exported once.
+func ExportAssetGraph(store *Store) ([]byte, error) {
+	assets, err := store.ListAssets()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]map[string]any, len(assets))
+	graph := make([]map[string]any, 0, len(assets))
+	for _, asset := range assets {
+		node := map[string]any{
+			"@id":                AssetIRI(asset.ID),
+			"@type":              "sosa:Platform",
+			"schema:identifier":  asset.ID,
+			"schema:name":        asset.Name,
+			"schema:dateCreated": asset.CreatedAt.Format(time.RFC3339),
+		}
+		if asset.TemplateName != "" {
+			node["template_name"] = asset.TemplateName
+		}
+		if len(asset.Labels) > 0 {
+			node["schema:keywords"] = asset.Labels
+		}
+		if asset.Latitude != nil && asset.Longitude != nil {
+			geo := map[string]any{
+				"@type":            "schema:GeoCoordinates",
+				"schema:latitude":  *asset.Latitude,
+				"schema:longitude": *asset.Longitude,
+			}
+			if asset.Altitude != nil {
+				geo["schema:elevation"] = *asset.Altitude
+			}
+			node["schema:geo"] = geo
+		}
+		nodes[asset.ID] = node
+		graph = append(graph, node)
+	}
+
+	var relations []*AssetRelation
+	for _, asset := range assets {
+		outgoing, err := store.GetRelationsBySourceAsset(asset.ID)
+		if err != nil {
+			return nil, err
+		}
+		relations = append(relations, outgoing...)
+	}
+
+	for _, rel := range DedupeSymmetricRelations(relations) {
+		predicate, ok := relationPredicate(rel.RelationType)
+		if !ok {
+			return nil, fmt.Errorf("no JSON-LD mapping for relation type: %s", rel.RelationType)
+		}
+		node := nodes[rel.SourceAssetID]
+		edge := map[string]any{"@id": AssetIRI(rel.TargetAssetID)}
+		if rel.Weight != nil {
+			edge["weight"] = *rel.Weight
+		}
+		edges, _ := node[predicate].([]map[string]any)
+		node[predicate] = append(edges, edge)
+	}
+
+	return json.Marshal(JSONLDDocument{
+		Context: JSONLDContextURL,
+		Graph:   graph,
+	})
+}
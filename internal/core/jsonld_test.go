@@ -0,0 +1,144 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadShippedContext returns the @context map from contexts/edg-context.jsonld
+func loadShippedContext(t *testing.T) map[string]any {
+	data, err := os.ReadFile("../../contexts/edg-context.jsonld")
+	require.NoError(t, err)
+
+	var doc struct {
+		Context map[string]any `json:"@context"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	return doc.Context
+}
+
+// TestExportAssetGraph tests nodes, edges and context references in the export
+func TestExportAssetGraph(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	lat, lon, alt := 37.5665, 126.978, 38.0
+	require.NoError(t, store.CreateAsset(&Asset{ID: "sensor", Name: "sensor-1", TemplateName: "temp", Labels: []string{"line-1"}, Latitude: &lat, Longitude: &lon, Altitude: &alt, CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateAsset(&Asset{ID: "machine", Name: "machine-1", CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateAsset(&Asset{ID: "hall", Name: "hall-1", CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateRelation(&AssetRelation{ID: "r1", SourceAssetID: "sensor", TargetAssetID: "machine", RelationType: RelationPartOf, CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateRelation(&AssetRelation{ID: "r2", SourceAssetID: "sensor", TargetAssetID: "machine", RelationType: RelationConnectedTo, CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateRelation(&AssetRelation{ID: "r3", SourceAssetID: "machine", TargetAssetID: "hall", RelationType: RelationLocatedIn, CreatedAt: time.Now()}))
+
+	data, err := ExportAssetGraph(store)
+	require.NoError(t, err)
+
+	var doc struct {
+		Context string           `json:"@context"`
+		Graph   []map[string]any `json:"@graph"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, JSONLDContextURL, doc.Context)
+	require.Len(t, doc.Graph, 3)
+
+	nodes := make(map[string]map[string]any)
+	for _, node := range doc.Graph {
+		nodes[node["@id"].(string)] = node
+	}
+
+	sensor := nodes[AssetIRI("sensor")]
+	require.NotNil(t, sensor)
+	assert.Equal(t, "sosa:Platform", sensor["@type"])
+	assert.Equal(t, "sensor-1", sensor["schema:name"])
+	assert.Equal(t, "temp", sensor["template_name"])
+	assert.Equal(t, []any{"line-1"}, sensor["schema:keywords"])
+	assert.Equal(t, []any{map[string]any{"@id": AssetIRI("machine")}}, sensor["ssn:isPartOf"])
+	assert.Equal(t, map[string]any{
+		"@type":            "schema:GeoCoordinates",
+		"schema:latitude":  lat,
+		"schema:longitude": lon,
+		"schema:elevation": alt,
+	}, sensor["schema:geo"])
+
+	machine := nodes[AssetIRI("machine")]
+	assert.Equal(t, []any{map[string]any{"@id": AssetIRI("hall")}}, machine["schema:containedInPlace"])
+	// connectedTo is symmetric and stored in canonical order, machine -> sensor
+	assert.Equal(t, []any{map[string]any{"@id": AssetIRI("sensor")}}, machine["sosa:isHostedBy"])
+
+	// Every compact IRI and term used must be declared in the shipped context
+	context := loadShippedContext(t)
+	for _, node := range doc.Graph {
+		for key := range node {
+			if strings.HasPrefix(key, "@") {
+				continue
+			}
+			if prefix, _, ok := strings.Cut(key, ":"); ok {
+				assert.Contains(t, context, prefix, "prefix of %s must be defined in @context", key)
+			} else {
+				assert.Contains(t, context, key, "term %s must be defined in @context", key)
+			}
+		}
+	}
+}
+
+// TestExportAssetGraph_SymmetricOnce tests that a connectedTo pair stored in
+// both directions exports as a single edge
+func TestExportAssetGraph_SymmetricOnce(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+	createTestAssets(t, store, "pump", "valve")
+
+	require.NoError(t, createTestRelation(t, store, "pump", "valve", RelationConnectedTo))
+	// Written before reverses were rejected
+	_, err = store.db.Exec(`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at)
+		VALUES ('legacy', 'valve', 'pump', 'connectedTo', ?)`, time.Now())
+	require.NoError(t, err)
+
+	data, err := ExportAssetGraph(store)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "sosa:isHostedBy"))
+}
+
+// TestExportAssetGraph_Weight tests that relation weights are exported as literals
+func TestExportAssetGraph_Weight(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+	createTestAssets(t, store, "pump", "tank", "valve")
+
+	weight := 2.5
+	require.NoError(t, store.CreateRelation(&AssetRelation{ID: "r1", SourceAssetID: "pump", TargetAssetID: "tank", RelationType: RelationConnectedTo, Weight: &weight, CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateRelation(&AssetRelation{ID: "r2", SourceAssetID: "pump", TargetAssetID: "valve", RelationType: RelationPartOf, CreatedAt: time.Now()}))
+
+	data, err := ExportAssetGraph(store)
+	require.NoError(t, err)
+
+	var doc struct {
+		Graph []map[string]any `json:"@graph"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	for _, node := range doc.Graph {
+		if node["@id"] != AssetIRI("pump") {
+			continue
+		}
+		assert.Equal(t, []any{map[string]any{"@id": AssetIRI("tank"), "weight": 2.5}}, node["sosa:isHostedBy"])
+		assert.Equal(t, []any{map[string]any{"@id": AssetIRI("valve")}}, node["ssn:isPartOf"])
+	}
+	assert.Contains(t, loadShippedContext(t), "weight")
+}
+
+// TestRelationPredicates_CoverAllTypes tests every relation type has a JSON-LD mapping
+func TestRelationPredicates_CoverAllTypes(t *testing.T) {
+	for _, rt := range ValidRelationTypes() {
+		_, ok := relationPredicate(rt)
+		assert.True(t, ok, rt)
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultLastSeenInterval is how often cmd/core writes last_seen updates
+// unless configured otherwise
+const DefaultLastSeenInterval = 10 * time.Second
+
+// TouchAssets sets the last_seen time of each asset in seen, in one
+// transaction. A time older than the stored one is ignored, and unknown IDs
+// are skipped. Cached lookups are not invalidated: last_seen does not affect
+// the data path.
+func (s *Store) TouchAssets(seen map[string]time.Time) error {
+	if len(seen) == 0 {
+		return nil
+	}
+	return s.WithTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(`UPDATE assets SET last_seen = ? WHERE id = ? AND (last_seen IS NULL OR last_seen < ?)`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare last seen update: %w", err)
+		}
+		defer stmt.Close()
+
+		for id, at := range seen {
+			at = at.Local()
+			if _, err := stmt.Exec(at, id, at); err != nil {
+				return fmt.Errorf("failed to update last seen of %s: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListStaleAssets retrieves assets with no data accepted within threshold,
+// including assets that never sent any, longest silent first. Soft-deleted
+// assets are not returned.
+func (s *Store) ListStaleAssets(threshold time.Duration) ([]*Asset, error) {
+	if threshold <= 0 {
+		return nil, errorf(ErrInvalid, "threshold must be positive")
+	}
+
+	rows, err := s.db.Query(
+		`SELECT `+assetColumns+` FROM assets WHERE `+assetNotDeleted+` AND (last_seen IS NULL OR last_seen < ?)
+		 ORDER BY last_seen IS NOT NULL, last_seen, id`,
+		time.Now().Add(-threshold).Local(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale assets: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAssets(rows)
+}
+
+// lastSeenWriter persists batched last_seen times
+type lastSeenWriter interface {
+	TouchAssets(seen map[string]time.Time) error
+}
+
+// LastSeenTracker collects the time each asset was last seen on the data
+// path and writes them in batches, so busy assets cost one update per
+// interval instead of one per message
+type LastSeenTracker struct {
+	store    lastSeenWriter
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+}
+
+// NewLastSeenTracker creates a tracker writing to store every interval once
+// Run is started
+func NewLastSeenTracker(store *Store, interval time.Duration) *LastSeenTracker {
+	return &LastSeenTracker{
+		store:    store,
+		interval: interval,
+		pending:  make(map[string]time.Time),
+	}
+}
+
+// Seen records that data from assetID was accepted at t
+func (t *LastSeenTracker) Seen(assetID string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if prev, ok := t.pending[assetID]; !ok || at.After(prev) {
+		t.pending[assetID] = at
+	}
+}
+
+// Flush writes the pending last_seen times. On failure they are kept for
+// the next flush unless a newer time was recorded meanwhile.
+func (t *LastSeenTracker) Flush() error {
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = make(map[string]time.Time, len(batch))
+	t.mu.Unlock()
+
+	if err := t.store.TouchAssets(batch); err != nil {
+		for id, at := range batch {
+			t.Seen(id, at)
+		}
+		return err
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is done
+func (t *LastSeenTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Flush(); err != nil {
+				coreLog().Warn("failed to update last seen", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestListStaleAssets tests that assets silent past the threshold are listed
+func TestListStaleAssets(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "fresh", "silent", "never", "retired")
+	require.NoError(t, store.SoftDeleteAsset("retired"))
+
+	now := time.Now()
+	require.NoError(t, store.TouchAssets(map[string]time.Time{
+		"fresh":   now,
+		"silent":  now.Add(-time.Hour),
+		"retired": now.Add(-time.Hour),
+		"unknown": now,
+	}))
+	// An older time does not move last_seen back
+	require.NoError(t, store.TouchAssets(map[string]time.Time{"fresh": now.Add(-2 * time.Hour)}))
+
+	stale, err := store.ListStaleAssets(10 * time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"never", "silent"}, assetIDs(stale))
+	require.NotNil(t, stale[1].LastSeen)
+	assert.WithinDuration(t, now.Add(-time.Hour), *stale[1].LastSeen, time.Second)
+
+	_, err = store.ListStaleAssets(0)
+	assert.True(t, errors.Is(err, ErrInvalid))
+}
+
+// TestHandleAssetData_LastSeen tests that only accepted data marks an asset as seen
+func TestHandleAssetData_LastSeen(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "faulty")
+
+	tracker := NewLastSeenTracker(store, time.Hour)
+	handler := NewDataHandler(nil, store)
+	handler.SetMinQuality(QualityUncertain)
+	handler.SetLastSeenTracker(tracker)
+	handler.SetDedupWindow(time.Hour)
+
+	// Accepted, then suppressed as unchanged: both count as seen
+	handler.HandleAssetData(dataMsg(t, "sensor-001", 21.5, QualityGood))
+	handler.HandleAssetData(dataMsg(t, "sensor-001", 21.5, QualityGood))
+	// Every value rejected as bad
+	handler.HandleAssetData(dataMsg(t, "faulty", 21.5, QualityBad))
+	require.NoError(t, tracker.Flush())
+
+	seen, err := store.GetAsset("sensor-001")
+	require.NoError(t, err)
+	require.NotNil(t, seen.LastSeen)
+	assert.WithinDuration(t, time.Now(), *seen.LastSeen, time.Minute)
+
+	rejected, err := store.GetAsset("faulty")
+	require.NoError(t, err)
+	assert.Nil(t, rejected.LastSeen)
+}
+
+// failingLastSeenWriter fails every write
+type failingLastSeenWriter struct{}
+
+func (*failingLastSeenWriter) TouchAssets(map[string]time.Time) error {
+	return errors.New("database is locked")
+}
+
+// TestLastSeenTracker_FlushRetries tests that a failed batch is kept for the next flush
+func TestLastSeenTracker_FlushRetries(t *testing.T) {
+	writer := &failingLastSeenWriter{}
+	tracker := &LastSeenTracker{store: writer, pending: make(map[string]time.Time)}
+
+	at := time.Now()
+	tracker.Seen("sensor-001", at)
+	tracker.Seen("sensor-001", at.Add(-time.Minute))
+	assert.Error(t, tracker.Flush())
+	assert.Equal(t, map[string]time.Time{"sensor-001": at}, tracker.pending)
+
+	tracker.store = newTestStore(t)
+	require.NoError(t, tracker.Flush())
+	assert.Empty(t, tracker.pending)
+}
@@ -1,11 +1,19 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,12 +21,20 @@ import (
 type TemplateLoader struct {
 	mu        sync.RWMutex
 	templates map[string]*AssetTemplate
+	sources   map[string]string // file each template was loaded from, by template name
+
+	// The directory last loaded with LoadFromDir or LoadFromFS, used by
+	// Reload. dir is set for LoadFromDir only and is also used by Watch.
+	fsys  fs.FS
+	fsDir string
+	dir   string
 }
 
 // NewTemplateLoader creates a new loader
 func NewTemplateLoader() *TemplateLoader {
 	return &TemplateLoader{
 		templates: make(map[string]*AssetTemplate),
+		sources:   make(map[string]string),
 	}
 }
 
@@ -29,13 +45,16 @@ func (l *TemplateLoader) LoadFromDir(dir string) error {
 		return fmt.Errorf("failed to read directory: %w", err)
 	}
 
+	l.mu.Lock()
+	l.fsys, l.fsDir, l.dir = os.DirFS(dir), ".", dir
+	l.mu.Unlock()
+
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
 		}
 
-		ext := filepath.Ext(entry.Name())
-		if ext != ".yaml" && ext != ".yml" {
+		if !isTemplateFile(entry.Name()) {
 			continue
 		}
 
@@ -48,25 +67,352 @@ func (l *TemplateLoader) LoadFromDir(dir string) error {
 	return nil
 }
 
+// Reload loads every template in the directory last passed to LoadFromDir
+// or LoadFromFS again and swaps them in for the ones loaded from it before,
+// so templates whose file was removed are dropped. Templates loaded from
+// other files are kept. Unlike LoadFromDir it does not stop at the first
+// bad file: each failure is reported and the templates of that file keep
+// their previously loaded version. Reloading built-in templates from an
+// embedded FS is supported and finds them unchanged. An unreadable
+// directory fails the reload and changes nothing.
+func (l *TemplateLoader) Reload() (*TemplateReloadResult, error) {
+	l.mu.RLock()
+	fsys, fsDir, dir := l.fsys, l.fsDir, l.dir
+	l.mu.RUnlock()
+
+	if fsys == nil {
+		return nil, errorf(ErrInvalid, "no template directory loaded")
+	}
+	// sourceName names a file as LoadFromDir or LoadFromFS recorded it
+	sourceName := func(name string) string {
+		if dir != "" {
+			return filepath.Join(dir, name)
+		}
+		return path.Join(fsDir, name)
+	}
+	entries, err := fs.ReadDir(fsys, fsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	result := &TemplateReloadResult{}
+	templates := make(map[string]*AssetTemplate)
+	sources := make(map[string]string)
+	failed := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !isTemplateFile(entry.Name()) {
+			continue
+		}
+		source := sourceName(entry.Name())
+		template, err := readTemplate(fsys, path.Join(fsDir, entry.Name()), source)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", source, err))
+			failed[source] = true
+			continue
+		}
+		templates[template.Name] = template
+		sources[template.Name] = source
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for name, source := range l.sources {
+		fromDir := sourceName(filepath.Base(source)) == source
+		if _, ok := templates[name]; !ok && (!fromDir || failed[source]) {
+			templates[name] = l.templates[name]
+			sources[name] = source
+		}
+	}
+	l.templates, l.sources = templates, sources
+	result.Count = len(templates)
+	return result, nil
+}
+
+// readTemplate reads and checks the template in file name of fsys, naming
+// it source in errors
+func readTemplate(fsys fs.FS, name, source string) (*AssetTemplate, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return checkTemplate(data, source)
+}
+
+// LoadFromFS loads all YAML templates from dir within fsys, e.g. an
+// embed.FS compiled into the binary. Templates are checked like those read
+// by LoadFromDir. Directories loaded this way can be reloaded but are not
+// watched.
+func (l *TemplateLoader) LoadFromFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	l.mu.Lock()
+	l.fsys, l.fsDir, l.dir = fsys, dir, ""
+	l.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.IsDir() || !isTemplateFile(entry.Name()) {
+			continue
+		}
+
+		name := path.Join(dir, entry.Name())
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("failed to load template (%s): failed to read file: %w", name, err)
+		}
+		if err := l.load(data, name); err != nil {
+			return fmt.Errorf("failed to load template (%s): %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// isTemplateFile reports whether name has a YAML extension
+func isTemplateFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
 // LoadFromFile loads a template from a single YAML file
 func (l *TemplateLoader) LoadFromFile(path string) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
+	return l.load(data, path)
+}
+
+// load parses, checks and registers the template read from path
+func (l *TemplateLoader) load(data []byte, path string) error {
+	template, err := checkTemplate(data, path)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.templates[template.Name] = template
+	l.sources[template.Name] = path
+	l.mu.Unlock()
+
+	return nil
+}
+
+// checkTemplate parses and checks the template read from path
+func checkTemplate(data []byte, path string) (*AssetTemplate, error) {
+	template, err := parseTemplate(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResources(template); err != nil {
+		return nil, err
+	}
+	if err := checkUnits(template, path); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// LoadFromMultiDoc loads every template from a multi-document YAML file,
+// with documents separated by "---". All documents are parsed before any is
+// registered, so a bad document leaves the loaded templates unchanged.
+func (l *TemplateLoader) LoadFromMultiDoc(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	var templates []*AssetTemplate
+	decoder := yaml.NewDecoder(f)
+	for index := 0; ; index++ {
+		var template *AssetTemplate
+		if err := decoder.Decode(&template); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("failed to parse YAML (document %d): %w", index, err)
+		}
+		if template == nil {
+			// empty document, e.g. a trailing separator
+			continue
+		}
+		if template.Name == "" {
+			return fmt.Errorf("template name is missing: %s (document %d)", path, index)
+		}
+		if err := checkResources(template); err != nil {
+			return fmt.Errorf("%w (document %d)", err, index)
+		}
+		if err := checkUnits(template, path); err != nil {
+			return fmt.Errorf("%w (document %d)", err, index)
+		}
+		templates = append(templates, template)
+	}
+
+	l.mu.Lock()
+	for _, template := range templates {
+		l.templates[template.Name] = template
+		l.sources[template.Name] = path
+	}
+	l.mu.Unlock()
+
+	return nil
+}
+
+// checkResources rejects resources without a name, with a name already
+// used in the template, or with an unknown value type
+func checkResources(template *AssetTemplate) error {
+	seen := make(map[string]bool, len(template.Resources))
+	for i, res := range template.Resources {
+		if res.Name == "" {
+			return fmt.Errorf("resource %d in template '%s' has no name", i, template.Name)
+		}
+		if seen[res.Name] {
+			return fmt.Errorf("duplicate resource '%s' in template '%s'", res.Name, template.Name)
+		}
+		seen[res.Name] = true
+
+		switch res.ValueType {
+		case ValueTypeNumber, ValueTypeText, ValueTypeFlag:
+		default:
+			return fmt.Errorf("resource '%s' in template '%s' has unknown valueType '%s' (NUMBER, TEXT or FLAG)",
+				res.Name, template.Name, res.ValueType)
+		}
+	}
+	return nil
+}
+
+// TemplateError lists the problems found in one template by Validate
+type TemplateError struct {
+	Template string
+	Problems []string
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("template '%s': %s", e.Template, strings.Join(e.Problems, "; "))
+}
+
+// Validate checks every loaded template and returns a *TemplateError for
+// each one with problems, ordered by template name. It reports empty and
+// duplicate resource names, unknown value types and min greater than max,
+// so broken templates surface at startup rather than at ingest.
+// errors.Join of the result lists every bad template.
+func (l *TemplateLoader) Validate() []error {
+	templates := l.List()
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
 
+	var errs []error
+	for _, template := range templates {
+		if problems := templateProblems(template); len(problems) > 0 {
+			errs = append(errs, &TemplateError{Template: template.Name, Problems: problems})
+		}
+	}
+	return errs
+}
+
+// templateProblems returns every problem of template's resources
+func templateProblems(template *AssetTemplate) []string {
+	var problems []string
+	seen := make(map[string]bool, len(template.Resources))
+	for i, res := range template.Resources {
+		switch {
+		case res.Name == "":
+			problems = append(problems, fmt.Sprintf("resource %d has no name", i))
+		case seen[res.Name]:
+			problems = append(problems, fmt.Sprintf("duplicate resource '%s'", res.Name))
+		}
+		seen[res.Name] = true
+
+		switch res.ValueType {
+		case ValueTypeNumber, ValueTypeText, ValueTypeFlag:
+		default:
+			problems = append(problems, fmt.Sprintf("resource '%s' has unknown valueType '%s'", res.Name, res.ValueType))
+		}
+		if res.Min != nil && res.Max != nil && *res.Min > *res.Max {
+			problems = append(problems, fmt.Sprintf("resource '%s' has min %g greater than max %g", res.Name, *res.Min, *res.Max))
+		}
+	}
+	return problems
+}
+
+// checkUnits rejects unknown units in strict-unit templates and warns
+// about them otherwise
+func checkUnits(template *AssetTemplate, path string) error {
+	unknown := unknownUnits(template)
+	if len(unknown) == 0 {
+		return nil
+	}
+	if template.StrictUnits {
+		return fmt.Errorf("unknown units in template '%s': %s", template.Name, strings.Join(unknown, ", "))
+	}
+	coreLog().Warn("template declares unknown units", "template", template.Name, "file", path, "units", unknown)
+	return nil
+}
+
+// parseTemplate parses a template read from path without registering it
+func parseTemplate(data []byte, path string) (*AssetTemplate, error) {
 	var template AssetTemplate
 	if err := yaml.Unmarshal(data, &template); err != nil {
-		return fmt.Errorf("failed to parse YAML: %w", err)
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
 	if template.Name == "" {
-		return fmt.Errorf("template name is missing: %s", path)
+		return nil, fmt.Errorf("template name is missing: %s", path)
 	}
 
-	l.mu.Lock()
-	l.templates[template.Name] = &template
-	l.mu.Unlock()
+	return &template, nil
+}
+
+// Watch reloads templates from the directory last passed to LoadFromDir
+// whenever a file in it is created or written. A file that fails to load is
+// logged and the previously loaded version is kept. Watching stops when ctx
+// is done.
+func (l *TemplateLoader) Watch(ctx context.Context) error {
+	l.mu.RLock()
+	dir := l.dir
+	l.mu.RUnlock()
+
+	if dir == "" {
+		return fmt.Errorf("no template directory loaded")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch directory: %w", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !isTemplateFile(event.Name) || !event.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				if err := l.LoadFromFile(event.Name); err != nil {
+					coreLog().Warn("template reload failed, keeping previous version", "file", event.Name, "error", err)
+					continue
+				}
+				coreLog().Info("template reloaded", "file", event.Name)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				coreLog().Error("template watcher error", "error", err)
+			}
+		}
+	}()
 
 	return nil
 }
@@ -90,6 +436,50 @@ func (l *TemplateLoader) List() []*AssetTemplate {
 	return list
 }
 
+// GetVersion returns the version of a template, 0 when it is unversioned
+// or not loaded
+func (l *TemplateLoader) GetVersion(name string) int {
+	if template := l.Get(name); template != nil {
+		return template.Version
+	}
+	return 0
+}
+
+// CheckTemplateVersion checks that the template version an asset was
+// created against is not older than the template's last breaking change.
+// Incompatible assets are rejected for StrictVersion templates and logged
+// otherwise. It is called alongside ValidateAssetData on the data path.
+func (l *TemplateLoader) CheckTemplateVersion(asset *Asset) error {
+	template := l.Get(asset.TemplateName)
+	if template == nil || asset.TemplateVersion >= template.BreakingVersion {
+		return nil
+	}
+	if template.StrictVersion {
+		return fmt.Errorf("asset '%s' uses template '%s' version %d, older than breaking version %d",
+			asset.ID, template.Name, asset.TemplateVersion, template.BreakingVersion)
+	}
+	coreLog().Warn("asset template version is incompatible", "asset_id", asset.ID, "template", template.Name,
+		"asset_version", asset.TemplateVersion, "breaking_version", template.BreakingVersion)
+	return nil
+}
+
+// checkAssetData runs the template checks of the data path on data sent
+// by asset: the template version, coercion of TEXT values when coerce is
+// set, and validation. It returns how many values were coerced.
+func (l *TemplateLoader) checkAssetData(asset *Asset, data *AssetData, coerce bool) (int, error) {
+	if err := l.CheckTemplateVersion(asset); err != nil {
+		return 0, err
+	}
+	var coerced int
+	if coerce {
+		var err error
+		if coerced, err = l.CoerceAssetData(asset.TemplateName, data); err != nil {
+			return coerced, err
+		}
+	}
+	return coerced, l.ValidateAssetData(asset.TemplateName, data)
+}
+
 // Exists checks if a template exists
 func (l *TemplateLoader) Exists(name string) bool {
 	l.mu.RLock()
@@ -119,20 +509,54 @@ func (l *TemplateLoader) ValidateAssetData(templateName string, data *AssetData)
 		resourceMap[template.Resources[i].Name] = &template.Resources[i]
 	}
 
-	// validate each TagValue
+	// check required tags before per-tag validation
+	present := make(map[string]bool, len(data.Values))
+	for _, tv := range data.Values {
+		present[tv.Name] = true
+	}
+	var missing []string
+	for _, res := range template.Resources {
+		if res.Required && !present[res.Name] {
+			missing = append(missing, res.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required tags: %s", strings.Join(missing, ", "))
+	}
+
+	// validate each TagValue. Quality never fails template validation;
+	// DataHandler.SetMinQuality filters on TagValue.EffectiveQuality, which
+	// lets StatusCode override the Quality string.
 	for _, tv := range data.Values {
 		res, ok := resourceMap[tv.Name]
 		if !ok {
+			if template.Strict {
+				return fmt.Errorf("tag '%s' is not defined in template '%s'", tv.Name, templateName)
+			}
 			// undefined tag (warning only, not an error)
 			continue
 		}
 
+		// a tag unit must match the declared one when both are set
+		if tv.Unit != "" && res.Unit != "" && tv.Unit != res.Unit {
+			if template.StrictUnits {
+				return fmt.Errorf("tag '%s' unit '%s' does not match template unit '%s'", tv.Name, tv.Unit, res.Unit)
+			}
+			coreLog().Warn("tag unit does not match template", "template", templateName, "tag", tv.Name, "unit", tv.Unit, "expected_unit", res.Unit)
+		}
+
 		// validate value type
 		switch res.ValueType {
 		case ValueTypeNumber:
 			if tv.Number == nil {
 				return fmt.Errorf("tag '%s' must be NUMBER type", tv.Name)
 			}
+			if res.Min != nil && *tv.Number < *res.Min {
+				return fmt.Errorf("tag '%s' value %g is below min %g", tv.Name, *tv.Number, *res.Min)
+			}
+			if res.Max != nil && *tv.Number > *res.Max {
+				return fmt.Errorf("tag '%s' value %g exceeds max %g", tv.Name, *tv.Number, *res.Max)
+			}
 		case ValueTypeText:
 			if tv.Text == nil {
 				return fmt.Errorf("tag '%s' must be TEXT type", tv.Name)
@@ -1,12 +1,19 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 // TestLoadFromFile_Success tests loading a valid YAML template
@@ -31,6 +38,46 @@ func TestLoadFromFile_Success(t *testing.T) {
 	assert.Equal(t, ValueTypeFlag, template.Resources[2].ValueType)
 }
 
+// TestLoadFromFile_ResourceDocumentation tests that resource display names
+// and descriptions are loaded and survive YAML and JSON round-trips
+func TestLoadFromFile_ResourceDocumentation(t *testing.T) {
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `
+name: documented-sensor
+resources:
+  - name: temperature
+    valueType: NUMBER
+    unit: celsius
+    displayName: Coolant temperature
+    description: Measured at the pump outlet
+  - name: status
+    valueType: TEXT
+`)))
+	template := loader.Get("documented-sensor")
+	require.NotNil(t, template)
+	assert.Equal(t, "Coolant temperature", template.Resources[0].DisplayName)
+	assert.Equal(t, "Measured at the pump outlet", template.Resources[0].Description)
+	assert.Empty(t, template.Resources[1].DisplayName)
+
+	encoded, err := yaml.Marshal(template)
+	require.NoError(t, err)
+	var fromYAML AssetTemplate
+	require.NoError(t, yaml.Unmarshal(encoded, &fromYAML))
+	assert.Equal(t, template.Resources, fromYAML.Resources)
+
+	encoded, err = json.Marshal(template)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"displayName":"Coolant temperature"`)
+	var fromJSON AssetTemplate
+	require.NoError(t, json.Unmarshal(encoded, &fromJSON))
+	assert.Equal(t, template.Resources, fromJSON.Resources)
+
+	// Documentation does not affect validation
+	value := 20.0
+	data := &AssetData{AssetID: "s1", Values: []TagValue{{Name: "temperature", Number: &value}}}
+	assert.NoError(t, loader.ValidateAssetData("documented-sensor", data))
+}
+
 // TestLoadFromFile_InvalidYAML tests handling of malformed YAML
 func TestLoadFromFile_InvalidYAML(t *testing.T) {
 	loader := NewTemplateLoader()
@@ -55,6 +102,89 @@ func TestLoadFromFile_MissingName(t *testing.T) {
 	assert.Contains(t, err.Error(), "template name is missing")
 }
 
+// TestLoadFromMultiDoc tests loading a catalog of templates from one file
+func TestLoadFromMultiDoc(t *testing.T) {
+	loader := NewTemplateLoader()
+	path := writeTemplate(t, `name: pump
+resources:
+  - name: flow
+    valueType: NUMBER
+---
+name: valve
+resources:
+  - name: open
+    valueType: FLAG
+---
+name: motor
+resources:
+  - name: rpm
+    valueType: NUMBER
+---
+`)
+
+	require.NoError(t, loader.LoadFromMultiDoc(path))
+	assert.Equal(t, 3, loader.Count())
+	require.NotNil(t, loader.Get("valve"))
+	assert.Equal(t, ValueTypeFlag, loader.Get("valve").Resources[0].ValueType)
+}
+
+// TestLoadFromMultiDoc_MissingName tests that a nameless document is reported
+// by index and nothing from the file is registered
+func TestLoadFromMultiDoc_MissingName(t *testing.T) {
+	loader := NewTemplateLoader()
+	path := writeTemplate(t, `name: pump
+resources:
+  - name: flow
+    valueType: NUMBER
+---
+resources:
+  - name: open
+    valueType: FLAG
+---
+name: motor
+resources:
+  - name: rpm
+    valueType: NUMBER
+`)
+
+	err := loader.LoadFromMultiDoc(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "template name is missing")
+	assert.Contains(t, err.Error(), "(document 1)")
+	assert.Equal(t, 0, loader.Count())
+}
+
+// TestCheckTemplateVersion tests breaking-version checks for recorded asset versions
+func TestCheckTemplateVersion(t *testing.T) {
+	logs := captureLogs(t)
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `name: pump
+version: 3
+breakingVersion: 2
+resources:
+  - name: flow
+    valueType: NUMBER
+`)))
+	assert.Equal(t, 3, loader.GetVersion("pump"))
+	assert.Equal(t, 0, loader.GetVersion("missing"))
+
+	assert.NoError(t, loader.CheckTemplateVersion(&Asset{ID: "a", TemplateName: "pump", TemplateVersion: 2}))
+	assert.Empty(t, logRecords(t, logs))
+
+	// Older versions only warn unless the template is strict
+	assert.NoError(t, loader.CheckTemplateVersion(&Asset{ID: "a", TemplateName: "pump", TemplateVersion: 1}))
+	records := logRecords(t, logs)
+	require.Len(t, records, 1)
+	assert.Equal(t, "asset template version is incompatible", records[0]["msg"])
+
+	loader.Get("pump").StrictVersion = true
+	err := loader.CheckTemplateVersion(&Asset{ID: "a", TemplateName: "pump", TemplateVersion: 1})
+	assert.EqualError(t, err, "asset 'a' uses template 'pump' version 1, older than breaking version 2")
+
+	// Assets of unknown templates are not checked
+	assert.NoError(t, loader.CheckTemplateVersion(&Asset{ID: "a", TemplateName: "missing"}))
+}
+
 // TestLoadFromDir_FailsOnInvalidFile tests that LoadFromDir returns error when directory contains invalid template
 func TestLoadFromDir_FailsOnInvalidFile(t *testing.T) {
 	loader := NewTemplateLoader()
@@ -66,6 +196,71 @@ func TestLoadFromDir_FailsOnInvalidFile(t *testing.T) {
 	assert.Contains(t, err.Error(), "template name is missing")
 }
 
+// TestLoadFromFS tests loading templates from a file system with the same checks as LoadFromDir
+func TestLoadFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/pump.yaml":     {Data: []byte("name: pump\nresources:\n  - name: flow\n    valueType: NUMBER\n")},
+		"templates/valve.yml":     {Data: []byte("name: valve\n")},
+		"templates/README.md":     {Data: []byte("not a template")},
+		"templates/nested/x.yaml": {Data: []byte("name: nested\n")},
+	}
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFS(fsys, "templates"))
+	assert.Equal(t, 2, loader.Count())
+	assert.True(t, loader.Exists("pump"))
+	assert.True(t, loader.Exists("valve"))
+
+	fsys["templates/broken.yaml"] = &fstest.MapFile{Data: []byte("resources: []\n")}
+	err := loader.LoadFromFS(fsys, "templates")
+	assert.ErrorContains(t, err, "templates/broken.yaml")
+	assert.ErrorContains(t, err, "template name is missing")
+
+	assert.ErrorIs(t, loader.LoadFromFS(fsys, "missing"), fs.ErrNotExist)
+}
+
+// TestValidate_ReportsEveryBadTemplate tests that the self-check lists each problem of each bad template
+func TestValidate_ReportsEveryBadTemplate(t *testing.T) {
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `
+name: inverted
+resources:
+  - name: temperature
+    valueType: NUMBER
+    min: 125
+    max: -40
+  - name: pressure
+    valueType: NUMBER
+    min: 0
+    max: 10
+`)))
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `
+name: good
+resources:
+  - name: running
+    valueType: FLAG
+`)))
+	// Registered without the load-time checks
+	loader.templates["broken"] = &AssetTemplate{Name: "broken", Resources: []AssetResource{
+		{Name: "state", ValueType: "ENUM"},
+		{Name: "state", ValueType: ValueTypeText},
+		{ValueType: ValueTypeFlag},
+	}}
+
+	errs := loader.Validate()
+	require.Len(t, errs, 2)
+	assert.Equal(t, "template 'broken': resource 'state' has unknown valueType 'ENUM'; duplicate resource 'state'; resource 2 has no name", errs[0].Error())
+	assert.Equal(t, "template 'inverted': resource 'temperature' has min 125 greater than max -40", errs[1].Error())
+
+	var templateErr *TemplateError
+	require.True(t, errors.As(errors.Join(errs...), &templateErr))
+	assert.Equal(t, "broken", templateErr.Template)
+
+	delete(loader.templates, "broken")
+	delete(loader.templates, "inverted")
+	assert.Empty(t, loader.Validate())
+}
+
 // TestValidateAssetData_Success tests successful data validation
 func TestValidateAssetData_Success(t *testing.T) {
 	loader := NewTemplateLoader()
@@ -109,3 +304,418 @@ func TestValidateAssetData_InvalidType(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "must be NUMBER type")
 }
+
+// writeTemplate writes a YAML template into a temp dir and returns its path
+func writeTemplate(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "template.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+// TestValidateAssetData_NumericRange tests min/max enforcement for NUMBER tags
+func TestValidateAssetData_NumericRange(t *testing.T) {
+	loader := NewTemplateLoader()
+	err := loader.LoadFromFile(writeTemplate(t, `
+name: ranged-sensor
+resources:
+  - name: temperature
+    valueType: NUMBER
+    min: -40
+    max: 125
+  - name: humidity
+    valueType: NUMBER
+`))
+	require.NoError(t, err)
+
+	template := loader.Get("ranged-sensor")
+	require.NotNil(t, template.Resources[0].Min)
+	assert.Equal(t, -40.0, *template.Resources[0].Min)
+	assert.Nil(t, template.Resources[1].Max)
+
+	tests := []struct {
+		name    string
+		value   float64
+		wantErr string
+	}{
+		{"within range", 25, ""},
+		{"at min", -40, ""},
+		{"at max", 125, ""},
+		{"above max", 200, "tag 'temperature' value 200 exceeds max 125"},
+		{"below min", -41.5, "tag 'temperature' value -41.5 is below min -40"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := tt.value
+			data := &AssetData{Values: []TagValue{{Name: "temperature", Number: &value}}}
+			err := loader.ValidateAssetData("ranged-sensor", data)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+			}
+		})
+	}
+
+	// Resources without bounds stay unconstrained
+	huge := 1e9
+	data := &AssetData{Values: []TagValue{{Name: "humidity", Number: &huge}}}
+	assert.NoError(t, loader.ValidateAssetData("ranged-sensor", data))
+}
+
+// TestValidateAssetData_StrictTemplate tests that undefined tags are rejected only by strict templates
+func TestValidateAssetData_StrictTemplate(t *testing.T) {
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `
+name: lenient-sensor
+resources:
+  - name: temperature
+    valueType: NUMBER
+`)))
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `
+name: strict-sensor
+strict: true
+resources:
+  - name: temperature
+    valueType: NUMBER
+`)))
+	assert.True(t, loader.Get("strict-sensor").Strict)
+	assert.False(t, loader.Get("lenient-sensor").Strict)
+
+	// Same payload with a misspelled tag
+	value := 25.0
+	data := &AssetData{Values: []TagValue{
+		{Name: "temperature", Number: &value},
+		{Name: "temprature", Number: &value},
+	}}
+
+	assert.NoError(t, loader.ValidateAssetData("lenient-sensor", data))
+
+	err := loader.ValidateAssetData("strict-sensor", data)
+	require.Error(t, err)
+	assert.Equal(t, "tag 'temprature' is not defined in template 'strict-sensor'", err.Error())
+}
+
+// TestValidateAssetData_RequiredResources tests that missing required tags are reported before type checks
+func TestValidateAssetData_RequiredResources(t *testing.T) {
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `
+name: required-sensor
+resources:
+  - name: temperature
+    valueType: NUMBER
+    required: true
+  - name: humidity
+    valueType: NUMBER
+    required: true
+  - name: status
+    valueType: TEXT
+`)))
+
+	value := 25.0
+	status := "ok"
+
+	// All required tags present, optional one omitted
+	data := &AssetData{Values: []TagValue{
+		{Name: "temperature", Number: &value},
+		{Name: "humidity", Number: &value},
+	}}
+	assert.NoError(t, loader.ValidateAssetData("required-sensor", data))
+
+	// Missing tags are listed, even when a present tag has the wrong type
+	data = &AssetData{Values: []TagValue{{Name: "status", Number: &value}}}
+	err := loader.ValidateAssetData("required-sensor", data)
+	require.Error(t, err)
+	assert.Equal(t, "missing required tags: temperature, humidity", err.Error())
+
+	data = &AssetData{Values: []TagValue{
+		{Name: "temperature", Number: &value},
+		{Name: "status", Text: &status},
+	}}
+	err = loader.ValidateAssetData("required-sensor", data)
+	require.Error(t, err)
+	assert.Equal(t, "missing required tags: humidity", err.Error())
+}
+
+// TestWatch_ReloadsChangedTemplate tests hot-reload and retention of the last good version
+func TestWatch_ReloadsChangedTemplate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sensor.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: watched\nresources:\n  - name: a\n    valueType: NUMBER\n"), 0644))
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromDir(dir))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, loader.Watch(ctx))
+
+	// Valid change is picked up
+	require.NoError(t, os.WriteFile(path, []byte("name: watched\nresources:\n  - name: a\n    valueType: NUMBER\n  - name: b\n    valueType: TEXT\n"), 0644))
+	require.Eventually(t, func() bool {
+		return len(loader.Get("watched").Resources) == 2
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// New file is picked up
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.yml"), []byte("name: other\n"), 0644))
+	require.Eventually(t, func() bool {
+		return loader.Exists("other")
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// Invalid change keeps the previous version
+	require.NoError(t, os.WriteFile(path, []byte("{ invalid yaml ["), 0644))
+	time.Sleep(200 * time.Millisecond)
+	template := loader.Get("watched")
+	require.NotNil(t, template)
+	assert.Len(t, template.Resources, 2)
+}
+
+// TestWatch_RequiresDirectory tests that Watch fails before LoadFromDir
+func TestWatch_RequiresDirectory(t *testing.T) {
+	loader := NewTemplateLoader()
+	err := loader.Watch(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no template directory loaded")
+}
+
+// TestReload tests that a reload picks up new files and keeps the previous
+// version of files that fail
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sensor.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("name: sensor\nresources:\n  - name: a\n    valueType: NUMBER\n"), 0644))
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromDir(dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pump.yml"), []byte("name: pump\n"), 0644))
+	result, err := loader.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+	assert.Empty(t, result.Errors)
+	assert.True(t, loader.Exists("pump"))
+
+	require.NoError(t, os.WriteFile(path, []byte("{ invalid yaml ["), 0644))
+	result, err = loader.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0], path)
+	assert.Len(t, loader.Get("sensor").Resources, 1)
+
+	// A vanished directory fails the reload and keeps every template
+	require.NoError(t, os.RemoveAll(dir))
+	_, err = loader.Reload()
+	require.Error(t, err)
+	assert.Equal(t, 2, loader.Count())
+}
+
+// TestReload_RemovedFile tests that a reload drops the templates of deleted
+// files and keeps templates loaded from elsewhere
+func TestReload_RemovedFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"sensor", "pump"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".yaml"), []byte("name: "+name+"\n"), 0644))
+	}
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+	require.NoError(t, loader.LoadFromDir(dir))
+	require.Equal(t, 3, loader.Count())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "pump.yaml")))
+	result, err := loader.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+	assert.Empty(t, result.Errors)
+	assert.False(t, loader.Exists("pump"))
+	assert.True(t, loader.Exists("sensor"))
+	assert.True(t, loader.Exists("test-sensor"))
+
+	// A template renamed within its file replaces the old name
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sensor.yaml"), []byte("name: probe\n"), 0644))
+	_, err = loader.Reload()
+	require.NoError(t, err)
+	assert.False(t, loader.Exists("sensor"))
+	assert.True(t, loader.Exists("probe"))
+}
+
+// TestReload_FS tests reloading templates loaded from a file system such as
+// the embedded built-in templates
+func TestReload_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/sensor.yaml": {Data: []byte("name: sensor\n")},
+		"templates/pump.yaml":   {Data: []byte("name: pump\n")},
+	}
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFS(fsys, "templates"))
+
+	result, err := loader.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Count)
+
+	delete(fsys, "templates/pump.yaml")
+	fsys["templates/broken.yaml"] = &fstest.MapFile{Data: []byte("{ invalid yaml [")}
+	result, err = loader.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Count)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0], "templates/broken.yaml")
+	assert.False(t, loader.Exists("pump"))
+}
+
+// TestReload_RequiresDirectory tests that Reload fails before LoadFromDir
+// or LoadFromFS
+func TestReload_RequiresDirectory(t *testing.T) {
+	_, err := NewTemplateLoader().Reload()
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+// TestLoadFromFile_UnknownUnits tests that unknown units warn by default and fail strict templates
+func TestLoadFromFile_UnknownUnits(t *testing.T) {
+	assert.True(t, IsKnownUnit("°C"))
+	assert.True(t, IsKnownUnit("mm/s"))
+	assert.False(t, IsKnownUnit("celsius"))
+
+	buf := captureLogs(t)
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `
+name: lenient-units
+resources:
+  - name: temperature
+    valueType: NUMBER
+    unit: celsius
+  - name: humidity
+    valueType: NUMBER
+    unit: "%"
+`)))
+	assert.True(t, loader.Exists("lenient-units"))
+
+	records := logRecords(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "template declares unknown units", records[0]["msg"])
+	assert.Equal(t, []any{"celsius"}, records[0]["units"])
+
+	err := loader.LoadFromFile(writeTemplate(t, `
+name: strict-units
+strictUnits: true
+resources:
+  - name: temperature
+    valueType: NUMBER
+    unit: celsius
+  - name: pressure
+    valueType: NUMBER
+    unit: furlongs
+`))
+	require.Error(t, err)
+	assert.Equal(t, "unknown units in template 'strict-units': celsius, furlongs", err.Error())
+	assert.False(t, loader.Exists("strict-units"))
+}
+
+// TestLoadFromFile_MalformedResources tests that bad resource declarations reject the template
+func TestLoadFromFile_MalformedResources(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"duplicate name", `
+name: dup
+resources:
+  - name: temperature
+    valueType: NUMBER
+  - name: temperature
+    valueType: TEXT
+`, "duplicate resource 'temperature' in template 'dup'"},
+		{"empty name", `
+name: unnamed
+resources:
+  - name: temperature
+    valueType: NUMBER
+  - valueType: FLAG
+`, "resource 1 in template 'unnamed' has no name"},
+		{"unknown value type", `
+name: typo
+resources:
+  - name: temperature
+    valueType: NUMERIC
+`, "resource 'temperature' in template 'typo' has unknown valueType 'NUMERIC'"},
+		{"missing value type", `
+name: untyped
+resources:
+  - name: temperature
+`, "resource 'temperature' in template 'untyped' has unknown valueType ''"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewTemplateLoader()
+			err := loader.LoadFromFile(writeTemplate(t, tt.yaml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+			assert.Equal(t, 0, loader.Count())
+		})
+	}
+}
+
+// TestLoadFromMultiDoc_MalformedResources tests that one bad document rejects the catalog
+func TestLoadFromMultiDoc_MalformedResources(t *testing.T) {
+	loader := NewTemplateLoader()
+	err := loader.LoadFromMultiDoc(writeTemplate(t, `
+name: good
+resources:
+  - name: temperature
+    valueType: NUMBER
+---
+name: dup
+resources:
+  - name: status
+    valueType: TEXT
+  - name: status
+    valueType: TEXT
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate resource 'status' in template 'dup' (document 1)")
+	assert.Equal(t, 0, loader.Count())
+}
+
+// TestValidateAssetData_UnitMismatch tests that a tag unit differing from the template is rejected only under strictUnits
+func TestValidateAssetData_UnitMismatch(t *testing.T) {
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `
+name: lenient-units
+resources:
+  - name: temperature
+    valueType: NUMBER
+    unit: "°C"
+`)))
+	require.NoError(t, loader.LoadFromFile(writeTemplate(t, `
+name: strict-units
+strictUnits: true
+resources:
+  - name: temperature
+    valueType: NUMBER
+    unit: "°C"
+`)))
+
+	value := 77.0
+	data := &AssetData{Values: []TagValue{{Name: "temperature", Number: &value, Unit: "°F"}}}
+
+	buf := captureLogs(t)
+	assert.NoError(t, loader.ValidateAssetData("lenient-units", data))
+	records := logRecords(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "tag unit does not match template", records[0]["msg"])
+	assert.Equal(t, "°C", records[0]["expected_unit"])
+
+	err := loader.ValidateAssetData("strict-units", data)
+	require.Error(t, err)
+	assert.Equal(t, "tag 'temperature' unit '°F' does not match template unit '°C'", err.Error())
+
+	// Matching and omitted units pass
+	data.Values[0].Unit = "°C"
+	assert.NoError(t, loader.ValidateAssetData("strict-units", data))
+	data.Values[0].Unit = ""
+	assert.NoError(t, loader.ValidateAssetData("strict-units", data))
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Log formats accepted by NewLogger
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// logger is the package-level structured logger
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	logger.Store(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+}
+
+// Logger returns the package-level logger
+func Logger() *slog.Logger {
+	return logger.Load()
+}
+
+// SetLogger replaces the package-level logger. Tests use it to capture
+// output in a buffer.
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// NewLogger creates a logger writing to w in the given format (text|json)
+// at the given level (debug|info|warn|error)
+func NewLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case LogFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case LogFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (expected text or json)", format)
+	}
+}
+
+// coreLog returns the logger for the data path
+func coreLog() *slog.Logger {
+	return Logger().With("component", "core")
+}
+
+// metaLog returns the logger for the metadata path
+func metaLog() *slog.Logger {
+	return Logger().With("component", "meta")
+}
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs swaps the package logger for a JSON logger writing to a buffer
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	l, err := NewLogger(&buf, LogFormatJSON, "debug")
+	require.NoError(t, err)
+
+	prev := Logger()
+	SetLogger(l)
+	t.Cleanup(func() { SetLogger(prev) })
+	return &buf
+}
+
+// logRecords decodes captured JSON log lines
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+// TestNewLogger tests format and level parsing
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	l, err := NewLogger(&buf, LogFormatJSON, "info")
+	require.NoError(t, err)
+	l.Debug("hidden")
+	l.Info("shown", "asset_id", "sensor-001")
+	records := logRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "shown", records[0]["msg"])
+	assert.Equal(t, "sensor-001", records[0]["asset_id"])
+
+	buf.Reset()
+	l, err = NewLogger(&buf, LogFormatText, "warn")
+	require.NoError(t, err)
+	l.Warn("text output", "subject", "a.b")
+	assert.Contains(t, buf.String(), "subject=a.b")
+
+	_, err = NewLogger(&buf, "xml", "info")
+	assert.Error(t, err)
+	_, err = NewLogger(&buf, LogFormatJSON, "loud")
+	assert.Error(t, err)
+}
+
+// TestHandleAssetData_StructuredLog tests that data handling emits structured fields
+func TestHandleAssetData_StructuredLog(t *testing.T) {
+	buf := captureLogs(t)
+	handler := NewDataHandler(nil, nil)
+
+	handler.HandleAssetData(&nats.Msg{
+		Subject: SubjectDataAsset,
+		Data:    []byte(`{"asset_id":"sensor-001","timestamp":1,"values":[{"name":"temperature","number":25.5,"quality":"good"}]}`),
+	})
+
+	records := logRecords(t, buf)
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "asset data received", records[0]["msg"])
+	assert.Equal(t, "core", records[0]["component"])
+	assert.Equal(t, "sensor-001", records[0]["asset_id"])
+	assert.Equal(t, float64(1), records[0]["tag_count"])
+
+	assert.Equal(t, "DEBUG", records[1]["level"])
+	assert.Equal(t, "temperature", records[1]["tag"])
+	assert.Equal(t, 25.5, records[1]["value"])
+}
@@ -2,85 +2,212 @@ package core
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
 )
 
-// NATS subjects
-const (
-	SubjectAssetCreate  = "platform.meta.asset.create"
-	SubjectAssetGet     = "platform.meta.asset.get"
-	SubjectAssetList    = "platform.meta.asset.list"
-	SubjectAssetDelete  = "platform.meta.asset.delete"
-	SubjectTemplateList = "platform.meta.template.list"
-
-	// Relation subjects
-	SubjectRelationCreate = "platform.meta.relation.create"
-	SubjectRelationGet    = "platform.meta.relation.get"
-	SubjectRelationList   = "platform.meta.relation.list"
-	SubjectRelationDelete = "platform.meta.relation.delete"
-)
-
 // MetaHandler handles metadata NATS messages
 type MetaHandler struct {
-	store  *Store
-	loader *TemplateLoader
+	store   *Store
+	loader  *TemplateLoader
+	metrics *Metrics
+	names   NamePolicy
+
+	maxPayload   int       // larger requests are refused unparsed; zero disables the limit
+	idNamespace  uuid.UUID // assets created with an external key get IDs derived in it
+	coerceValues bool      // validation requests coerce values like the data path
+
+	subjectPrefix string     // replaces DefaultSubjectPrefix in subscribed subjects
+	inFlight      *InFlight  // nil when requests are not tracked
+	nc            *nats.Conn // lifecycle events are published here; set by RegisterHandlers
+
+	// outcomes holds the Response.Success of each request being handled,
+	// keyed by *nats.Msg, for the timed middleware
+	outcomes sync.Map
 }
 
 // NewMetaHandler creates a new handler
 func NewMetaHandler(store *Store, loader *TemplateLoader) *MetaHandler {
 	return &MetaHandler{
-		store:  store,
-		loader: loader,
+		store:   store,
+		loader:  loader,
+		metrics: NewMetrics(),
+		names:   DefaultNamePolicy(),
+
+		maxPayload:  DefaultMaxPayload,
+		idNamespace: DefaultAssetIDNamespace,
+	}
+}
+
+// SetMetrics replaces the handler's metrics
+func (h *MetaHandler) SetMetrics(m *Metrics) {
+	h.metrics = m
+}
+
+// SetNamePolicy replaces the rules applied to asset and template names
+func (h *MetaHandler) SetNamePolicy(policy NamePolicy) {
+	h.names = policy
+}
+
+// SetMaxPayload refuses requests larger than n bytes with ErrCodeBadRequest
+// before they are parsed. Zero or less disables the limit.
+func (h *MetaHandler) SetMaxPayload(n int) {
+	if n < 0 {
+		n = 0
 	}
+	h.maxPayload = n
+}
+
+// SetCoerceValues makes validation requests coerce TEXT values before
+// validating them, matching DataHandler.SetCoerceValues
+func (h *MetaHandler) SetCoerceValues(enabled bool) {
+	h.coerceValues = enabled
 }
 
-// RegisterHandlers registers NATS subscriptions
+// SetAssetIDNamespace replaces the namespace asset IDs are derived in from
+// the external key of a create request
+func (h *MetaHandler) SetAssetIDNamespace(namespace uuid.UUID) {
+	h.idNamespace = namespace
+}
+
+// newAssetID returns the ID of an asset created with externalKey: derived
+// from the key when one is given, random otherwise
+func (h *MetaHandler) newAssetID(externalKey string) string {
+	if externalKey == "" {
+		return uuid.New().String()
+	}
+	return AssetIDForKey(h.idNamespace, externalKey)
+}
+
+// SetSubjectPrefix makes RegisterHandlers subscribe under prefix instead of
+// DefaultSubjectPrefix
+func (h *MetaHandler) SetSubjectPrefix(prefix string) {
+	h.subjectPrefix = prefix
+}
+
+// SetInFlight makes RegisterHandlers count every request in tracker
+func (h *MetaHandler) SetInFlight(tracker *InFlight) {
+	h.inFlight = tracker
+}
+
+// RegisterHandlers registers NATS subscriptions. Lifecycle events are
+// published on the same connection.
 func (h *MetaHandler) RegisterHandlers(nc *nats.Conn) error {
+	h.nc = nc
 	handlers := map[string]nats.MsgHandler{
-		SubjectAssetCreate:  h.handleAssetCreate,
-		SubjectAssetGet:     h.handleAssetGet,
-		SubjectAssetList:    h.handleAssetList,
-		SubjectAssetDelete:  h.handleAssetDelete,
-		SubjectTemplateList: h.handleTemplateList,
+		SubjectAssetCreate:    h.handleAssetCreate,
+		SubjectAssetGet:       h.handleAssetGet,
+		SubjectAssetList:      h.handleAssetList,
+		SubjectAssetDelete:    h.handleAssetDelete,
+		SubjectAssetUpdate:    h.handleAssetUpdate,
+		SubjectAssetRestore:   h.handleAssetRestore,
+		SubjectAssetClone:     h.handleAssetClone,
+		SubjectAssetBatch:     h.handleAssetBatchCreate,
+		SubjectAssetSearch:    h.handleAssetSearch,
+		SubjectAssetStale:     h.handleAssetStale,
+		SubjectTemplateList:   h.handleTemplateList,
+		SubjectTemplateReload: h.handleTemplateReload,
+		SubjectValidate:       h.handleValidate,
+		SubjectStats:          h.handleStats,
+		SubjectSchema:         h.handleSchema,
+		SubjectCheck:          h.handleCheck,
+
+		// Stored data queries
+		SubjectDataLatest:    h.handleDataLatest,
+		SubjectDataAggregate: h.handleDataAggregate,
 
 		// Relation handlers
-		SubjectRelationCreate: h.handleRelationCreate,
-		SubjectRelationGet:    h.handleRelationGet,
-		SubjectRelationList:   h.handleRelationList,
-		SubjectRelationDelete: h.handleRelationDelete,
+		SubjectRelationCreate:  h.handleRelationCreate,
+		SubjectRelationGet:     h.handleRelationGet,
+		SubjectRelationExists:  h.handleRelationExists,
+		SubjectRelationCount:   h.handleRelationCount,
+		SubjectRelationBetween: h.handleRelationBetween,
+		SubjectRelationList:    h.handleRelationList,
+		SubjectRelationDelete:  h.handleRelationDelete,
+		SubjectRelationUpdate:  h.handleRelationUpdate,
+		SubjectRelationTree:    h.handleRelationTree,
+		SubjectRelationBatch:   h.handleRelationBatchCreate,
+
+		SubjectRelationListAll: h.handleRelationListAll,
+
+		// Export handlers
+		SubjectExportJSONLD:   h.handleExportJSONLD,
+		SubjectSnapshotExport: h.handleSnapshotExport,
+		SubjectSnapshotImport: h.handleSnapshotImport,
 	}
 
 	for subject, handler := range handlers {
+		subject = PrefixSubject(h.subjectPrefix, subject)
+		handler = h.sized(handler)
+		handler = h.timed(subject, handler)
+		handler = traced(handler)
+		if h.inFlight != nil {
+			handler = h.inFlight.Track(handler)
+		}
 		if _, err := nc.Subscribe(subject, handler); err != nil {
 			return err
 		}
-		log.Printf("[Meta] Subscribed: %s", subject)
+		metaLog().Info("subscribed", "subject", subject)
 	}
 
 	return nil
 }
 
-// Response is a common response structure
-type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+// sized wraps a request handler to refuse requests over the payload limit
+func (h *MetaHandler) sized(handler nats.MsgHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		if h.maxPayload > 0 && len(msg.Data) > h.maxPayload {
+			metaLog().Warn("refused oversized request", "subject", msg.Subject, "size", len(msg.Data), "max_payload", h.maxPayload)
+			h.fail(msg, ErrCodeBadRequest, fmt.Sprintf("request of %d bytes exceeds the maximum of %d", len(msg.Data), h.maxPayload))
+			return
+		}
+		handler(msg)
+	}
+}
+
+// timed wraps a request handler to record its latency on subject, labelled
+// with the Success of the response it sent. A request left without a
+// response counts as an error.
+func (h *MetaHandler) timed(subject string, handler nats.MsgHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		start := time.Now()
+		h.outcomes.Store(msg, false)
+		handler(msg)
+		success, _ := h.outcomes.LoadAndDelete(msg)
+		h.metrics.ObserveMetaRequest(subject, success.(bool), time.Since(start))
+	}
+}
+
+// errorCode maps a store error to its response code. Specific errors such as
+// ErrRelationCycle map through the kind they belong to.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return ErrCodeNotFound
+	case errors.Is(err, ErrDuplicate):
+		return ErrCodeDuplicate
+	case errors.Is(err, ErrInvalid):
+		return ErrCodeValidation
+	default:
+		return ErrCodeInternal
+	}
 }
 
 // marshalResponse marshals response with fallback on error
 func (h *MetaHandler) marshalResponse(resp Response) []byte {
 	data, err := json.Marshal(resp)
 	if err != nil {
-		log.Printf("[Meta] Failed to marshal response: %v", err)
+		metaLog().Error("failed to marshal response", "error", err)
 		// Send fallback error response instead of corrupted data
-		errorResp := Response{Success: false, Error: "internal error: response marshal failed"}
+		errorResp := Response{Success: false, Error: "internal error: response marshal failed", ErrorCode: ErrCodeInternal}
 		if fallbackData, err2 := json.Marshal(errorResp); err2 != nil {
-			log.Printf("[Meta] Failed to marshal fallback error response: %v", err2)
-			data = []byte("{\"success\":false,\"error\":\"internal error\"}")
+			metaLog().Error("failed to marshal fallback error response", "error", err2)
+			data = []byte("{\"success\":false,\"error\":\"internal error\",\"error_code\":\"ERR_INTERNAL\"}")
 		} else {
 			data = fallbackData
 		}
@@ -89,91 +216,240 @@ func (h *MetaHandler) marshalResponse(resp Response) []byte {
 }
 
 func (h *MetaHandler) reply(msg *nats.Msg, resp Response) {
+	h.metrics.MetaRequests.Inc()
+	if _, ok := h.outcomes.Load(msg); ok {
+		h.outcomes.Store(msg, resp.Success)
+	}
 	data := h.marshalResponse(resp)
 	msg.Respond(data)
 }
 
-// CreateAssetRequest is a request to create an asset
-type CreateAssetRequest struct {
-	Name         string   `json:"name"`
-	TemplateName string   `json:"template_name,omitempty"`
-	Labels       []string `json:"labels,omitempty"`
+// fail replies with an error message and code
+func (h *MetaHandler) fail(msg *nats.Msg, code, message string) {
+	h.reply(msg, Response{Success: false, Error: message, ErrorCode: code})
+}
+
+// failErr replies with err, deriving the code from its kind
+func (h *MetaHandler) failErr(msg *nats.Msg, err error) {
+	h.fail(msg, errorCode(err), err.Error())
 }
 
 func (h *MetaHandler) handleAssetCreate(msg *nats.Msg) {
 	var req CreateAssetRequest
 	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		h.reply(msg, Response{Success: false, Error: "invalid request format"})
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if err := h.normalizeNames(&req); err != nil {
+		h.failErr(msg, err)
 		return
 	}
 
 	if req.Name == "" {
-		h.reply(msg, Response{Success: false, Error: "name is required"})
+		h.fail(msg, ErrCodeBadRequest, "name is required")
 		return
 	}
 
+	// An earlier create with the same key, even of a now deleted asset
+	id := h.newAssetID(req.ExternalKey)
+	if req.ExternalKey != "" {
+		if existing, _ := h.store.GetAssetIncludeDeleted(id); existing != nil {
+			h.fail(msg, ErrCodeDuplicate, "asset with this external_key already exists: "+id)
+			return
+		}
+	}
+
 	// check for duplicate
 	existing, _ := h.store.GetAssetByName(req.Name)
 	if existing != nil {
-		h.reply(msg, Response{Success: false, Error: "asset name already exists"})
+		h.fail(msg, ErrCodeDuplicate, "asset name already exists")
 		return
 	}
 
 	// check if template exists (optional)
 	if req.TemplateName != "" && !h.loader.Exists(req.TemplateName) {
-		h.reply(msg, Response{Success: false, Error: "template not found"})
+		h.fail(msg, ErrCodeNotFound, "template not found")
+		return
+	}
+
+	if err := validateLocation(req.Latitude, req.Longitude); err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	if err := validateExternalIDs(req.ExternalIDs); err != nil {
+		h.failErr(msg, err)
 		return
 	}
 
 	asset := &Asset{
-		ID:           uuid.New().String(),
-		Name:         req.Name,
-		TemplateName: req.TemplateName,
-		Labels:       req.Labels,
-		CreatedAt:    time.Now(),
+		ID:              id,
+		Name:            req.Name,
+		TemplateName:    req.TemplateName,
+		TemplateVersion: h.loader.GetVersion(req.TemplateName),
+		Labels:          req.Labels,
+		Attributes:      req.Attributes,
+		ExternalIDs:     req.ExternalIDs,
+		Latitude:        req.Latitude,
+		Longitude:       req.Longitude,
+		Altitude:        req.Altitude,
+		CreatedAt:       time.Now(),
 	}
 
 	if err := h.store.CreateAsset(asset); err != nil {
-		h.reply(msg, Response{Success: false, Error: err.Error()})
+		h.failErr(msg, err)
 		return
 	}
 
-	log.Printf("[Meta] Asset created: %s (%s)", asset.Name, asset.ID)
+	metaLog().Info("asset created", "asset_id", asset.ID, "name", asset.Name)
+	h.publishEvent(SubjectEventAssetCreated, assetEvent(asset))
 	h.reply(msg, Response{Success: true, Data: asset})
 }
 
-// GetAssetRequest is a request to get an asset
-type GetAssetRequest struct {
-	ID   string `json:"id,omitempty"`
-	Name string `json:"name,omitempty"`
+// normalizeNames trims and checks the asset and template names of req
+func (h *MetaHandler) normalizeNames(req *CreateAssetRequest) error {
+	name, err := h.names.normalize("name", req.Name)
+	if err != nil {
+		return err
+	}
+	templateName, err := h.names.normalize("template_name", req.TemplateName)
+	if err != nil {
+		return err
+	}
+	req.Name, req.TemplateName = name, templateName
+	return nil
+}
+
+func (h *MetaHandler) handleAssetBatchCreate(msg *nats.Msg) {
+	var req BatchCreateAssetsRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if len(req.Assets) == 0 {
+		h.fail(msg, ErrCodeBadRequest, "assets is required")
+		return
+	}
+
+	// Run the single-create checks for every entry before touching the store
+	seen := make(map[string]bool, len(req.Assets))
+	seenIDs := make(map[string]bool, len(req.Assets))
+	assets := make([]*Asset, 0, len(req.Assets))
+	for i, item := range req.Assets {
+		var reason, code string
+		normErr := h.normalizeNames(&item)
+		id := h.newAssetID(item.ExternalKey)
+		switch {
+		case normErr != nil:
+			reason, code = normErr.Error(), ErrCodeValidation
+		case item.Name == "":
+			reason, code = "name is required", ErrCodeBadRequest
+		case seen[item.Name]:
+			reason, code = "duplicate name in batch", ErrCodeDuplicate
+		case seenIDs[id]:
+			reason, code = "duplicate external_key in batch", ErrCodeDuplicate
+		case item.TemplateName != "" && !h.loader.Exists(item.TemplateName):
+			reason, code = "template not found", ErrCodeNotFound
+		default:
+			if err := validateLocation(item.Latitude, item.Longitude); err != nil {
+				reason, code = err.Error(), ErrCodeValidation
+			} else if err := validateExternalIDs(item.ExternalIDs); err != nil {
+				reason, code = err.Error(), ErrCodeValidation
+			} else if existing, _ := h.store.GetAssetByName(item.Name); existing != nil {
+				reason, code = "asset name already exists", ErrCodeDuplicate
+			} else if item.ExternalKey != "" {
+				if existing, _ := h.store.GetAssetIncludeDeleted(id); existing != nil {
+					reason, code = "asset with this external_key already exists: "+id, ErrCodeDuplicate
+				}
+			}
+		}
+		if reason != "" {
+			h.reply(msg, Response{
+				Success:   false,
+				Data:      BatchItemError{Index: i, Name: item.Name, Error: reason},
+				Error:     fmt.Sprintf("asset %d (%s): %s", i, item.Name, reason),
+				ErrorCode: code,
+			})
+			return
+		}
+		seen[item.Name] = true
+		seenIDs[id] = true
+
+		assets = append(assets, &Asset{
+			ID:              id,
+			Name:            item.Name,
+			TemplateName:    item.TemplateName,
+			TemplateVersion: h.loader.GetVersion(item.TemplateName),
+			Labels:          item.Labels,
+			Attributes:      item.Attributes,
+			ExternalIDs:     item.ExternalIDs,
+			Latitude:        item.Latitude,
+			Longitude:       item.Longitude,
+			Altitude:        item.Altitude,
+			CreatedAt:       time.Now(),
+		})
+	}
+
+	if err := h.store.CreateAssetsBatch(assets); err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	metaLog().Info("batch created assets", "count", len(assets))
+	for _, asset := range assets {
+		h.publishEvent(SubjectEventAssetCreated, assetEvent(asset))
+	}
+	h.reply(msg, Response{Success: true, Data: BatchCreateAssetsResponse{
+		Created: len(assets),
+		Assets:  assets,
+	}})
 }
 
 func (h *MetaHandler) handleAssetGet(msg *nats.Msg) {
 	var req GetAssetRequest
 	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		h.reply(msg, Response{Success: false, Error: "invalid request format"})
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
 		return
 	}
 
 	var asset *Asset
 	var err error
 
-	if req.ID != "" {
+	if req.ID != "" && req.IncludeDeleted {
+		asset, err = h.store.GetAssetIncludeDeleted(req.ID)
+	} else if req.ID != "" {
 		asset, err = h.store.GetAsset(req.ID)
 	} else if req.Name != "" {
 		asset, err = h.store.GetAssetByName(req.Name)
+	} else if req.ExternalID != "" && req.Scheme != "" {
+		asset, err = h.store.GetAssetByExternalID(req.Scheme, req.ExternalID)
+	} else if req.ExternalID != "" {
+		h.fail(msg, ErrCodeBadRequest, "scheme is required with external_id")
+		return
 	} else {
-		h.reply(msg, Response{Success: false, Error: "id or name is required"})
+		h.fail(msg, ErrCodeBadRequest, "id, name or external_id is required")
 		return
 	}
 
 	if err != nil {
-		h.reply(msg, Response{Success: false, Error: err.Error()})
+		h.failErr(msg, err)
 		return
 	}
 
 	if asset == nil {
-		h.reply(msg, Response{Success: false, Error: "asset not found"})
+		h.fail(msg, ErrCodeNotFound, "asset not found")
+		return
+	}
+
+	if req.IncludeDegree {
+		count, err := h.relationCount(asset.ID)
+		if err != nil {
+			h.failErr(msg, err)
+			return
+		}
+		h.reply(msg, Response{Success: true, Data: AssetWithDegree{Asset: asset, Degree: count}})
 		return
 	}
 
@@ -181,80 +457,537 @@ func (h *MetaHandler) handleAssetGet(msg *nats.Msg) {
 }
 
 func (h *MetaHandler) handleAssetList(msg *nats.Msg) {
-	assets, err := h.store.ListAssets()
+	// An empty request keeps the legacy behavior of returning every asset
+	if len(msg.Data) == 0 {
+		assets, err := h.store.ListAssets()
+		if err != nil {
+			h.failErr(msg, err)
+			return
+		}
+
+		h.reply(msg, Response{Success: true, Data: assets})
+		return
+	}
+
+	var req ListAssetsRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = DefaultListLimit
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	opts := ListOptions{
+		Limit:        req.Limit,
+		Offset:       req.Offset,
+		TemplateName: req.TemplateName,
+		Label:        req.Label,
+
+		IncludeDeleted: req.IncludeDeleted,
+		OrderBy:        req.OrderBy,
+		OrderDir:       req.OrderDir,
+	}
+	if _, err := assetOrderClause(req.OrderBy, req.OrderDir); err != nil {
+		h.fail(msg, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if req.CreatedAfter != nil {
+		opts.CreatedAfter = *req.CreatedAfter
+	}
+	if req.CreatedBefore != nil {
+		opts.CreatedBefore = *req.CreatedBefore
+	}
+	if !opts.CreatedAfter.IsZero() && !opts.CreatedBefore.IsZero() && !opts.CreatedAfter.Before(opts.CreatedBefore) {
+		h.fail(msg, ErrCodeValidation, "created_after must be before created_before")
+		return
+	}
+
+	assets, total, err := h.store.ListAssetsFiltered(opts)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+	if assets == nil {
+		assets = []*Asset{}
+	}
+
+	h.reply(msg, Response{Success: true, Data: ListAssetsResponse{
+		Assets: assets,
+		Total:  total,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}})
+}
+
+// Label match modes for asset search
+const (
+	LabelMatchAll = "all"
+	LabelMatchAny = "any"
+)
+
+func (h *MetaHandler) handleAssetSearch(msg *nats.Msg) {
+	var req SearchAssetsRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if len(req.Labels) == 0 && req.LabelKey == "" && req.NamePrefix == "" {
+		h.fail(msg, ErrCodeBadRequest, "labels, label_key or name_prefix is required")
+		return
+	}
+	if req.LabelValue != "" && req.LabelKey == "" {
+		h.fail(msg, ErrCodeBadRequest, "label_value requires label_key")
+		return
+	}
+
+	var matchAll bool
+	switch req.Match {
+	case "", LabelMatchAll:
+		matchAll = true
+	case LabelMatchAny:
+		matchAll = false
+	default:
+		h.fail(msg, ErrCodeValidation, `match must be "all" or "any"`)
+		return
+	}
+
+	assets, err := h.store.SearchAssets(AssetSearch{
+		Labels:     req.Labels,
+		MatchAll:   matchAll,
+		LabelKey:   req.LabelKey,
+		LabelValue: req.LabelValue,
+		NamePrefix: req.NamePrefix,
+		Limit:      req.Limit,
+	})
 	if err != nil {
-		h.reply(msg, Response{Success: false, Error: err.Error()})
+		h.failErr(msg, err)
 		return
 	}
+	if assets == nil {
+		assets = []*Asset{}
+	}
 
 	h.reply(msg, Response{Success: true, Data: assets})
 }
 
-// DeleteAssetRequest is a request to delete an asset
-type DeleteAssetRequest struct {
-	ID string `json:"id"`
+func (h *MetaHandler) handleAssetStale(msg *nats.Msg) {
+	var req StaleAssetsRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.Threshold == "" {
+		h.fail(msg, ErrCodeBadRequest, "threshold is required")
+		return
+	}
+	threshold, err := time.ParseDuration(req.Threshold)
+	if err != nil {
+		h.fail(msg, ErrCodeValidation, "invalid threshold: "+err.Error())
+		return
+	}
+
+	assets, err := h.store.ListStaleAssets(threshold)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+	if assets == nil {
+		assets = []*Asset{}
+	}
+
+	h.reply(msg, Response{Success: true, Data: assets})
 }
 
 func (h *MetaHandler) handleAssetDelete(msg *nats.Msg) {
 	var req DeleteAssetRequest
 	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		h.reply(msg, Response{Success: false, Error: "invalid request format"})
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
 		return
 	}
 
 	if req.ID == "" {
-		h.reply(msg, Response{Success: false, Error: "id is required"})
+		h.fail(msg, ErrCodeBadRequest, "id is required")
 		return
 	}
 
-	if err := h.store.DeleteAsset(req.ID); err != nil {
-		h.reply(msg, Response{Success: false, Error: err.Error()})
+	var err error
+	if req.Hard {
+		err = h.store.DeleteAsset(req.ID)
+	} else {
+		err = h.store.SoftDeleteAsset(req.ID)
+	}
+	if err != nil {
+		h.failErr(msg, err)
 		return
 	}
 
-	log.Printf("[Meta] Asset deleted: %s", req.ID)
+	metaLog().Info("asset deleted", "asset_id", req.ID, "hard", req.Hard)
+	h.publishEvent(SubjectEventAssetDeleted, AssetEvent{AssetID: req.ID, Hard: req.Hard, Timestamp: time.Now().UnixMilli()})
 	h.reply(msg, Response{Success: true})
 }
 
+func (h *MetaHandler) handleAssetRestore(msg *nats.Msg) {
+	var req RestoreAssetRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.ID == "" {
+		h.fail(msg, ErrCodeBadRequest, "id is required")
+		return
+	}
+
+	if err := h.store.RestoreAsset(req.ID); err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	asset, err := h.store.GetAsset(req.ID)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	metaLog().Info("asset restored", "asset_id", req.ID)
+	h.publishEvent(SubjectEventAssetUpdated, assetEvent(asset))
+	h.reply(msg, Response{Success: true, Data: asset})
+}
+
+func (h *MetaHandler) handleAssetClone(msg *nats.Msg) {
+	var req CloneAssetRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.ID == "" {
+		h.fail(msg, ErrCodeBadRequest, "id is required")
+		return
+	}
+	name, err := h.names.normalize("name", req.Name)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+	if name == "" {
+		h.fail(msg, ErrCodeBadRequest, "name is required")
+		return
+	}
+
+	clone := &Asset{ID: h.newAssetID(""), Name: name, CreatedAt: time.Now()}
+	relations, err := h.store.CloneAsset(req.ID, clone, req.CopyRelations)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	metaLog().Info("asset cloned", "asset_id", clone.ID, "name", clone.Name, "source_id", req.ID, "relations", len(relations))
+	h.publishEvent(SubjectEventAssetCreated, assetEvent(clone))
+	for _, relation := range relations {
+		h.publishEvent(SubjectEventRelationCreated, relationEvent(relation))
+	}
+	h.reply(msg, Response{Success: true, Data: clone})
+}
+
+func (h *MetaHandler) handleAssetUpdate(msg *nats.Msg) {
+	var req UpdateAssetRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.ID == "" {
+		h.fail(msg, ErrCodeBadRequest, "id is required")
+		return
+	}
+
+	asset := &Asset{ID: req.ID}
+	var fields []string
+
+	if req.Name != nil {
+		name, err := h.names.normalize("name", *req.Name)
+		if err != nil {
+			h.failErr(msg, err)
+			return
+		}
+		if name == "" {
+			h.fail(msg, ErrCodeValidation, "name must not be empty")
+			return
+		}
+		asset.Name = name
+		fields = append(fields, AssetFieldName)
+	}
+	if req.TemplateName != nil {
+		name, err := h.names.normalize("template_name", *req.TemplateName)
+		if err != nil {
+			h.failErr(msg, err)
+			return
+		}
+		req.TemplateName = &name
+		if *req.TemplateName != "" && !h.loader.Exists(*req.TemplateName) {
+			h.fail(msg, ErrCodeNotFound, "template not found")
+			return
+		}
+		asset.TemplateName = *req.TemplateName
+		asset.TemplateVersion = h.loader.GetVersion(*req.TemplateName)
+		fields = append(fields, AssetFieldTemplateName)
+	}
+	if req.Latitude != nil || req.Longitude != nil || req.Altitude != nil {
+		if req.Latitude == nil || req.Longitude == nil {
+			h.fail(msg, ErrCodeValidation, "latitude and longitude must be set together")
+			return
+		}
+		if err := validateLocation(req.Latitude, req.Longitude); err != nil {
+			h.failErr(msg, err)
+			return
+		}
+		asset.Latitude, asset.Longitude, asset.Altitude = req.Latitude, req.Longitude, req.Altitude
+		fields = append(fields, AssetFieldLocation)
+	}
+	if req.Labels != nil {
+		asset.Labels = *req.Labels
+		fields = append(fields, AssetFieldLabels)
+	}
+	if req.Attributes != nil {
+		asset.Attributes = *req.Attributes
+		fields = append(fields, AssetFieldAttributes)
+	}
+	if req.ExternalIDs != nil {
+		asset.ExternalIDs = *req.ExternalIDs
+		fields = append(fields, AssetFieldExternalIDs)
+	}
+
+	if len(fields) == 0 {
+		h.fail(msg, ErrCodeValidation, "no fields to update")
+		return
+	}
+
+	if err := h.store.UpdateAsset(asset, fields); err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	updated, err := h.store.GetAsset(req.ID)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	metaLog().Info("asset updated", "asset_id", req.ID, "fields", fields)
+	h.publishEvent(SubjectEventAssetUpdated, assetEvent(updated, fields...))
+	h.reply(msg, Response{Success: true, Data: updated})
+}
+
 func (h *MetaHandler) handleTemplateList(msg *nats.Msg) {
 	templates := h.loader.List()
 	h.reply(msg, Response{Success: true, Data: templates})
 }
 
-// ==================== AssetRelation Handlers ====================
+// handleTemplateReload reloads the template directory. Files that fail to
+// load are listed in the reply rather than failing the request.
+func (h *MetaHandler) handleTemplateReload(msg *nats.Msg) {
+	result, err := h.loader.Reload()
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+	if len(result.Errors) > 0 {
+		metaLog().Warn("templates reloaded with errors", "count", result.Count, "errors", result.Errors)
+	} else {
+		metaLog().Info("templates reloaded", "count", result.Count)
+	}
+	h.reply(msg, Response{Success: true, Data: result})
+}
 
-// CreateRelationRequest is a request to create a relation
-type CreateRelationRequest struct {
-	SourceAssetID string            `json:"source_asset_id"`
-	TargetAssetID string            `json:"target_asset_id"`
-	RelationType  RelationType      `json:"relation_type"`
-	Metadata      map[string]string `json:"metadata,omitempty"`
+// handleValidate runs the data path's template checks without storing or
+// publishing anything. The template version is checked for the stored
+// asset named by the data when it uses the template; any other sender
+// counts as a new asset. Values are coerced as on the data path when
+// SetCoerceValues is enabled. Unlike the data path, an unknown template is
+// an error rather than a pass.
+func (h *MetaHandler) handleValidate(msg *nats.Msg) {
+	var req ValidateDataRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.TemplateName == "" || req.Data == nil {
+		h.fail(msg, ErrCodeBadRequest, "template_name and data are required")
+		return
+	}
+
+	if !h.loader.Exists(req.TemplateName) {
+		h.fail(msg, ErrCodeNotFound, "template not found")
+		return
+	}
+
+	asset := &Asset{ID: req.Data.AssetID, TemplateName: req.TemplateName, TemplateVersion: h.loader.GetVersion(req.TemplateName)}
+	if req.Data.AssetID != "" {
+		stored, err := h.store.GetAsset(req.Data.AssetID)
+		if err != nil {
+			h.failErr(msg, err)
+			return
+		}
+		if stored != nil && stored.TemplateName == req.TemplateName {
+			asset = stored
+		}
+	}
+	if _, err := h.loader.checkAssetData(asset, req.Data, h.coerceValues); err != nil {
+		h.fail(msg, ErrCodeValidation, err.Error())
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: ValidateDataResponse{Valid: true}})
+}
+
+func (h *MetaHandler) handleStats(msg *nats.Msg) {
+	stats, err := h.store.GetStats()
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: stats})
+}
+
+func (h *MetaHandler) handleCheck(msg *nats.Msg) {
+	req := CheckRequest{}
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			h.fail(msg, ErrCodeBadRequest, "invalid request format")
+			return
+		}
+	}
+	if req.Limit < 0 {
+		h.fail(msg, ErrCodeValidation, "limit must not be negative")
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = DefaultIntegrityIDLimit
+	}
+
+	report, err := h.store.CheckIntegrity(h.loader.Exists, req.Limit)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: report})
 }
 
+func (h *MetaHandler) handleSchema(msg *nats.Msg) {
+	var req SchemaRequest
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			h.fail(msg, ErrCodeBadRequest, "invalid request format")
+			return
+		}
+	}
+
+	if req.Type != "" {
+		schema, err := GenerateSchema(req.Type)
+		if err != nil {
+			h.failErr(msg, err)
+			return
+		}
+		h.reply(msg, Response{Success: true, Data: schema})
+		return
+	}
+
+	schemas := make(map[string]*JSONSchema)
+	for _, name := range SchemaTypeNames() {
+		schema, err := GenerateSchema(name)
+		if err != nil {
+			h.failErr(msg, err)
+			return
+		}
+		schemas[name] = schema
+	}
+	h.reply(msg, Response{Success: true, Data: schemas})
+}
+
+func (h *MetaHandler) handleDataLatest(msg *nats.Msg) {
+	var req LatestDataRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.AssetID == "" {
+		h.fail(msg, ErrCodeBadRequest, "asset_id is required")
+		return
+	}
+
+	data, err := h.store.GetLatestData(req.AssetID)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: data})
+}
+
+func (h *MetaHandler) handleDataAggregate(msg *nats.Msg) {
+	var req AggregateDataRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.AssetID == "" || req.Tag == "" || req.Bucket == "" {
+		h.fail(msg, ErrCodeBadRequest, "asset_id, tag and bucket are required")
+		return
+	}
+	bucket, err := time.ParseDuration(req.Bucket)
+	if err != nil {
+		h.fail(msg, ErrCodeValidation, "invalid bucket: "+err.Error())
+		return
+	}
+
+	buckets, err := h.store.AggregateData(req.AssetID, req.Tag, req.From, req.To, bucket)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: buckets})
+}
+
+// ==================== AssetRelation Handlers ====================
+
 func (h *MetaHandler) handleRelationCreate(msg *nats.Msg) {
 	var req CreateRelationRequest
 	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		h.reply(msg, Response{Success: false, Error: "invalid request format"})
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
 		return
 	}
 
 	// Validate required fields
 	if req.SourceAssetID == "" {
-		h.reply(msg, Response{Success: false, Error: "source_asset_id is required"})
+		h.fail(msg, ErrCodeBadRequest, "source_asset_id is required")
 		return
 	}
 	if req.TargetAssetID == "" {
-		h.reply(msg, Response{Success: false, Error: "target_asset_id is required"})
+		h.fail(msg, ErrCodeBadRequest, "target_asset_id is required")
 		return
 	}
 	if req.RelationType == "" {
-		h.reply(msg, Response{Success: false, Error: "relation_type is required"})
+		h.fail(msg, ErrCodeBadRequest, "relation_type is required")
 		return
 	}
 
 	// Validate relation type
 	if !IsValidRelationType(req.RelationType) {
-		h.reply(msg, Response{Success: false, Error: "invalid relation_type"})
+		h.fail(msg, ErrCodeValidation, "invalid relation_type")
 		return
 	}
 
@@ -266,66 +999,225 @@ func (h *MetaHandler) handleRelationCreate(msg *nats.Msg) {
 		RelationType:  req.RelationType,
 		CreatedAt:     time.Now(),
 		Metadata:      req.Metadata,
+		Attributes:    req.Attributes,
+		Weight:        req.Weight,
 	}
 
 	if err := h.store.CreateRelation(relation); err != nil {
-		h.reply(msg, Response{Success: false, Error: err.Error()})
+		h.failErr(msg, err)
 		return
 	}
 
-	log.Printf("[Meta] Relation created: %s (%s -> %s, type: %s)",
-		relation.ID, relation.SourceAssetID, relation.TargetAssetID, relation.RelationType)
+	metaLog().Info("relation created",
+		"relation_id", relation.ID,
+		"source_asset_id", relation.SourceAssetID,
+		"target_asset_id", relation.TargetAssetID,
+		"relation_type", relation.RelationType,
+	)
+	h.publishEvent(SubjectEventRelationCreated, relationEvent(relation))
 	h.reply(msg, Response{Success: true, Data: relation})
 }
 
-// GetRelationRequest is a request to get a relation
-type GetRelationRequest struct {
-	ID string `json:"id"`
+// handleRelationBatchCreate creates every relation or none. A rejection lists
+// each offending entry as a BatchItemError in Data.
+func (h *MetaHandler) handleRelationBatchCreate(msg *nats.Msg) {
+	var req BatchCreateRelationsRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if len(req.Relations) == 0 {
+		h.fail(msg, ErrCodeBadRequest, "relations is required")
+		return
+	}
+
+	var missing []BatchItemError
+	relations := make([]*AssetRelation, 0, len(req.Relations))
+	for i, item := range req.Relations {
+		var reason string
+		switch {
+		case item.SourceAssetID == "":
+			reason = "source_asset_id is required"
+		case item.TargetAssetID == "":
+			reason = "target_asset_id is required"
+		case item.RelationType == "":
+			reason = "relation_type is required"
+		}
+		if reason != "" {
+			missing = append(missing, BatchItemError{Index: i, Error: reason})
+			continue
+		}
+
+		relations = append(relations, &AssetRelation{
+			ID:            uuid.New().String(),
+			SourceAssetID: item.SourceAssetID,
+			TargetAssetID: item.TargetAssetID,
+			RelationType:  item.RelationType,
+			CreatedAt:     time.Now(),
+			Metadata:      item.Metadata,
+			Attributes:    item.Attributes,
+			Weight:        item.Weight,
+		})
+	}
+
+	if len(missing) > 0 {
+		batchErr := &RelationBatchError{Items: missing}
+		h.reply(msg, Response{Success: false, Data: batchErr.Items, Error: batchErr.Error(), ErrorCode: ErrCodeBadRequest})
+		return
+	}
+
+	if err := h.store.CreateRelationsBatch(relations); err != nil {
+		var batchErr *RelationBatchError
+		if errors.As(err, &batchErr) {
+			h.reply(msg, Response{Success: false, Data: batchErr.Items, Error: err.Error(), ErrorCode: errorCode(err)})
+			return
+		}
+		h.failErr(msg, err)
+		return
+	}
+
+	metaLog().Info("batch created relations", "count", len(relations))
+	for _, relation := range relations {
+		h.publishEvent(SubjectEventRelationCreated, relationEvent(relation))
+	}
+	h.reply(msg, Response{Success: true, Data: BatchCreateRelationsResponse{
+		Created:   len(relations),
+		Relations: relations,
+	}})
 }
 
 func (h *MetaHandler) handleRelationGet(msg *nats.Msg) {
 	var req GetRelationRequest
 	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		h.reply(msg, Response{Success: false, Error: "invalid request format"})
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
 		return
 	}
 
 	if req.ID == "" {
-		h.reply(msg, Response{Success: false, Error: "id is required"})
+		h.fail(msg, ErrCodeBadRequest, "id is required")
 		return
 	}
 
 	relation, err := h.store.GetRelation(req.ID)
 	if err != nil {
-		h.reply(msg, Response{Success: false, Error: err.Error()})
+		h.failErr(msg, err)
 		return
 	}
 
 	if relation == nil {
-		h.reply(msg, Response{Success: false, Error: "relation not found"})
+		h.fail(msg, ErrCodeNotFound, "relation not found")
 		return
 	}
 
 	h.reply(msg, Response{Success: true, Data: relation})
 }
 
-// ListRelationsRequest is a request to list relations
-type ListRelationsRequest struct {
-	AssetID      string       `json:"asset_id,omitempty"`
-	RelationType RelationType `json:"relation_type,omitempty"`
-	Direction    string       `json:"direction,omitempty"` // "outgoing", "incoming", "both"
+func (h *MetaHandler) handleRelationExists(msg *nats.Msg) {
+	var req RelationExistsRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.SourceAssetID == "" || req.TargetAssetID == "" || req.RelationType == "" {
+		h.fail(msg, ErrCodeBadRequest, "source_asset_id, target_asset_id and relation_type are required")
+		return
+	}
+	if !IsValidRelationType(req.RelationType) {
+		h.fail(msg, ErrCodeValidation, "invalid relation_type")
+		return
+	}
+
+	id, err := h.store.FindRelation(req.SourceAssetID, req.TargetAssetID, req.RelationType)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: RelationExistsResponse{Exists: id != "", ID: id}})
+}
+
+// handleRelationBetween lists the relations between two assets in either
+// direction, as seen from asset_id. Unknown assets have none.
+func (h *MetaHandler) handleRelationBetween(msg *nats.Msg) {
+	var req RelationsBetweenRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.AssetID == "" || req.OtherAssetID == "" {
+		h.fail(msg, ErrCodeBadRequest, "asset_id and other_asset_id are required")
+		return
+	}
+
+	relations, err := h.store.GetRelationsBetween(req.AssetID, req.OtherAssetID)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: relations})
+}
+
+// handleRelationCount replies with the relation counts of an asset, so graph
+// views can size nodes without listing every relation
+func (h *MetaHandler) handleRelationCount(msg *nats.Msg) {
+	var req RelationCountRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.AssetID == "" {
+		h.fail(msg, ErrCodeBadRequest, "asset_id is required")
+		return
+	}
+
+	asset, err := h.store.GetAsset(req.AssetID)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+	if asset == nil {
+		h.fail(msg, ErrCodeNotFound, "asset not found")
+		return
+	}
+
+	count, err := h.relationCount(asset.ID)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+	h.reply(msg, Response{Success: true, Data: count})
+}
+
+// relationCount returns the RelationCount of assetID
+func (h *MetaHandler) relationCount(assetID string) (RelationCount, error) {
+	incoming, outgoing, err := h.store.CountRelations(assetID)
+	if err != nil {
+		return RelationCount{}, err
+	}
+	return RelationCount{Incoming: incoming, Outgoing: outgoing, Total: incoming + outgoing}, nil
 }
 
 func (h *MetaHandler) handleRelationList(msg *nats.Msg) {
 	var req ListRelationsRequest
 	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		h.reply(msg, Response{Success: false, Error: "invalid request format"})
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
 		return
 	}
 
 	var relations []*AssetRelation
 	var err error
 
+	query := RelationQuery{
+		RelationType:  req.RelationType,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+	}
+
 	// If asset_id is provided, filter by direction
 	if req.AssetID != "" {
 		direction := req.Direction
@@ -335,71 +1227,212 @@ func (h *MetaHandler) handleRelationList(msg *nats.Msg) {
 
 		switch direction {
 		case "outgoing":
-			relations, err = h.store.GetRelationsBySourceAsset(req.AssetID)
+			query.SourceAssetID = req.AssetID
+			relations, err = h.store.QueryRelations(query)
 		case "incoming":
-			relations, err = h.store.GetRelationsByTargetAsset(req.AssetID)
+			query.TargetAssetID = req.AssetID
+			relations, err = h.store.QueryRelations(query)
 		case "both":
 			// Get both outgoing and incoming
-			outgoing, err1 := h.store.GetRelationsBySourceAsset(req.AssetID)
-			incoming, err2 := h.store.GetRelationsByTargetAsset(req.AssetID)
+			outQuery, inQuery := query, query
+			outQuery.SourceAssetID = req.AssetID
+			inQuery.TargetAssetID = req.AssetID
+			outgoing, err1 := h.store.QueryRelations(outQuery)
+			incoming, err2 := h.store.QueryRelations(inQuery)
 			if err1 != nil {
 				err = err1
 			} else if err2 != nil {
 				err = err2
 			} else {
-				relations = append(outgoing, incoming...)
+				// A symmetric edge stored either way round is listed once
+				relations = DedupeSymmetricRelations(append(outgoing, incoming...))
 			}
 		default:
-			h.reply(msg, Response{Success: false, Error: "invalid direction (use: outgoing, incoming, both)"})
+			h.fail(msg, ErrCodeValidation, "invalid direction (use: outgoing, incoming, both)")
 			return
 		}
-
-		if err != nil {
-			h.reply(msg, Response{Success: false, Error: err.Error()})
+	} else if req.RelationType != "" {
+		// Without asset_id, list every relation of the requested type
+		if !IsValidRelationType(req.RelationType) {
+			h.fail(msg, ErrCodeValidation, "invalid relation_type")
 			return
 		}
-
-		// Filter by relation type if provided
-		if req.RelationType != "" {
-			filtered := []*AssetRelation{}
-			for _, rel := range relations {
-				if rel.RelationType == req.RelationType {
-					filtered = append(filtered, rel)
-				}
-			}
-			relations = filtered
-		}
+		relations, err = h.store.QueryRelations(query)
 	} else {
-		// No asset_id provided - this could list all relations, but we'll return error for now
-		h.reply(msg, Response{Success: false, Error: "asset_id is required"})
+		h.fail(msg, ErrCodeBadRequest, "asset_id or relation_type is required")
 		return
 	}
 
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+	if relations == nil {
+		relations = []*AssetRelation{}
+	}
+
 	h.reply(msg, Response{Success: true, Data: relations})
 }
 
-// DeleteRelationRequest is a request to delete a relation
-type DeleteRelationRequest struct {
-	ID string `json:"id"`
+func (h *MetaHandler) handleRelationListAll(msg *nats.Msg) {
+	var req ListAllRelationsRequest
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			h.fail(msg, ErrCodeBadRequest, "invalid request format")
+			return
+		}
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = DefaultListLimit
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	relations, total, err := h.store.ListRelations(req.Limit, req.Offset)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+	if relations == nil {
+		relations = []*AssetRelation{}
+	}
+
+	h.reply(msg, Response{Success: true, Data: ListAllRelationsResponse{
+		Relations: relations,
+		Total:     total,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+	}})
 }
 
 func (h *MetaHandler) handleRelationDelete(msg *nats.Msg) {
 	var req DeleteRelationRequest
 	if err := json.Unmarshal(msg.Data, &req); err != nil {
-		h.reply(msg, Response{Success: false, Error: "invalid request format"})
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
 		return
 	}
 
 	if req.ID == "" {
-		h.reply(msg, Response{Success: false, Error: "id is required"})
+		h.fail(msg, ErrCodeBadRequest, "id is required")
 		return
 	}
 
 	if err := h.store.DeleteRelation(req.ID); err != nil {
-		h.reply(msg, Response{Success: false, Error: err.Error()})
+		h.failErr(msg, err)
+		return
+	}
+
+	metaLog().Info("relation deleted", "relation_id", req.ID)
+	h.publishEvent(SubjectEventRelationDeleted, RelationEvent{RelationID: req.ID, Timestamp: time.Now().UnixMilli()})
+	h.reply(msg, Response{Success: true})
+}
+
+func (h *MetaHandler) handleRelationUpdate(msg *nats.Msg) {
+	var req UpdateRelationRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.ID == "" {
+		h.fail(msg, ErrCodeBadRequest, "id is required")
+		return
+	}
+
+	relation, err := h.store.UpdateRelation(req.ID, req.Metadata, req.Attributes, req.Weight, req.ClearWeight)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	metaLog().Info("relation updated", "relation_id", req.ID)
+	h.publishEvent(SubjectEventRelationUpdated, relationEvent(relation))
+	h.reply(msg, Response{Success: true, Data: relation})
+}
+
+func (h *MetaHandler) handleRelationTree(msg *nats.Msg) {
+	var req RelationTreeRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if req.AssetID == "" {
+		h.fail(msg, ErrCodeBadRequest, "asset_id is required")
+		return
+	}
+	if req.RelationType == "" {
+		req.RelationType = RelationPartOf
+	}
+	if !IsValidRelationType(req.RelationType) {
+		h.fail(msg, ErrCodeValidation, "invalid relation_type")
+		return
+	}
+	if req.Direction == "" {
+		req.Direction = TreeDescendants
+	}
+	if req.Direction != TreeDescendants && req.Direction != TreeAncestors {
+		h.fail(msg, ErrCodeValidation, "invalid direction (use: descendants, ancestors)")
+		return
+	}
+	if req.Depth < 0 {
+		h.fail(msg, ErrCodeValidation, "depth must not be negative")
+		return
+	}
+
+	assets, err := h.store.TraverseRelations(req.AssetID, req.RelationType, req.Direction, req.Depth)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: assets})
+}
+
+// ==================== Export Handlers ====================
+
+func (h *MetaHandler) handleExportJSONLD(msg *nats.Msg) {
+	doc, err := ExportAssetGraph(h.store)
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: json.RawMessage(doc)})
+}
+
+func (h *MetaHandler) handleSnapshotExport(msg *nats.Msg) {
+	snapshot, err := h.store.ExportSnapshot()
+	if err != nil {
+		h.failErr(msg, err)
+		return
+	}
+
+	h.reply(msg, Response{Success: true, Data: json.RawMessage(snapshot)})
+}
+
+func (h *MetaHandler) handleSnapshotImport(msg *nats.Msg) {
+	var req ImportSnapshotRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.fail(msg, ErrCodeBadRequest, "invalid request format")
+		return
+	}
+
+	if len(req.Snapshot) == 0 {
+		h.fail(msg, ErrCodeBadRequest, "snapshot is required")
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = SnapshotMerge
+	}
+
+	if err := h.store.ImportSnapshot(req.Snapshot, req.Mode); err != nil {
+		h.failErr(msg, err)
 		return
 	}
 
-	log.Printf("[Meta] Relation deleted: %s", req.ID)
+	metaLog().Info("snapshot imported", "mode", req.Mode)
 	h.reply(msg, Response{Success: true})
 }
@@ -3,9 +3,13 @@ package core
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -107,6 +111,88 @@ func TestMetaHandler_GetAsset_NotFound(t *testing.T) {
 	assert.Nil(t, retrieved)
 }
 
+// TestHandleAssetDelete_SoftAndHard tests that NATS deletes are soft by default and can be restored
+func TestHandleAssetDelete_SoftAndHard(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "line", "machine")
+	require.NoError(t, createTestRelation(t, handler.store, "machine", "line", RelationPartOf))
+
+	resp := request(t, nc, SubjectAssetDelete, DeleteAssetRequest{ID: "line"})
+	require.True(t, resp.Success, resp.Error)
+
+	resp = request(t, nc, SubjectAssetGet, GetAssetRequest{ID: "line"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, "asset not found", resp.Error)
+	assert.Equal(t, ErrCodeNotFound, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetGet, GetAssetRequest{ID: "line", IncludeDeleted: true})
+	require.True(t, resp.Success, resp.Error)
+	var asset Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &asset))
+	assert.NotNil(t, asset.DeletedAt)
+
+	resp = request(t, nc, SubjectAssetList, ListAssetsRequest{IncludeDeleted: true})
+	require.True(t, resp.Success, resp.Error)
+	var page ListAssetsResponse
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	assert.Equal(t, 2, page.Total)
+
+	resp = request(t, nc, SubjectAssetRestore, RestoreAssetRequest{ID: "line"})
+	require.True(t, resp.Success, resp.Error)
+	var restored Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &restored))
+	assert.Equal(t, "line", restored.ID)
+	assert.Nil(t, restored.DeletedAt)
+
+	// The relation survived the soft delete
+	relations, err := handler.store.GetRelationsByTargetAsset("line")
+	require.NoError(t, err)
+	assert.Len(t, relations, 1)
+
+	resp = request(t, nc, SubjectAssetDelete, DeleteAssetRequest{ID: "line", Hard: true})
+	require.True(t, resp.Success, resp.Error)
+
+	relations, err = handler.store.GetRelationsByTargetAsset("line")
+	require.NoError(t, err)
+	assert.Empty(t, relations)
+
+	resp = request(t, nc, SubjectAssetRestore, RestoreAssetRequest{ID: "line"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, "deleted asset not found: line", resp.Error)
+}
+
+// TestHandleAssetClone tests cloning an asset over NATS
+func TestHandleAssetClone(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "m1", Name: "machine-1", TemplateName: "test-sensor", Labels: []string{"line:1"}, CreatedAt: time.Now()}))
+	createTestAssets(t, handler.store, "line")
+	require.NoError(t, createTestRelation(t, handler.store, "m1", "line", RelationPartOf))
+
+	resp := request(t, nc, SubjectAssetClone, CloneAssetRequest{ID: "m1", Name: "machine-2", CopyRelations: true})
+	require.True(t, resp.Success, resp.Error)
+	var clone Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &clone))
+	assert.NotEqual(t, "m1", clone.ID)
+	assert.Equal(t, "machine-2", clone.Name)
+	assert.Equal(t, "test-sensor", clone.TemplateName)
+	assert.Equal(t, []string{"line:1"}, clone.Labels)
+	exists, err := handler.store.RelationExists(clone.ID, "line", RelationPartOf)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	resp = request(t, nc, SubjectAssetClone, CloneAssetRequest{ID: "m1", Name: "machine-2"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeDuplicate, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetClone, CloneAssetRequest{ID: "m1"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetClone, CloneAssetRequest{ID: "missing", Name: "machine-3"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeNotFound, resp.ErrorCode)
+}
+
 // TestMetaHandler_DeleteAsset tests asset deletion
 func TestMetaHandler_DeleteAsset(t *testing.T) {
 	store, err := NewStore(":memory:")
@@ -197,8 +283,260 @@ func TestMetaHandler_TemplateValidation(t *testing.T) {
 	assert.False(t, handler.loader.Exists("non-existent"))
 }
 
+// testResponse mirrors Response but keeps Data raw for typed decoding
+type testResponse struct {
+	Success   bool            `json:"success"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	ErrorCode string          `json:"error_code,omitempty"`
+}
+
+// newTestMetaHandler wires a MetaHandler to an embedded NATS server
+func newTestMetaHandler(t *testing.T) (*MetaHandler, *nats.Conn) {
+	_, nc, _ := startTestNATSServer(t, false)
+
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+
+	handler := NewMetaHandler(store, loader)
+	require.NoError(t, handler.RegisterHandlers(nc))
+	require.NoError(t, nc.Flush())
+
+	return handler, nc
+}
+
+// request sends a JSON request to subject and decodes the reply
+func request(t *testing.T, nc *nats.Conn, subject string, payload interface{}) testResponse {
+	var data []byte
+	if payload != nil {
+		var err error
+		data, err = json.Marshal(payload)
+		require.NoError(t, err)
+	}
+
+	msg, err := nc.Request(subject, data, 2*time.Second)
+	require.NoError(t, err)
+
+	var resp testResponse
+	require.NoError(t, json.Unmarshal(msg.Data, &resp))
+	return resp
+}
+
+// TestHandleAssetList_Paginated tests the paginated list request over NATS
+// TestHandleAssetList_Order tests order_by and order_dir over NATS
+func TestHandleAssetList_Order(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "pump", "boiler", "valve")
+
+	resp := request(t, nc, SubjectAssetList, ListAssetsRequest{OrderBy: "name", OrderDir: "desc"})
+	require.True(t, resp.Success, resp.Error)
+	var page ListAssetsResponse
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	assert.Equal(t, []string{"valve", "pump", "boiler"}, assetIDs(page.Assets))
+
+	resp = request(t, nc, SubjectAssetList, ListAssetsRequest{OrderBy: "labels"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+	assert.Contains(t, resp.Error, "order_by")
+
+	resp = request(t, nc, SubjectAssetList, ListAssetsRequest{OrderDir: "up"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+}
+
+func TestHandleAssetList_Paginated(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	base := time.Now()
+	for i := 1; i <= 3; i++ {
+		asset := &Asset{
+			ID:        fmt.Sprintf("id-%d", i),
+			Name:      fmt.Sprintf("asset-%d", i),
+			Labels:    []string{"line-1"},
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+		}
+		require.NoError(t, handler.store.CreateAsset(asset))
+	}
+
+	// Legacy empty request returns a plain array
+	resp := request(t, nc, SubjectAssetList, nil)
+	require.True(t, resp.Success, resp.Error)
+	var all []*Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &all))
+	assert.Len(t, all, 3)
+
+	resp = request(t, nc, SubjectAssetList, ListAssetsRequest{Limit: 2, Label: "line-1"})
+	require.True(t, resp.Success, resp.Error)
+	var page ListAssetsResponse
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, 2, page.Limit)
+	assert.Len(t, page.Assets, 2)
+
+	// Unset limit defaults to DefaultListLimit
+	resp = request(t, nc, SubjectAssetList, ListAssetsRequest{Label: "missing"})
+	require.True(t, resp.Success, resp.Error)
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	assert.Equal(t, DefaultListLimit, page.Limit)
+	assert.Equal(t, 0, page.Total)
+	assert.Empty(t, page.Assets)
+}
+
+// TestHandleAssetUpdate tests partial updates over NATS
+func TestHandleAssetUpdate(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "id-1", Name: "asset-1", Labels: []string{"a"}, CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "id-2", Name: "asset-2", CreatedAt: time.Now()}))
+
+	newName := "renamed"
+	template := "test-sensor"
+	resp := request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: "id-1", Name: &newName, TemplateName: &template})
+	require.True(t, resp.Success, resp.Error)
+
+	var updated Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &updated))
+	assert.Equal(t, "renamed", updated.Name)
+	assert.Equal(t, "test-sensor", updated.TemplateName)
+	assert.Equal(t, []string{"a"}, updated.Labels, "labels were not provided and must be kept")
+
+	taken := "asset-2"
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: "id-1", Name: &taken})
+	assert.False(t, resp.Success)
+	assert.Contains(t, resp.Error, "asset name already exists")
+	assert.Equal(t, ErrCodeDuplicate, resp.ErrorCode)
+
+	unknown := "no-such-template"
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: "id-1", TemplateName: &unknown})
+	assert.False(t, resp.Success)
+	assert.Equal(t, "template not found", resp.Error)
+
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: "id-1"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, "no fields to update", resp.Error)
+}
+
+// TestHandleAsset_Attributes tests attributes on the create and update subjects
+func TestHandleAsset_Attributes(t *testing.T) {
+	_, nc := newTestMetaHandler(t)
+
+	resp := request(t, nc, SubjectAssetCreate, CreateAssetRequest{
+		Name:       "plc-1",
+		Attributes: map[string]string{"vendor": "Siemens", "model": "S7"},
+	})
+	require.True(t, resp.Success, resp.Error)
+	var created Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+	assert.Equal(t, "Siemens", created.Attributes["vendor"])
+
+	attrs := map[string]string{"vendor": "Siemens", "model": "S7-1500"}
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: created.ID, Attributes: &attrs})
+	require.True(t, resp.Success, resp.Error)
+	var updated Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &updated))
+	assert.Equal(t, attrs, updated.Attributes)
+}
+
+// TestHandleAssetBatchCreate tests batch creation and collision reporting over NATS
+func TestHandleAssetBatchCreate(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	resp := request(t, nc, SubjectAssetBatch, BatchCreateAssetsRequest{Assets: []CreateAssetRequest{
+		{Name: "batch-1", TemplateName: "test-sensor"},
+		{Name: "batch-2", Labels: []string{"line-1"}},
+	}})
+	require.True(t, resp.Success, resp.Error)
+	var created BatchCreateAssetsResponse
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+	assert.Equal(t, 2, created.Created)
+	require.Len(t, created.Assets, 2)
+	assert.NotEmpty(t, created.Assets[0].ID)
+
+	// Collision with an existing asset rejects the whole batch
+	resp = request(t, nc, SubjectAssetBatch, BatchCreateAssetsRequest{Assets: []CreateAssetRequest{
+		{Name: "batch-3"},
+		{Name: "batch-1"},
+	}})
+	assert.False(t, resp.Success)
+	var batchErr BatchItemError
+	require.NoError(t, json.Unmarshal(resp.Data, &batchErr))
+	assert.Equal(t, 1, batchErr.Index)
+	assert.Equal(t, "batch-1", batchErr.Name)
+	assert.Equal(t, "asset name already exists", batchErr.Error)
+	assert.Equal(t, ErrCodeDuplicate, resp.ErrorCode)
+
+	missing, err := handler.store.GetAssetByName("batch-3")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	// Duplicates within the batch and unknown templates are rejected too
+	resp = request(t, nc, SubjectAssetBatch, BatchCreateAssetsRequest{Assets: []CreateAssetRequest{
+		{Name: "batch-4"},
+		{Name: "batch-4"},
+	}})
+	assert.False(t, resp.Success)
+	assert.Contains(t, resp.Error, "duplicate name in batch")
+
+	resp = request(t, nc, SubjectAssetBatch, BatchCreateAssetsRequest{Assets: []CreateAssetRequest{
+		{Name: "batch-5", TemplateName: "unknown"},
+	}})
+	assert.False(t, resp.Success)
+	assert.Contains(t, resp.Error, "template not found")
+}
+
 // ==================== AssetRelation Handler Tests ====================
 
+// TestHandleRelationBatchCreate tests batch relation creation and per-entry error reporting over NATS
+func TestHandleRelationBatchCreate(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "line", "machine-1", "machine-2")
+
+	resp := request(t, nc, SubjectRelationBatch, BatchCreateRelationsRequest{Relations: []CreateRelationRequest{
+		{SourceAssetID: "machine-1", TargetAssetID: "line", RelationType: RelationPartOf},
+		{SourceAssetID: "machine-2", TargetAssetID: "line", RelationType: RelationPartOf, Metadata: map[string]string{"slot": "2"}},
+	}})
+	require.True(t, resp.Success, resp.Error)
+	var created BatchCreateRelationsResponse
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+	assert.Equal(t, 2, created.Created)
+	require.Len(t, created.Relations, 2)
+	assert.NotEmpty(t, created.Relations[0].ID)
+	assert.NotEqual(t, created.Relations[0].ID, created.Relations[1].ID)
+	assert.Equal(t, "2", created.Relations[1].Metadata["slot"])
+
+	// Missing fields are reported per entry
+	resp = request(t, nc, SubjectRelationBatch, BatchCreateRelationsRequest{Relations: []CreateRelationRequest{
+		{SourceAssetID: "machine-1", TargetAssetID: "line", RelationType: RelationConnectedTo},
+		{SourceAssetID: "machine-1", RelationType: RelationConnectedTo},
+	}})
+	assert.False(t, resp.Success)
+	var items []BatchItemError
+	require.NoError(t, json.Unmarshal(resp.Data, &items))
+	assert.Equal(t, []BatchItemError{{Index: 1, Error: "target_asset_id is required"}}, items)
+
+	// Store validation failures reject the whole batch
+	resp = request(t, nc, SubjectRelationBatch, BatchCreateRelationsRequest{Relations: []CreateRelationRequest{
+		{SourceAssetID: "machine-1", TargetAssetID: "line", RelationType: RelationConnectedTo},
+		{SourceAssetID: "ghost", TargetAssetID: "line", RelationType: RelationPartOf},
+		{SourceAssetID: "line", TargetAssetID: "machine-1", RelationType: RelationPartOf},
+	}})
+	assert.False(t, resp.Success)
+	require.NoError(t, json.Unmarshal(resp.Data, &items))
+	assert.Equal(t, []BatchItemError{{Index: 1, Error: "source asset not found: ghost"}}, items)
+
+	relations, err := handler.store.GetRelationsByType(RelationConnectedTo)
+	require.NoError(t, err)
+	assert.Empty(t, relations)
+
+	resp = request(t, nc, SubjectRelationBatch, BatchCreateRelationsRequest{})
+	assert.False(t, resp.Success)
+	assert.Equal(t, "relations is required", resp.Error)
+}
+
 // TestHandleRelationCreate_Success tests successful relation creation
 func TestHandleRelationCreate_Success(t *testing.T) {
 	store, err := NewStore(":memory:")
@@ -451,6 +789,291 @@ func TestHandleRelationDelete_Success(t *testing.T) {
 	assert.Nil(t, retrieved)
 }
 
+// TestHandleRelationUpdate tests replacing relation metadata over NATS
+func TestHandleRelationUpdate(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "line", "machine")
+	require.NoError(t, createTestRelation(t, handler.store, "machine", "line", RelationPartOf))
+	id := "machine-partOf-line"
+
+	resp := request(t, nc, SubjectRelationUpdate, UpdateRelationRequest{ID: id, Metadata: map[string]string{"slot": "4"}})
+	require.True(t, resp.Success, resp.Error)
+	var relation AssetRelation
+	require.NoError(t, json.Unmarshal(resp.Data, &relation))
+	assert.Equal(t, id, relation.ID)
+	assert.Equal(t, "4", relation.Metadata["slot"])
+
+	resp = request(t, nc, SubjectRelationUpdate, UpdateRelationRequest{Metadata: map[string]string{"slot": "4"}})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectRelationUpdate, UpdateRelationRequest{ID: "missing"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeNotFound, resp.ErrorCode)
+}
+
+// TestHandleRelationUpdate_KeepsWeight tests that an update without a weight
+// keeps it and clear_weight removes it
+func TestHandleRelationUpdate_KeepsWeight(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "pump", "tank")
+
+	weight := 150.0
+	resp := request(t, nc, SubjectRelationCreate, CreateRelationRequest{
+		SourceAssetID: "pump",
+		TargetAssetID: "tank",
+		RelationType:  RelationConnectedTo,
+		Weight:        &weight,
+	})
+	require.True(t, resp.Success, resp.Error)
+	var relation AssetRelation
+	require.NoError(t, json.Unmarshal(resp.Data, &relation))
+
+	resp = request(t, nc, SubjectRelationUpdate, UpdateRelationRequest{ID: relation.ID, Metadata: map[string]string{"slot": "1"}})
+	require.True(t, resp.Success, resp.Error)
+	require.NoError(t, json.Unmarshal(resp.Data, &relation))
+	require.NotNil(t, relation.Weight)
+	assert.Equal(t, 150.0, *relation.Weight)
+	assert.Equal(t, map[string]string{"slot": "1"}, relation.Metadata)
+
+	resp = request(t, nc, SubjectRelationUpdate, UpdateRelationRequest{ID: relation.ID, Weight: &weight, ClearWeight: true})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectRelationUpdate, UpdateRelationRequest{ID: relation.ID, ClearWeight: true})
+	require.True(t, resp.Success, resp.Error)
+	relation = AssetRelation{}
+	require.NoError(t, json.Unmarshal(resp.Data, &relation))
+	assert.Nil(t, relation.Weight)
+}
+
+// TestHandleRelationAttributes tests that typed attributes keep their JSON
+// types through create and update over NATS
+func TestHandleRelationAttributes(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "pump", "tank")
+
+	resp := request(t, nc, SubjectRelationCreate, CreateRelationRequest{
+		SourceAssetID: "pump",
+		TargetAssetID: "tank",
+		RelationType:  RelationConnectedTo,
+		Attributes:    map[string]any{"diameter": 150, "code": "150"},
+	})
+	require.True(t, resp.Success, resp.Error)
+	var relation AssetRelation
+	require.NoError(t, json.Unmarshal(resp.Data, &relation))
+	assert.Equal(t, 150.0, relation.Attributes["diameter"])
+	assert.Equal(t, "150", relation.Attributes["code"])
+
+	// an update without attributes keeps them
+	resp = request(t, nc, SubjectRelationUpdate, UpdateRelationRequest{ID: relation.ID, Metadata: map[string]string{"slot": "1"}})
+	require.True(t, resp.Success, resp.Error)
+	require.NoError(t, json.Unmarshal(resp.Data, &relation))
+	assert.Equal(t, 150.0, relation.Attributes["diameter"])
+
+	resp = request(t, nc, SubjectRelationUpdate, UpdateRelationRequest{ID: relation.ID, Attributes: map[string]any{"nested": []int{1}}})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+}
+
+// TestHandleRelationTree tests the tree subject over NATS
+func TestHandleRelationTree(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	for _, id := range []string{"plant", "line", "machine"} {
+		require.NoError(t, handler.store.CreateAsset(&Asset{ID: id, Name: id, CreatedAt: time.Now()}))
+	}
+	require.NoError(t, handler.store.CreateRelation(&AssetRelation{ID: "r1", SourceAssetID: "line", TargetAssetID: "plant", RelationType: RelationPartOf, CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateRelation(&AssetRelation{ID: "r2", SourceAssetID: "machine", TargetAssetID: "line", RelationType: RelationPartOf, CreatedAt: time.Now()}))
+
+	resp := request(t, nc, SubjectRelationTree, RelationTreeRequest{AssetID: "plant"})
+	require.True(t, resp.Success, resp.Error)
+	var assets []*Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &assets))
+	require.Len(t, assets, 2)
+	assert.Equal(t, "line", assets[0].ID)
+	assert.Equal(t, "machine", assets[1].ID)
+
+	resp = request(t, nc, SubjectRelationTree, RelationTreeRequest{AssetID: "machine", Direction: TreeAncestors, Depth: 1})
+	require.True(t, resp.Success, resp.Error)
+	require.NoError(t, json.Unmarshal(resp.Data, &assets))
+	require.Len(t, assets, 1)
+	assert.Equal(t, "line", assets[0].ID)
+
+	resp = request(t, nc, SubjectRelationTree, RelationTreeRequest{AssetID: "plant", Direction: "up"})
+	assert.False(t, resp.Success)
+}
+
+// TestHandleRelationList_TypeOnly tests listing by relation_type without asset_id
+func TestHandleRelationList_TypeOnly(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, handler.store.CreateAsset(&Asset{ID: id, Name: id, CreatedAt: time.Now()}))
+	}
+	require.NoError(t, handler.store.CreateRelation(&AssetRelation{ID: "r1", SourceAssetID: "a", TargetAssetID: "b", RelationType: RelationLocatedIn, CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateRelation(&AssetRelation{ID: "r2", SourceAssetID: "b", TargetAssetID: "c", RelationType: RelationPartOf, CreatedAt: time.Now()}))
+
+	resp := request(t, nc, SubjectRelationList, ListRelationsRequest{RelationType: RelationLocatedIn})
+	require.True(t, resp.Success, resp.Error)
+	var relations []*AssetRelation
+	require.NoError(t, json.Unmarshal(resp.Data, &relations))
+	require.Len(t, relations, 1)
+	assert.Equal(t, "r1", relations[0].ID)
+
+	// A future created_after excludes everything
+	resp = request(t, nc, SubjectRelationList, ListRelationsRequest{AssetID: "b", CreatedAfter: time.Now().Add(time.Hour).Unix()})
+	require.True(t, resp.Success, resp.Error)
+	require.NoError(t, json.Unmarshal(resp.Data, &relations))
+	assert.Empty(t, relations)
+
+	resp = request(t, nc, SubjectRelationList, ListRelationsRequest{})
+	assert.False(t, resp.Success)
+	assert.Equal(t, "asset_id or relation_type is required", resp.Error)
+}
+
+// TestHandleRelationCreate_Measures tests that the measures relation type is accepted over NATS
+func TestHandleRelationCreate_Measures(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "sensor", Name: "sensor", CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "air-temp", Name: "air-temp", CreatedAt: time.Now()}))
+
+	resp := request(t, nc, SubjectRelationCreate, CreateRelationRequest{
+		SourceAssetID: "sensor",
+		TargetAssetID: "air-temp",
+		RelationType:  RelationMeasures,
+	})
+	require.True(t, resp.Success, resp.Error)
+
+	var relation AssetRelation
+	require.NoError(t, json.Unmarshal(resp.Data, &relation))
+	assert.Equal(t, RelationMeasures, relation.RelationType)
+}
+
+// TestHandleAssetSearch tests label search over NATS
+func TestHandleAssetSearch(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "a", Name: "a", Labels: []string{"line-1", "critical"}, CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "b", Name: "b", Labels: []string{"line-1"}, CreatedAt: time.Now()}))
+
+	resp := request(t, nc, SubjectAssetSearch, SearchAssetsRequest{Labels: []string{"line-1", "critical"}})
+	require.True(t, resp.Success, resp.Error)
+	var assets []*Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &assets))
+	assert.Equal(t, []string{"a"}, assetIDs(assets))
+
+	resp = request(t, nc, SubjectAssetSearch, SearchAssetsRequest{Labels: []string{"line-1", "critical"}, Match: LabelMatchAny})
+	require.True(t, resp.Success, resp.Error)
+	require.NoError(t, json.Unmarshal(resp.Data, &assets))
+	assert.Len(t, assets, 2)
+
+	resp = request(t, nc, SubjectAssetSearch, SearchAssetsRequest{Labels: []string{"line-1"}, Match: "some"})
+	assert.False(t, resp.Success)
+
+	resp = request(t, nc, SubjectAssetSearch, SearchAssetsRequest{})
+	assert.False(t, resp.Success)
+	assert.Equal(t, "labels, label_key or name_prefix is required", resp.Error)
+}
+
+// TestHandleAssetSearch_NamePrefix tests name prefix search alone and with labels
+func TestHandleAssetSearch_NamePrefix(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "a", Name: "pump-2", Labels: []string{"critical"}, CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "b", Name: "Pump-1", CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "c", Name: "press-1", Labels: []string{"critical"}, CreatedAt: time.Now()}))
+
+	search := func(req SearchAssetsRequest) []string {
+		resp := request(t, nc, SubjectAssetSearch, req)
+		require.True(t, resp.Success, resp.Error)
+		var assets []*Asset
+		require.NoError(t, json.Unmarshal(resp.Data, &assets))
+		return assetIDs(assets)
+	}
+	assert.Equal(t, []string{"b", "a"}, search(SearchAssetsRequest{NamePrefix: "pump"}))
+	assert.Equal(t, []string{"b"}, search(SearchAssetsRequest{NamePrefix: "pump", Limit: 1}))
+	assert.Equal(t, []string{"c", "b", "a"}, search(SearchAssetsRequest{NamePrefix: "p"}))
+	assert.Equal(t, []string{"c", "a"}, search(SearchAssetsRequest{NamePrefix: "p", Labels: []string{"critical"}}))
+	assert.Empty(t, search(SearchAssetsRequest{NamePrefix: "valve"}))
+}
+
+// TestHandleAssetSearch_NamePrefixLimit tests that the limit applies after
+// the label filters, so labelled assets ordered after more than limit
+// unlabelled name matches are still found
+func TestHandleAssetSearch_NamePrefixLimit(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	for i := 1; i <= 5; i++ {
+		asset := &Asset{ID: fmt.Sprint(i), Name: fmt.Sprintf("pump-%d", i), CreatedAt: time.Now()}
+		if i >= 4 {
+			asset.Labels = []string{"critical", "site:berlin"}
+		}
+		require.NoError(t, handler.store.CreateAsset(asset))
+	}
+
+	for name, req := range map[string]SearchAssetsRequest{
+		"labels":    {NamePrefix: "pump", Labels: []string{"critical"}, Limit: 2},
+		"label key": {NamePrefix: "pump", LabelKey: "site", LabelValue: "berlin", Limit: 2},
+	} {
+		resp := request(t, nc, SubjectAssetSearch, req)
+		require.True(t, resp.Success, resp.Error)
+		var assets []*Asset
+		require.NoError(t, json.Unmarshal(resp.Data, &assets))
+		assert.Equal(t, []string{"4", "5"}, assetIDs(assets), name)
+	}
+
+	resp := request(t, nc, SubjectAssetSearch, SearchAssetsRequest{NamePrefix: "pump", Labels: []string{"critical"}, Limit: 1})
+	require.True(t, resp.Success, resp.Error)
+	var assets []*Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &assets))
+	assert.Equal(t, []string{"4"}, assetIDs(assets))
+}
+
+// TestHandleAssetSearch_LabelKV tests searching key:value labels alone and with plain labels
+func TestHandleAssetSearch_LabelKV(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	base := time.Now()
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "a", Name: "a", Labels: []string{"site:berlin", "critical"}, CreatedAt: base}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "b", Name: "b", Labels: []string{"site:berlin"}, CreatedAt: base.Add(time.Second)}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "c", Name: "c", Labels: []string{"site:paris", "critical"}, CreatedAt: base.Add(2 * time.Second)}))
+
+	search := func(req SearchAssetsRequest) []string {
+		resp := request(t, nc, SubjectAssetSearch, req)
+		require.True(t, resp.Success, resp.Error)
+		var assets []*Asset
+		require.NoError(t, json.Unmarshal(resp.Data, &assets))
+		return assetIDs(assets)
+	}
+	assert.Equal(t, []string{"b", "a"}, search(SearchAssetsRequest{LabelKey: "site", LabelValue: "berlin"}))
+	assert.Equal(t, []string{"c", "b", "a"}, search(SearchAssetsRequest{LabelKey: "site"}))
+	assert.Equal(t, []string{"a"}, search(SearchAssetsRequest{LabelKey: "site", LabelValue: "berlin", Labels: []string{"critical"}}))
+	assert.Empty(t, search(SearchAssetsRequest{LabelKey: "site", LabelValue: "tokyo"}))
+
+	resp := request(t, nc, SubjectAssetSearch, SearchAssetsRequest{LabelValue: "berlin"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+}
+
+// TestHandleStats tests the stats subject
+func TestHandleStats(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "a", Name: "a", TemplateName: "test-sensor", CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "b", Name: "b", CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateRelation(&AssetRelation{ID: "r1", SourceAssetID: "a", TargetAssetID: "b", RelationType: RelationPartOf, CreatedAt: time.Now()}))
+
+	resp := request(t, nc, SubjectStats, nil)
+	require.True(t, resp.Success, resp.Error)
+
+	var stats StoreStats
+	require.NoError(t, json.Unmarshal(resp.Data, &stats))
+	assert.Equal(t, 2, stats.TotalAssets)
+	assert.Equal(t, 1, stats.AssetsByTemplate["test-sensor"])
+	assert.Equal(t, 1, stats.TotalRelations)
+	assert.Equal(t, 1, stats.RelationsByType["partOf"])
+}
+
 // ==================== Reply Function Tests ====================
 
 // TestMarshalResponse_Success tests successful response marshaling
@@ -507,3 +1130,532 @@ func TestMarshalResponse_MarshalError(t *testing.T) {
 	assert.False(t, result.Success, "Expected Success=false in fallback response")
 	assert.Contains(t, result.Error, "internal error", "Expected error message in fallback response")
 }
+
+// TestMetaHandler_ErrorCodes tests that failure responses carry a stable error code
+func TestMetaHandler_ErrorCodes(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "line", "machine")
+	outOfRange, zero := 91.0, 0.0
+
+	tests := []struct {
+		name    string
+		subject string
+		payload any
+		code    string
+	}{
+		{"duplicate name", SubjectAssetCreate, CreateAssetRequest{Name: "line"}, ErrCodeDuplicate},
+		{"asset not found", SubjectAssetGet, GetAssetRequest{ID: "missing"}, ErrCodeNotFound},
+		{"asset delete not found", SubjectAssetDelete, DeleteAssetRequest{ID: "missing"}, ErrCodeNotFound},
+		{"relation not found", SubjectRelationGet, GetRelationRequest{ID: "missing"}, ErrCodeNotFound},
+		{"relation source not found", SubjectRelationCreate, CreateRelationRequest{SourceAssetID: "missing", TargetAssetID: "line", RelationType: RelationPartOf}, ErrCodeNotFound},
+		{"missing field", SubjectAssetCreate, CreateAssetRequest{}, ErrCodeBadRequest},
+		{"latitude out of range", SubjectAssetCreate, CreateAssetRequest{Name: "far", Latitude: &outOfRange, Longitude: &zero}, ErrCodeValidation},
+		{"longitude without latitude", SubjectAssetCreate, CreateAssetRequest{Name: "half", Longitude: &zero}, ErrCodeValidation},
+		{"invalid relation type", SubjectRelationCreate, CreateRelationRequest{SourceAssetID: "machine", TargetAssetID: "line", RelationType: "bogus"}, ErrCodeValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := request(t, nc, tt.subject, tt.payload)
+			assert.False(t, resp.Success)
+			assert.NotEmpty(t, resp.Error)
+			assert.Equal(t, tt.code, resp.ErrorCode)
+		})
+	}
+
+	msg, err := nc.Request(SubjectAssetCreate, []byte("not json"), 2*time.Second)
+	require.NoError(t, err)
+	var resp testResponse
+	require.NoError(t, json.Unmarshal(msg.Data, &resp))
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+}
+
+// TestErrorCode_SpecificErrors tests that store errors match their specific
+// error and kind with errors.Is and reach clients with the kind's code
+func TestErrorCode_SpecificErrors(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	store := handler.store
+	createTestAssets(t, store, "line", "machine")
+	require.NoError(t, createTestRelation(t, store, "machine", "line", RelationPartOf))
+	line, err := store.GetAssetByName("line")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		err      error
+		specific error
+		kind     error
+		code     string
+	}{
+		{"asset not found", store.DeleteAsset("missing"), ErrAssetNotFound, ErrNotFound, ErrCodeNotFound},
+		{"relation not found", store.DeleteRelation("missing"), ErrRelationNotFound, ErrNotFound, ErrCodeNotFound},
+		{"duplicate name", store.CreateAsset(&Asset{ID: "other", Name: line.Name, CreatedAt: time.Now()}), ErrDuplicateName, ErrDuplicate, ErrCodeDuplicate},
+		{"relation exists", createTestRelation(t, store, "machine", "line", RelationPartOf), ErrRelationExists, ErrDuplicate, ErrCodeDuplicate},
+		{"relation cycle", createTestRelation(t, store, "line", "machine", RelationPartOf), ErrRelationCycle, ErrInvalid, ErrCodeValidation},
+		{"invalid relation type", createTestRelation(t, store, "line", "machine", "bogus"), ErrInvalidRelationType, ErrInvalid, ErrCodeValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.err)
+			assert.ErrorIs(t, tt.err, tt.specific)
+			assert.ErrorIs(t, tt.err, tt.kind)
+			assert.Equal(t, tt.code, errorCode(tt.err))
+		})
+	}
+	assert.NotErrorIs(t, store.DeleteAsset("missing"), ErrRelationNotFound)
+
+	// The same errors surface through the handler with the kind's code
+	resp := request(t, nc, SubjectRelationCreate, CreateRelationRequest{SourceAssetID: "line", TargetAssetID: "machine", RelationType: RelationPartOf})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+	assert.Contains(t, resp.Error, ErrRelationCycle.Error())
+}
+
+// TestHandleAssetCreate_Location tests that coordinates are stored on create
+// and replaced together on update
+func TestHandleAssetCreate_Location(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	lat, lon, alt := 37.5665, 126.978, 38.0
+	resp := request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "gateway", Latitude: &lat, Longitude: &lon, Altitude: &alt})
+	require.True(t, resp.Success, resp.Error)
+	var asset Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &asset))
+	require.NotNil(t, asset.Latitude)
+	assert.Equal(t, lat, *asset.Latitude)
+	assert.Equal(t, alt, *asset.Altitude)
+
+	stored, err := handler.store.GetAsset(asset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, lon, *stored.Longitude)
+
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: asset.ID, Latitude: &lat})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	newLon := 127.0
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: asset.ID, Latitude: &lat, Longitude: &newLon})
+	require.True(t, resp.Success, resp.Error)
+	stored, err = handler.store.GetAsset(asset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, newLon, *stored.Longitude)
+	assert.Nil(t, stored.Altitude)
+}
+
+// TestHandleAssetGet_ExternalID tests lookup by scheme and external id
+func TestHandleAssetGet_ExternalID(t *testing.T) {
+	_, nc := newTestMetaHandler(t)
+
+	resp := request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-1", ExternalIDs: map[string]string{"erp": "M-100"}})
+	require.True(t, resp.Success, resp.Error)
+	var created Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+
+	resp = request(t, nc, SubjectAssetGet, GetAssetRequest{Scheme: "erp", ExternalID: "M-100"})
+	require.True(t, resp.Success, resp.Error)
+	var asset Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &asset))
+	assert.Equal(t, created.ID, asset.ID)
+	assert.Equal(t, map[string]string{"erp": "M-100"}, asset.ExternalIDs)
+
+	resp = request(t, nc, SubjectAssetGet, GetAssetRequest{Scheme: "erp", ExternalID: "M-999"})
+	assert.Equal(t, ErrCodeNotFound, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetGet, GetAssetRequest{ExternalID: "M-100"})
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-2", ExternalIDs: map[string]string{"erp": "M-100"}})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeDuplicate, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-3", ExternalIDs: map[string]string{"ERP": "M-300"}})
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+}
+
+// TestHandleValidate tests dry-run validation results and that nothing is stored
+func TestHandleValidate(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	temperature, status := 21.5, "running"
+	resp := request(t, nc, SubjectValidate, ValidateDataRequest{
+		TemplateName: "test-sensor",
+		Data: &AssetData{AssetID: "sensor-001", Values: []TagValue{
+			{Name: "temperature", Number: &temperature, Unit: "celsius"},
+		}},
+	})
+	require.True(t, resp.Success, resp.Error)
+	assert.JSONEq(t, `{"valid":true}`, string(resp.Data))
+
+	resp = request(t, nc, SubjectValidate, ValidateDataRequest{
+		TemplateName: "test-sensor",
+		Data:         &AssetData{Values: []TagValue{{Name: "temperature", Text: &status}}},
+	})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+	assert.Contains(t, resp.Error, "temperature")
+
+	resp = request(t, nc, SubjectValidate, ValidateDataRequest{TemplateName: "missing", Data: &AssetData{}})
+	assert.Equal(t, ErrCodeNotFound, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectValidate, ValidateDataRequest{TemplateName: "test-sensor"})
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+
+	// Validation has no side effects
+	assets, err := handler.store.ListAssets()
+	require.NoError(t, err)
+	assert.Empty(t, assets)
+	count, err := handler.store.CountAssetData()
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+// TestHandleValidate_Coerce tests that validation coerces values only when
+// enabled, as the data path does
+func TestHandleValidate_Coerce(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	text := "21.5"
+	req := ValidateDataRequest{
+		TemplateName: "test-sensor",
+		Data:         &AssetData{Values: []TagValue{{Name: "temperature", Text: &text, Unit: "celsius"}}},
+	}
+	resp := request(t, nc, SubjectValidate, req)
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	handler.SetCoerceValues(true)
+	resp = request(t, nc, SubjectValidate, req)
+	assert.True(t, resp.Success, resp.Error)
+}
+
+// TestHandleValidate_StrictTemplateVersion tests that validation rejects data
+// from a stored asset created before the template's breaking version
+func TestHandleValidate_StrictTemplateVersion(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	require.NoError(t, handler.loader.LoadFromFile(writeTemplate(t, `name: pump
+version: 2
+breakingVersion: 2
+strictVersion: true
+resources:
+  - name: flow
+    valueType: NUMBER
+`)))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "old", Name: "old", TemplateName: "pump", TemplateVersion: 1, CreatedAt: time.Now()}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "new", Name: "new", TemplateName: "pump", TemplateVersion: 2, CreatedAt: time.Now()}))
+
+	flow := 1.5
+	validate := func(assetID string) testResponse {
+		return request(t, nc, SubjectValidate, ValidateDataRequest{
+			TemplateName: "pump",
+			Data:         &AssetData{AssetID: assetID, Values: []TagValue{{Name: "flow", Number: &flow}}},
+		})
+	}
+
+	resp := validate("old")
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	for _, id := range []string{"new", "unknown", ""} {
+		resp = validate(id)
+		assert.True(t, resp.Success, "%q: %s", id, resp.Error)
+	}
+}
+
+// TestHandleAssetList_CreatedRange tests created_after/created_before filters
+func TestHandleAssetList_CreatedRange(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	base := time.Now().Add(-time.Hour)
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "old", Name: "old", CreatedAt: base.Add(-time.Hour)}))
+	require.NoError(t, handler.store.CreateAsset(&Asset{ID: "new", Name: "new", CreatedAt: base.Add(time.Minute)}))
+
+	resp := request(t, nc, SubjectAssetList, ListAssetsRequest{CreatedAfter: &base})
+	require.True(t, resp.Success, resp.Error)
+	var page ListAssetsResponse
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, []string{"new"}, assetIDs(page.Assets))
+
+	resp = request(t, nc, SubjectAssetList, ListAssetsRequest{CreatedBefore: &base})
+	require.True(t, resp.Success, resp.Error)
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	assert.Equal(t, []string{"old"}, assetIDs(page.Assets))
+
+	resp = request(t, nc, SubjectAssetList, ListAssetsRequest{CreatedAfter: &base, CreatedBefore: &base})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+}
+
+// TestHandleRelationList_BothSymmetric tests that "both" lists a symmetric
+// edge once even when it is stored in both directions
+func TestHandleRelationList_BothSymmetric(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "pump", "valve", "line")
+
+	require.NoError(t, createTestRelation(t, handler.store, "pump", "valve", RelationConnectedTo))
+	require.NoError(t, createTestRelation(t, handler.store, "pump", "line", RelationPartOf))
+	_, err := handler.store.db.Exec(`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at)
+		VALUES ('legacy', 'valve', 'pump', 'connectedTo', ?)`, time.Now())
+	require.NoError(t, err)
+
+	resp := request(t, nc, SubjectRelationList, ListRelationsRequest{AssetID: "pump"})
+	require.True(t, resp.Success, resp.Error)
+	var relations []*AssetRelation
+	require.NoError(t, json.Unmarshal(resp.Data, &relations))
+	assert.Len(t, relations, 2)
+
+	resp = request(t, nc, SubjectRelationCreate, CreateRelationRequest{SourceAssetID: "valve", TargetAssetID: "line", RelationType: RelationConnectedTo})
+	require.True(t, resp.Success, resp.Error)
+	resp = request(t, nc, SubjectRelationCreate, CreateRelationRequest{SourceAssetID: "line", TargetAssetID: "valve", RelationType: RelationConnectedTo})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeDuplicate, resp.ErrorCode)
+}
+
+// TestHandleAssetCreate_RecordsTemplateVersion tests that assets record the
+// template version and template lists expose it
+func TestHandleAssetCreate_RecordsTemplateVersion(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	require.NoError(t, handler.loader.LoadFromFile(writeTemplate(t, `name: pump
+version: 4
+resources:
+  - name: flow
+    valueType: NUMBER
+`)))
+
+	resp := request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-1", TemplateName: "pump"})
+	require.True(t, resp.Success, resp.Error)
+	var created Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+	assert.Equal(t, 4, created.TemplateVersion)
+
+	stored, err := handler.store.GetAsset(created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, stored.TemplateVersion)
+
+	resp = request(t, nc, SubjectTemplateList, nil)
+	require.True(t, resp.Success, resp.Error)
+	var templates []*AssetTemplate
+	require.NoError(t, json.Unmarshal(resp.Data, &templates))
+	versions := make(map[string]int)
+	for _, tmpl := range templates {
+		versions[tmpl.Name] = tmpl.Version
+	}
+	assert.Equal(t, map[string]int{"pump": 4, "test-sensor": 0}, versions)
+}
+
+// TestHandleRelationListAll tests the paginated global relation listing
+func TestHandleRelationListAll(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "line", "machine", "sensor")
+	require.NoError(t, createTestRelation(t, handler.store, "machine", "line", RelationPartOf))
+	require.NoError(t, createTestRelation(t, handler.store, "sensor", "machine", RelationPartOf))
+
+	resp := request(t, nc, SubjectRelationListAll, ListAllRelationsRequest{Limit: 1})
+	require.True(t, resp.Success, resp.Error)
+	var page ListAllRelationsResponse
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, 1, page.Limit)
+	assert.Len(t, page.Relations, 1)
+
+	// An empty request uses the default page size
+	resp = request(t, nc, SubjectRelationListAll, nil)
+	require.True(t, resp.Success, resp.Error)
+	require.NoError(t, json.Unmarshal(resp.Data, &page))
+	assert.Equal(t, DefaultListLimit, page.Limit)
+	assert.Len(t, page.Relations, 2)
+}
+
+// TestHandleAssetStale tests listing silent assets over NATS
+func TestHandleAssetStale(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "fresh", "silent")
+	require.NoError(t, handler.store.TouchAssets(map[string]time.Time{"fresh": time.Now()}))
+
+	resp := request(t, nc, SubjectAssetStale, StaleAssetsRequest{Threshold: "15m"})
+	require.True(t, resp.Success, resp.Error)
+	var assets []*Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &assets))
+	require.Len(t, assets, 1)
+	assert.Equal(t, "silent", assets[0].ID)
+
+	resp = request(t, nc, SubjectAssetStale, StaleAssetsRequest{Threshold: "soon"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetStale, StaleAssetsRequest{Threshold: "-1m"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+}
+
+// TestHandleDataLatest tests fetching an asset's latest reading over NATS
+func TestHandleDataLatest(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	resp := request(t, nc, SubjectDataLatest, LatestDataRequest{AssetID: "sensor-001"})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeNotFound, resp.ErrorCode)
+
+	value := 21.5
+	require.NoError(t, handler.store.InsertAssetData(&AssetData{
+		AssetID: "sensor-001", Timestamp: baseMillis + 100, Values: []TagValue{{Name: "temperature", Number: &value}},
+	}))
+
+	resp = request(t, nc, SubjectDataLatest, LatestDataRequest{AssetID: "sensor-001"})
+	require.True(t, resp.Success, resp.Error)
+	var data AssetData
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	assert.Equal(t, baseMillis+100, data.Timestamp)
+	require.Len(t, data.Values, 1)
+	assert.Equal(t, 21.5, *data.Values[0].Number)
+
+	resp = request(t, nc, SubjectDataLatest, LatestDataRequest{})
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+}
+
+// TestHandleDataAggregate tests per-bucket aggregates over NATS
+func TestHandleDataAggregate(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	for i, ts := range []int64{0, 30_000, 120_000} {
+		value := float64(i)
+		require.NoError(t, handler.store.InsertAssetData(&AssetData{
+			AssetID: "sensor-001", Timestamp: baseMillis + ts, Values: []TagValue{{Name: "temperature", Number: &value}},
+		}))
+	}
+
+	resp := request(t, nc, SubjectDataAggregate, AggregateDataRequest{
+		AssetID: "sensor-001", Tag: "temperature", From: baseMillis, To: baseMillis + 180_000, Bucket: "1m",
+	})
+	require.True(t, resp.Success, resp.Error)
+	var buckets []DataBucket
+	require.NoError(t, json.Unmarshal(resp.Data, &buckets))
+	require.Len(t, buckets, 3)
+	assert.Equal(t, 2, buckets[0].Count)
+	assert.Equal(t, 0.5, *buckets[0].Avg)
+	assert.Equal(t, 0, buckets[1].Count)
+	assert.Nil(t, buckets[1].Avg)
+	assert.Equal(t, 1, buckets[2].Count)
+
+	resp = request(t, nc, SubjectDataAggregate, AggregateDataRequest{AssetID: "sensor-001", Tag: "temperature", Bucket: "often"})
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectDataAggregate, AggregateDataRequest{AssetID: "sensor-001", Tag: "temperature", To: 1, Bucket: "0s"})
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectDataAggregate, AggregateDataRequest{AssetID: "sensor-001"})
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+}
+
+// TestHandleRelationCount tests relation counts over NATS, on their own
+// subject and in the asset get reply
+func TestHandleRelationCount(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "machine", "line", "sensor")
+	require.NoError(t, createTestRelation(t, handler.store, "machine", "line", RelationPartOf))
+	require.NoError(t, createTestRelation(t, handler.store, "sensor", "machine", RelationMeasures))
+
+	resp := request(t, nc, SubjectRelationCount, RelationCountRequest{AssetID: "machine"})
+	require.True(t, resp.Success, resp.Error)
+	var count RelationCount
+	require.NoError(t, json.Unmarshal(resp.Data, &count))
+	assert.Equal(t, RelationCount{Incoming: 1, Outgoing: 1, Total: 2}, count)
+
+	resp = request(t, nc, SubjectAssetGet, GetAssetRequest{ID: "line", IncludeDegree: true})
+	require.True(t, resp.Success, resp.Error)
+	var asset AssetWithDegree
+	require.NoError(t, json.Unmarshal(resp.Data, &asset))
+	assert.Equal(t, "line", asset.ID)
+	assert.Equal(t, RelationCount{Incoming: 1, Total: 1}, asset.Degree)
+
+	// Without the flag the reply is the plain asset
+	resp = request(t, nc, SubjectAssetGet, GetAssetRequest{ID: "line"})
+	require.True(t, resp.Success, resp.Error)
+	assert.NotContains(t, string(resp.Data), "degree")
+
+	resp = request(t, nc, SubjectRelationCount, RelationCountRequest{AssetID: "missing"})
+	assert.Equal(t, ErrCodeNotFound, resp.ErrorCode)
+	resp = request(t, nc, SubjectRelationCount, RelationCountRequest{})
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+}
+
+// TestHandleRelationBetween tests listing the relations between two assets
+func TestHandleRelationBetween(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "machine", "sensor")
+	require.NoError(t, createTestRelation(t, handler.store, "sensor", "machine", RelationMeasures))
+
+	resp := request(t, nc, SubjectRelationBetween, RelationsBetweenRequest{AssetID: "machine", OtherAssetID: "sensor"})
+	require.True(t, resp.Success, resp.Error)
+	var relations []*RelationBetween
+	require.NoError(t, json.Unmarshal(resp.Data, &relations))
+	require.Len(t, relations, 1)
+	assert.Equal(t, "sensor-measures-machine", relations[0].ID)
+	assert.Equal(t, "incoming", relations[0].Direction)
+
+	resp = request(t, nc, SubjectRelationBetween, RelationsBetweenRequest{AssetID: "machine"})
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+}
+
+// TestHandleRelationExists tests the relation existence check over NATS
+func TestHandleRelationExists(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	createTestAssets(t, handler.store, "machine", "line")
+	require.NoError(t, createTestRelation(t, handler.store, "machine", "line", RelationPartOf))
+
+	check := func(req RelationExistsRequest) RelationExistsResponse {
+		t.Helper()
+		resp := request(t, nc, SubjectRelationExists, req)
+		require.True(t, resp.Success, resp.Error)
+		var result RelationExistsResponse
+		require.NoError(t, json.Unmarshal(resp.Data, &result))
+		return result
+	}
+
+	assert.Equal(t, RelationExistsResponse{Exists: true, ID: "machine-partOf-line"},
+		check(RelationExistsRequest{SourceAssetID: "machine", TargetAssetID: "line", RelationType: RelationPartOf}))
+	assert.Equal(t, RelationExistsResponse{},
+		check(RelationExistsRequest{SourceAssetID: "line", TargetAssetID: "machine", RelationType: RelationPartOf}))
+
+	resp := request(t, nc, SubjectRelationExists, RelationExistsRequest{SourceAssetID: "machine", TargetAssetID: "line", RelationType: "ownerOf"})
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectRelationExists, RelationExistsRequest{SourceAssetID: "machine"})
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+}
+
+// TestMetaHandler_MaxPayload tests that oversized requests are refused
+func TestMetaHandler_MaxPayload(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	handler.SetMaxPayload(64)
+
+	resp := request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump"})
+	require.True(t, resp.Success, resp.Error)
+
+	resp = request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: strings.Repeat("x", 100)})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeBadRequest, resp.ErrorCode)
+	assert.Contains(t, resp.Error, "exceeds the maximum of 64")
+}
+
+// TestHandleTemplateReload tests reloading the template directory on request
+func TestHandleTemplateReload(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+
+	resp := request(t, nc, SubjectTemplateReload, nil)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sensor.yaml"), []byte("name: sensor\n"), 0644))
+	require.NoError(t, handler.loader.LoadFromDir(dir))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pump.yaml"), []byte("name: pump\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("{ invalid yaml ["), 0644))
+
+	resp = request(t, nc, SubjectTemplateReload, nil)
+	require.True(t, resp.Success, resp.Error)
+	var result TemplateReloadResult
+	require.NoError(t, json.Unmarshal(resp.Data, &result))
+	assert.Equal(t, 3, result.Count) // test-sensor, sensor and pump
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0], "broken.yaml")
+	assert.True(t, handler.loader.Exists("pump"))
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a monotonically increasing metric
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add increments the counter by n
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+// Value returns the current count
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+// latencyBuckets are the upper bounds, in seconds, of request latency
+// histograms
+var latencyBuckets = [...]float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Histogram counts observations into the latencyBuckets
+type Histogram struct {
+	mu     sync.Mutex
+	counts [len(latencyBuckets) + 1]uint64 // per bucket, the last one for +Inf
+	sum    float64
+}
+
+// Observe records one value in seconds
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(latencyBuckets[:], v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// snapshot returns the cumulative bucket counts, ending with +Inf, and the
+// sum of the observations
+func (h *Histogram) snapshot() ([]uint64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cumulative := make([]uint64, len(h.counts))
+	var total uint64
+	for i, n := range h.counts {
+		total += n
+		cumulative[i] = total
+	}
+	return cumulative, h.sum
+}
+
+// metaSeries identifies the latency histogram of one subject and status
+type metaSeries struct {
+	subject string
+	status  string // "success" or "error"
+}
+
+// gaugeFunc is a gauge sampled at scrape time
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+// Metrics holds application metrics exported in Prometheus text format
+type Metrics struct {
+	// Data path
+	MessagesReceived     Counter
+	ValidationFailures   Counter
+	PublishErrors        Counter
+	PublishRetries       Counter
+	DeadLetters          Counter
+	AssetsAutoRegistered Counter
+	DedupSuppressed      Counter
+	RateLimited          Counter
+	UnregisteredData     Counter
+	DuplicatesSkipped    Counter
+	UnknownQuality       Counter
+	QualityRejected      Counter
+	UnknownSchema        Counter
+	EdgeEvents           Counter
+	ValuesCoerced        Counter
+	OversizedMessages    Counter
+
+	// Metadata path
+	MetaRequests Counter
+
+	// Live stream
+	StreamDropped Counter
+
+	mu     sync.RWMutex
+	gauges map[string]gaugeFunc
+
+	metaMu      sync.Mutex
+	metaLatency map[metaSeries]*Histogram
+}
+
+// NewMetrics creates an empty metrics set
+func NewMetrics() *Metrics {
+	return &Metrics{
+		gauges: make(map[string]gaugeFunc),
+	}
+}
+
+// RegisterGauge adds (or replaces) a gauge whose value is read from fn on
+// every scrape
+func (m *Metrics) RegisterGauge(name, help string, fn func() float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[name] = gaugeFunc{name: name, help: help, fn: fn}
+}
+
+// ObserveMetaRequest records the handling time of a metadata request on
+// subject, labelled by whether its response reported success
+func (m *Metrics) ObserveMetaRequest(subject string, success bool, d time.Duration) {
+	series := metaSeries{subject: subject, status: "error"}
+	if success {
+		series.status = "success"
+	}
+
+	m.metaMu.Lock()
+	if m.metaLatency == nil {
+		m.metaLatency = make(map[metaSeries]*Histogram)
+	}
+	h, ok := m.metaLatency[series]
+	if !ok {
+		h = &Histogram{}
+		m.metaLatency[series] = h
+	}
+	m.metaMu.Unlock()
+
+	h.Observe(d.Seconds())
+}
+
+// writeMetaLatency writes the metadata request histograms ordered by
+// subject and status
+func (m *Metrics) writeMetaLatency(w io.Writer) {
+	const name = "edg_meta_request_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Metadata request handling time by subject and response status.\n# TYPE %s histogram\n", name, name)
+
+	m.metaMu.Lock()
+	series := make([]metaSeries, 0, len(m.metaLatency))
+	histograms := make(map[metaSeries]*Histogram, len(m.metaLatency))
+	for s, h := range m.metaLatency {
+		series = append(series, s)
+		histograms[s] = h
+	}
+	m.metaMu.Unlock()
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].subject != series[j].subject {
+			return series[i].subject < series[j].subject
+		}
+		return series[i].status < series[j].status
+	})
+	for _, s := range series {
+		labels := fmt.Sprintf("subject=%q,status=%q", s.subject, s.status)
+		buckets, sum := histograms[s].snapshot()
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", name, labels, bound, buckets[i])
+		}
+		count := buckets[len(buckets)-1]
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, count)
+		fmt.Fprintf(w, "%s_sum{%s} %g\n%s_count{%s} %d\n", name, labels, sum, name, labels, count)
+	}
+}
+
+// namedCounter pairs a counter with its exported name and help text
+type namedCounter struct {
+	name string
+	help string
+	c    *Counter
+}
+
+// counters lists every counter in exposition order
+func (m *Metrics) counters() []namedCounter {
+	return []namedCounter{
+		{"edg_messages_received_total", "Asset data messages received.", &m.MessagesReceived},
+		{"edg_validation_failures_total", "Asset data messages rejected by template validation.", &m.ValidationFailures},
+		{"edg_jetstream_publish_errors_total", "JetStream publishes that failed on every attempt.", &m.PublishErrors},
+		{"edg_jetstream_publish_retries_total", "JetStream publish attempts that were retried.", &m.PublishRetries},
+		{"edg_dead_letters_total", "Messages written to the dead-letter destination.", &m.DeadLetters},
+		{"edg_assets_auto_registered_total", "Assets registered automatically from the data path.", &m.AssetsAutoRegistered},
+		{"edg_dedup_suppressed_total", "Tag values dropped as unchanged repeats.", &m.DedupSuppressed},
+		{"edg_rate_limited_total", "Asset data messages dropped by the per-asset rate limit.", &m.RateLimited},
+		{"edg_unregistered_data_total", "Asset data messages from unknown assets diverted because auto-registration is off.", &m.UnregisteredData},
+		{"edg_duplicates_skipped_total", "Asset data messages dropped as redeliveries in idempotent mode.", &m.DuplicatesSkipped},
+		{"edg_unknown_quality_total", "Tag values with an unrecognized quality, treated as uncertain.", &m.UnknownQuality},
+		{"edg_unknown_schema_total", "Data messages in an unknown schema version.", &m.UnknownSchema},
+		{"edg_edge_events_total", "Rising edges detected on edge-tracked FLAG tags.", &m.EdgeEvents},
+		{"edg_values_coerced_total", "TEXT tag values converted to the template's NUMBER or FLAG type.", &m.ValuesCoerced},
+		{"edg_oversized_messages_total", "Data messages dropped for exceeding the maximum payload size.", &m.OversizedMessages},
+		{"edg_quality_rejected_total", "Tag values rejected for falling below the minimum quality.", &m.QualityRejected},
+		{"edg_meta_requests_total", "Metadata requests handled.", &m.MetaRequests},
+		{"edg_stream_dropped_total", "Validated data messages dropped for /stream clients that fell behind.", &m.StreamDropped},
+	}
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, c := range m.counters() {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.c.Value())
+	}
+	m.writeMetaLatency(bw)
+
+	m.mu.RLock()
+	gauges := make([]gaugeFunc, 0, len(m.gauges))
+	for _, g := range m.gauges {
+		gauges = append(gauges, g)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(gauges, func(i, j int) bool { return gauges[i].name < gauges[j].name })
+	for _, g := range gauges {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", g.name, g.help, g.name, g.name, g.fn())
+	}
+
+	return bw.Flush()
+}
+
+// Handler returns an http.Handler serving the metrics for /metrics
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := m.WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
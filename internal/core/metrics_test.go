@@ -0,0 +1,124 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMetrics_WritePrometheus tests the text exposition output
+func TestMetrics_WritePrometheus(t *testing.T) {
+	m := NewMetrics()
+	m.MessagesReceived.Add(3)
+	m.PublishErrors.Inc()
+	m.RegisterGauge("edg_test_gauge", "A test gauge.", func() float64 { return 1.5 })
+
+	var buf bytes.Buffer
+	require.NoError(t, m.WritePrometheus(&buf))
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE edg_messages_received_total counter\nedg_messages_received_total 3\n")
+	assert.Contains(t, out, "edg_jetstream_publish_errors_total 1\n")
+	assert.Contains(t, out, "edg_validation_failures_total 0\n")
+	assert.Contains(t, out, "# TYPE edg_test_gauge gauge\nedg_test_gauge 1.5\n")
+}
+
+// TestMetrics_Handler tests the /metrics HTTP handler
+func TestMetrics_Handler(t *testing.T) {
+	m := NewMetrics()
+	m.AssetsAutoRegistered.Inc()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, rec.Body.String(), "edg_assets_auto_registered_total 1\n")
+}
+
+// TestMetrics_Histogram tests cumulative histogram buckets in the exposition
+func TestMetrics_Histogram(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveMetaRequest("platform.meta.asset.get", true, 2*time.Millisecond)
+	m.ObserveMetaRequest("platform.meta.asset.get", true, 10*time.Millisecond)
+	m.ObserveMetaRequest("platform.meta.asset.get", true, 10*time.Second)
+
+	var buf bytes.Buffer
+	require.NoError(t, m.WritePrometheus(&buf))
+	out := buf.String()
+
+	series := `{subject="platform.meta.asset.get",status="success"`
+	assert.Contains(t, out, "# TYPE edg_meta_request_duration_seconds histogram\n")
+	assert.Contains(t, out, "edg_meta_request_duration_seconds_bucket"+series+`,le="0.001"} 0`+"\n")
+	assert.Contains(t, out, "edg_meta_request_duration_seconds_bucket"+series+`,le="0.0025"} 1`+"\n")
+	assert.Contains(t, out, "edg_meta_request_duration_seconds_bucket"+series+`,le="0.01"} 2`+"\n")
+	assert.Contains(t, out, "edg_meta_request_duration_seconds_bucket"+series+`,le="5"} 2`+"\n")
+	assert.Contains(t, out, "edg_meta_request_duration_seconds_bucket"+series+`,le="+Inf"} 3`+"\n")
+	assert.Contains(t, out, "edg_meta_request_duration_seconds_sum"+series+"} 10.012\n")
+	assert.Contains(t, out, "edg_meta_request_duration_seconds_count"+series+"} 3\n")
+}
+
+// TestMetrics_MetaRequestLatency tests that every metadata subject is timed
+// with the status of its response
+func TestMetrics_MetaRequestLatency(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	m := handler.metrics
+
+	resp := request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-1"})
+	require.True(t, resp.Success)
+	resp = request(t, nc, SubjectAssetGet, GetAssetRequest{ID: "missing"})
+	require.False(t, resp.Success)
+	resp = request(t, nc, SubjectAssetGet, GetAssetRequest{ID: "missing"})
+	require.False(t, resp.Success)
+
+	// Latency is recorded after the reply is sent
+	require.Eventually(t, func() bool {
+		var buf bytes.Buffer
+		require.NoError(t, m.WritePrometheus(&buf))
+		out := buf.String()
+		return strings.Contains(out, `edg_meta_request_duration_seconds_count{subject="platform.meta.asset.create",status="success"} 1`) &&
+			strings.Contains(out, `edg_meta_request_duration_seconds_count{subject="platform.meta.asset.get",status="error"} 2`)
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestMetrics_DataHandlerCounters tests counters incremented by HandleAssetData
+func TestMetrics_DataHandlerCounters(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	loader := NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("testdata/valid_template.yaml"))
+
+	m := NewMetrics()
+	handler := NewDataHandler(nil, store)
+	handler.SetTemplateLoader(loader)
+	handler.SetMetrics(m)
+
+	temp := 20.0
+	valid, err := json.Marshal(&AssetData{AssetID: "new-sensor", Values: []TagValue{{Name: "temperature", Number: &temp}}})
+	require.NoError(t, err)
+	handler.HandleAssetData(&nats.Msg{Data: valid})
+
+	require.NoError(t, store.UpdateAssetTemplate("new-sensor", "test-sensor"))
+	text := "hot"
+	invalid, err := json.Marshal(&AssetData{AssetID: "new-sensor", Values: []TagValue{{Name: "temperature", Text: &text}}})
+	require.NoError(t, err)
+	handler.HandleAssetData(&nats.Msg{Data: invalid})
+
+	assert.Equal(t, uint64(2), m.MessagesReceived.Value())
+	assert.Equal(t, uint64(1), m.AssetsAutoRegistered.Value())
+	assert.Equal(t, uint64(1), m.ValidationFailures.Value())
+
+	var buf bytes.Buffer
+	require.NoError(t, m.WritePrometheus(&buf))
+	assert.Contains(t, buf.String(), "edg_data_stored 1\n")
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultNamePattern allows letters, digits, '-', '_' and '.'
+const DefaultNamePattern = `^[A-Za-z0-9._-]+$`
+
+// NamePolicy restricts the asset and template names accepted by the meta
+// handlers. Names are stored in unique indexes and written to logs, so
+// control characters and oversized names are rejected up front.
+type NamePolicy struct {
+	MaxLength int            // bytes; zero disables the limit
+	Pattern   *regexp.Regexp // nil allows any characters
+}
+
+// DefaultNamePolicy returns the policy used by NewMetaHandler
+func DefaultNamePolicy() NamePolicy {
+	return NamePolicy{
+		MaxLength: 128,
+		Pattern:   regexp.MustCompile(DefaultNamePattern),
+	}
+}
+
+// normalize trims surrounding whitespace from name and checks the result.
+// field names the value in errors. An empty name is returned as is for the
+// caller to decide whether it is required.
+func (p NamePolicy) normalize(field, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return name, nil
+	}
+	if p.MaxLength > 0 && len(name) > p.MaxLength {
+		return "", errorf(ErrInvalid, "%s exceeds max length of %d bytes", field, p.MaxLength)
+	}
+	if p.Pattern != nil && !p.Pattern.MatchString(name) {
+		return "", errorf(ErrInvalid, "%s %q does not match %s", field, name, p.Pattern)
+	}
+	return name, nil
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNamePolicy_Normalize tests trimming, the length limit and the pattern
+func TestNamePolicy_Normalize(t *testing.T) {
+	p := DefaultNamePolicy()
+
+	name, err := p.normalize("name", "  pump-1.a_b\t")
+	require.NoError(t, err)
+	assert.Equal(t, "pump-1.a_b", name)
+
+	name, err = p.normalize("name", "   ")
+	require.NoError(t, err)
+	assert.Empty(t, name)
+
+	// Exactly at the limit passes, one byte over fails
+	name, err = p.normalize("name", strings.Repeat("a", p.MaxLength))
+	require.NoError(t, err)
+	assert.Len(t, name, p.MaxLength)
+	_, err = p.normalize("name", strings.Repeat("a", p.MaxLength+1))
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.ErrorContains(t, err, "name exceeds max length of 128 bytes")
+
+	// Trimming happens before the length check
+	_, err = p.normalize("name", " "+strings.Repeat("a", p.MaxLength)+" ")
+	assert.NoError(t, err)
+
+	for _, bad := range []string{"pump 1", "pump\x00", "line\n1", "pump/1", "温度"} {
+		_, err := p.normalize("name", bad)
+		assert.ErrorIs(t, err, ErrInvalid, bad)
+	}
+
+	custom := NamePolicy{MaxLength: 0, Pattern: regexp.MustCompile(`^[a-z ]+$`)}
+	_, err = custom.normalize("name", "pump one"+strings.Repeat("x", 1000))
+	assert.NoError(t, err)
+	_, err = NamePolicy{}.normalize("name", "anything\tgoes")
+	assert.NoError(t, err)
+}
+
+// TestHandleAssetCreate_NamePolicy tests that handlers trim names and reject
+// names breaking the policy with ERR_VALIDATION
+func TestHandleAssetCreate_NamePolicy(t *testing.T) {
+	handler, nc := newTestMetaHandler(t)
+	max := handler.names.MaxLength
+
+	resp := request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "  pump-1 ", TemplateName: " test-sensor "})
+	require.True(t, resp.Success, resp.Error)
+	var asset Asset
+	require.NoError(t, json.Unmarshal(resp.Data, &asset))
+	assert.Equal(t, "pump-1", asset.Name)
+	assert.Equal(t, "test-sensor", asset.TemplateName)
+
+	resp = request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: strings.Repeat("n", max)})
+	assert.True(t, resp.Success, resp.Error)
+
+	resp = request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: strings.Repeat("n", max+1)})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump\x1b[31m"})
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetCreate, CreateAssetRequest{Name: "pump-2", TemplateName: "temp sensor"})
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	resp = request(t, nc, SubjectAssetBatch, BatchCreateAssetsRequest{Assets: []CreateAssetRequest{
+		{Name: "ok-1"}, {Name: "bad name"},
+	}})
+	assert.False(t, resp.Success)
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+	var itemErr BatchItemError
+	require.NoError(t, json.Unmarshal(resp.Data, &itemErr))
+	assert.Equal(t, 1, itemErr.Index)
+	assert.Equal(t, "bad name", itemErr.Name)
+
+	renamed := " pump-renamed "
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: asset.ID, Name: &renamed})
+	require.True(t, resp.Success, resp.Error)
+	stored, err := handler.store.GetAsset(asset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pump-renamed", stored.Name)
+
+	tooLong := strings.Repeat("n", max+1)
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: asset.ID, Name: &tooLong})
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+
+	blank := "  "
+	resp = request(t, nc, SubjectAssetUpdate, UpdateAssetRequest{ID: asset.ID, Name: &blank})
+	assert.Equal(t, ErrCodeValidation, resp.ErrorCode)
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxQueryBody bounds the JSON body of a /query request
+const maxQueryBody = 1 << 20
+
+// QueryRequest selects the stored readings of one asset served by
+// QueryHandler. From and To are unix milliseconds, as Grafana's ${__from}
+// and ${__to} expand to.
+type QueryRequest struct {
+	AssetID string   `json:"asset_id"`
+	Tag     string   `json:"tag,omitempty"`
+	Tags    []string `json:"tags,omitempty"` // more tags, each its own series
+	From    int64    `json:"from"`
+	To      int64    `json:"to"`
+
+	// The bucket width is Interval, a Go duration such as "1m", or
+	// IntervalMs, as Grafana's ${__interval_ms} expands to
+	Interval   string `json:"interval,omitempty"`
+	IntervalMs int64  `json:"interval_ms,omitempty"`
+}
+
+// QuerySeries is one tag's time series in the SimpleJSON shape. Each point
+// is [average, bucket start in unix ms]; the average is null for buckets
+// without readings so graphs show a gap.
+type QuerySeries struct {
+	Target     string   `json:"target"`
+	Datapoints [][2]any `json:"datapoints"`
+}
+
+// QueryHandler serves /query: the per-bucket averages of NUMBER tags of an
+// asset from the persisted data, so dashboards can chart history over HTTP
+// without speaking NATS. POST takes a QueryRequest as JSON; GET takes the
+// same fields as query parameters, with tag repeated for several tags.
+func QueryHandler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		switch r.Method {
+		case http.MethodPost:
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxQueryBody)).Decode(&req); err != nil {
+				writeQueryError(w, http.StatusBadRequest, "invalid request format")
+				return
+			}
+		case http.MethodGet:
+			var err error
+			if req, err = parseQueryParams(r); err != nil {
+				writeQueryError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeQueryError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		series, err := runQuery(store, req)
+		switch {
+		case errors.Is(err, ErrInvalid):
+			writeQueryError(w, http.StatusBadRequest, err.Error())
+			return
+		case err != nil:
+			coreLog().Error("failed to query data", "asset_id", req.AssetID, "error", err)
+			writeQueryError(w, http.StatusInternalServerError, "failed to query data")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(series); err != nil {
+			coreLog().Error("failed to write query response", "error", err)
+		}
+	})
+}
+
+// parseQueryParams reads a QueryRequest from the URL query of r
+func parseQueryParams(r *http.Request) (QueryRequest, error) {
+	params := r.URL.Query()
+	req := QueryRequest{
+		AssetID:  params.Get("asset_id"),
+		Tags:     params["tag"],
+		Interval: params.Get("interval"),
+	}
+	for name, dst := range map[string]*int64{"from": &req.From, "to": &req.To, "interval_ms": &req.IntervalMs} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return req, errors.New("invalid " + name + ": " + v)
+			}
+			*dst = n
+		}
+	}
+	return req, nil
+}
+
+// runQuery aggregates every requested tag with Store.AggregateData
+func runQuery(store *Store, req QueryRequest) ([]QuerySeries, error) {
+	tags := req.Tags
+	if req.Tag != "" {
+		tags = append([]string{req.Tag}, tags...)
+	}
+	if req.AssetID == "" || len(tags) == 0 {
+		return nil, errorf(ErrInvalid, "asset_id and tag are required")
+	}
+
+	bucket := time.Duration(req.IntervalMs) * time.Millisecond
+	if req.Interval != "" {
+		var err error
+		if bucket, err = time.ParseDuration(req.Interval); err != nil {
+			return nil, errorf(ErrInvalid, "invalid interval: %v", err)
+		}
+	}
+	if bucket == 0 {
+		return nil, errorf(ErrInvalid, "interval or interval_ms is required")
+	}
+
+	series := make([]QuerySeries, 0, len(tags))
+	for _, tag := range tags {
+		buckets, err := store.AggregateData(req.AssetID, tag, req.From, req.To, bucket)
+		if err != nil {
+			return nil, err
+		}
+		s := QuerySeries{Target: tag, Datapoints: make([][2]any, len(buckets))}
+		for i, b := range buckets {
+			s.Datapoints[i] = [2]any{b.Avg, b.Start}
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// writeQueryError writes {"error": msg} with code
+func writeQueryError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": msg}); err != nil {
+		coreLog().Error("failed to write query response", "error", err)
+	}
+}
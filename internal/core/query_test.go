@@ -0,0 +1,96 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveQuery runs the query handler and returns the status code and body
+func serveQuery(t *testing.T, store *Store, r *http.Request) (int, []byte) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	QueryHandler(store).ServeHTTP(rec, r)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	return rec.Code, rec.Body.Bytes()
+}
+
+// TestQueryHandler tests SimpleJSON series for several tags over POST and GET
+func TestQueryHandler(t *testing.T) {
+	store := newTestStore(t)
+	for i, ts := range []int64{0, 30_000, 120_000} {
+		temp, pressure := float64(i), float64(10*i)
+		require.NoError(t, store.InsertAssetData(&AssetData{
+			AssetID: "sensor-001", Timestamp: baseMillis + ts, Values: []TagValue{
+				{Name: "temperature", Number: &temp},
+				{Name: "pressure", Number: &pressure},
+			},
+		}))
+	}
+
+	body := fmt.Sprintf(`{"asset_id": "sensor-001", "tag": "temperature", "tags": ["pressure"], "from": %d, "to": %d, "interval": "1m"}`,
+		baseMillis, baseMillis+180_000)
+	code, data := serveQuery(t, store, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, code, string(data))
+	assert.JSONEq(t, fmt.Sprintf(`[
+		{"target": "temperature", "datapoints": [[0.5, %[1]d], [null, %[2]d], [2, %[3]d]]},
+		{"target": "pressure", "datapoints": [[5, %[1]d], [null, %[2]d], [20, %[3]d]]}
+	]`, baseMillis, baseMillis+60_000, baseMillis+120_000), string(data))
+
+	code, data = serveQuery(t, store, httptest.NewRequest(http.MethodGet,
+		fmt.Sprintf("/query?asset_id=sensor-001&tag=pressure&from=%d&to=%d&interval_ms=60000", baseMillis, baseMillis+120_000), nil))
+	require.Equal(t, http.StatusOK, code, string(data))
+	var series []QuerySeries
+	require.NoError(t, json.Unmarshal(data, &series))
+	require.Len(t, series, 1)
+	assert.Equal(t, "pressure", series[0].Target)
+	assert.Len(t, series[0].Datapoints, 2)
+}
+
+// TestQueryHandler_Seconds tests that readings stamped in unix seconds are
+// returned at their time in milliseconds
+func TestQueryHandler_Seconds(t *testing.T) {
+	store := newTestStore(t)
+	start := time.Now().Truncate(time.Minute)
+	for i, offset := range []time.Duration{0, 30 * time.Second, 2 * time.Minute} {
+		temp := float64(i)
+		require.NoError(t, store.InsertAssetData(&AssetData{
+			AssetID: "sensor-001", Timestamp: start.Add(offset).Unix(), Values: []TagValue{{Name: "temperature", Number: &temp}},
+		}))
+	}
+
+	code, data := serveQuery(t, store, httptest.NewRequest(http.MethodGet,
+		fmt.Sprintf("/query?asset_id=sensor-001&tag=temperature&from=%d&to=%d&interval=1m",
+			start.UnixMilli(), start.Add(3*time.Minute).UnixMilli()), nil))
+	require.Equal(t, http.StatusOK, code, string(data))
+	assert.JSONEq(t, fmt.Sprintf(`[{"target": "temperature", "datapoints": [[0.5, %d], [null, %d], [2, %d]]}]`,
+		start.UnixMilli(), start.Add(time.Minute).UnixMilli(), start.Add(2*time.Minute).UnixMilli()), string(data))
+}
+
+// TestQueryHandler_Errors tests the rejected requests
+func TestQueryHandler_Errors(t *testing.T) {
+	store := newTestStore(t)
+
+	for name, r := range map[string]*http.Request{
+		"malformed body":   httptest.NewRequest(http.MethodPost, "/query", strings.NewReader("{")),
+		"missing tag":      httptest.NewRequest(http.MethodGet, "/query?asset_id=a&to=1&interval=1s", nil),
+		"missing interval": httptest.NewRequest(http.MethodGet, "/query?asset_id=a&tag=t&to=1", nil),
+		"bad interval":     httptest.NewRequest(http.MethodGet, "/query?asset_id=a&tag=t&to=1&interval=often", nil),
+		"bad from":         httptest.NewRequest(http.MethodGet, "/query?asset_id=a&tag=t&from=now&interval=1s", nil),
+		"empty range":      httptest.NewRequest(http.MethodGet, "/query?asset_id=a&tag=t&interval=1s", nil),
+	} {
+		code, data := serveQuery(t, store, r)
+		assert.Equal(t, http.StatusBadRequest, code, name)
+		assert.Contains(t, string(data), `"error"`, name)
+	}
+
+	code, _ := serveQuery(t, store, httptest.NewRequest(http.MethodDelete, "/query", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// tokenBucket holds the remaining tokens of one asset
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a per-asset token bucket limiter. Each asset may send rate
+// messages per second with bursts of up to burst messages. A bucket left
+// idle for refill is full again, the same as a missing one, so such buckets
+// are swept out every refill to keep unknown asset IDs from piling up.
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	refill time.Duration // time for an empty bucket to fill up
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+// newRateLimiter creates a limiter allowing perSecond messages per asset,
+// with a burst of one second's worth of messages (at least one)
+func newRateLimiter(perSecond float64) *rateLimiter {
+	burst := perSecond
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    perSecond,
+		burst:   burst,
+		refill:  time.Duration(burst / perSecond * float64(time.Second)),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow reports whether a message from assetID may be processed at now,
+// consuming a token if so
+func (l *rateLimiter) allow(assetID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) >= l.refill {
+		maps.DeleteFunc(l.buckets, func(_ string, b *tokenBucket) bool {
+			return now.Sub(b.last) >= l.refill
+		})
+		l.swept = now
+	}
+
+	b, ok := l.buckets[assetID]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[assetID] = b
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHandleAssetData_RateLimit tests that messages above the per-asset rate are dropped and counted
+func TestHandleAssetData_RateLimit(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+	handler.SetRateLimit(2)
+
+	// Burst of 2 per asset; the rest within the same instant are dropped
+	for i := 0; i < 5; i++ {
+		handler.HandleAssetData(dataMsg(t, "noisy", float64(i), "good"))
+	}
+	handler.HandleAssetData(dataMsg(t, "quiet", 1, "good"))
+
+	assert.Equal(t, 3, handler.GetDataCount())
+	assert.Equal(t, uint64(3), handler.metrics.RateLimited.Value())
+}
+
+// TestHandleAssetData_RateLimitDisabledByDefault tests that the handler is unlimited by default
+func TestHandleAssetData_RateLimitDisabledByDefault(t *testing.T) {
+	handler := NewDataHandler(nil, nil)
+
+	for i := 0; i < 20; i++ {
+		handler.HandleAssetData(dataMsg(t, "sensor-001", float64(i), "good"))
+	}
+
+	assert.Equal(t, 20, handler.GetDataCount())
+	assert.Equal(t, uint64(0), handler.metrics.RateLimited.Value())
+}
+
+// TestRateLimiter_Refill tests token refill over time
+func TestRateLimiter_Refill(t *testing.T) {
+	l := newRateLimiter(1)
+	start := time.Now()
+
+	assert.True(t, l.allow("a", start))
+	assert.False(t, l.allow("a", start.Add(500*time.Millisecond)))
+	assert.True(t, l.allow("a", start.Add(1100*time.Millisecond)))
+
+	// Idle time does not accumulate beyond the burst
+	assert.True(t, l.allow("a", start.Add(time.Hour)))
+	assert.False(t, l.allow("a", start.Add(time.Hour)))
+}
+
+// TestRateLimiter_SweepsIdleBuckets tests that buckets idle long enough to
+// be full again are dropped while draining ones are kept
+func TestRateLimiter_SweepsIdleBuckets(t *testing.T) {
+	l := newRateLimiter(1)
+	start := time.Now()
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, l.allow(fmt.Sprintf("random-%d", i), start))
+	}
+	assert.True(t, l.allow("noisy", start.Add(1500*time.Millisecond)))
+	assert.Len(t, l.buckets, 1)
+
+	// A bucket still refilling survives the next sweep and keeps limiting
+	assert.False(t, l.allow("noisy", start.Add(2200*time.Millisecond)))
+	assert.True(t, l.allow("other", start.Add(2600*time.Millisecond)))
+	assert.Len(t, l.buckets, 2)
+	assert.True(t, l.allow("noisy", start.Add(2600*time.Millisecond)))
+	assert.False(t, l.allow("noisy", start.Add(2600*time.Millisecond)))
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RelationTypeDefinition describes a relation type: its name and the
+// JSON-LD term relations of the type are exported as
+type RelationTypeDefinition struct {
+	Name      RelationType `yaml:"name"`
+	Predicate string       `yaml:"predicate"` // e.g. "ssn:isPartOf"; a prefix must be declared in edg-context.jsonld
+	Symmetric bool         `yaml:"symmetric"` // A -> B is the same edge as B -> A
+}
+
+// RelationTypesFile is the file format read by LoadRelationTypes
+type RelationTypesFile struct {
+	RelationTypes []RelationTypeDefinition `yaml:"relation_types"`
+}
+
+// builtinRelationTypes are always valid and cannot be redefined
+var builtinRelationTypes = []RelationTypeDefinition{
+	{Name: RelationPartOf, Predicate: "ssn:isPartOf"},
+	{Name: RelationConnectedTo, Predicate: "sosa:isHostedBy", Symmetric: true},
+	{Name: RelationLocatedIn, Predicate: "schema:containedInPlace"},
+	{Name: RelationMeasures, Predicate: "sosa:observes"},
+}
+
+// relationTypeNamePattern restricts configured names to identifiers like
+// the built-in ones
+var relationTypeNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// relationTypeRegistry holds the valid relation types in registration order
+type relationTypeRegistry struct {
+	mu    sync.RWMutex
+	order []RelationType
+	defs  map[RelationType]RelationTypeDefinition
+}
+
+// relationTypes is the registry consulted by IsValidRelationType and the
+// other relation type lookups
+var relationTypes = newRelationTypeRegistry()
+
+// newRelationTypeRegistry returns a registry of the built-in types
+func newRelationTypeRegistry() *relationTypeRegistry {
+	r := &relationTypeRegistry{defs: make(map[RelationType]RelationTypeDefinition)}
+	for _, def := range builtinRelationTypes {
+		r.order = append(r.order, def.Name)
+		r.defs[def.Name] = def
+	}
+	return r
+}
+
+// lookup returns the definition of rt
+func (r *relationTypeRegistry) lookup(rt RelationType) (RelationTypeDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	def, ok := r.defs[rt]
+	return def, ok
+}
+
+// names returns the registered types in registration order
+func (r *relationTypeRegistry) names() []RelationType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]RelationType(nil), r.order...)
+}
+
+// register adds defs after checking all of them; on error nothing is added
+func (r *relationTypeRegistry) register(defs []RelationTypeDefinition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[RelationType]bool, len(defs))
+	for i, def := range defs {
+		if !relationTypeNamePattern.MatchString(string(def.Name)) {
+			return fmt.Errorf("relation_types[%d]: invalid name %q", i, def.Name)
+		}
+		if _, exists := r.defs[def.Name]; exists || seen[def.Name] {
+			return fmt.Errorf("relation_types[%d]: relation type %s is already defined", i, def.Name)
+		}
+		if def.Predicate == "" {
+			return fmt.Errorf("relation_types[%d]: predicate is required", i)
+		}
+		seen[def.Name] = true
+	}
+	for _, def := range defs {
+		r.order = append(r.order, def.Name)
+		r.defs[def.Name] = def
+	}
+	return nil
+}
+
+// RegisterRelationTypes adds relation types to the built-in ones. It fails
+// without adding any type if a name is invalid or already defined. Types
+// should be registered at startup, before relations are written.
+func RegisterRelationTypes(defs ...RelationTypeDefinition) error {
+	return relationTypes.register(defs)
+}
+
+// LoadRelationTypes reads a RelationTypesFile and registers its types
+func LoadRelationTypes(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read relation types: %w", err)
+	}
+	var file RelationTypesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse relation types %s: %w", path, err)
+	}
+	if err := RegisterRelationTypes(file.RelationTypes...); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// relationPredicate returns the JSON-LD term of rt
+func relationPredicate(rt RelationType) (string, bool) {
+	def, ok := relationTypes.lookup(rt)
+	return def.Predicate, ok
+}
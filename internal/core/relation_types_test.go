@@ -0,0 +1,91 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resetRelationTypes restores the built-in relation types when t ends
+func resetRelationTypes(t *testing.T) {
+	t.Cleanup(func() { relationTypes = newRelationTypeRegistry() })
+}
+
+// TestLoadRelationTypes tests that configured types are merged with the built-ins
+func TestLoadRelationTypes(t *testing.T) {
+	resetRelationTypes(t)
+	require.NoError(t, LoadRelationTypes("testdata/relation_types.yaml"))
+
+	assert.Equal(t, []RelationType{
+		RelationPartOf, RelationConnectedTo, RelationLocatedIn, RelationMeasures, "feeds", "pairedWith",
+	}, ValidRelationTypes())
+	assert.True(t, IsValidRelationType("feeds"))
+	assert.True(t, IsValidRelationType(RelationPartOf))
+	assert.False(t, IsSymmetricRelationType("feeds"))
+	assert.True(t, IsSymmetricRelationType("pairedWith"))
+	assert.False(t, IsHierarchicalRelationType("feeds"))
+
+	predicate, ok := relationPredicate("pairedWith")
+	assert.True(t, ok)
+	assert.Equal(t, "schema:isSimilarTo", predicate)
+
+	schema, err := GenerateSchema("AssetRelation")
+	require.NoError(t, err)
+	assert.Contains(t, schema.Properties["relation_type"].Enum, "feeds")
+}
+
+// TestRegisterRelationTypes_Rejected tests that invalid definitions add nothing
+func TestRegisterRelationTypes_Rejected(t *testing.T) {
+	resetRelationTypes(t)
+
+	tests := []struct {
+		name string
+		defs []RelationTypeDefinition
+		err  string
+	}{
+		{"built-in name", []RelationTypeDefinition{{Name: RelationPartOf, Predicate: "ex:part"}}, "already defined"},
+		{"duplicate in file", []RelationTypeDefinition{
+			{Name: "feeds", Predicate: "ex:feeds"}, {Name: "feeds", Predicate: "ex:feeds"},
+		}, "already defined"},
+		{"empty name", []RelationTypeDefinition{{Predicate: "ex:feeds"}}, "invalid name"},
+		{"bad name", []RelationTypeDefinition{{Name: "feeds into", Predicate: "ex:feeds"}}, "invalid name"},
+		{"no predicate", []RelationTypeDefinition{{Name: "feeds"}}, "predicate is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, RegisterRelationTypes(tt.defs...), tt.err)
+			assert.Len(t, ValidRelationTypes(), len(builtinRelationTypes))
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "types.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("relation_types: [{name: partOf, predicate: 'ex:p'}]"), 0o644))
+	assert.ErrorContains(t, LoadRelationTypes(path), "relation type partOf is already defined")
+	assert.Error(t, LoadRelationTypes(filepath.Join(t.TempDir(), "missing.yaml")))
+}
+
+// TestCreateRelation_RegisteredType tests that the store accepts, canonicalizes
+// and exports a configured relation type
+func TestCreateRelation_RegisteredType(t *testing.T) {
+	resetRelationTypes(t)
+	require.NoError(t, LoadRelationTypes("testdata/relation_types.yaml"))
+	store := newTestStore(t)
+	createTestAssets(t, store, "a", "b")
+
+	require.NoError(t, store.CreateRelation(&AssetRelation{
+		ID: "r1", SourceAssetID: "b", TargetAssetID: "a", RelationType: "pairedWith",
+	}))
+	rel, err := store.GetRelation("r1")
+	require.NoError(t, err)
+	assert.Equal(t, "a", rel.SourceAssetID, "symmetric relations are stored in canonical order")
+
+	err = store.CreateRelation(&AssetRelation{ID: "r2", SourceAssetID: "a", TargetAssetID: "b", RelationType: "undefined"})
+	assert.ErrorIs(t, err, ErrInvalidRelationType)
+
+	doc, err := ExportAssetGraph(store)
+	require.NoError(t, err)
+	assert.Contains(t, string(doc), "schema:isSimilarTo")
+}
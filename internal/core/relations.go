@@ -1,44 +1,107 @@
 package core
 
-import "time"
-
-// RelationType represents the type of relationship between assets
-type RelationType string
-
-const (
-	// RelationPartOf indicates a hierarchical relationship (ssn:isPartOf)
-	RelationPartOf RelationType = "partOf"
-	// RelationConnectedTo indicates a peer/network connection (sosa:isHostedBy)
-	RelationConnectedTo RelationType = "connectedTo"
-	// RelationLocatedIn indicates spatial containment (schema:containedInPlace)
-	RelationLocatedIn RelationType = "locatedIn"
+import (
+	"encoding/json"
+	"math"
 )
 
-// AssetRelation represents a relationship between two assets
-type AssetRelation struct {
-	ID            string            `json:"id"`
-	SourceAssetID string            `json:"source_asset_id"`
-	TargetAssetID string            `json:"target_asset_id"`
-	RelationType  RelationType      `json:"relation_type"`
-	CreatedAt     time.Time         `json:"created_at"`
-	Metadata      map[string]string `json:"metadata,omitempty"`
+// validateRelationAttributes checks encoded relation attributes: keys must
+// not be empty and values must be strings, numbers or booleans
+func validateRelationAttributes(encoded []byte) error {
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &attributes); err != nil {
+		return errorf(ErrInvalid, "invalid relation attributes: %v", err)
+	}
+	for key, raw := range attributes {
+		if key == "" {
+			return errorf(ErrInvalid, "relation attribute key must not be empty")
+		}
+		switch raw[0] {
+		case '{', '[', 'n':
+			return errorf(ErrInvalid, "relation attribute %s must be a string, number or boolean", key)
+		}
+	}
+	return nil
+}
+
+// validateRelationWeight rejects weights that cannot be stored or exported
+func validateRelationWeight(weight *float64) error {
+	if weight != nil && (math.IsNaN(*weight) || math.IsInf(*weight, 0)) {
+		return errorf(ErrInvalid, "relation weight must be finite")
+	}
+	return nil
 }
 
-// IsValidRelationType checks if a RelationType is valid
+// IsValidRelationType checks if a RelationType is built in or registered
 func IsValidRelationType(rt RelationType) bool {
+	_, ok := relationTypes.lookup(rt)
+	return ok
+}
+
+// ValidRelationTypes returns all valid relation types, the built-in ones
+// first
+func ValidRelationTypes() []RelationType {
+	return relationTypes.names()
+}
+
+// IsHierarchicalRelationType reports whether relations of this type form a
+// hierarchy that must stay acyclic
+func IsHierarchicalRelationType(rt RelationType) bool {
 	switch rt {
-	case RelationPartOf, RelationConnectedTo, RelationLocatedIn:
+	case RelationPartOf, RelationLocatedIn:
 		return true
 	default:
 		return false
 	}
 }
 
-// ValidRelationTypes returns all valid relation types
-func ValidRelationTypes() []RelationType {
-	return []RelationType{
-		RelationPartOf,
-		RelationConnectedTo,
-		RelationLocatedIn,
+// IsSymmetricRelationType reports whether A -> B of this type implies B -> A,
+// so both directions are one logical edge
+func IsSymmetricRelationType(rt RelationType) bool {
+	def, _ := relationTypes.lookup(rt)
+	return def.Symmetric
+}
+
+// CanonicalizeRelation orders the assets of a symmetric relation so the
+// source ID sorts before the target ID. A -> B and B -> A are then stored
+// as the same row and the unique constraint rejects the second. Relations
+// of other types are left untouched.
+func CanonicalizeRelation(relation *AssetRelation) {
+	if IsSymmetricRelationType(relation.RelationType) && relation.TargetAssetID < relation.SourceAssetID {
+		relation.SourceAssetID, relation.TargetAssetID = relation.TargetAssetID, relation.SourceAssetID
+	}
+}
+
+// symmetricRelationTypes returns the valid relation types that are symmetric
+func symmetricRelationTypes() []RelationType {
+	var types []RelationType
+	for _, rt := range ValidRelationTypes() {
+		if IsSymmetricRelationType(rt) {
+			types = append(types, rt)
+		}
+	}
+	return types
+}
+
+// DedupeSymmetricRelations drops relations of symmetric types whose pair of
+// assets already appeared in either direction. Other relations are kept
+// as-is and the order is preserved.
+func DedupeSymmetricRelations(relations []*AssetRelation) []*AssetRelation {
+	seen := make(map[[3]string]bool)
+	deduped := relations[:0:0]
+	for _, rel := range relations {
+		if IsSymmetricRelationType(rel.RelationType) {
+			a, b := rel.SourceAssetID, rel.TargetAssetID
+			if b < a {
+				a, b = b, a
+			}
+			key := [3]string{string(rel.RelationType), a, b}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		deduped = append(deduped, rel)
 	}
+	return deduped
 }
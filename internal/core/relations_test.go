@@ -15,6 +15,7 @@ func TestRelationType_IsValid_ValidTypes(t *testing.T) {
 		RelationPartOf,
 		RelationConnectedTo,
 		RelationLocatedIn,
+		RelationMeasures,
 	}
 
 	for _, relType := range validTypes {
@@ -44,10 +45,11 @@ func TestRelationType_IsValid_InvalidType(t *testing.T) {
 func TestValidRelationTypes_ReturnsAll(t *testing.T) {
 	validTypes := ValidRelationTypes()
 
-	assert.Len(t, validTypes, 3, "expected 3 valid relation types")
+	assert.Len(t, validTypes, 4, "expected 4 valid relation types")
 	assert.Contains(t, validTypes, RelationPartOf)
 	assert.Contains(t, validTypes, RelationConnectedTo)
 	assert.Contains(t, validTypes, RelationLocatedIn)
+	assert.Contains(t, validTypes, RelationMeasures)
 }
 
 // TestAssetRelation_JSONSerialization tests JSON marshaling and unmarshaling
@@ -100,3 +102,38 @@ func TestAssetRelation_JSONSerialization_OmitsNilMetadata(t *testing.T) {
 	jsonStr := string(jsonData)
 	assert.NotContains(t, jsonStr, "metadata", "metadata field should be omitted when nil")
 }
+
+// TestIsHierarchicalRelationType tests which relation types must stay acyclic
+func TestIsHierarchicalRelationType(t *testing.T) {
+	assert.True(t, IsHierarchicalRelationType(RelationPartOf))
+	assert.True(t, IsHierarchicalRelationType(RelationLocatedIn))
+	assert.False(t, IsHierarchicalRelationType(RelationConnectedTo))
+}
+
+// TestIsSymmetricRelationType tests which relation types are direction-free
+func TestIsSymmetricRelationType(t *testing.T) {
+	assert.True(t, IsSymmetricRelationType(RelationConnectedTo))
+	assert.False(t, IsSymmetricRelationType(RelationPartOf))
+	assert.False(t, IsSymmetricRelationType(RelationLocatedIn))
+	assert.False(t, IsSymmetricRelationType(RelationMeasures))
+}
+
+// TestDedupeSymmetricRelations tests that only symmetric reverses are dropped
+func TestDedupeSymmetricRelations(t *testing.T) {
+	relations := []*AssetRelation{
+		{ID: "r1", SourceAssetID: "a", TargetAssetID: "b", RelationType: RelationConnectedTo},
+		{ID: "r2", SourceAssetID: "b", TargetAssetID: "a", RelationType: RelationConnectedTo},
+		{ID: "r3", SourceAssetID: "a", TargetAssetID: "b", RelationType: RelationMeasures},
+		{ID: "r4", SourceAssetID: "b", TargetAssetID: "a", RelationType: RelationMeasures},
+		{ID: "r5", SourceAssetID: "a", TargetAssetID: "c", RelationType: RelationConnectedTo},
+	}
+
+	deduped := DedupeSymmetricRelations(relations)
+
+	ids := make([]string, 0, len(deduped))
+	for _, rel := range deduped {
+		ids = append(ids, rel.ID)
+	}
+	assert.Equal(t, []string{"r1", "r3", "r4", "r5"}, ids)
+	assert.Len(t, relations, 5, "input must not be modified")
+}
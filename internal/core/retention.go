@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultPruneInterval is how often cmd/core prunes expired data when a
+// retention is configured
+const DefaultPruneInterval = time.Hour
+
+// pruneBatchSize bounds the rows deleted per statement. Each batch commits
+// on its own, so writers on the data path wait for one batch at most.
+const pruneBatchSize = 5000
+
+// PruneDataOlderThan deletes persisted data with a timestamp before t and
+// returns the number of rows deleted. Rows are deleted in batches over
+// idx_asset_data_ts.
+func (s *Store) PruneDataOlderThan(t time.Time) (int64, error) {
+	var total int64
+	for {
+		result, err := s.db.Exec(
+			`DELETE FROM asset_data WHERE id IN (
+				SELECT id FROM asset_data WHERE timestamp < ? LIMIT ?)`,
+			t.UnixMilli(), pruneBatchSize,
+		)
+		if err != nil {
+			return total, fmt.Errorf("failed to prune asset data: %w", err)
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return total, fmt.Errorf("failed to prune asset data: %w", err)
+		}
+		total += deleted
+		if deleted < pruneBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// dataPruner deletes expired persisted data
+type dataPruner interface {
+	PruneDataOlderThan(t time.Time) (int64, error)
+}
+
+// DataPruner periodically deletes data older than the retention
+type DataPruner struct {
+	store     dataPruner
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewDataPruner creates a pruner that keeps retention worth of data in
+// store, pruning every interval once Run is started
+func NewDataPruner(store *Store, retention, interval time.Duration) *DataPruner {
+	return &DataPruner{store: store, retention: retention, interval: interval}
+}
+
+// Prune deletes data older than the retention and returns how many rows
+// were deleted
+func (p *DataPruner) Prune() (int64, error) {
+	return p.store.PruneDataOlderThan(time.Now().Add(-p.retention))
+}
+
+// Run prunes once immediately and then every interval until ctx is done
+func (p *DataPruner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		deleted, err := p.Prune()
+		if err != nil {
+			coreLog().Warn("failed to prune asset data", "error", err, "deleted", deleted)
+		} else {
+			coreLog().Info("pruned asset data", "deleted", deleted, "retention", p.retention, "duration", time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
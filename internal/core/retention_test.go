@@ -0,0 +1,36 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPruneDataOlderThan tests that only data before the cutoff is deleted,
+// whether its timestamp arrived in unix seconds or milliseconds
+func TestPruneDataOlderThan(t *testing.T) {
+	store := newTestStore(t)
+
+	now := time.Now().Truncate(time.Second)
+	old := now.Add(-48 * time.Hour)
+	for _, ts := range []int64{old.UnixMilli(), old.Unix(), now.UnixMilli(), now.Unix()} {
+		require.NoError(t, store.InsertAssetData(&AssetData{AssetID: "sensor-001", Timestamp: ts}))
+	}
+
+	deleted, err := store.PruneDataOlderThan(now.Add(-24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	kept, err := store.QueryAssetData("sensor-001", 0, now.UnixMilli())
+	require.NoError(t, err)
+	require.Len(t, kept, 2)
+	assert.Equal(t, now.UnixMilli(), kept[0].Timestamp)
+	assert.Equal(t, now.UnixMilli(), kept[1].Timestamp)
+
+	// A second pass finds nothing left to prune
+	deleted, err = NewDataPruner(store, 24*time.Hour, DefaultPruneInterval).Prune()
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
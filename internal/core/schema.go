@@ -0,0 +1,203 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaDialect is the JSON Schema draft of generated documents
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaTypes lists the types served on SubjectSchema, by Go type name.
+// Schemas are reflected from the types themselves so they cannot drift.
+var schemaTypes = indexSchemaTypes(
+	// Entities and the data envelope
+	Asset{}, AssetTemplate{}, AssetResource{}, AssetRelation{},
+	AssetData{}, TagValue{}, Snapshot{}, DataBucket{}, EdgeEvent{},
+	AssetDataBatch{}, RejectedData{}, StoreStats{}, Response{},
+	AssetEvent{}, RelationEvent{},
+
+	// Requests and replies
+	CreateAssetRequest{}, BatchCreateAssetsRequest{}, BatchCreateAssetsResponse{},
+	BatchItemError{}, GetAssetRequest{}, AssetWithDegree{}, ListAssetsRequest{}, ListAssetsResponse{},
+	SearchAssetsRequest{}, StaleAssetsRequest{}, DeleteAssetRequest{},
+	RestoreAssetRequest{}, CloneAssetRequest{}, UpdateAssetRequest{}, ValidateDataRequest{},
+	ValidateDataResponse{}, LatestDataRequest{}, AggregateDataRequest{},
+	CreateRelationRequest{}, BatchCreateRelationsRequest{},
+	BatchCreateRelationsResponse{}, GetRelationRequest{}, RelationExistsRequest{},
+	RelationExistsResponse{}, RelationsBetweenRequest{}, RelationBetween{}, RelationCountRequest{}, RelationCount{}, ListRelationsRequest{}, ListAllRelationsRequest{},
+	ListAllRelationsResponse{}, DeleteRelationRequest{}, UpdateRelationRequest{},
+	RelationTreeRequest{}, ImportSnapshotRequest{}, SchemaRequest{},
+	CheckRequest{}, IntegrityReport{}, TemplateReloadResult{},
+)
+
+// typeEnums restricts string types to their valid values
+var typeEnums = map[reflect.Type]func() []string{
+	reflect.TypeOf(RelationType("")): relationTypeNames, // read per request to include registered types
+	reflect.TypeOf(Quality("")): func() []string {
+		return []string{string(QualityGood), string(QualityUncertain), string(QualityBad)}
+	},
+}
+
+// fieldEnums restricts plain string fields, keyed by type name and JSON name
+var fieldEnums = map[string][]string{
+	"AssetResource.valueType": {ValueTypeNumber, ValueTypeText, ValueTypeFlag},
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func indexSchemaTypes(values ...interface{}) map[string]reflect.Type {
+	types := make(map[string]reflect.Type, len(values))
+	for _, v := range values {
+		t := reflect.TypeOf(v)
+		types[t.Name()] = t
+	}
+	return types
+}
+
+func relationTypeNames() []string {
+	names := make([]string, 0, len(ValidRelationTypes()))
+	for _, rt := range ValidRelationTypes() {
+		names = append(names, string(rt))
+	}
+	return names
+}
+
+// SchemaTypeNames returns the names of the types with a schema, sorted
+func SchemaTypeNames() []string {
+	names := make([]string, 0, len(schemaTypes))
+	for name := range schemaTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenerateSchema returns the JSON Schema of the named API type. Nested
+// structs are described in $defs and referenced by name.
+func GenerateSchema(name string) (*JSONSchema, error) {
+	t, ok := schemaTypes[name]
+	if !ok {
+		return nil, errorf(ErrNotFound, "no schema for type: %s", name)
+	}
+
+	g := &schemaGenerator{defs: make(map[string]*JSONSchema)}
+	schema := g.structSchema(t)
+	schema.Schema = SchemaDialect
+	schema.Title = name
+	if len(g.defs) > 0 {
+		schema.Defs = g.defs
+	}
+	return schema, nil
+}
+
+// schemaGenerator collects the definitions of nested structs
+type schemaGenerator struct {
+	root reflect.Type
+	defs map[string]*JSONSchema
+}
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if enum, ok := typeEnums[t]; ok {
+		return &JSONSchema{Type: "string", Enum: enum()}
+	}
+
+	switch {
+	case t == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &JSONSchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &JSONSchema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.ref(t)
+	default:
+		// interface{} and anything else accepts any JSON value
+		return &JSONSchema{}
+	}
+}
+
+// ref describes a nested struct in $defs and returns a reference to it
+func (g *schemaGenerator) ref(t reflect.Type) *JSONSchema {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+	if t == g.root {
+		return &JSONSchema{Ref: "#"}
+	}
+	if _, ok := g.defs[t.Name()]; !ok {
+		g.defs[t.Name()] = nil // placeholder so recursive types terminate
+		g.defs[t.Name()] = g.structSchema(t)
+	}
+	return &JSONSchema{Ref: "#/$defs/" + t.Name()}
+}
+
+// structSchema describes the JSON fields of a struct, following the
+// encoding/json rules for tags, unexported fields and embedding
+func (g *schemaGenerator) structSchema(t reflect.Type) *JSONSchema {
+	if g.root == nil {
+		g.root = t
+	}
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	g.addFields(schema, t)
+	return schema
+}
+
+func (g *schemaGenerator) addFields(schema *JSONSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		if enum, ok := fieldEnums[t.Name()+"."+name]; ok {
+			schema.Properties[name] = &JSONSchema{Type: "string", Enum: enum}
+			continue
+		}
+		schema.Properties[name] = g.schemaFor(field.Type)
+	}
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenerateSchema tests that schemas follow the JSON encoding of the Go types
+func TestGenerateSchema(t *testing.T) {
+	schema, err := GenerateSchema("AssetData")
+	require.NoError(t, err)
+	assert.Equal(t, SchemaDialect, schema.Schema)
+	assert.Equal(t, "object", schema.Type)
+	assert.Equal(t, "integer", schema.Properties["timestamp"].Type)
+	assert.Equal(t, "string", schema.Properties["metadata"].AdditionalProperties.Type)
+
+	values := schema.Properties["values"]
+	assert.Equal(t, "array", values.Type)
+	assert.Equal(t, "#/$defs/TagValue", values.Items.Ref)
+
+	tag := schema.Defs["TagValue"]
+	require.NotNil(t, tag)
+	assert.Equal(t, "number", tag.Properties["number"].Type)
+	assert.Equal(t, "boolean", tag.Properties["flag"].Type)
+	assert.Equal(t, []string{"good", "uncertain", "bad"}, tag.Properties["quality"].Enum)
+	assert.Equal(t, 0.0, *tag.Properties["status_code"].Minimum)
+
+	asset, err := GenerateSchema("Asset")
+	require.NoError(t, err)
+	assert.Equal(t, "date-time", asset.Properties["created_at"].Format)
+	assert.Equal(t, "date-time", asset.Properties["last_seen"].Format)
+	assert.Nil(t, asset.Defs)
+
+	_, err = GenerateSchema("MetaHandler")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+// TestGenerateSchema_Enums tests that relation and value types are listed as enums
+func TestGenerateSchema_Enums(t *testing.T) {
+	relation, err := GenerateSchema("CreateRelationRequest")
+	require.NoError(t, err)
+	assert.Equal(t, relationTypeNames(), relation.Properties["relation_type"].Enum)
+	assert.Len(t, relation.Properties["relation_type"].Enum, len(ValidRelationTypes()))
+
+	template, err := GenerateSchema("AssetTemplate")
+	require.NoError(t, err)
+	resource := template.Defs["AssetResource"]
+	require.NotNil(t, resource)
+	assert.Equal(t, []string{"NUMBER", "TEXT", "FLAG"}, resource.Properties["valueType"].Enum)
+}
+
+// TestGenerateSchema_AllTypes tests that every registered type covers its JSON fields
+func TestGenerateSchema_AllTypes(t *testing.T) {
+	for _, name := range SchemaTypeNames() {
+		schema, err := GenerateSchema(name)
+		require.NoError(t, err, name)
+
+		// Marshal the zero value and check each emitted key is described
+		encoded, err := json.Marshal(reflect.New(schemaTypes[name]).Interface())
+		require.NoError(t, err, name)
+		var fields map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(encoded, &fields), name)
+		for field := range fields {
+			assert.Contains(t, schema.Properties, field, "%s.%s", name, field)
+		}
+	}
+}
+
+// TestHandleSchema tests schema lookup by type name
+func TestHandleSchema(t *testing.T) {
+	_, nc := newTestMetaHandler(t)
+
+	resp := request(t, nc, SubjectSchema, SchemaRequest{Type: "CreateAssetRequest"})
+	require.True(t, resp.Success, resp.Error)
+	var schema JSONSchema
+	require.NoError(t, json.Unmarshal(resp.Data, &schema))
+	assert.Equal(t, "CreateAssetRequest", schema.Title)
+	assert.Equal(t, "array", schema.Properties["labels"].Type)
+
+	resp = request(t, nc, SubjectSchema, SchemaRequest{})
+	require.True(t, resp.Success, resp.Error)
+	var schemas map[string]*JSONSchema
+	require.NoError(t, json.Unmarshal(resp.Data, &schemas))
+	assert.Len(t, schemas, len(SchemaTypeNames()))
+	assert.Contains(t, schemas, "AssetData")
+
+	resp = request(t, nc, SubjectSchema, SchemaRequest{Type: "Unknown"})
+	assert.Equal(t, ErrCodeNotFound, resp.ErrorCode)
+}
@@ -0,0 +1,276 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SnapshotVersion is the snapshot format written by ExportSnapshot
+const SnapshotVersion = 1
+
+// ExportSnapshot returns a JSON Snapshot of the store, read in one
+// transaction so assets and relations are consistent
+func (s *Store) ExportSnapshot() ([]byte, error) {
+	snapshot := Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: time.Now().UTC(),
+		Assets:    []*Asset{},
+		Relations: []*AssetRelation{},
+	}
+
+	err := s.WithTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT ` + assetColumns + ` FROM assets ORDER BY created_at, id`)
+		if err != nil {
+			return fmt.Errorf("failed to export assets: %w", err)
+		}
+		assets, err := scanAssets(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.Query(`SELECT ` + relationColumns + ` FROM asset_relations ORDER BY created_at, id`)
+		if err != nil {
+			return fmt.Errorf("failed to export relations: %w", err)
+		}
+		relations, err := scanRelations(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		snapshot.Assets = append(snapshot.Assets, assets...)
+		snapshot.Relations = append(snapshot.Relations, relations...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(snapshot, "", "  ")
+}
+
+// ImportSnapshot applies a JSON Snapshot in a single transaction. In merge
+// mode assets and relations are upserted by ID; a relation whose source,
+// target and type already exist under another ID is skipped. In replace
+// mode the store's assets and relations are deleted first. Every relation
+// must reference an asset in the snapshot or, when merging, in the store.
+func (s *Store) ImportSnapshot(data []byte, mode string) error {
+	if mode != SnapshotMerge && mode != SnapshotReplace {
+		return errorf(ErrInvalid, "invalid import mode: %q (merge|replace)", mode)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return errorf(ErrInvalid, "invalid snapshot: %v", err)
+	}
+	if err := validateSnapshot(&snapshot); err != nil {
+		return err
+	}
+	if err := s.checkSnapshotLimits(&snapshot); err != nil {
+		return err
+	}
+
+	err := s.WithTx(func(tx *sql.Tx) error {
+		if mode == SnapshotReplace {
+			if _, err := tx.Exec(`DELETE FROM asset_relations`); err != nil {
+				return fmt.Errorf("failed to clear relations: %w", err)
+			}
+			if _, err := tx.Exec(`DELETE FROM assets`); err != nil {
+				return fmt.Errorf("failed to clear assets: %w", err)
+			}
+		}
+		if err := checkSnapshotReferences(tx, &snapshot); err != nil {
+			return err
+		}
+
+		for _, asset := range snapshot.Assets {
+			if err := upsertAsset(tx, asset); err != nil {
+				return err
+			}
+		}
+		for _, relation := range snapshot.Relations {
+			if err := importRelation(tx, relation); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Replace mode may have removed any asset
+	if mode == SnapshotReplace {
+		s.assetsChanged()
+		return nil
+	}
+	ids := make([]string, len(snapshot.Assets))
+	for i, asset := range snapshot.Assets {
+		ids[i] = asset.ID
+	}
+	s.assetsChanged(ids...)
+	return nil
+}
+
+// validateSnapshot checks a snapshot on its own: version, required fields and
+// unique IDs
+func validateSnapshot(snapshot *Snapshot) error {
+	if snapshot.Version != SnapshotVersion {
+		return errorf(ErrInvalid, "unsupported snapshot version: %d", snapshot.Version)
+	}
+
+	assetIDs := make(map[string]bool, len(snapshot.Assets))
+	for i, asset := range snapshot.Assets {
+		if asset == nil || asset.ID == "" || asset.Name == "" {
+			return errorf(ErrInvalid, "asset %d: id and name are required", i)
+		}
+		if assetIDs[asset.ID] {
+			return errorf(ErrInvalid, "duplicate asset id: %s", asset.ID)
+		}
+		assetIDs[asset.ID] = true
+		if err := validateLocation(asset.Latitude, asset.Longitude); err != nil {
+			return errorf(ErrInvalid, "asset %s: %v", asset.ID, err)
+		}
+	}
+
+	relationIDs := make(map[string]bool, len(snapshot.Relations))
+	for i, relation := range snapshot.Relations {
+		if relation == nil || relation.ID == "" {
+			return errorf(ErrInvalid, "relation %d: id is required", i)
+		}
+		if relationIDs[relation.ID] {
+			return errorf(ErrInvalid, "duplicate relation id: %s", relation.ID)
+		}
+		relationIDs[relation.ID] = true
+		if !IsValidRelationType(relation.RelationType) {
+			return errorf(ErrInvalidRelationType, "relation %s: invalid relation type: %s", relation.ID, relation.RelationType)
+		}
+	}
+	return nil
+}
+
+// checkSnapshotLimits applies the store's size limits to the snapshot
+func (s *Store) checkSnapshotLimits(snapshot *Snapshot) error {
+	for _, asset := range snapshot.Assets {
+		if err := s.checkLabels(asset.Labels); err != nil {
+			return fmt.Errorf("asset %s: %w", asset.ID, err)
+		}
+	}
+	for _, relation := range snapshot.Relations {
+		metadata, attributes, err := marshalRelationJSON(relation)
+		if err == nil {
+			err = s.checkRelationMetadata(metadata, attributes)
+		}
+		if err != nil {
+			return fmt.Errorf("relation %s: %w", relation.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkSnapshotReferences verifies that every relation endpoint is in the
+// snapshot or already stored
+func checkSnapshotReferences(q querier, snapshot *Snapshot) error {
+	present := make(map[string]bool, len(snapshot.Assets))
+	for _, asset := range snapshot.Assets {
+		present[asset.ID] = true
+	}
+
+	for _, relation := range snapshot.Relations {
+		for _, id := range []string{relation.SourceAssetID, relation.TargetAssetID} {
+			if present[id] {
+				continue
+			}
+			var stored bool
+			if err := q.QueryRow(`SELECT EXISTS(SELECT 1 FROM assets WHERE id = ?)`, id).Scan(&stored); err != nil {
+				return fmt.Errorf("failed to check asset %s: %w", id, err)
+			}
+			if !stored {
+				return errorf(ErrInvalid, "relation %s references missing asset: %s", relation.ID, id)
+			}
+			present[id] = true
+		}
+	}
+	return nil
+}
+
+// upsertAsset writes every column of asset, creating or overwriting the row
+// with its ID
+func upsertAsset(q querier, asset *Asset) error {
+	labels, attributes, err := marshalAssetJSON(asset)
+	if err != nil {
+		return err
+	}
+	externalIDs, err := prepareExternalIDs(q, asset.ExternalIDs)
+	if err != nil {
+		return err
+	}
+	if asset.CreatedAt.IsZero() {
+		asset.CreatedAt = time.Now()
+	}
+
+	_, err = q.Exec(
+		`INSERT INTO assets (id, name, template_name, template_version, labels, attributes, external_ids, latitude, longitude, altitude, created_at, deleted_at, last_seen)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name, template_name = excluded.template_name, template_version = excluded.template_version,
+			labels = excluded.labels, attributes = excluded.attributes, external_ids = excluded.external_ids,
+			latitude = excluded.latitude, longitude = excluded.longitude, altitude = excluded.altitude,
+			created_at = excluded.created_at, deleted_at = excluded.deleted_at, last_seen = excluded.last_seen`,
+		asset.ID, asset.Name, asset.TemplateName, asset.TemplateVersion, labels, attributes, externalIDs,
+		asset.Latitude, asset.Longitude, asset.Altitude, asset.CreatedAt, asset.DeletedAt, asset.LastSeen,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return duplicateAssetError(err, asset)
+		}
+		return fmt.Errorf("failed to import asset %s: %w", asset.ID, err)
+	}
+	return nil
+}
+
+// importRelation writes relation in place of any relation with its ID,
+// applying the same cycle check as CreateRelation
+func importRelation(q querier, relation *AssetRelation) error {
+	if _, err := q.Exec(`DELETE FROM asset_relations WHERE id = ?`, relation.ID); err != nil {
+		return fmt.Errorf("failed to import relation %s: %w", relation.ID, err)
+	}
+
+	CanonicalizeRelation(relation)
+	exists, err := relationExists(q, relation.SourceAssetID, relation.TargetAssetID, relation.RelationType)
+	if err != nil {
+		return fmt.Errorf("failed to check relation %s: %w", relation.ID, err)
+	}
+	if exists {
+		return nil
+	}
+
+	if IsHierarchicalRelationType(relation.RelationType) {
+		cycle, err := wouldCreateCycle(q, relation.SourceAssetID, relation.TargetAssetID, relation.RelationType)
+		if err != nil {
+			return fmt.Errorf("failed to check for cycles: %w", err)
+		}
+		if cycle {
+			return errorf(ErrRelationCycle, "relation %s would create a cycle", relation.ID)
+		}
+	}
+
+	metadata, attributes, err := marshalRelationJSON(relation)
+	if err != nil {
+		return err
+	}
+	if relation.CreatedAt.IsZero() {
+		relation.CreatedAt = time.Now()
+	}
+	if _, err := q.Exec(
+		`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at, metadata, attributes, weight)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		relation.ID, relation.SourceAssetID, relation.TargetAssetID,
+		relation.RelationType, relation.CreatedAt, metadata, attributes, relation.Weight,
+	); err != nil {
+		return fmt.Errorf("failed to import relation %s: %w", relation.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore creates an in-memory store closed with the test
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// decodeSnapshot exports store and decodes the result
+func decodeSnapshot(t *testing.T, store *Store) Snapshot {
+	t.Helper()
+	data, err := store.ExportSnapshot()
+	require.NoError(t, err)
+	var snapshot Snapshot
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	return snapshot
+}
+
+// TestSnapshot_RoundTrip tests that a replace import reproduces the exported graph
+func TestSnapshot_RoundTrip(t *testing.T) {
+	source := newTestStore(t)
+	lat, lon := 37.5, 127.0
+	require.NoError(t, source.CreateAsset(&Asset{
+		ID: "line", Name: "line", Labels: []string{"hall-a"}, Attributes: map[string]string{"vendor": "acme"},
+		ExternalIDs: map[string]string{"erp": "L-1"}, Latitude: &lat, Longitude: &lon, CreatedAt: time.Now(),
+	}))
+	createTestAssets(t, source, "machine", "retired")
+	require.NoError(t, source.SoftDeleteAsset("retired"))
+	require.NoError(t, source.CreateRelation(&AssetRelation{
+		ID: "rel-1", SourceAssetID: "machine", TargetAssetID: "line", RelationType: RelationPartOf,
+		Metadata: map[string]string{"slot": "1"}, CreatedAt: time.Now(),
+	}))
+
+	data, err := source.ExportSnapshot()
+	require.NoError(t, err)
+
+	target := newTestStore(t)
+	createTestAssets(t, target, "stale")
+	require.NoError(t, target.ImportSnapshot(data, SnapshotReplace))
+
+	want, got := decodeSnapshot(t, source), decodeSnapshot(t, target)
+	assert.Equal(t, want.Assets, got.Assets)
+	assert.Equal(t, want.Relations, got.Relations)
+
+	retired, err := target.GetAssetIncludeDeleted("retired")
+	require.NoError(t, err)
+	assert.NotNil(t, retired.DeletedAt)
+}
+
+// TestImportSnapshot_Merge tests upserts by ID alongside existing data
+func TestImportSnapshot_Merge(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "line", "machine")
+	require.NoError(t, createTestRelation(t, store, "machine", "line", RelationPartOf))
+
+	snapshot, err := json.Marshal(Snapshot{
+		Version: SnapshotVersion,
+		Assets: []*Asset{
+			{ID: "line", Name: "line-renamed", CreatedAt: time.Now()},
+			{ID: "sensor", Name: "sensor", CreatedAt: time.Now()},
+		},
+		Relations: []*AssetRelation{
+			// references an asset that is only in the store
+			{ID: "rel-sensor", SourceAssetID: "sensor", TargetAssetID: "machine", RelationType: RelationPartOf},
+			// same edge as the stored relation under another ID
+			{ID: "rel-dup", SourceAssetID: "machine", TargetAssetID: "line", RelationType: RelationPartOf},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.ImportSnapshot(snapshot, SnapshotMerge))
+
+	line, err := store.GetAsset("line")
+	require.NoError(t, err)
+	assert.Equal(t, "line-renamed", line.Name)
+
+	relations, err := store.QueryRelations(RelationQuery{})
+	require.NoError(t, err)
+	ids := make([]string, len(relations))
+	for i, relation := range relations {
+		ids[i] = relation.ID
+	}
+	assert.ElementsMatch(t, []string{"machine-partOf-line", "rel-sensor"}, ids)
+}
+
+// TestImportSnapshot_Rejected tests that invalid snapshots change nothing
+func TestImportSnapshot_Rejected(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "line")
+
+	tests := []struct {
+		name     string
+		mode     string
+		snapshot Snapshot
+		want     string
+	}{
+		{"bad mode", "overwrite", Snapshot{Version: SnapshotVersion}, "invalid import mode"},
+		{"bad version", SnapshotMerge, Snapshot{Version: 99}, "unsupported snapshot version"},
+		{"missing asset", SnapshotMerge, Snapshot{Version: SnapshotVersion, Relations: []*AssetRelation{
+			{ID: "r", SourceAssetID: "ghost", TargetAssetID: "line", RelationType: RelationPartOf},
+		}}, "references missing asset: ghost"},
+		{"stored asset gone after replace", SnapshotReplace, Snapshot{Version: SnapshotVersion,
+			Assets: []*Asset{{ID: "machine", Name: "machine"}},
+			Relations: []*AssetRelation{
+				{ID: "r", SourceAssetID: "machine", TargetAssetID: "line", RelationType: RelationPartOf},
+			}}, "references missing asset: line"},
+		{"cycle", SnapshotMerge, Snapshot{Version: SnapshotVersion,
+			Assets: []*Asset{{ID: "a", Name: "a"}, {ID: "b", Name: "b"}},
+			Relations: []*AssetRelation{
+				{ID: "r1", SourceAssetID: "a", TargetAssetID: "b", RelationType: RelationPartOf},
+				{ID: "r2", SourceAssetID: "b", TargetAssetID: "a", RelationType: RelationPartOf},
+			}}, "would create a cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.snapshot)
+			require.NoError(t, err)
+
+			err = store.ImportSnapshot(data, tt.mode)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalid), err)
+			assert.Contains(t, err.Error(), tt.want)
+
+			snapshot := decodeSnapshot(t, store)
+			require.Len(t, snapshot.Assets, 1)
+			assert.Equal(t, "line", snapshot.Assets[0].ID)
+			assert.Empty(t, snapshot.Relations)
+		})
+	}
+}
@@ -6,36 +6,102 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Store is a SQLite-based metadata store
 type Store struct {
-	db *sql.DB
+	db     *sql.DB
+	limits StoreOptions // only the size limits are used after opening
+
+	// called with the IDs of assets after they are created, changed or
+	// deleted, or with none when any asset may have changed; registered
+	// before the store is shared
+	assetHooks []func(ids ...string)
+}
+
+// StoreOptions configures the SQLite connection used by a Store
+type StoreOptions struct {
+	// JournalMode is the SQLite journal mode; WAL lets readers proceed while
+	// a write is in progress. Ignored for in-memory databases.
+	JournalMode string
+	// BusyTimeout is how long a connection waits for a lock before failing
+	// with "database is locked"
+	BusyTimeout time.Duration
+	// MaxOpenConns limits the connection pool. In-memory databases always
+	// use a single connection since each connection would see its own DB.
+	MaxOpenConns int
+
+	// Size limits protecting the database from oversized writes; zero
+	// disables a limit
+	MaxRelationMetadataBytes int // serialized JSON metadata of a relation
+	MaxAssetLabels           int // labels per asset
+	MaxLabelLength           int // bytes per label
+}
+
+// DefaultStoreOptions returns the options used by NewStore
+func DefaultStoreOptions() StoreOptions {
+	return StoreOptions{
+		JournalMode:  "WAL",
+		BusyTimeout:  5 * time.Second,
+		MaxOpenConns: 8,
+
+		MaxRelationMetadataBytes: 4096,
+		MaxAssetLabels:           64,
+		MaxLabelLength:           128,
+	}
 }
 
-// NewStore creates and initializes a new Store
+// NewStore creates and initializes a new Store with default options
 func NewStore(dbPath string) (*Store, error) {
+	return NewStoreWithOptions(dbPath, DefaultStoreOptions())
+}
+
+// NewStoreWithOptions creates and initializes a new Store
+func NewStoreWithOptions(dbPath string, opts StoreOptions) (*Store, error) {
 	// Create data directory if not exists
 	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	// Per-connection settings go in the DSN so every pooled connection gets
+	// them: foreign keys, the busy timeout, and immediate transactions so a
+	// check-then-write sequence inside WithTx cannot interleave with another
+	// writer.
+	dsn := fmt.Sprintf("%s?_foreign_keys=on&_txlock=immediate&_busy_timeout=%d",
+		dbPath, opts.BusyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open DB: %w", err)
 	}
 
-	// Enable foreign key constraints
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	inMemory := dbPath == ":memory:"
+	if inMemory {
+		db.SetMaxOpenConns(1)
+	} else if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+
+	// The journal mode is stored in the database file, so setting it once is enough
+	if opts.JournalMode != "" && !inMemory {
+		var mode string
+		if err := db.QueryRow("PRAGMA journal_mode = " + opts.JournalMode).Scan(&mode); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to set journal mode: %w", err)
+		}
+		if !strings.EqualFold(mode, opts.JournalMode) {
+			db.Close()
+			return nil, fmt.Errorf("failed to set journal mode: got %s, want %s", mode, opts.JournalMode)
+		}
 	}
 
-	store := &Store{db: db}
+	store := &Store{db: db, limits: opts}
 	if err := store.init(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to initialize DB: %w", err)
@@ -44,9 +110,26 @@ func NewStore(dbPath string) (*Store, error) {
 	return store, nil
 }
 
-// init creates tables
-func (s *Store) init() error {
-	schema := `
+// migration is one step of the schema history. Versions must be
+// consecutive and never change once released.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// execSQL returns a migration step that runs a fixed SQL script
+func execSQL(script string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(script)
+		return err
+	}
+}
+
+// migrations lists every schema migration in order. v1 uses IF NOT EXISTS so
+// databases created before migrations existed adopt it idempotently.
+var migrations = []migration{
+	{version: 1, name: "initial schema", up: execSQL(`
 	CREATE TABLE IF NOT EXISTS assets (
 		id TEXT PRIMARY KEY,
 		name TEXT UNIQUE NOT NULL,
@@ -71,9 +154,171 @@ func (s *Store) init() error {
 	CREATE INDEX IF NOT EXISTS idx_relations_source ON asset_relations(source_asset_id);
 	CREATE INDEX IF NOT EXISTS idx_relations_target ON asset_relations(target_asset_id);
 	CREATE INDEX IF NOT EXISTS idx_relations_type ON asset_relations(relation_type);
-	`
-	_, err := s.db.Exec(schema)
-	return err
+
+	CREATE TABLE IF NOT EXISTS asset_data (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		asset_id TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		tag_values TEXT NOT NULL,
+		metadata TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_asset_data_asset_ts ON asset_data(asset_id, timestamp);
+	`)},
+	{version: 2, name: "asset attributes", up: execSQL(`ALTER TABLE assets ADD COLUMN attributes TEXT`)},
+	{version: 3, name: "asset soft delete", up: execSQL(`ALTER TABLE assets ADD COLUMN deleted_at DATETIME`)},
+	{version: 4, name: "asset created_at index", up: execSQL(`CREATE INDEX IF NOT EXISTS idx_assets_created_at ON assets(created_at)`)},
+	{version: 5, name: "asset template version", up: execSQL(`ALTER TABLE assets ADD COLUMN template_version INTEGER NOT NULL DEFAULT 0`)},
+	// content_hash is only set in idempotent mode; NULLs never conflict
+	{version: 6, name: "asset data content hash", up: execSQL(`
+	ALTER TABLE asset_data ADD COLUMN content_hash TEXT;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_asset_data_content_hash ON asset_data(content_hash);
+	`)},
+	{version: 7, name: "asset location", up: execSQL(`
+	ALTER TABLE assets ADD COLUMN latitude REAL;
+	ALTER TABLE assets ADD COLUMN longitude REAL;
+	ALTER TABLE assets ADD COLUMN altitude REAL;
+	CREATE INDEX IF NOT EXISTS idx_assets_location ON assets(latitude, longitude);
+	`)},
+	// Unique indexes over external_ids are created per scheme on first use;
+	// see prepareExternalIDs
+	{version: 8, name: "asset external ids", up: execSQL(`ALTER TABLE assets ADD COLUMN external_ids TEXT`)},
+	{version: 9, name: "asset last seen", up: execSQL(`
+	ALTER TABLE assets ADD COLUMN last_seen DATETIME;
+	CREATE INDEX IF NOT EXISTS idx_assets_last_seen ON assets(last_seen);
+	`)},
+	{version: 10, name: "relation weight", up: execSQL(`ALTER TABLE asset_relations ADD COLUMN weight REAL`)},
+	// Triggers keep the key:value label index in step with every write of
+	// assets.labels; existing labels are indexed once here
+	{version: 11, name: "asset label key values", up: execSQL(`
+	CREATE TABLE IF NOT EXISTS asset_label_kv (
+		asset_id TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (asset_id, key, value),
+		FOREIGN KEY (asset_id) REFERENCES assets(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_asset_label_kv ON asset_label_kv(key, value);
+
+	CREATE TRIGGER IF NOT EXISTS assets_label_kv_insert AFTER INSERT ON assets BEGIN
+		INSERT OR IGNORE INTO asset_label_kv (asset_id, key, value) ` + labelKVSelect + ` AND a.id = NEW.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS assets_label_kv_update AFTER UPDATE OF labels ON assets BEGIN
+		DELETE FROM asset_label_kv WHERE asset_id = NEW.id;
+		INSERT OR IGNORE INTO asset_label_kv (asset_id, key, value) ` + labelKVSelect + ` AND a.id = NEW.id;
+	END;
+
+	INSERT OR IGNORE INTO asset_label_kv (asset_id, key, value) ` + labelKVSelect + `;
+	`)},
+	{version: 12, name: "canonical symmetric relations", up: func(tx *sql.Tx) error {
+		_, err := canonicalizeRelations(tx)
+		return err
+	}},
+	// Retention pruning deletes by timestamp across all assets
+	{version: 13, name: "asset data timestamp index", up: execSQL(`CREATE INDEX IF NOT EXISTS idx_asset_data_ts ON asset_data(timestamp)`)},
+	// LIKE is case-insensitive, so only a NOCASE index serves name prefix searches
+	{version: 14, name: "asset name nocase index", up: execSQL(`CREATE INDEX IF NOT EXISTS idx_assets_name_nocase ON assets(name COLLATE NOCASE)`)},
+	{version: 15, name: "relation attributes", up: execSQL(`ALTER TABLE asset_relations ADD COLUMN attributes TEXT`)},
+	// Range queries, aggregates and pruning compare timestamps in milliseconds
+	{version: 16, name: "millisecond data timestamps", up: millisDataTimestamps},
+}
+
+// labelKVSelect selects (asset_id, key, value) for every "key:value" label
+// of every asset, split at the first colon; further conditions on the
+// asset aliased a may be appended. Labels with an empty key or value are
+// plain tags, and malformed label JSON is skipped rather than failing the
+// write.
+const labelKVSelect = `SELECT a.id, substr(l.value, 1, instr(l.value, ':') - 1), substr(l.value, instr(l.value, ':') + 1)
+		FROM assets a, json_each(CASE WHEN json_valid(a.labels) THEN a.labels END) l
+		WHERE l.type = 'text' AND instr(l.value, ':') > 1 AND instr(l.value, ':') < length(l.value)`
+
+// init applies pending schema migrations
+func (s *Store) init() error {
+	if _, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	// All pending migrations are applied atomically
+	return s.WithTx(func(tx *sql.Tx) error {
+		current, err := schemaVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if m.version <= current {
+				continue
+			}
+			if err := m.up(tx); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+			}
+			if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+			}
+		}
+		return nil
+	})
+}
+
+// SchemaVersion returns the latest applied migration version
+func (s *Store) SchemaVersion() (int, error) {
+	return schemaVersion(s.db)
+}
+
+// schemaVersion reads the latest applied migration version using q
+func schemaVersion(q querier) (int, error) {
+	var version int
+	if err := q.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Ping checks that the database answers queries
+func (s *Store) Ping() error {
+	var one int
+	if err := s.db.QueryRow("SELECT 1").Scan(&one); err != nil {
+		return fmt.Errorf("store ping failed: %w", err)
+	}
+	return nil
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx so helpers can run
+// inside or outside a transaction
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// WithTx runs fn inside a transaction, committing if it returns nil and
+// rolling back otherwise
+func (s *Store) WithTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// assetsChanged runs the asset change hooks
+func (s *Store) assetsChanged(ids ...string) {
+	for _, hook := range s.assetHooks {
+		hook(ids...)
+	}
 }
 
 // Close closes the DB connection
@@ -81,299 +326,1679 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
+// checkLabels enforces the label count and length limits
+func (s *Store) checkLabels(labels []string) error {
+	if max := s.limits.MaxAssetLabels; max > 0 && len(labels) > max {
+		return errorf(ErrInvalid, "asset has %d labels, more than the maximum of %d", len(labels), max)
+	}
+	if max := s.limits.MaxLabelLength; max > 0 {
+		for _, label := range labels {
+			if len(label) > max {
+				return errorf(ErrInvalid, "label exceeds max length of %d bytes", max)
+			}
+		}
+	}
+	return nil
+}
+
+// checkRelationMetadata enforces the relation metadata size limit on the
+// serialized metadata and attributes, each on its own
+func (s *Store) checkRelationMetadata(metadata, attributes string) error {
+	max := s.limits.MaxRelationMetadataBytes
+	if max > 0 && len(metadata) > max {
+		return errorf(ErrInvalid, "relation metadata exceeds max size: %d bytes, limit %d", len(metadata), max)
+	}
+	if max > 0 && len(attributes) > max {
+		return errorf(ErrInvalid, "relation attributes exceed max size: %d bytes, limit %d", len(attributes), max)
+	}
+	return nil
+}
+
 // CreateAsset creates a new asset
 func (s *Store) CreateAsset(asset *Asset) error {
-	labels, err := json.Marshal(asset.Labels)
+	if err := s.checkLabels(asset.Labels); err != nil {
+		return err
+	}
+	labels, attributes, err := marshalAssetJSON(asset)
 	if err != nil {
-		return fmt.Errorf("failed to marshal asset labels: %w", err)
+		return err
+	}
+	externalIDs, err := prepareExternalIDs(s.db, asset.ExternalIDs)
+	if err != nil {
+		return err
 	}
 
 	_, err = s.db.Exec(
-		`INSERT INTO assets (id, name, template_name, labels, created_at) VALUES (?, ?, ?, ?, ?)`,
-		asset.ID, asset.Name, asset.TemplateName, string(labels), asset.CreatedAt,
+		`INSERT INTO assets (id, name, template_name, template_version, labels, attributes, external_ids, latitude, longitude, altitude, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		asset.ID, asset.Name, asset.TemplateName, asset.TemplateVersion, labels, attributes, externalIDs,
+		asset.Latitude, asset.Longitude, asset.Altitude, asset.CreatedAt,
 	)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return duplicateAssetError(err, asset)
+		}
 		return fmt.Errorf("failed to create asset: %w", err)
 	}
+	s.assetsChanged(asset.ID)
 	return nil
 }
 
-// GetAsset retrieves an asset by ID
-func (s *Store) GetAsset(id string) (*Asset, error) {
-	row := s.db.QueryRow(
-		`SELECT id, name, template_name, labels, created_at FROM assets WHERE id = ?`,
-		id,
-	)
-
-	var asset Asset
-	var labelsJSON string
-	err := row.Scan(&asset.ID, &asset.Name, &asset.TemplateName, &labelsJSON, &asset.CreatedAt)
-	if err == sql.ErrNoRows {
-		return nil, nil
+// EnsureAsset creates asset unless an asset with its ID already exists,
+// including a soft-deleted one, and reports whether it was created. Unlike
+// CreateAsset it is safe to race: of several concurrent calls for one ID,
+// one creates the asset and the others return false without an error.
+// Conflicts on the name or an external ID still fail.
+func (s *Store) EnsureAsset(asset *Asset) (bool, error) {
+	if err := s.checkLabels(asset.Labels); err != nil {
+		return false, err
 	}
+	labels, attributes, err := marshalAssetJSON(asset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset: %w", err)
+		return false, err
 	}
-
-	if err := json.Unmarshal([]byte(labelsJSON), &asset.Labels); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal asset labels: %w", err)
+	externalIDs, err := prepareExternalIDs(s.db, asset.ExternalIDs)
+	if err != nil {
+		return false, err
 	}
-	return &asset, nil
-}
 
-// GetAssetByName retrieves an asset by name
-func (s *Store) GetAssetByName(name string) (*Asset, error) {
-	row := s.db.QueryRow(
-		`SELECT id, name, template_name, labels, created_at FROM assets WHERE name = ?`,
-		name,
+	result, err := s.db.Exec(
+		`INSERT INTO assets (id, name, template_name, template_version, labels, attributes, external_ids, latitude, longitude, altitude, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(id) DO NOTHING`,
+		asset.ID, asset.Name, asset.TemplateName, asset.TemplateVersion, labels, attributes, externalIDs,
+		asset.Latitude, asset.Longitude, asset.Altitude, asset.CreatedAt,
 	)
-
-	var asset Asset
-	var labelsJSON string
-	err := row.Scan(&asset.ID, &asset.Name, &asset.TemplateName, &labelsJSON, &asset.CreatedAt)
-	if err == sql.ErrNoRows {
-		return nil, nil
+	if err != nil {
+		if isUniqueViolation(err) {
+			return false, duplicateAssetError(err, asset)
+		}
+		return false, fmt.Errorf("failed to create asset: %w", err)
 	}
+	n, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset: %w", err)
+		return false, fmt.Errorf("failed to create asset: %w", err)
 	}
-
-	if err := json.Unmarshal([]byte(labelsJSON), &asset.Labels); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal asset labels: %w", err)
+	if n == 0 {
+		return false, nil
 	}
-	return &asset, nil
+	s.assetsChanged(asset.ID)
+	return true, nil
 }
 
-// ListAssets retrieves all assets
-func (s *Store) ListAssets() ([]*Asset, error) {
-	rows, err := s.db.Query(
-		`SELECT id, name, template_name, labels, created_at FROM assets ORDER BY created_at DESC`,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list assets: %w", err)
+// CreateAssetsBatch creates all assets in a single transaction. If any insert
+// fails the whole batch is rolled back.
+func (s *Store) CreateAssetsBatch(assets []*Asset) error {
+	for _, asset := range assets {
+		if err := s.checkLabels(asset.Labels); err != nil {
+			return fmt.Errorf("asset %s: %w", asset.Name, err)
+		}
 	}
-	defer rows.Close()
 
-	var assets []*Asset
-	for rows.Next() {
-		var asset Asset
-		var labelsJSON string
-		if err := rows.Scan(&asset.ID, &asset.Name, &asset.TemplateName, &labelsJSON, &asset.CreatedAt); err != nil {
-			return nil, err
-		}
-		if err := json.Unmarshal([]byte(labelsJSON), &asset.Labels); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal asset labels: %w", err)
+	err := s.WithTx(func(tx *sql.Tx) error {
+		// Indexes are created before the insert is prepared, as schema
+		// changes invalidate prepared statements
+		externalIDs := make([]any, len(assets))
+		for i, asset := range assets {
+			var err error
+			if externalIDs[i], err = prepareExternalIDs(tx, asset.ExternalIDs); err != nil {
+				return err
+			}
 		}
-		assets = append(assets, &asset)
-	}
 
-	return assets, nil
-}
+		stmt, err := tx.Prepare(`INSERT INTO assets (id, name, template_name, template_version, labels, attributes, external_ids, latitude, longitude, altitude, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare asset insert: %w", err)
+		}
+		defer stmt.Close()
 
-// DeleteAsset deletes an asset by ID
-func (s *Store) DeleteAsset(id string) error {
-	result, err := s.db.Exec(`DELETE FROM assets WHERE id = ?`, id)
+		for i, asset := range assets {
+			labels, attributes, err := marshalAssetJSON(asset)
+			if err != nil {
+				return err
+			}
+			if _, err := stmt.Exec(asset.ID, asset.Name, asset.TemplateName, asset.TemplateVersion, labels, attributes, externalIDs[i],
+				asset.Latitude, asset.Longitude, asset.Altitude, asset.CreatedAt); err != nil {
+				if isUniqueViolation(err) {
+					return duplicateAssetError(err, asset)
+				}
+				return fmt.Errorf("failed to create asset %s: %w", asset.Name, err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete asset: %w", err)
+		return err
 	}
 
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
-		return fmt.Errorf("asset not found: %s", id)
+	ids := make([]string, len(assets))
+	for i, asset := range assets {
+		ids[i] = asset.ID
 	}
+	s.assetsChanged(ids...)
 	return nil
 }
 
-// AssetExists checks if an asset exists
-func (s *Store) AssetExists(id string) (bool, error) {
-	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM assets WHERE id = ?`, id).Scan(&count)
+// assetColumns is the column list shared by every asset SELECT
+const assetColumns = `id, name, template_name, template_version, labels, attributes, external_ids, latitude, longitude, altitude, created_at, deleted_at, last_seen`
+
+// assetNotDeleted is the condition excluding soft-deleted assets
+const assetNotDeleted = `deleted_at IS NULL`
+
+// marshalAssetJSON encodes the JSON-backed asset columns
+func marshalAssetJSON(asset *Asset) (labels, attributes string, err error) {
+	labelsJSON, err := json.Marshal(asset.Labels)
 	if err != nil {
-		return false, err
+		return "", "", fmt.Errorf("failed to marshal asset labels: %w", err)
 	}
-	return count > 0, nil
+	attributesJSON, err := json.Marshal(asset.Attributes)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal asset attributes: %w", err)
+	}
+	return string(labelsJSON), string(attributesJSON), nil
 }
 
-// UpdateAssetTemplate updates an asset's template
-func (s *Store) UpdateAssetTemplate(id, templateName string) error {
-	result, err := s.db.Exec(
-		`UPDATE assets SET template_name = ? WHERE id = ?`,
-		templateName, id,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to update asset: %w", err)
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanAsset scans a single asset row selected with assetColumns
+func scanAsset(row rowScanner) (*Asset, error) {
+	var asset Asset
+	var labelsJSON string
+	var attributesJSON sql.NullString // NULL for rows created before attributes existed
+	var externalIDsJSON sql.NullString
+	var latitude, longitude, altitude sql.NullFloat64
+	var deletedAt, lastSeen sql.NullTime
+	if err := row.Scan(&asset.ID, &asset.Name, &asset.TemplateName, &asset.TemplateVersion, &labelsJSON, &attributesJSON, &externalIDsJSON,
+		&latitude, &longitude, &altitude, &asset.CreatedAt, &deletedAt, &lastSeen); err != nil {
+		return nil, err
+	}
+	asset.Latitude = nullFloat(latitude)
+	asset.Longitude = nullFloat(longitude)
+	asset.Altitude = nullFloat(altitude)
+	if deletedAt.Valid {
+		asset.DeletedAt = &deletedAt.Time
+	}
+	if lastSeen.Valid {
+		asset.LastSeen = &lastSeen.Time
 	}
 
-	affected, _ := result.RowsAffected()
-	if affected == 0 {
-		return fmt.Errorf("asset not found: %s", id)
+	if err := json.Unmarshal([]byte(labelsJSON), &asset.Labels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal asset labels: %w", err)
 	}
-	return nil
+	if attributesJSON.Valid && attributesJSON.String != "" {
+		if err := json.Unmarshal([]byte(attributesJSON.String), &asset.Attributes); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal asset attributes: %w", err)
+		}
+	}
+	if externalIDsJSON.Valid {
+		if err := json.Unmarshal([]byte(externalIDsJSON.String), &asset.ExternalIDs); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal asset external ids: %w", err)
+		}
+	}
+	return &asset, nil
 }
 
-// StoreStats contains store statistics
-type StoreStats struct {
-	TotalAssets int       `json:"total_assets"`
-	LastUpdated time.Time `json:"last_updated"`
+// nullFloat converts a nullable column to an optional value
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
 }
 
-// GetStats returns store statistics
-func (s *Store) GetStats() (*StoreStats, error) {
-	var count int
-	err := s.db.QueryRow(`SELECT COUNT(*) FROM assets`).Scan(&count)
+// CloneAsset creates clone as a copy of the asset sourceID: its template,
+// labels and attributes are copied, while clone supplies the ID, name and
+// creation time. External IDs and the location identify and place a single
+// device, so they are not copied. With copyRelations the outgoing relations
+// of the source are copied as well, starting at the clone and keeping type,
+// target, metadata, attributes and weight; a symmetric relation is outgoing
+// from either end. The copied relations are returned. Everything is written
+// in one transaction.
+func (s *Store) CloneAsset(sourceID string, clone *Asset, copyRelations bool) ([]*AssetRelation, error) {
+	var copied []*AssetRelation
+	err := s.WithTx(func(tx *sql.Tx) error {
+		source, err := scanAsset(tx.QueryRow(`SELECT `+assetColumns+` FROM assets WHERE id = ? AND `+assetNotDeleted, sourceID))
+		if err == sql.ErrNoRows {
+			return errorf(ErrAssetNotFound, "asset not found: %s", sourceID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get asset: %w", err)
+		}
+
+		clone.TemplateName = source.TemplateName
+		clone.TemplateVersion = source.TemplateVersion
+		clone.Labels = source.Labels
+		clone.Attributes = source.Attributes
+		labels, attributes, err := marshalAssetJSON(clone)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO assets (id, name, template_name, template_version, labels, attributes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			clone.ID, clone.Name, clone.TemplateName, clone.TemplateVersion, labels, attributes, clone.CreatedAt,
+		); err != nil {
+			if isUniqueViolation(err) {
+				return duplicateAssetError(err, clone)
+			}
+			return fmt.Errorf("failed to create asset: %w", err)
+		}
+
+		if !copyRelations {
+			return nil
+		}
+		rows, err := tx.Query(`SELECT `+relationColumns+` FROM asset_relations
+			WHERE source_asset_id = ? OR target_asset_id = ? ORDER BY created_at, rowid`, sourceID, sourceID)
+		if err != nil {
+			return fmt.Errorf("failed to query relations: %w", err)
+		}
+		relations, err := scanRelations(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		for _, relation := range relations {
+			target := relation.TargetAssetID
+			if relation.SourceAssetID != sourceID {
+				if !IsSymmetricRelationType(relation.RelationType) {
+					continue
+				}
+				target = relation.SourceAssetID
+			}
+			relation.ID = uuid.New().String()
+			relation.SourceAssetID, relation.TargetAssetID = clone.ID, target
+			relation.CreatedAt = clone.CreatedAt
+			CanonicalizeRelation(relation)
+			metadataJSON, attributesJSON, err := marshalRelationJSON(relation)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(
+				`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at, metadata, attributes, weight)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				relation.ID, relation.SourceAssetID, relation.TargetAssetID,
+				relation.RelationType, relation.CreatedAt, metadataJSON, attributesJSON, relation.Weight,
+			); err != nil {
+				return fmt.Errorf("failed to copy relation: %w", err)
+			}
+			copied = append(copied, relation)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	return &StoreStats{
-		TotalAssets: count,
-		LastUpdated: time.Now(),
-	}, nil
+	s.assetsChanged(clone.ID)
+	return copied, nil
 }
 
-// ==================== AssetRelation Methods ====================
+// GetAsset retrieves an asset by ID. Soft-deleted assets are not returned.
+func (s *Store) GetAsset(id string) (*Asset, error) {
+	return s.getAsset(id, false)
+}
 
-// CreateRelation creates a new asset relation
-func (s *Store) CreateRelation(relation *AssetRelation) error {
-	// Validate source and target assets exist
-	sourceExists, err := s.AssetExists(relation.SourceAssetID)
-	if err != nil {
-		return fmt.Errorf("failed to check source asset: %w", err)
-	}
-	if !sourceExists {
-		return fmt.Errorf("source asset not found: %s", relation.SourceAssetID)
-	}
+// GetAssetIncludeDeleted retrieves an asset by ID, including a soft-deleted one
+func (s *Store) GetAssetIncludeDeleted(id string) (*Asset, error) {
+	return s.getAsset(id, true)
+}
 
-	targetExists, err := s.AssetExists(relation.TargetAssetID)
-	if err != nil {
-		return fmt.Errorf("failed to check target asset: %w", err)
-	}
-	if !targetExists {
-		return fmt.Errorf("target asset not found: %s", relation.TargetAssetID)
+func (s *Store) getAsset(id string, includeDeleted bool) (*Asset, error) {
+	query := `SELECT ` + assetColumns + ` FROM assets WHERE id = ?`
+	if !includeDeleted {
+		query += ` AND ` + assetNotDeleted
 	}
+	row := s.db.QueryRow(query, id)
 
-	// Marshal metadata
-	var metadataJSON string
-	if relation.Metadata != nil {
-		metadata, err := json.Marshal(relation.Metadata)
-		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
-		}
-		metadataJSON = string(metadata)
+	asset, err := scanAsset(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-
-	// Insert relation
-	_, err = s.db.Exec(
-		`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at, metadata)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		relation.ID, relation.SourceAssetID, relation.TargetAssetID,
-		relation.RelationType, relation.CreatedAt, metadataJSON,
-	)
 	if err != nil {
-		return fmt.Errorf("failed to create relation: %w", err)
+		return nil, fmt.Errorf("failed to get asset: %w", err)
 	}
-	return nil
+	return asset, nil
 }
 
-// GetRelation retrieves a relation by ID
-func (s *Store) GetRelation(id string) (*AssetRelation, error) {
+// GetAssetByName retrieves an asset by name. Soft-deleted assets are not
+// returned, but their names stay reserved until they are hard-deleted.
+func (s *Store) GetAssetByName(name string) (*Asset, error) {
 	row := s.db.QueryRow(
-		`SELECT id, source_asset_id, target_asset_id, relation_type, created_at, metadata
-		 FROM asset_relations WHERE id = ?`,
-		id,
+		`SELECT `+assetColumns+` FROM assets WHERE name = ? AND `+assetNotDeleted,
+		name,
 	)
 
-	var relation AssetRelation
-	var metadataJSON sql.NullString
-	err := row.Scan(
-		&relation.ID, &relation.SourceAssetID, &relation.TargetAssetID,
-		&relation.RelationType, &relation.CreatedAt, &metadataJSON,
-	)
+	asset, err := scanAsset(row)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get relation: %w", err)
+		return nil, fmt.Errorf("failed to get asset: %w", err)
 	}
+	return asset, nil
+}
 
-	// Unmarshal metadata if present
-	if metadataJSON.Valid && metadataJSON.String != "" {
-		if err := json.Unmarshal([]byte(metadataJSON.String), &relation.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal relation metadata: %w", err)
-		}
+// GetAssetByExternalID retrieves the asset whose external id for scheme is
+// value. Soft-deleted assets are not returned.
+func (s *Store) GetAssetByExternalID(scheme, value string) (*Asset, error) {
+	if err := validateExternalIDScheme(scheme); err != nil {
+		return nil, err
 	}
 
-	return &relation, nil
+	row := s.db.QueryRow(
+		`SELECT `+assetColumns+` FROM assets WHERE `+externalIDExpr(scheme)+` = ? AND `+assetNotDeleted,
+		value,
+	)
+
+	asset, err := scanAsset(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset: %w", err)
+	}
+	return asset, nil
 }
 
-// GetRelationsBySourceAsset retrieves all relations from a source asset
-func (s *Store) GetRelationsBySourceAsset(assetID string) ([]*AssetRelation, error) {
+// ListAssets retrieves all assets that are not soft-deleted
+func (s *Store) ListAssets() ([]*Asset, error) {
 	rows, err := s.db.Query(
-		`SELECT id, source_asset_id, target_asset_id, relation_type, created_at, metadata
-		 FROM asset_relations WHERE source_asset_id = ? ORDER BY created_at DESC`,
-		assetID,
+		`SELECT ` + assetColumns + ` FROM assets WHERE ` + assetNotDeleted + ` ORDER BY created_at DESC`,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query relations: %w", err)
+		return nil, fmt.Errorf("failed to list assets: %w", err)
 	}
 	defer rows.Close()
 
-	var relations []*AssetRelation
-	for rows.Next() {
-		var relation AssetRelation
-		var metadataJSON sql.NullString
-		if err := rows.Scan(
-			&relation.ID, &relation.SourceAssetID, &relation.TargetAssetID,
-			&relation.RelationType, &relation.CreatedAt, &metadataJSON,
-		); err != nil {
+	return scanAssets(rows)
+}
+
+// scanAssets scans every remaining row into assets
+func scanAssets(rows *sql.Rows) ([]*Asset, error) {
+	var assets []*Asset
+	for rows.Next() {
+		asset, err := scanAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+	return assets, rows.Err()
+}
+
+// DefaultListLimit is the page size used when ListOptions.Limit is unset
+const DefaultListLimit = 100
+
+// ListOptions filters and paginates asset listings
+type ListOptions struct {
+	Limit        int
+	Offset       int
+	TemplateName string
+	Label        string // matches assets whose labels array contains this value
+
+	// IncludeDeleted also lists soft-deleted assets
+	IncludeDeleted bool
+
+	// CreatedAfter and CreatedBefore bound created_at to [after, before);
+	// a zero value leaves that side open
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// OrderBy is one of assetOrderColumns, created_at when empty. OrderDir
+	// is asc or desc; when empty created_at sorts newest first and the
+	// other columns ascending.
+	OrderBy  string
+	OrderDir string
+}
+
+// assetOrderColumns are the columns assets may be listed by. Only these
+// names are ever placed in the ORDER BY clause.
+var assetOrderColumns = []string{"created_at", "name", "template_name"}
+
+// assetOrderClause builds the ORDER BY clause of ListAssetsFiltered. Names
+// sort ignoring ASCII case, and assets sharing a template are ordered by
+// name.
+func assetOrderClause(orderBy, orderDir string) (string, error) {
+	if orderBy == "" {
+		orderBy = "created_at"
+	}
+	var dir string
+	switch strings.ToLower(orderDir) {
+	case "":
+		dir = "ASC"
+		if orderBy == "created_at" {
+			dir = "DESC"
+		}
+	case "asc":
+		dir = "ASC"
+	case "desc":
+		dir = "DESC"
+	default:
+		return "", errorf(ErrInvalid, "invalid order_dir %q (expected asc or desc)", orderDir)
+	}
+
+	switch orderBy {
+	case "created_at":
+		return `created_at ` + dir, nil
+	case "name":
+		return `name COLLATE NOCASE ` + dir, nil
+	case "template_name":
+		return `template_name ` + dir + `, name COLLATE NOCASE`, nil
+	default:
+		return "", errorf(ErrInvalid, "invalid order_by %q (expected one of %s)", orderBy, strings.Join(assetOrderColumns, ", "))
+	}
+}
+
+// createdAtConds returns the conditions bounding created_at to [from, to).
+// created_at is stored as text in the server's local time zone, so bounds
+// are converted to local time to compare correctly and use the index.
+func createdAtConds(from, to time.Time) ([]string, []any) {
+	var conds []string
+	var args []any
+	if !from.IsZero() {
+		conds = append(conds, `created_at >= ?`)
+		args = append(args, from.Local())
+	}
+	if !to.IsZero() {
+		conds = append(conds, `created_at < ?`)
+		args = append(args, to.Local())
+	}
+	return conds, args
+}
+
+// ListAssetsByTimeRange retrieves assets created in [from, to), newest
+// first. A zero bound is open-ended.
+func (s *Store) ListAssetsByTimeRange(from, to time.Time) ([]*Asset, error) {
+	conds, args := createdAtConds(from, to)
+	conds = append([]string{assetNotDeleted}, conds...)
+
+	rows, err := s.db.Query(
+		`SELECT `+assetColumns+` FROM assets WHERE `+strings.Join(conds, " AND ")+` ORDER BY created_at DESC`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assets: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAssets(rows)
+}
+
+// ListAssetsNear retrieves assets within radiusMeters of (lat, lon), nearest
+// first. Candidates are prefiltered with a bounding box in SQL and then
+// checked with the haversine distance.
+func (s *Store) ListAssetsNear(lat, lon, radiusMeters float64) ([]*Asset, error) {
+	if err := validateLocation(&lat, &lon); err != nil {
+		return nil, err
+	}
+	if radiusMeters < 0 {
+		return nil, errorf(ErrInvalid, "radius must not be negative")
+	}
+
+	minLat, maxLat, minLon, maxLon, boundedLon := boundingBox(lat, lon, radiusMeters)
+	query := `SELECT ` + assetColumns + ` FROM assets WHERE ` + assetNotDeleted +
+		` AND latitude BETWEEN ? AND ? AND longitude IS NOT NULL`
+	args := []any{minLat, maxLat}
+	if boundedLon {
+		query += ` AND longitude BETWEEN ? AND ?`
+		args = append(args, minLon, maxLon)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list assets: %w", err)
+	}
+	defer rows.Close()
+
+	candidates, err := scanAssets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	distances := make(map[string]float64, len(candidates))
+	var assets []*Asset
+	for _, asset := range candidates {
+		d := haversineMeters(lat, lon, *asset.Latitude, *asset.Longitude)
+		if d <= radiusMeters {
+			distances[asset.ID] = d
+			assets = append(assets, asset)
+		}
+	}
+	sort.SliceStable(assets, func(i, j int) bool { return distances[assets[i].ID] < distances[assets[j].ID] })
+	return assets, nil
+}
+
+// ListAssetsFiltered retrieves a page of assets matching opts, plus the
+// total number of matching assets before pagination
+func (s *Store) ListAssetsFiltered(opts ListOptions) ([]*Asset, int, error) {
+	order, err := assetOrderClause(opts.OrderBy, opts.OrderDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var conds []string
+	var args []any
+
+	if !opts.IncludeDeleted {
+		conds = append(conds, assetNotDeleted)
+	}
+	if opts.TemplateName != "" {
+		conds = append(conds, `template_name = ?`)
+		args = append(args, opts.TemplateName)
+	}
+	if opts.Label != "" {
+		conds = append(conds, `EXISTS (SELECT 1 FROM json_each(assets.labels) WHERE json_each.value = ?)`)
+		args = append(args, opts.Label)
+	}
+	timeConds, timeArgs := createdAtConds(opts.CreatedAfter, opts.CreatedBefore)
+	conds = append(conds, timeConds...)
+	args = append(args, timeArgs...)
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM assets`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count assets: %w", err)
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	offset := opts.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := s.db.Query(
+		`SELECT `+assetColumns+` FROM assets`+where+` ORDER BY `+order+` LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list assets: %w", err)
+	}
+	defer rows.Close()
+
+	assets, err := scanAssets(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return assets, total, nil
+}
+
+// AssetSearch selects assets by labels and name; every criterion that is
+// set must match
+type AssetSearch struct {
+	Labels   []string // plain labels
+	MatchAll bool     // every one of Labels rather than at least one
+
+	// LabelKey matches "key:value" labels with that key, restricted to
+	// LabelValue when set. Labels are split at the first colon, so
+	// "url:http://x" has key "url" and value "http://x".
+	LabelKey   string
+	LabelValue string
+
+	// NamePrefix matches names starting with it, ignoring ASCII case. The
+	// results are then ordered by name and capped at Limit, or at
+	// DefaultListLimit when Limit is not positive; otherwise they are
+	// ordered newest first.
+	NamePrefix string
+	Limit      int
+}
+
+// SearchAssets returns the assets matching q, soft-deleted ones excluded.
+// The criteria are combined in one query, so the limit applies to assets
+// matching all of them.
+func (s *Store) SearchAssets(q AssetSearch) ([]*Asset, error) {
+	query, args, err := assetSearchQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search assets: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAssets(rows)
+}
+
+// likeEscaper escapes the LIKE wildcards of a literal, with \ as the
+// escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// assetSearchQuery builds the query of SearchAssets. A name prefix is
+// matched with LIKE, which the NOCASE name index serves, so it stays cheap
+// on large fleets.
+func assetSearchQuery(q AssetSearch) (string, []any, error) {
+	conds := []string{assetNotDeleted}
+	var args []any
+
+	if len(q.Labels) > 0 {
+		// Deduplicate so the MatchAll count comparison is exact
+		seen := make(map[string]bool, len(q.Labels))
+		var labels []any
+		for _, label := range q.Labels {
+			if !seen[label] {
+				seen[label] = true
+				labels = append(labels, label)
+			}
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(labels)), ", ")
+		args = append(args, labels...)
+		if q.MatchAll {
+			conds = append(conds, `(SELECT COUNT(DISTINCT json_each.value) FROM json_each(assets.labels) WHERE json_each.value IN (`+placeholders+`)) = ?`)
+			args = append(args, len(labels))
+		} else {
+			conds = append(conds, `EXISTS (SELECT 1 FROM json_each(assets.labels) WHERE json_each.value IN (`+placeholders+`))`)
+		}
+	}
+
+	if q.LabelKey != "" {
+		cond := `EXISTS (SELECT 1 FROM asset_label_kv kv WHERE kv.asset_id = assets.id AND kv.key = ?`
+		args = append(args, q.LabelKey)
+		if q.LabelValue != "" {
+			cond += ` AND kv.value = ?`
+			args = append(args, q.LabelValue)
+		}
+		conds = append(conds, cond+`)`)
+	} else if q.LabelValue != "" {
+		return "", nil, errorf(ErrInvalid, "label value requires a label key")
+	}
+
+	if q.NamePrefix != "" {
+		conds = append(conds, `name LIKE ? ESCAPE '\'`)
+		args = append(args, likeEscaper.Replace(q.NamePrefix)+"%")
+	}
+
+	if len(conds) == 1 {
+		return "", nil, errorf(ErrInvalid, "labels, a label key or a name prefix is required")
+	}
+
+	query := `SELECT ` + assetColumns + ` FROM assets WHERE ` + strings.Join(conds, " AND ")
+	if q.NamePrefix == "" {
+		return query + ` ORDER BY created_at DESC`, args, nil
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	return query + ` ORDER BY name COLLATE NOCASE, name LIMIT ?`, append(args, limit), nil
+}
+
+// SearchAssetsByLabels returns assets carrying every label (matchAll) or at
+// least one of them, newest first
+func (s *Store) SearchAssetsByLabels(labels []string, matchAll bool) ([]*Asset, error) {
+	if len(labels) == 0 {
+		return nil, errorf(ErrInvalid, "at least one label is required")
+	}
+	return s.SearchAssets(AssetSearch{Labels: labels, MatchAll: matchAll})
+}
+
+// SearchAssetsByNamePrefix returns up to limit assets whose name starts with
+// prefix, ignoring ASCII case, ordered by name. A non-positive limit uses
+// DefaultListLimit.
+func (s *Store) SearchAssetsByNamePrefix(prefix string, limit int) ([]*Asset, error) {
+	if prefix == "" {
+		return nil, errorf(ErrInvalid, "name prefix is required")
+	}
+	return s.SearchAssets(AssetSearch{NamePrefix: prefix, Limit: limit})
+}
+
+// FindAssetsByLabelKV returns assets with a "key:value" label, newest
+// first. An empty value matches every value of key.
+func (s *Store) FindAssetsByLabelKV(key, value string) ([]*Asset, error) {
+	if key == "" {
+		return nil, errorf(ErrInvalid, "label key is required")
+	}
+	return s.SearchAssets(AssetSearch{LabelKey: key, LabelValue: value})
+}
+
+// DeleteAsset permanently deletes an asset by ID, soft-deleted or not. Its
+// relations are removed with it.
+func (s *Store) DeleteAsset(id string) error {
+	result, err := s.db.Exec(`DELETE FROM assets WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete asset: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errorf(ErrAssetNotFound, "asset not found: %s", id)
+	}
+	s.assetsChanged(id)
+	return nil
+}
+
+// SoftDeleteAsset marks an asset as deleted without removing it or its
+// relations. It is hidden from lookups until RestoreAsset is called.
+func (s *Store) SoftDeleteAsset(id string) error {
+	result, err := s.db.Exec(`UPDATE assets SET deleted_at = ? WHERE id = ? AND `+assetNotDeleted, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete asset: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errorf(ErrAssetNotFound, "asset not found: %s", id)
+	}
+	s.assetsChanged(id)
+	return nil
+}
+
+// RestoreAsset undoes SoftDeleteAsset
+func (s *Store) RestoreAsset(id string) error {
+	result, err := s.db.Exec(`UPDATE assets SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore asset: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errorf(ErrAssetNotFound, "deleted asset not found: %s", id)
+	}
+	s.assetsChanged(id)
+	return nil
+}
+
+// AssetExists checks if an asset exists and is not soft-deleted
+func (s *Store) AssetExists(id string) (bool, error) {
+	return assetExists(s.db, id)
+}
+
+// assetExists checks if an asset exists and is not soft-deleted using q
+func assetExists(q querier, id string) (bool, error) {
+	var count int
+	err := q.QueryRow(`SELECT COUNT(*) FROM assets WHERE id = ? AND `+assetNotDeleted, id).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// UpdateAssetTemplate updates an asset's template
+func (s *Store) UpdateAssetTemplate(id, templateName string) error {
+	result, err := s.db.Exec(
+		`UPDATE assets SET template_name = ? WHERE id = ? AND `+assetNotDeleted,
+		templateName, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update asset: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errorf(ErrAssetNotFound, "asset not found: %s", id)
+	}
+	s.assetsChanged(id)
+	return nil
+}
+
+// AdoptAssetTemplate sets the template and version of an asset that has no
+// template yet and reports whether it did. An asset that has one keeps it,
+// even if it was set after the caller looked.
+func (s *Store) AdoptAssetTemplate(id, templateName string, version int) (bool, error) {
+	result, err := s.db.Exec(
+		`UPDATE assets SET template_name = ?, template_version = ?
+		 WHERE id = ? AND (template_name IS NULL OR template_name = '') AND `+assetNotDeleted,
+		templateName, version, id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update asset: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return false, nil
+	}
+	s.assetsChanged(id)
+	return true, nil
+}
+
+// Asset fields accepted by UpdateAsset
+const (
+	AssetFieldName         = "name"
+	AssetFieldTemplateName = "template_name"
+	AssetFieldLabels       = "labels"
+	AssetFieldLocation     = "location" // latitude, longitude and altitude together
+	AssetFieldAttributes   = "attributes"
+	AssetFieldExternalIDs  = "external_ids" // replaces every scheme
+)
+
+// UpdateAsset updates only the listed fields of an existing asset, taking
+// the new values from asset
+func (s *Store) UpdateAsset(asset *Asset, fields []string) error {
+	if len(fields) == 0 {
+		return errorf(ErrInvalid, "no fields to update")
+	}
+
+	var sets []string
+	var args []any
+	for _, field := range fields {
+		switch field {
+		case AssetFieldName:
+			sets = append(sets, "name = ?")
+			args = append(args, asset.Name)
+		case AssetFieldTemplateName:
+			// the recorded version follows the template it belongs to
+			sets = append(sets, "template_name = ?", "template_version = ?")
+			args = append(args, asset.TemplateName, asset.TemplateVersion)
+		case AssetFieldLocation:
+			sets = append(sets, "latitude = ?", "longitude = ?", "altitude = ?")
+			args = append(args, asset.Latitude, asset.Longitude, asset.Altitude)
+		case AssetFieldLabels:
+			if err := s.checkLabels(asset.Labels); err != nil {
+				return err
+			}
+			labels, err := json.Marshal(asset.Labels)
+			if err != nil {
+				return fmt.Errorf("failed to marshal asset labels: %w", err)
+			}
+			sets = append(sets, "labels = ?")
+			args = append(args, string(labels))
+		case AssetFieldAttributes:
+			attributes, err := json.Marshal(asset.Attributes)
+			if err != nil {
+				return fmt.Errorf("failed to marshal asset attributes: %w", err)
+			}
+			sets = append(sets, "attributes = ?")
+			args = append(args, string(attributes))
+		case AssetFieldExternalIDs:
+			externalIDs, err := prepareExternalIDs(s.db, asset.ExternalIDs)
+			if err != nil {
+				return err
+			}
+			sets = append(sets, "external_ids = ?")
+			args = append(args, externalIDs)
+		default:
+			return errorf(ErrInvalid, "unknown asset field: %s", field)
+		}
+	}
+	args = append(args, asset.ID)
+
+	result, err := s.db.Exec(
+		`UPDATE assets SET `+strings.Join(sets, ", ")+` WHERE id = ? AND `+assetNotDeleted,
+		args...,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return duplicateAssetError(err, asset)
+		}
+		return fmt.Errorf("failed to update asset: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return errorf(ErrAssetNotFound, "asset not found: %s", asset.ID)
+	}
+	s.assetsChanged(asset.ID)
+	return nil
+}
+
+// isUniqueViolation reports whether err is a SQLite UNIQUE constraint failure.
+// The message is matched rather than the sqlite3.Error code so this also
+// compiles in CGO_ENABLED=0 builds.
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// GetStats returns store statistics
+func (s *Store) GetStats() (*StoreStats, error) {
+	assetsByTemplate, totalAssets, err := s.countGroups(`SELECT COALESCE(template_name, ''), COUNT(*) FROM assets WHERE ` + assetNotDeleted + ` GROUP BY 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count assets: %w", err)
+	}
+
+	relationsByType, totalRelations, err := s.countGroups(`SELECT relation_type, COUNT(*) FROM asset_relations GROUP BY 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count relations: %w", err)
+	}
+
+	return &StoreStats{
+		TotalAssets:      totalAssets,
+		AssetsByTemplate: assetsByTemplate,
+		TotalRelations:   totalRelations,
+		RelationsByType:  relationsByType,
+		LastUpdated:      time.Now(),
+	}, nil
+}
+
+// countGroups runs a (key, count) GROUP BY query and returns the counts by
+// key along with their sum
+func (s *Store) countGroups(query string) (map[string]int, int, error) {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	total := 0
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, 0, err
+		}
+		counts[key] = count
+		total += count
+	}
+	return counts, total, rows.Err()
+}
+
+// ==================== AssetRelation Methods ====================
+
+// CreateRelation creates a new asset relation. The existence and cycle checks
+// run in the same transaction as the insert, so a concurrent asset delete
+// cannot slip between them. Symmetric relations are canonicalized in place
+// first, see CanonicalizeRelation.
+func (s *Store) CreateRelation(relation *AssetRelation) error {
+	if !IsValidRelationType(relation.RelationType) {
+		return errorf(ErrInvalidRelationType, "invalid relation type: %s", relation.RelationType)
+	}
+	CanonicalizeRelation(relation)
+	metadataJSON, attributesJSON, err := marshalRelationJSON(relation)
+	if err != nil {
+		return err
+	}
+	if err := s.checkRelationMetadata(metadataJSON, attributesJSON); err != nil {
+		return err
+	}
+	if err := validateRelationWeight(relation.Weight); err != nil {
+		return err
+	}
+
+	return s.WithTx(func(tx *sql.Tx) error {
+		// Validate source and target assets exist
+		sourceExists, err := assetExists(tx, relation.SourceAssetID)
+		if err != nil {
+			return fmt.Errorf("failed to check source asset: %w", err)
+		}
+		if !sourceExists {
+			return errorf(ErrAssetNotFound, "source asset not found: %s", relation.SourceAssetID)
+		}
+
+		targetExists, err := assetExists(tx, relation.TargetAssetID)
+		if err != nil {
+			return fmt.Errorf("failed to check target asset: %w", err)
+		}
+		if !targetExists {
+			return errorf(ErrAssetNotFound, "target asset not found: %s", relation.TargetAssetID)
+		}
+
+		// Hierarchical relations must not form a cycle
+		if IsHierarchicalRelationType(relation.RelationType) {
+			cycle, err := wouldCreateCycle(tx, relation.SourceAssetID, relation.TargetAssetID, relation.RelationType)
+			if err != nil {
+				return fmt.Errorf("failed to check for cycles: %w", err)
+			}
+			if cycle {
+				return errorf(ErrRelationCycle, "relation would create a cycle")
+			}
+		}
+
+		// Insert relation; the unique constraint also catches the reverse of
+		// a symmetric relation, as both are stored in canonical order
+		_, err = tx.Exec(
+			`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at, metadata, attributes, weight)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			relation.ID, relation.SourceAssetID, relation.TargetAssetID,
+			relation.RelationType, relation.CreatedAt, metadataJSON, attributesJSON, relation.Weight,
+		)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return errorf(ErrRelationExists, "relation already exists")
+			}
+			return fmt.Errorf("failed to create relation: %w", err)
+		}
+		return nil
+	})
+}
+
+// marshalRelationJSON encodes the metadata and attributes columns, each
+// empty when unset. Attributes are checked with validateRelationAttributes.
+func marshalRelationJSON(relation *AssetRelation) (metadata, attributes string, err error) {
+	if relation.Metadata != nil {
+		encoded, err := json.Marshal(relation.Metadata)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		metadata = string(encoded)
+	}
+	if relation.Attributes != nil {
+		if attributes, err = marshalRelationAttributes(relation.Attributes); err != nil {
+			return "", "", err
+		}
+	}
+	return metadata, attributes, nil
+}
+
+// marshalRelationAttributes checks and encodes relation attributes
+func marshalRelationAttributes(attributes map[string]any) (string, error) {
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return "", errorf(ErrInvalid, "invalid relation attributes: %v", err)
+	}
+	if err := validateRelationAttributes(encoded); err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// RelationBatchError lists the entries that caused CreateRelationsBatch to
+// reject a batch
+type RelationBatchError struct {
+	Items []BatchItemError
+}
+
+func (e *RelationBatchError) Error() string {
+	reasons := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		reasons = append(reasons, fmt.Sprintf("relation %d: %s", item.Index, item.Error))
+	}
+	return "relation batch rejected: " + strings.Join(reasons, "; ")
+}
+
+// Unwrap classifies batch rejections as ErrInvalid
+func (e *RelationBatchError) Unwrap() error { return ErrInvalid }
+
+// CreateRelationsBatch creates all relations in a single transaction. Relation
+// types and source/target existence are checked for every entry before any
+// insert, and all failures are reported together as a *RelationBatchError.
+// Hierarchical relations are then checked for cycles one by one, so a cycle
+// formed within the batch itself is rejected as well. Symmetric relations
+// are canonicalized in place like in CreateRelation.
+func (s *Store) CreateRelationsBatch(relations []*AssetRelation) error {
+	metadata := make([]string, len(relations))
+	attributes := make([]string, len(relations))
+	for i, relation := range relations {
+		CanonicalizeRelation(relation)
+		m, a, err := marshalRelationJSON(relation)
+		if err == nil {
+			err = s.checkRelationMetadata(m, a)
+		}
+		if err == nil {
+			err = validateRelationWeight(relation.Weight)
+		}
+		if err != nil {
+			return fmt.Errorf("relation %d: %w", i, err)
+		}
+		metadata[i], attributes[i] = m, a
+	}
+
+	return s.WithTx(func(tx *sql.Tx) error {
+		exists := make(map[string]bool)
+		checkAsset := func(id string) (bool, error) {
+			if found, ok := exists[id]; ok {
+				return found, nil
+			}
+			found, err := assetExists(tx, id)
+			if err != nil {
+				return false, fmt.Errorf("failed to check asset %s: %w", id, err)
+			}
+			exists[id] = found
+			return found, nil
+		}
+
+		var invalid []BatchItemError
+		for i, relation := range relations {
+			if !IsValidRelationType(relation.RelationType) {
+				invalid = append(invalid, BatchItemError{Index: i, Error: fmt.Sprintf("invalid relation type: %s", relation.RelationType)})
+				continue
+			}
+			sourceExists, err := checkAsset(relation.SourceAssetID)
+			if err != nil {
+				return err
+			}
+			if !sourceExists {
+				invalid = append(invalid, BatchItemError{Index: i, Error: fmt.Sprintf("source asset not found: %s", relation.SourceAssetID)})
+				continue
+			}
+			targetExists, err := checkAsset(relation.TargetAssetID)
+			if err != nil {
+				return err
+			}
+			if !targetExists {
+				invalid = append(invalid, BatchItemError{Index: i, Error: fmt.Sprintf("target asset not found: %s", relation.TargetAssetID)})
+			}
+		}
+		if len(invalid) > 0 {
+			return &RelationBatchError{Items: invalid}
+		}
+
+		stmt, err := tx.Prepare(`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at, metadata, attributes, weight)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare relation insert: %w", err)
+		}
+		defer stmt.Close()
+
+		for i, relation := range relations {
+			if IsHierarchicalRelationType(relation.RelationType) {
+				cycle, err := wouldCreateCycle(tx, relation.SourceAssetID, relation.TargetAssetID, relation.RelationType)
+				if err != nil {
+					return fmt.Errorf("failed to check for cycles: %w", err)
+				}
+				if cycle {
+					return &RelationBatchError{Items: []BatchItemError{{Index: i, Error: "relation would create a cycle"}}}
+				}
+			}
+			if _, err := stmt.Exec(relation.ID, relation.SourceAssetID, relation.TargetAssetID,
+				relation.RelationType, relation.CreatedAt, metadata[i], attributes[i], relation.Weight); err != nil {
+				if isUniqueViolation(err) {
+					return &RelationBatchError{Items: []BatchItemError{{Index: i, Error: "relation already exists"}}}
+				}
+				return fmt.Errorf("failed to create relation %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
+
+// CanonicalizeRelations rewrites stored symmetric relations into canonical
+// order, see CanonicalizeRelation, and returns how many rows changed. A row
+// whose canonical counterpart is already stored is a duplicate and deleted.
+// Migration 12 runs this once; it is only needed again after rows were
+// written around the store or a registered type became symmetric.
+func (s *Store) CanonicalizeRelations() (int, error) {
+	var changed int
+	err := s.WithTx(func(tx *sql.Tx) error {
+		var err error
+		changed, err = canonicalizeRelations(tx)
+		return err
+	})
+	return changed, err
+}
+
+// millisValues returns values with their timestamps in unix milliseconds,
+// copying the slice only when a timestamp is in seconds
+func millisValues(values []TagValue) []TagValue {
+	out := values
+	for i, v := range values {
+		if v.Timestamp == nil || timestampMillis(*v.Timestamp) == *v.Timestamp {
+			continue
+		}
+		if &out[0] == &values[0] {
+			out = append([]TagValue(nil), values...)
+		}
+		ts := timestampMillis(*v.Timestamp)
+		out[i].Timestamp = &ts
+	}
+	return out
+}
+
+// millisDataTimestamps converts the message and tag timestamps stored in
+// unix seconds, which were accepted on ingest before they were normalized,
+// to unix milliseconds
+func millisDataTimestamps(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, timestamp, tag_values FROM asset_data
+		WHERE (timestamp > ? AND timestamp < ?) OR EXISTS (
+			SELECT 1 FROM json_each(asset_data.tag_values) v
+			WHERE json_extract(v.value, '$.timestamp') > ? AND json_extract(v.value, '$.timestamp') < ?)`,
+		-int64(unixSecondsLimit), int64(unixSecondsLimit), -int64(unixSecondsLimit), int64(unixSecondsLimit))
+	if err != nil {
+		return err
+	}
+	type row struct {
+		id        int64
+		timestamp int64
+		values    string
+	}
+	var stale []row
+	for rows.Next() {
+		var r row
+		var values sql.NullString
+		if err := rows.Scan(&r.id, &r.timestamp, &values); err != nil {
+			rows.Close()
+			return err
+		}
+		r.values = values.String
+		stale = append(stale, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range stale {
+		values := r.values
+		if values != "" {
+			var tags []TagValue
+			if err := json.Unmarshal([]byte(values), &tags); err != nil {
+				return fmt.Errorf("failed to parse tag values of row %d: %w", r.id, err)
+			}
+			data, err := json.Marshal(millisValues(tags))
+			if err != nil {
+				return err
+			}
+			values = string(data)
+		}
+		if _, err := tx.Exec(`UPDATE asset_data SET timestamp = ?, tag_values = ? WHERE id = ?`,
+			timestampMillis(r.timestamp), values, r.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canonicalizeRelations implements CanonicalizeRelations using q
+func canonicalizeRelations(q querier) (int, error) {
+	types := symmetricRelationTypes()
+	if len(types) == 0 {
+		return 0, nil
+	}
+	args := make([]any, len(types))
+	for i, rt := range types {
+		args[i] = rt
+	}
+	reversed := `relation_type IN (?` + strings.Repeat(", ?", len(types)-1) + `) AND source_asset_id > target_asset_id`
+
+	deleted, err := q.Exec(`DELETE FROM asset_relations WHERE `+reversed+` AND EXISTS (
+		SELECT 1 FROM asset_relations c
+		WHERE c.source_asset_id = asset_relations.target_asset_id
+		  AND c.target_asset_id = asset_relations.source_asset_id
+		  AND c.relation_type = asset_relations.relation_type)`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete duplicate relations: %w", err)
+	}
+	swapped, err := q.Exec(`UPDATE asset_relations
+		SET source_asset_id = target_asset_id, target_asset_id = source_asset_id
+		WHERE `+reversed, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to canonicalize relations: %w", err)
+	}
+
+	d, _ := deleted.RowsAffected()
+	n, _ := swapped.RowsAffected()
+	return int(d + n), nil
+}
+
+// relationExists reports whether a source -> target relation of type rt exists
+func relationExists(q querier, source, target string, rt RelationType) (bool, error) {
+	var exists bool
+	err := q.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM asset_relations WHERE source_asset_id = ? AND target_asset_id = ? AND relation_type = ?)`,
+		source, target, rt,
+	).Scan(&exists)
+	return exists, err
+}
+
+// relationID returns the ID of the source -> target relation of type rt,
+// or "" if there is none
+func relationID(q querier, source, target string, rt RelationType) (string, error) {
+	var id string
+	err := q.QueryRow(
+		`SELECT id FROM asset_relations WHERE source_asset_id = ? AND target_asset_id = ? AND relation_type = ?`,
+		source, target, rt,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// maxCycleCheckNodes bounds the traversal done by wouldCreateCycle
+const maxCycleCheckNodes = 10000
+
+// wouldCreateCycle reports whether adding source -> target with type rt would
+// close a cycle, i.e. whether source is already reachable from target by
+// following rt relations
+func wouldCreateCycle(q querier, source, target string, rt RelationType) (bool, error) {
+	if source == target {
+		return true, nil
+	}
+
+	visited := map[string]bool{target: true}
+	queue := []string{target}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		next, err := relatedAssetIDs(q, current, rt, true)
+		if err != nil {
+			return false, err
+		}
+
+		for _, id := range next {
+			if id == source {
+				return true, nil
+			}
+			if visited[id] {
+				continue
+			}
+			if len(visited) >= maxCycleCheckNodes {
+				return false, fmt.Errorf("relation graph exceeds %d nodes", maxCycleCheckNodes)
+			}
+			visited[id] = true
+			queue = append(queue, id)
+		}
+	}
+
+	return false, nil
+}
+
+// relatedAssetIDs returns the assets linked to assetID by rt relations:
+// targets of its outgoing relations, or sources of its incoming ones
+func relatedAssetIDs(q querier, assetID string, rt RelationType, outgoing bool) ([]string, error) {
+	query := `SELECT target_asset_id FROM asset_relations WHERE source_asset_id = ? AND relation_type = ? ORDER BY created_at, rowid`
+	if !outgoing {
+		query = `SELECT source_asset_id FROM asset_relations WHERE target_asset_id = ? AND relation_type = ? ORDER BY created_at, rowid`
+	}
+
+	rows, err := q.Query(query, assetID, rt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query relations: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
 			return nil, err
 		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Tree traversal directions
+const (
+	TreeDescendants = "descendants"
+	TreeAncestors   = "ancestors"
+)
+
+// GetDescendants returns every asset below assetID in the rt hierarchy
+// (assets that are, directly or transitively, the source of an rt relation
+// targeting it), in breadth-first order
+func (s *Store) GetDescendants(assetID string, rt RelationType) ([]*Asset, error) {
+	return s.TraverseRelations(assetID, rt, TreeDescendants, 0)
+}
+
+// GetAncestors returns the chain of assets above assetID in the rt
+// hierarchy, in breadth-first order
+func (s *Store) GetAncestors(assetID string, rt RelationType) ([]*Asset, error) {
+	return s.TraverseRelations(assetID, rt, TreeAncestors, 0)
+}
+
+// TraverseRelations walks rt relations from assetID in the given direction
+// up to maxDepth levels (0 means unlimited) and returns the reached assets in
+// breadth-first order, excluding assetID itself. A visited set guards against
+// cycles in malformed graphs.
+func (s *Store) TraverseRelations(assetID string, rt RelationType, direction string, maxDepth int) ([]*Asset, error) {
+	var outgoing bool
+	switch direction {
+	case TreeDescendants:
+		outgoing = false
+	case TreeAncestors:
+		outgoing = true
+	default:
+		return nil, errorf(ErrInvalid, "invalid direction: %s", direction)
+	}
+
+	visited := map[string]bool{assetID: true}
+	level := []string{assetID}
+	var order []string
 
-		if metadataJSON.Valid && metadataJSON.String != "" {
-			if err := json.Unmarshal([]byte(metadataJSON.String), &relation.Metadata); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal relation metadata: %w", err)
+	for depth := 1; len(level) > 0 && (maxDepth <= 0 || depth <= maxDepth); depth++ {
+		var next []string
+		for _, current := range level {
+			ids, err := relatedAssetIDs(s.db, current, rt, outgoing)
+			if err != nil {
+				return nil, err
 			}
+			for _, id := range ids {
+				if visited[id] {
+					continue
+				}
+				visited[id] = true
+				next = append(next, id)
+			}
+		}
+		order = append(order, next...)
+		level = next
+	}
+
+	assets := make([]*Asset, 0, len(order))
+	for _, id := range order {
+		asset, err := s.GetAsset(id)
+		if err != nil {
+			return nil, err
 		}
+		if asset != nil {
+			assets = append(assets, asset)
+		}
+	}
+	return assets, nil
+}
+
+// relationColumns is the column list shared by every relation SELECT
+const relationColumns = `id, source_asset_id, target_asset_id, relation_type, created_at, metadata, attributes, weight`
+
+// scanRelation scans a single relation row selected with relationColumns
+func scanRelation(row rowScanner) (*AssetRelation, error) {
+	var relation AssetRelation
+	var metadataJSON, attributesJSON sql.NullString
+	var weight sql.NullFloat64
+	if err := row.Scan(
+		&relation.ID, &relation.SourceAssetID, &relation.TargetAssetID,
+		&relation.RelationType, &relation.CreatedAt, &metadataJSON, &attributesJSON, &weight,
+	); err != nil {
+		return nil, err
+	}
+	if weight.Valid {
+		relation.Weight = &weight.Float64
+	}
 
-		relations = append(relations, &relation)
+	// Unmarshal metadata if present
+	if metadataJSON.Valid && metadataJSON.String != "" {
+		if err := json.Unmarshal([]byte(metadataJSON.String), &relation.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal relation metadata: %w", err)
+		}
+	}
+	if attributesJSON.Valid && attributesJSON.String != "" {
+		if err := json.Unmarshal([]byte(attributesJSON.String), &relation.Attributes); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal relation attributes: %w", err)
+		}
 	}
 
-	return relations, nil
+	return &relation, nil
 }
 
-// GetRelationsByTargetAsset retrieves all relations to a target asset
-func (s *Store) GetRelationsByTargetAsset(assetID string) ([]*AssetRelation, error) {
+// FindRelation returns the ID of the relation CreateRelation would report
+// as a duplicate of source -> target with type rt: the same triple or, for
+// symmetric types, the reverse one. It returns "" if there is none.
+func (s *Store) FindRelation(source, target string, rt RelationType) (string, error) {
+	id, err := relationID(s.db, source, target, rt)
+	if err == nil && id == "" && IsSymmetricRelationType(rt) {
+		id, err = relationID(s.db, target, source, rt)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to find relation: %w", err)
+	}
+	return id, nil
+}
+
+// RelationExists reports whether creating source -> target with type rt
+// would fail as a duplicate
+func (s *Store) RelationExists(source, target string, rt RelationType) (bool, error) {
+	id, err := s.FindRelation(source, target, rt)
+	return id != "", err
+}
+
+// CountRelations returns the number of relations pointing to and from
+// assetID, using the source and target indexes. A symmetric relation counts
+// in the direction it is stored, see CanonicalizeRelation. An unknown asset
+// has no relations.
+func (s *Store) CountRelations(assetID string) (incoming, outgoing int, err error) {
+	err = s.db.QueryRow(
+		`SELECT (SELECT COUNT(*) FROM asset_relations WHERE target_asset_id = ?),
+		        (SELECT COUNT(*) FROM asset_relations WHERE source_asset_id = ?)`,
+		assetID, assetID,
+	).Scan(&incoming, &outgoing)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count relations: %w", err)
+	}
+	return incoming, outgoing, nil
+}
+
+// GetRelationsBetween returns every relation between assets a and b, in
+// either direction, oldest first. Each is tagged with its direction as seen
+// from a.
+func (s *Store) GetRelationsBetween(a, b string) ([]*RelationBetween, error) {
 	rows, err := s.db.Query(
-		`SELECT id, source_asset_id, target_asset_id, relation_type, created_at, metadata
-		 FROM asset_relations WHERE target_asset_id = ? ORDER BY created_at DESC`,
-		assetID,
+		`SELECT `+relationColumns+` FROM asset_relations
+		 WHERE (source_asset_id = ? AND target_asset_id = ?) OR (source_asset_id = ? AND target_asset_id = ?)
+		 ORDER BY created_at, rowid`,
+		a, b, b, a,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relations between assets: %w", err)
+	}
+	defer rows.Close()
+
+	relations, err := scanRelations(rows)
+	if err != nil {
+		return nil, err
+	}
+	between := make([]*RelationBetween, len(relations))
+	for i, relation := range relations {
+		direction := "outgoing"
+		if relation.SourceAssetID != a {
+			direction = "incoming"
+		}
+		between[i] = &RelationBetween{AssetRelation: relation, Direction: direction}
+	}
+	return between, nil
+}
+
+// GetRelation retrieves a relation by ID
+func (s *Store) GetRelation(id string) (*AssetRelation, error) {
+	row := s.db.QueryRow(
+		`SELECT `+relationColumns+` FROM asset_relations WHERE id = ?`,
+		id,
 	)
+
+	relation, err := scanRelation(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relation: %w", err)
+	}
+	return relation, nil
+}
+
+// RelationQuery filters relation listings; zero-valued fields are ignored
+type RelationQuery struct {
+	SourceAssetID string
+	TargetAssetID string
+	RelationType  RelationType
+	CreatedAfter  int64 // unix seconds, inclusive
+	CreatedBefore int64 // unix seconds, inclusive
+}
+
+// QueryRelations retrieves relations matching q, newest first
+func (s *Store) QueryRelations(q RelationQuery) ([]*AssetRelation, error) {
+	var conds []string
+	var args []any
+
+	if q.SourceAssetID != "" {
+		conds = append(conds, `source_asset_id = ?`)
+		args = append(args, q.SourceAssetID)
+	}
+	if q.TargetAssetID != "" {
+		conds = append(conds, `target_asset_id = ?`)
+		args = append(args, q.TargetAssetID)
+	}
+	if q.RelationType != "" {
+		conds = append(conds, `relation_type = ?`)
+		args = append(args, q.RelationType)
+	}
+	if q.CreatedAfter != 0 {
+		conds = append(conds, `unixepoch(created_at) >= ?`)
+		args = append(args, q.CreatedAfter)
+	}
+	if q.CreatedBefore != 0 {
+		conds = append(conds, `unixepoch(created_at) <= ?`)
+		args = append(args, q.CreatedBefore)
+	}
+
+	query := `SELECT ` + relationColumns + ` FROM asset_relations`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query relations: %w", err)
 	}
 	defer rows.Close()
 
+	return scanRelations(rows)
+}
+
+// ListRelations retrieves a page of all relations, newest first, plus the
+// total number of relations. A non-positive limit uses DefaultListLimit.
+func (s *Store) ListRelations(limit, offset int) ([]*AssetRelation, int, error) {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM asset_relations`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count relations: %w", err)
+	}
+
+	rows, err := s.db.Query(
+		`SELECT `+relationColumns+` FROM asset_relations ORDER BY created_at DESC, rowid DESC LIMIT ? OFFSET ?`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list relations: %w", err)
+	}
+	defer rows.Close()
+
+	relations, err := scanRelations(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return relations, total, nil
+}
+
+// scanRelations scans every remaining row into relations
+func scanRelations(rows *sql.Rows) ([]*AssetRelation, error) {
 	var relations []*AssetRelation
 	for rows.Next() {
-		var relation AssetRelation
-		var metadataJSON sql.NullString
-		if err := rows.Scan(
-			&relation.ID, &relation.SourceAssetID, &relation.TargetAssetID,
-			&relation.RelationType, &relation.CreatedAt, &metadataJSON,
-		); err != nil {
+		relation, err := scanRelation(rows)
+		if err != nil {
 			return nil, err
 		}
+		relations = append(relations, relation)
+	}
+	return relations, rows.Err()
+}
 
-		if metadataJSON.Valid && metadataJSON.String != "" {
-			if err := json.Unmarshal([]byte(metadataJSON.String), &relation.Metadata); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal relation metadata: %w", err)
-			}
-		}
+// GetRelationsBySourceAsset retrieves all relations from a source asset
+func (s *Store) GetRelationsBySourceAsset(assetID string) ([]*AssetRelation, error) {
+	return s.QueryRelations(RelationQuery{SourceAssetID: assetID})
+}
 
-		relations = append(relations, &relation)
-	}
+// GetRelationsByTargetAsset retrieves all relations to a target asset
+func (s *Store) GetRelationsByTargetAsset(assetID string) ([]*AssetRelation, error) {
+	return s.QueryRelations(RelationQuery{TargetAssetID: assetID})
+}
 
-	return relations, nil
+// GetRelationsByType retrieves all relations of a given type
+func (s *Store) GetRelationsByType(rt RelationType) ([]*AssetRelation, error) {
+	return s.QueryRelations(RelationQuery{RelationType: rt})
 }
 
 // DeleteRelation deletes a relation by ID
@@ -385,7 +2010,278 @@ func (s *Store) DeleteRelation(id string) error {
 
 	affected, _ := result.RowsAffected()
 	if affected == 0 {
-		return fmt.Errorf("relation not found: %s", id)
+		return errorf(ErrRelationNotFound, "relation not found: %s", id)
 	}
 	return nil
 }
+
+// UpdateRelationMetadata replaces the metadata of an existing relation and
+// returns the updated relation. Source, target and type are immutable.
+func (s *Store) UpdateRelationMetadata(id string, metadata map[string]string) (*AssetRelation, error) {
+	encoded, _, err := marshalRelationJSON(&AssetRelation{Metadata: metadata})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRelationMetadata(encoded, ""); err != nil {
+		return nil, err
+	}
+	return s.updateRelation(id, `metadata = ?`, encoded)
+}
+
+// UpdateRelation replaces the metadata of an existing relation and returns
+// the updated relation. Attributes and weight are replaced too unless nil,
+// which keeps them, so clients unaware of them do not clear them; an empty
+// attributes map clears them, and clearWeight clears the weight.
+func (s *Store) UpdateRelation(id string, metadata map[string]string, attributes map[string]any, weight *float64, clearWeight bool) (*AssetRelation, error) {
+	encoded, encodedAttributes, err := marshalRelationJSON(&AssetRelation{Metadata: metadata, Attributes: attributes})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRelationMetadata(encoded, encodedAttributes); err != nil {
+		return nil, err
+	}
+	if err := validateRelationWeight(weight); err != nil {
+		return nil, err
+	}
+	if weight != nil && clearWeight {
+		return nil, errorf(ErrInvalid, "weight and clear_weight are mutually exclusive")
+	}
+
+	assignments, args := `metadata = ?`, []any{encoded}
+	if attributes != nil {
+		assignments += `, attributes = ?`
+		args = append(args, encodedAttributes)
+	}
+	if weight != nil || clearWeight {
+		assignments += `, weight = ?`
+		args = append(args, weight)
+	}
+	return s.updateRelation(id, assignments, args...)
+}
+
+// updateRelation applies the SET clause assignments to relation id and
+// returns the updated relation
+func (s *Store) updateRelation(id, assignments string, args ...any) (*AssetRelation, error) {
+	result, err := s.db.Exec(`UPDATE asset_relations SET `+assignments+` WHERE id = ?`, append(args, id)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update relation: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return nil, errorf(ErrRelationNotFound, "relation not found: %s", id)
+	}
+	return s.GetRelation(id)
+}
+
+// ==================== AssetData Methods ====================
+
+// InsertAssetData persists a single data message
+func (s *Store) InsertAssetData(data *AssetData) error {
+	_, err := s.insertAssetData(data, nil)
+	return err
+}
+
+// InsertAssetDataOnce persists a data message under its content hash. It
+// reports false, without error, when a message with the same hash is
+// already stored.
+func (s *Store) InsertAssetDataOnce(data *AssetData, hash string) (bool, error) {
+	return s.insertAssetData(data, hash)
+}
+
+// insertAssetData inserts data with an optional content hash (nil for none).
+// The message and tag timestamps are stored in unix milliseconds, whichever
+// unit they arrive in.
+func (s *Store) insertAssetData(data *AssetData, hash any) (bool, error) {
+	values, err := json.Marshal(millisValues(data.Values))
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal tag values: %w", err)
+	}
+
+	var metadataJSON string
+	if data.Metadata != nil {
+		metadata, err := json.Marshal(data.Metadata)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		metadataJSON = string(metadata)
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO asset_data (asset_id, timestamp, tag_values, metadata, content_hash) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (content_hash) DO NOTHING`,
+		data.AssetID, timestampMillis(data.Timestamp), string(values), metadataJSON, hash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert asset data: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert asset data: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// QueryAssetData retrieves an asset's data with from <= timestamp <= to,
+// ordered by timestamp ascending
+func (s *Store) QueryAssetData(assetID string, from, to int64) ([]AssetData, error) {
+	rows, err := s.db.Query(
+		`SELECT asset_id, timestamp, tag_values, metadata FROM asset_data
+		 WHERE asset_id = ? AND timestamp >= ? AND timestamp <= ?
+		 ORDER BY timestamp ASC, id ASC`,
+		assetID, from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query asset data: %w", err)
+	}
+	defer rows.Close()
+
+	var result []AssetData
+	for rows.Next() {
+		data, err := scanAssetData(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *data)
+	}
+
+	return result, rows.Err()
+}
+
+// ScanAssetData calls fn for every stored message with from <= timestamp <
+// to, in timestamp order, without holding them all in memory. An empty
+// assetID scans every asset. Iteration stops at the first error of fn,
+// which is returned.
+func (s *Store) ScanAssetData(assetID string, from, to int64, fn func(*AssetData) error) error {
+	query := `SELECT asset_id, timestamp, tag_values, metadata FROM asset_data
+		 WHERE timestamp >= ? AND timestamp < ?`
+	args := []any{from, to}
+	if assetID != "" {
+		query += ` AND asset_id = ?`
+		args = append(args, assetID)
+	}
+	query += ` ORDER BY timestamp ASC, id ASC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to scan asset data: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		data, err := scanAssetData(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetLatestData retrieves an asset's data with the highest timestamp, the
+// last stored one on ties. It is a single seek on idx_asset_data_asset_ts.
+func (s *Store) GetLatestData(assetID string) (*AssetData, error) {
+	row := s.db.QueryRow(
+		`SELECT asset_id, timestamp, tag_values, metadata FROM asset_data
+		 WHERE asset_id = ?
+		 ORDER BY timestamp DESC, id DESC
+		 LIMIT 1`,
+		assetID,
+	)
+
+	data, err := scanAssetData(row)
+	if err == sql.ErrNoRows {
+		return nil, errorf(ErrNotFound, "no data for asset: %s", assetID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest data: %w", err)
+	}
+	return data, nil
+}
+
+// MaxAggregateBuckets bounds the buckets AggregateData returns, gaps
+// included
+const MaxAggregateBuckets = 10000
+
+// AggregateData computes min, max and average of a NUMBER tag per bucket
+// over the readings with from <= timestamp < to, bucketed by the message
+// timestamp in unix milliseconds. Every bucket in the range is returned,
+// in order, including empty ones.
+func (s *Store) AggregateData(assetID, tag string, from, to int64, bucket time.Duration) ([]DataBucket, error) {
+	width := bucket.Milliseconds()
+	if width <= 0 {
+		return nil, errorf(ErrInvalid, "bucket must be at least 1ms")
+	}
+	if to <= from {
+		return nil, errorf(ErrInvalid, "to must be after from")
+	}
+	// The span is taken unsigned: to - from overflows int64 for ranges
+	// wider than half of it
+	count := (uint64(to)-uint64(from)-1)/uint64(width) + 1
+	if count > MaxAggregateBuckets {
+		return nil, errorf(ErrInvalid, "range spans %d buckets, more than %d", count, MaxAggregateBuckets)
+	}
+
+	rows, err := s.db.Query(
+		`SELECT (d.timestamp - ?) / ? AS bucket,
+			COUNT(*), MIN(json_extract(v.value, '$.number')), MAX(json_extract(v.value, '$.number')), AVG(json_extract(v.value, '$.number'))
+		 FROM asset_data d, json_each(d.tag_values) v
+		 WHERE d.asset_id = ? AND d.timestamp >= ? AND d.timestamp < ?
+		   AND json_extract(v.value, '$.name') = ? AND json_extract(v.value, '$.number') IS NOT NULL
+		 GROUP BY bucket
+		 ORDER BY bucket`,
+		from, width, assetID, from, to, tag,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate asset data: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := make([]DataBucket, count)
+	for i := range buckets {
+		buckets[i].Start = from + int64(i)*width
+	}
+	for rows.Next() {
+		var index int64
+		var b DataBucket
+		var min, max, avg float64
+		if err := rows.Scan(&index, &b.Count, &min, &max, &avg); err != nil {
+			return nil, fmt.Errorf("failed to scan aggregate: %w", err)
+		}
+		b.Start = buckets[index].Start
+		b.Min, b.Max, b.Avg = &min, &max, &avg
+		buckets[index] = b
+	}
+	return buckets, rows.Err()
+}
+
+// scanAssetData scans a row of asset_id, timestamp, tag_values, metadata
+func scanAssetData(row rowScanner) (*AssetData, error) {
+	var data AssetData
+	var valuesJSON string
+	var metadataJSON sql.NullString
+	if err := row.Scan(&data.AssetID, &data.Timestamp, &valuesJSON, &metadataJSON); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(valuesJSON), &data.Values); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tag values: %w", err)
+	}
+	if metadataJSON.Valid && metadataJSON.String != "" {
+		if err := json.Unmarshal([]byte(metadataJSON.String), &data.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data metadata: %w", err)
+		}
+	}
+	return &data, nil
+}
+
+// CountAssetData returns the number of persisted data messages
+func (s *Store) CountAssetData() (int, error) {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM asset_data`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count asset data: %w", err)
+	}
+	return count, nil
+}
@@ -1,6 +1,14 @@
 package core
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -8,6 +16,10 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// baseMillis is a reading time in unix milliseconds for data fixtures;
+// smaller timestamps would be taken as unix seconds
+const baseMillis int64 = 1_700_000_000_000
+
 // TestNewStore_Success tests successful store creation with in-memory DB
 func TestNewStore_Success(t *testing.T) {
 	store, err := NewStore(":memory:")
@@ -47,6 +59,85 @@ func TestCreateAsset_Success(t *testing.T) {
 	assert.Equal(t, asset.Labels, retrieved.Labels)
 }
 
+// TestEnsureAsset tests that an existing ID is left alone while other
+// conflicts still fail
+func TestEnsureAsset(t *testing.T) {
+	store := newTestStore(t)
+
+	created, err := store.EnsureAsset(&Asset{ID: "asset-001", Name: "first", CreatedAt: time.Now()})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	created, err = store.EnsureAsset(&Asset{ID: "asset-001", Name: "second", CreatedAt: time.Now()})
+	require.NoError(t, err)
+	assert.False(t, created)
+	asset, err := store.GetAsset("asset-001")
+	require.NoError(t, err)
+	assert.Equal(t, "first", asset.Name)
+
+	// A soft-deleted asset keeps its ID
+	require.NoError(t, store.SoftDeleteAsset("asset-001"))
+	created, err = store.EnsureAsset(&Asset{ID: "asset-001", Name: "third", CreatedAt: time.Now()})
+	require.NoError(t, err)
+	assert.False(t, created)
+
+	_, err = store.EnsureAsset(&Asset{ID: "asset-002", Name: "first", CreatedAt: time.Now()})
+	assert.ErrorIs(t, err, ErrDuplicateName)
+}
+
+// TestEnsureAsset_Concurrent tests that racing registrations of one ID
+// create it exactly once
+func TestEnsureAsset_Concurrent(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "edg.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	const workers = 20
+	var wg sync.WaitGroup
+	results := make([]bool, workers)
+	errs := make([]error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = store.EnsureAsset(&Asset{ID: "sensor-001", Name: "sensor-001", CreatedAt: time.Now()})
+		}()
+	}
+	wg.Wait()
+
+	createdCount := 0
+	for i := range workers {
+		require.NoError(t, errs[i])
+		if results[i] {
+			createdCount++
+		}
+	}
+	assert.Equal(t, 1, createdCount)
+}
+
+// TestAdoptAssetTemplate tests that only assets without a template take one
+func TestAdoptAssetTemplate(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "bare")
+	require.NoError(t, store.CreateAsset(&Asset{ID: "managed", Name: "managed", TemplateName: "pump", TemplateVersion: 1, CreatedAt: time.Now()}))
+
+	adopted, err := store.AdoptAssetTemplate("bare", "sensor", 3)
+	require.NoError(t, err)
+	assert.True(t, adopted)
+	asset, err := store.GetAsset("bare")
+	require.NoError(t, err)
+	assert.Equal(t, "sensor", asset.TemplateName)
+	assert.Equal(t, 3, asset.TemplateVersion)
+
+	adopted, err = store.AdoptAssetTemplate("managed", "sensor", 3)
+	require.NoError(t, err)
+	assert.False(t, adopted)
+	asset, err = store.GetAsset("managed")
+	require.NoError(t, err)
+	assert.Equal(t, "pump", asset.TemplateName)
+	assert.Equal(t, 1, asset.TemplateVersion)
+}
+
 // TestCreateAsset_DuplicateName tests duplicate name rejection
 func TestCreateAsset_DuplicateName(t *testing.T) {
 	store, err := NewStore(":memory:")
@@ -71,7 +162,7 @@ func TestCreateAsset_DuplicateName(t *testing.T) {
 
 	// Second insert should fail due to UNIQUE constraint
 	err = store.CreateAsset(asset2)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrDuplicate)
 }
 
 // TestGetAsset_Success tests retrieval by ID
@@ -152,6 +243,87 @@ func TestDeleteAsset_Success(t *testing.T) {
 	assert.Nil(t, retrieved)
 }
 
+// TestSoftDeleteAsset tests that tombstoned assets are hidden, keep their relations and can be restored
+func TestSoftDeleteAsset(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.CreateAsset(&Asset{ID: "line", Name: "line", Labels: []string{"plant-a"}, CreatedAt: time.Now()}))
+	createTestAssets(t, store, "machine", "robot")
+	require.NoError(t, createTestRelation(t, store, "machine", "line", RelationPartOf))
+
+	require.NoError(t, store.SoftDeleteAsset("line"))
+
+	// Hidden from default lookups
+	asset, err := store.GetAsset("line")
+	require.NoError(t, err)
+	assert.Nil(t, asset)
+	asset, err = store.GetAssetByName("line")
+	require.NoError(t, err)
+	assert.Nil(t, asset)
+	exists, err := store.AssetExists("line")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	assets, err := store.ListAssets()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"machine", "robot"}, assetIDs(assets))
+	assets, err = store.SearchAssetsByLabels([]string{"plant-a"}, true)
+	require.NoError(t, err)
+	assert.Empty(t, assets)
+	_, total, err := store.ListAssetsFiltered(ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalAssets)
+
+	// Visible on request, with the tombstone time
+	asset, err = store.GetAssetIncludeDeleted("line")
+	require.NoError(t, err)
+	require.NotNil(t, asset)
+	require.NotNil(t, asset.DeletedAt)
+	_, total, err = store.ListAssetsFiltered(ListOptions{IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	// Relations survive but new ones cannot target the tombstone
+	relations, err := store.GetRelationsByTargetAsset("line")
+	require.NoError(t, err)
+	assert.Len(t, relations, 1)
+	err = createTestRelation(t, store, "robot", "line", RelationPartOf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "target asset not found")
+
+	// Deleted assets cannot be updated or deleted again
+	err = store.UpdateAsset(&Asset{ID: "line", Name: "renamed"}, []string{AssetFieldName})
+	assert.ErrorContains(t, err, "asset not found")
+	err = store.SoftDeleteAsset("line")
+	assert.ErrorContains(t, err, "asset not found")
+
+	require.NoError(t, store.RestoreAsset("line"))
+	asset, err = store.GetAsset("line")
+	require.NoError(t, err)
+	require.NotNil(t, asset)
+	assert.Nil(t, asset.DeletedAt)
+
+	err = store.RestoreAsset("line")
+	assert.EqualError(t, err, "deleted asset not found: line")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// A hard delete removes tombstones along with their relations
+	require.NoError(t, store.SoftDeleteAsset("line"))
+	require.NoError(t, store.DeleteAsset("line"))
+	asset, err = store.GetAssetIncludeDeleted("line")
+	require.NoError(t, err)
+	assert.Nil(t, asset)
+	relations, err = store.GetRelationsByTargetAsset("line")
+	require.NoError(t, err)
+	assert.Empty(t, relations)
+}
+
 // TestDeleteAsset_NotFound tests deletion of non-existent asset
 func TestDeleteAsset_NotFound(t *testing.T) {
 	store, err := NewStore(":memory:")
@@ -192,219 +364,857 @@ func TestListAssets(t *testing.T) {
 	assert.Equal(t, "asset-001", retrieved[2].ID)
 }
 
-// ==================== AssetRelation Tests ====================
-
-// TestCreateRelation_Success tests successful relation creation
-func TestCreateRelation_Success(t *testing.T) {
+// TestListAssetsFiltered_Pagination tests limit/offset paging with total count
+func TestListAssetsFiltered_Pagination(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	// Create source and target assets
-	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
-	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
-	require.NoError(t, store.CreateAsset(sourceAsset))
-	require.NoError(t, store.CreateAsset(targetAsset))
-
-	// Create relation
-	relation := &AssetRelation{
-		ID:            "rel-001",
-		SourceAssetID: "asset-001",
-		TargetAssetID: "asset-002",
-		RelationType:  RelationPartOf,
-		CreatedAt:     time.Now(),
-		Metadata: map[string]string{
-			"installed_date": "2025-01-15",
-		},
+	base := time.Now()
+	for i := 1; i <= 5; i++ {
+		asset := &Asset{
+			ID:        fmt.Sprintf("asset-%03d", i),
+			Name:      fmt.Sprintf("sensor-%d", i),
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+		}
+		require.NoError(t, store.CreateAsset(asset))
 	}
 
-	err = store.CreateRelation(relation)
+	page, total, err := store.ListAssetsFiltered(ListOptions{Limit: 2, Offset: 1})
 	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+	require.Len(t, page, 2)
+	assert.Equal(t, "asset-004", page[0].ID)
+	assert.Equal(t, "asset-003", page[1].ID)
 
-	// Verify creation
-	retrieved, err := store.GetRelation("rel-001")
+	// Zero options fall back to the default limit
+	all, total, err := store.ListAssetsFiltered(ListOptions{})
 	require.NoError(t, err)
-	require.NotNil(t, retrieved)
-	assert.Equal(t, relation.ID, retrieved.ID)
-	assert.Equal(t, relation.SourceAssetID, retrieved.SourceAssetID)
-	assert.Equal(t, relation.TargetAssetID, retrieved.TargetAssetID)
-	assert.Equal(t, relation.RelationType, retrieved.RelationType)
-	assert.Equal(t, relation.Metadata, retrieved.Metadata)
+	assert.Equal(t, 5, total)
+	assert.Len(t, all, 5)
 }
 
-// TestCreateRelation_DuplicateError tests duplicate relation rejection
-func TestCreateRelation_DuplicateError(t *testing.T) {
+// TestListAssetsFiltered_Order tests ordering by the allowed columns and the
+// rejection of any other
+func TestListAssetsFiltered_Order(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	// Create assets
-	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
-	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
-	require.NoError(t, store.CreateAsset(sourceAsset))
-	require.NoError(t, store.CreateAsset(targetAsset))
-
-	// Create first relation
-	relation1 := &AssetRelation{
-		ID:            "rel-001",
-		SourceAssetID: "asset-001",
-		TargetAssetID: "asset-002",
-		RelationType:  RelationPartOf,
-		CreatedAt:     time.Now(),
+	base := time.Now()
+	for i, asset := range []*Asset{
+		{ID: "a1", Name: "pump", TemplateName: "vibration"},
+		{ID: "a2", Name: "Boiler", TemplateName: "temp"},
+		{ID: "a3", Name: "chiller", TemplateName: "vibration"},
+		{ID: "a4", Name: "valve"},
+	} {
+		asset.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		require.NoError(t, store.CreateAsset(asset))
 	}
-	require.NoError(t, store.CreateRelation(relation1))
 
-	// Try to create duplicate (same source, target, type)
-	relation2 := &AssetRelation{
-		ID:            "rel-002",
-		SourceAssetID: "asset-001",
-		TargetAssetID: "asset-002",
-		RelationType:  RelationPartOf, // Same type
-		CreatedAt:     time.Now(),
+	for _, tt := range []struct {
+		orderBy, orderDir string
+		want              []string
+	}{
+		{"", "", []string{"a4", "a3", "a2", "a1"}},
+		{"created_at", "asc", []string{"a1", "a2", "a3", "a4"}},
+		{"name", "", []string{"a2", "a3", "a1", "a4"}},
+		{"name", "DESC", []string{"a4", "a1", "a3", "a2"}},
+		{"template_name", "asc", []string{"a4", "a2", "a3", "a1"}},
+		{"template_name", "desc", []string{"a3", "a1", "a2", "a4"}},
+	} {
+		assets, _, err := store.ListAssetsFiltered(ListOptions{OrderBy: tt.orderBy, OrderDir: tt.orderDir})
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, assetIDs(assets), "%s %s", tt.orderBy, tt.orderDir)
 	}
 
-	err = store.CreateRelation(relation2)
-	assert.Error(t, err, "duplicate relation should be rejected")
+	_, _, err = store.ListAssetsFiltered(ListOptions{OrderBy: "id; DROP TABLE assets"})
+	assert.True(t, errors.Is(err, ErrInvalid))
+	_, _, err = store.ListAssetsFiltered(ListOptions{OrderBy: "name", OrderDir: "sideways"})
+	assert.True(t, errors.Is(err, ErrInvalid))
 }
 
-// TestCreateRelation_InvalidSourceAsset tests creation with non-existent source
-func TestCreateRelation_InvalidSourceAsset(t *testing.T) {
+// TestListAssetsByTimeRange tests half-open creation time bounds
+func TestListAssetsByTimeRange(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	// Create only target asset
-	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
-	require.NoError(t, store.CreateAsset(targetAsset))
-
-	// Try to create relation with invalid source
-	relation := &AssetRelation{
-		ID:            "rel-001",
-		SourceAssetID: "non-existent",
-		TargetAssetID: "asset-002",
-		RelationType:  RelationPartOf,
-		CreatedAt:     time.Now(),
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, day := range []int{-1, 0, 15, 31} {
+		require.NoError(t, store.CreateAsset(&Asset{
+			ID:        fmt.Sprintf("asset-%03d", i+1),
+			Name:      fmt.Sprintf("sensor-%d", i+1),
+			CreatedAt: base.AddDate(0, 0, day).Local(),
+		}))
 	}
 
-	err = store.CreateRelation(relation)
-	assert.Error(t, err, "relation with invalid source should fail")
+	march, err := store.ListAssetsByTimeRange(base, base.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"asset-003", "asset-002"}, assetIDs(march))
+
+	since, err := store.ListAssetsByTimeRange(base, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"asset-004", "asset-003", "asset-002"}, assetIDs(since))
+
+	all, err := store.ListAssetsByTimeRange(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+
+	// Bounds combine with pagination on the filtered list
+	page, total, err := store.ListAssetsFiltered(ListOptions{Limit: 1, Offset: 1, CreatedBefore: base.AddDate(0, 1, 0)})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"asset-002"}, assetIDs(page))
 }
 
-// TestCreateRelation_InvalidTargetAsset tests creation with non-existent target
-func TestCreateRelation_InvalidTargetAsset(t *testing.T) {
+// TestListAssetsFiltered_LabelAndTemplate tests label and template filters
+func TestListAssetsFiltered_LabelAndTemplate(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	// Create only source asset
-	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
-	require.NoError(t, store.CreateAsset(sourceAsset))
-
-	// Try to create relation with invalid target
-	relation := &AssetRelation{
-		ID:            "rel-001",
-		SourceAssetID: "asset-001",
-		TargetAssetID: "non-existent",
-		RelationType:  RelationPartOf,
-		CreatedAt:     time.Now(),
+	assets := []*Asset{
+		{ID: "asset-001", Name: "sensor-1", TemplateName: "temp", Labels: []string{"building-a", "floor-1"}, CreatedAt: time.Now()},
+		{ID: "asset-002", Name: "sensor-2", TemplateName: "vibration", Labels: []string{"building-a"}, CreatedAt: time.Now()},
+		{ID: "asset-003", Name: "sensor-3", TemplateName: "temp", Labels: []string{"building-b"}, CreatedAt: time.Now()},
+		{ID: "asset-004", Name: "sensor-4", CreatedAt: time.Now()},
+	}
+	for _, asset := range assets {
+		require.NoError(t, store.CreateAsset(asset))
 	}
 
-	err = store.CreateRelation(relation)
-	assert.Error(t, err, "relation with invalid target should fail")
+	byLabel, total, err := store.ListAssetsFiltered(ListOptions{Label: "building-a"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, byLabel, 2)
+
+	// Label must match a whole element, not a substring
+	_, total, err = store.ListAssetsFiltered(ListOptions{Label: "building"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, total)
+
+	both, total, err := store.ListAssetsFiltered(ListOptions{Label: "building-a", TemplateName: "temp"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, both, 1)
+	assert.Equal(t, "asset-001", both[0].ID)
 }
 
-// TestGetRelation_Found tests successful relation retrieval
-func TestGetRelation_Found(t *testing.T) {
+// TestSearchAssetsByLabels tests AND/OR label matching
+func TestSearchAssetsByLabels(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	// Create assets and relation
-	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
-	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
-	require.NoError(t, store.CreateAsset(sourceAsset))
-	require.NoError(t, store.CreateAsset(targetAsset))
+	base := time.Now()
+	assets := []*Asset{
+		{ID: "asset-001", Name: "sensor-1", Labels: []string{"building-a", "floor-1"}, CreatedAt: base},
+		{ID: "asset-002", Name: "sensor-2", Labels: []string{"building-a"}, CreatedAt: base.Add(time.Second)},
+		{ID: "asset-003", Name: "sensor-3", Labels: []string{"floor-1", "building-b"}, CreatedAt: base.Add(2 * time.Second)},
+		{ID: "asset-004", Name: "sensor-4", CreatedAt: base.Add(3 * time.Second)},
+	}
+	for _, asset := range assets {
+		require.NoError(t, store.CreateAsset(asset))
+	}
 
-	relation := &AssetRelation{
-		ID:            "rel-001",
-		SourceAssetID: "asset-001",
-		TargetAssetID: "asset-002",
-		RelationType:  RelationConnectedTo,
-		CreatedAt:     time.Now(),
+	all, err := store.SearchAssetsByLabels([]string{"building-a", "floor-1"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"asset-001"}, assetIDs(all))
+
+	matchedAny, err := store.SearchAssetsByLabels([]string{"building-a", "floor-1"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"asset-003", "asset-002", "asset-001"}, assetIDs(matchedAny))
+
+	// Duplicate labels do not break the match-all count
+	all, err = store.SearchAssetsByLabels([]string{"building-a", "building-a"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"asset-002", "asset-001"}, assetIDs(all))
+
+	none, err := store.SearchAssetsByLabels([]string{"building-c"}, false)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	_, err = store.SearchAssetsByLabels(nil, true)
+	assert.Error(t, err)
+}
+
+// TestSearchAssetsByNamePrefix tests case-insensitive prefix matching, wildcard
+// escaping, ordering, limits and use of the name index
+func TestSearchAssetsByNamePrefix(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "pump-10", "Pump-2", "pump_3", "pumpX", "press-1", "100%-valve", "1000-valve")
+	require.NoError(t, store.SoftDeleteAsset("pump-10"))
+
+	search := func(prefix string, limit int) []string {
+		t.Helper()
+		assets, err := store.SearchAssetsByNamePrefix(prefix, limit)
+		require.NoError(t, err)
+		return assetIDs(assets)
 	}
-	require.NoError(t, store.CreateRelation(relation))
+	assert.Equal(t, []string{"Pump-2", "pump_3", "pumpX"}, search("pump", 0))
+	assert.Equal(t, []string{"Pump-2", "pump_3"}, search("PUMP", 2))
+	assert.Equal(t, []string{"pump_3"}, search("pump_", 0), "_ is literal")
+	assert.Equal(t, []string{"100%-valve"}, search("100%", 0), "% is literal")
+	assert.Empty(t, search("ump", 0), "matches prefixes only")
 
-	// Retrieve relation
-	retrieved, err := store.GetRelation("rel-001")
+	_, err := store.SearchAssetsByNamePrefix("", 10)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	query, args, err := assetSearchQuery(AssetSearch{NamePrefix: "pump", Labels: []string{"critical"}, Limit: 10})
 	require.NoError(t, err)
-	require.NotNil(t, retrieved)
-	assert.Equal(t, "rel-001", retrieved.ID)
+	rows, err := store.db.Query(`EXPLAIN QUERY PLAN `+query, args...)
+	require.NoError(t, err)
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		require.NoError(t, rows.Scan(&id, &parent, &unused, &detail))
+		plan = append(plan, detail)
+	}
+	assert.Contains(t, strings.Join(plan, "\n"), "USING INDEX idx_assets_name_nocase")
 }
 
-// TestGetRelation_NotFound tests retrieval of non-existent relation
-func TestGetRelation_NotFound(t *testing.T) {
+// TestFindAssetsByLabelKV tests that key:value labels are indexed on every write path
+func TestFindAssetsByLabelKV(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	retrieved, err := store.GetRelation("non-existent")
+	base := time.Now()
+	require.NoError(t, store.CreateAsset(&Asset{ID: "a", Name: "a", Labels: []string{"site:berlin", "line-1", "url:http://plc-1"}, CreatedAt: base}))
+	require.NoError(t, store.CreateAssetsBatch([]*Asset{
+		{ID: "b", Name: "b", Labels: []string{"site:berlin", "site:hall-2"}, CreatedAt: base.Add(time.Second)},
+		{ID: "c", Name: "c", Labels: []string{":berlin", "site:", "site"}, CreatedAt: base.Add(2 * time.Second)},
+	}))
+
+	find := func(key, value string) []string {
+		t.Helper()
+		assets, err := store.FindAssetsByLabelKV(key, value)
+		require.NoError(t, err)
+		return assetIDs(assets)
+	}
+	assert.Equal(t, []string{"b", "a"}, find("site", "berlin"))
+	assert.Equal(t, []string{"b"}, find("site", "hall-2"))
+	assert.Equal(t, []string{"b", "a"}, find("site", ""))
+	assert.Equal(t, []string{"a"}, find("url", "http://plc-1"))
+	assert.Empty(t, find("line-1", ""))
+
+	// Plain labels keep working as tags
+	plain, err := store.SearchAssetsByLabels([]string{"site:berlin", "line-1"}, true)
 	require.NoError(t, err)
-	assert.Nil(t, retrieved)
+	assert.Equal(t, []string{"a"}, assetIDs(plain))
+
+	// Updating labels replaces the index entries
+	require.NoError(t, store.UpdateAsset(&Asset{ID: "a", Labels: []string{"site:paris"}}, []string{AssetFieldLabels}))
+	assert.Equal(t, []string{"b"}, find("site", "berlin"))
+	assert.Equal(t, []string{"a"}, find("site", "paris"))
+
+	// Soft-deleted assets are hidden and deleted ones leave no entries
+	require.NoError(t, store.SoftDeleteAsset("a"))
+	assert.Empty(t, find("site", "paris"))
+	require.NoError(t, store.DeleteAsset("b"))
+	var entries int
+	require.NoError(t, store.db.QueryRow(`SELECT COUNT(*) FROM asset_label_kv WHERE asset_id = 'b'`).Scan(&entries))
+	assert.Zero(t, entries)
+
+	_, err = store.FindAssetsByLabelKV("", "berlin")
+	assert.True(t, errors.Is(err, ErrInvalid))
 }
 
-// TestGetRelationsBySourceAsset tests retrieval by source asset
-func TestGetRelationsBySourceAsset(t *testing.T) {
+// TestAssetAttributes_RoundTrip tests attributes persistence across create, read and update paths
+func TestAssetAttributes_RoundTrip(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	// Create assets
-	source := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
-	target1 := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
-	target2 := &Asset{ID: "asset-003", Name: "equipment-2", CreatedAt: time.Now()}
-	require.NoError(t, store.CreateAsset(source))
-	require.NoError(t, store.CreateAsset(target1))
-	require.NoError(t, store.CreateAsset(target2))
+	attrs := map[string]string{"vendor": "Siemens", "model": "S7"}
+	require.NoError(t, store.CreateAsset(&Asset{ID: "plc", Name: "plc-1", Attributes: attrs, CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateAssetsBatch([]*Asset{{ID: "batch", Name: "batch-1", Attributes: attrs, CreatedAt: time.Now()}}))
+	createTestAssets(t, store, "plain")
 
-	// Create relations from source
-	rel1 := &AssetRelation{
-		ID:            "rel-001",
-		SourceAssetID: "asset-001",
-		TargetAssetID: "asset-002",
-		RelationType:  RelationPartOf,
-		CreatedAt:     time.Now(),
-	}
-	rel2 := &AssetRelation{
-		ID:            "rel-002",
-		SourceAssetID: "asset-001",
-		TargetAssetID: "asset-003",
-		RelationType:  RelationConnectedTo,
-		CreatedAt:     time.Now(),
-	}
-	require.NoError(t, store.CreateRelation(rel1))
-	require.NoError(t, store.CreateRelation(rel2))
+	asset, err := store.GetAsset("plc")
+	require.NoError(t, err)
+	assert.Equal(t, attrs, asset.Attributes)
 
-	// Get relations by source
-	relations, err := store.GetRelationsBySourceAsset("asset-001")
+	asset, err = store.GetAssetByName("batch-1")
 	require.NoError(t, err)
-	assert.Len(t, relations, 2)
+	assert.Equal(t, attrs, asset.Attributes)
+
+	asset, err = store.GetAsset("plain")
+	require.NoError(t, err)
+	assert.Nil(t, asset.Attributes)
+
+	assets, err := store.ListAssets()
+	require.NoError(t, err)
+	assert.Len(t, assets, 3)
+
+	require.NoError(t, store.UpdateAsset(&Asset{ID: "plc", Attributes: map[string]string{"vendor": "ABB"}}, []string{AssetFieldAttributes}))
+	asset, err = store.GetAsset("plc")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"vendor": "ABB"}, asset.Attributes)
+	assert.Equal(t, "plc-1", asset.Name)
 }
 
-// TestGetRelationsByTargetAsset tests retrieval by target asset
-func TestGetRelationsByTargetAsset(t *testing.T) {
+// TestUpdateAsset_PartialFields tests that only listed fields are updated
+func TestUpdateAsset_PartialFields(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	// Create assets
-	source1 := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
-	source2 := &Asset{ID: "asset-002", Name: "sensor-2", CreatedAt: time.Now()}
-	target := &Asset{ID: "asset-003", Name: "equipment-1", CreatedAt: time.Now()}
-	require.NoError(t, store.CreateAsset(source1))
-	require.NoError(t, store.CreateAsset(source2))
-	require.NoError(t, store.CreateAsset(target))
+	asset := &Asset{
+		ID:           "asset-001",
+		Name:         "sensor-1",
+		TemplateName: "temp",
+		Labels:       []string{"building-a"},
+		CreatedAt:    time.Now(),
+	}
+	require.NoError(t, store.CreateAsset(asset))
 
-	// Create relations to target
+	// Name is set on the input but not listed, so it must be ignored
+	err = store.UpdateAsset(&Asset{ID: "asset-001", Name: "ignored", Labels: []string{"building-b"}}, []string{AssetFieldLabels})
+	require.NoError(t, err)
+
+	retrieved, err := store.GetAsset("asset-001")
+	require.NoError(t, err)
+	assert.Equal(t, "sensor-1", retrieved.Name)
+	assert.Equal(t, "temp", retrieved.TemplateName)
+	assert.Equal(t, []string{"building-b"}, retrieved.Labels)
+}
+
+// TestUpdateAsset_DuplicateName tests that renames respect the UNIQUE constraint
+func TestUpdateAsset_DuplicateName(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.CreateAsset(&Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateAsset(&Asset{ID: "asset-002", Name: "sensor-2", CreatedAt: time.Now()}))
+
+	err = store.UpdateAsset(&Asset{ID: "asset-002", Name: "sensor-1"}, []string{AssetFieldName})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "asset name already exists")
+}
+
+// TestUpdateAsset_NotFound tests updating a missing asset
+func TestUpdateAsset_NotFound(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	err = store.UpdateAsset(&Asset{ID: "missing", Name: "x"}, []string{AssetFieldName})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "asset not found")
+
+	err = store.UpdateAsset(&Asset{ID: "missing"}, []string{"created_at"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown asset field")
+}
+
+// TestAssetExternalIDs tests lookup by scheme and per-scheme uniqueness
+func TestAssetExternalIDs(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.CreateAsset(&Asset{ID: "asset-001", Name: "pump-1", ExternalIDs: map[string]string{"erp": "M-100", "aas": "urn:aas:pump-1"}, CreatedAt: time.Now()}))
+	// The same value under another scheme does not collide
+	require.NoError(t, store.CreateAsset(&Asset{ID: "asset-002", Name: "pump-2", ExternalIDs: map[string]string{"aas": "M-100"}, CreatedAt: time.Now()}))
+
+	asset, err := store.GetAssetByExternalID("erp", "M-100")
+	require.NoError(t, err)
+	require.NotNil(t, asset)
+	assert.Equal(t, "asset-001", asset.ID)
+	assert.Equal(t, map[string]string{"erp": "M-100", "aas": "urn:aas:pump-1"}, asset.ExternalIDs)
+
+	asset, err = store.GetAssetByExternalID("aas", "M-100")
+	require.NoError(t, err)
+	assert.Equal(t, "asset-002", asset.ID)
+
+	asset, err = store.GetAssetByExternalID("erp", "M-999")
+	require.NoError(t, err)
+	assert.Nil(t, asset)
+
+	err = store.CreateAsset(&Asset{ID: "asset-003", Name: "pump-3", ExternalIDs: map[string]string{"erp": "M-100"}, CreatedAt: time.Now()})
+	assert.ErrorIs(t, err, ErrDuplicate)
+	assert.ErrorContains(t, err, "external id already in use")
+
+	err = store.UpdateAsset(&Asset{ID: "asset-002", ExternalIDs: map[string]string{"erp": "M-100"}}, []string{AssetFieldExternalIDs})
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	// Replacing the ids frees the old value
+	require.NoError(t, store.UpdateAsset(&Asset{ID: "asset-001", ExternalIDs: map[string]string{"erp": "M-101"}}, []string{AssetFieldExternalIDs}))
+	require.NoError(t, store.UpdateAsset(&Asset{ID: "asset-002", ExternalIDs: map[string]string{"erp": "M-100"}}, []string{AssetFieldExternalIDs}))
+
+	// Soft-deleted assets are hidden but keep their ids, like their names
+	require.NoError(t, store.SoftDeleteAsset("asset-002"))
+	asset, err = store.GetAssetByExternalID("erp", "M-100")
+	require.NoError(t, err)
+	assert.Nil(t, asset)
+	err = store.CreateAsset(&Asset{ID: "asset-004", Name: "pump-4", ExternalIDs: map[string]string{"erp": "M-100"}, CreatedAt: time.Now()})
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	err = store.CreateAssetsBatch([]*Asset{
+		{ID: "asset-005", Name: "pump-5", ExternalIDs: map[string]string{"sap": "42"}, CreatedAt: time.Now()},
+		{ID: "asset-006", Name: "pump-6", ExternalIDs: map[string]string{"sap": "42"}, CreatedAt: time.Now()},
+	})
+	assert.ErrorIs(t, err, ErrDuplicate)
+	exists, err := store.AssetExists("asset-005")
+	require.NoError(t, err)
+	assert.False(t, exists, "batch is rolled back")
+
+	for _, scheme := range []string{"", "ERP", "erp'); DROP TABLE assets; --", "1erp"} {
+		_, err = store.GetAssetByExternalID(scheme, "x")
+		assert.ErrorIs(t, err, ErrInvalid, scheme)
+	}
+	err = store.CreateAsset(&Asset{ID: "asset-007", Name: "pump-7", ExternalIDs: map[string]string{"erp": ""}, CreatedAt: time.Now()})
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+// TestAssetLocation tests that coordinates round-trip and can be replaced or cleared
+func TestAssetLocation(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	lat, lon, alt := 37.5665, 126.978, 38.0
+	require.NoError(t, store.CreateAsset(&Asset{ID: "asset-001", Name: "sensor-1", Latitude: &lat, Longitude: &lon, Altitude: &alt, CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateAsset(&Asset{ID: "asset-002", Name: "sensor-2", CreatedAt: time.Now()}))
+
+	got, err := store.GetAsset("asset-001")
+	require.NoError(t, err)
+	require.NotNil(t, got.Latitude)
+	assert.Equal(t, lat, *got.Latitude)
+	assert.Equal(t, lon, *got.Longitude)
+	assert.Equal(t, alt, *got.Altitude)
+
+	got, err = store.GetAsset("asset-002")
+	require.NoError(t, err)
+	assert.Nil(t, got.Latitude)
+	assert.Nil(t, got.Longitude)
+	assert.Nil(t, got.Altitude)
+
+	newLat, newLon := 35.1796, 129.0756
+	require.NoError(t, store.UpdateAsset(&Asset{ID: "asset-001", Latitude: &newLat, Longitude: &newLon}, []string{AssetFieldLocation}))
+	got, err = store.GetAsset("asset-001")
+	require.NoError(t, err)
+	assert.Equal(t, newLat, *got.Latitude)
+	assert.Equal(t, newLon, *got.Longitude)
+	assert.Nil(t, got.Altitude, "location is replaced as a whole")
+
+	require.NoError(t, store.UpdateAsset(&Asset{ID: "asset-001"}, []string{AssetFieldLocation}))
+	got, err = store.GetAsset("asset-001")
+	require.NoError(t, err)
+	assert.Nil(t, got.Latitude)
+}
+
+// TestListAssetsNear tests radius filtering and nearest-first ordering
+func TestListAssetsNear(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	locate := func(id string, lat, lon float64) {
+		require.NoError(t, store.CreateAsset(&Asset{ID: id, Name: id, Latitude: &lat, Longitude: &lon, CreatedAt: time.Now()}))
+	}
+	locate("far", 37.6665, 126.978)    // ~11 km north
+	locate("near", 37.5765, 126.978)   // ~1.1 km north
+	locate("here", 37.5665, 126.978)   // origin
+	locate("busan", 35.1796, 129.0756) // ~325 km
+	createTestAssets(t, store, "unplaced")
+
+	assets, err := store.ListAssetsNear(37.5665, 126.978, 2000)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"here", "near"}, assetIDs(assets))
+
+	assets, err = store.ListAssetsNear(37.5665, 126.978, 20000)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"here", "near", "far"}, assetIDs(assets))
+
+	// A radius wider than the bounding box can express still filters by distance
+	assets, err = store.ListAssetsNear(37.5665, 126.978, 15000000)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"here", "near", "far", "busan"}, assetIDs(assets))
+
+	require.NoError(t, store.DeleteAsset("near"))
+	assets, err = store.ListAssetsNear(37.5665, 126.978, 2000)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"here"}, assetIDs(assets))
+
+	_, err = store.ListAssetsNear(91, 0, 1000)
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = store.ListAssetsNear(0, 0, -1)
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+// TestCreateAssetsBatch_Success tests creating several assets at once
+func TestCreateAssetsBatch_Success(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	batch := []*Asset{
+		{ID: "asset-001", Name: "sensor-1", Labels: []string{"a"}, CreatedAt: time.Now()},
+		{ID: "asset-002", Name: "sensor-2", CreatedAt: time.Now()},
+	}
+	require.NoError(t, store.CreateAssetsBatch(batch))
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.TotalAssets)
+}
+
+// TestGetStats_Breakdown tests asset and relation counts grouped by template and type
+func TestGetStats_Breakdown(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.CreateAssetsBatch([]*Asset{
+		{ID: "a", Name: "a", TemplateName: "temp", CreatedAt: time.Now()},
+		{ID: "b", Name: "b", TemplateName: "temp", CreatedAt: time.Now()},
+		{ID: "c", Name: "c", TemplateName: "vibration", CreatedAt: time.Now()},
+		{ID: "d", Name: "d", CreatedAt: time.Now()},
+	}))
+	require.NoError(t, createTestRelation(t, store, "a", "d", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "b", "d", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "c", "d", RelationLocatedIn))
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.TotalAssets)
+	assert.Equal(t, map[string]int{"temp": 2, "vibration": 1, "": 1}, stats.AssetsByTemplate)
+	assert.Equal(t, 3, stats.TotalRelations)
+	assert.Equal(t, map[string]int{"partOf": 2, "locatedIn": 1}, stats.RelationsByType)
+}
+
+// TestCreateAssetsBatch_RollbackOnDuplicate tests that one collision rolls back the whole batch
+func TestCreateAssetsBatch_RollbackOnDuplicate(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.CreateAsset(&Asset{ID: "existing", Name: "sensor-2", CreatedAt: time.Now()}))
+
+	batch := []*Asset{
+		{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()},
+		{ID: "asset-002", Name: "sensor-2", CreatedAt: time.Now()},
+	}
+	err = store.CreateAssetsBatch(batch)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "asset name already exists: sensor-2")
+
+	retrieved, err := store.GetAsset("asset-001")
+	require.NoError(t, err)
+	assert.Nil(t, retrieved, "first insert must be rolled back")
+}
+
+// ==================== AssetRelation Tests ====================
+
+// TestCreateRelation_Success tests successful relation creation
+func TestCreateRelation_Success(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Create source and target assets
+	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
+	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
+	require.NoError(t, store.CreateAsset(sourceAsset))
+	require.NoError(t, store.CreateAsset(targetAsset))
+
+	// Create relation
+	relation := &AssetRelation{
+		ID:            "rel-001",
+		SourceAssetID: "asset-001",
+		TargetAssetID: "asset-002",
+		RelationType:  RelationPartOf,
+		CreatedAt:     time.Now(),
+		Metadata: map[string]string{
+			"installed_date": "2025-01-15",
+		},
+	}
+
+	err = store.CreateRelation(relation)
+	require.NoError(t, err)
+
+	// Verify creation
+	retrieved, err := store.GetRelation("rel-001")
+	require.NoError(t, err)
+	require.NotNil(t, retrieved)
+	assert.Equal(t, relation.ID, retrieved.ID)
+	assert.Equal(t, relation.SourceAssetID, retrieved.SourceAssetID)
+	assert.Equal(t, relation.TargetAssetID, retrieved.TargetAssetID)
+	assert.Equal(t, relation.RelationType, retrieved.RelationType)
+	assert.Equal(t, relation.Metadata, retrieved.Metadata)
+}
+
+// TestCreateRelation_DuplicateError tests duplicate relation rejection
+func TestCreateRelation_DuplicateError(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Create assets
+	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
+	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
+	require.NoError(t, store.CreateAsset(sourceAsset))
+	require.NoError(t, store.CreateAsset(targetAsset))
+
+	// Create first relation
+	relation1 := &AssetRelation{
+		ID:            "rel-001",
+		SourceAssetID: "asset-001",
+		TargetAssetID: "asset-002",
+		RelationType:  RelationPartOf,
+		CreatedAt:     time.Now(),
+	}
+	require.NoError(t, store.CreateRelation(relation1))
+
+	// Try to create duplicate (same source, target, type)
+	relation2 := &AssetRelation{
+		ID:            "rel-002",
+		SourceAssetID: "asset-001",
+		TargetAssetID: "asset-002",
+		RelationType:  RelationPartOf, // Same type
+		CreatedAt:     time.Now(),
+	}
+
+	err = store.CreateRelation(relation2)
+	assert.Error(t, err, "duplicate relation should be rejected")
+}
+
+// TestCreateRelation_SymmetricReverseDuplicate tests that the reverse of a
+// symmetric relation is rejected while asymmetric reverses are not
+func TestCreateRelation_SymmetricReverseDuplicate(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+	createTestAssets(t, store, "pump", "valve", "sensor")
+
+	require.NoError(t, createTestRelation(t, store, "pump", "valve", RelationConnectedTo))
+	err = createTestRelation(t, store, "valve", "pump", RelationConnectedTo)
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	require.NoError(t, createTestRelation(t, store, "sensor", "pump", RelationMeasures))
+	require.NoError(t, createTestRelation(t, store, "pump", "sensor", RelationMeasures))
+
+	err = store.CreateRelationsBatch([]*AssetRelation{
+		{ID: "b1", SourceAssetID: "sensor", TargetAssetID: "valve", RelationType: RelationConnectedTo, CreatedAt: time.Now()},
+		{ID: "b2", SourceAssetID: "valve", TargetAssetID: "sensor", RelationType: RelationConnectedTo, CreatedAt: time.Now()},
+	})
+	var batchErr *RelationBatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []BatchItemError{{Index: 1, Error: "relation already exists"}}, batchErr.Items)
+
+	relations, err := store.GetRelationsBySourceAsset("sensor")
+	require.NoError(t, err)
+	assert.Len(t, relations, 1, "rejected batch must not insert anything")
+}
+
+// TestCreateRelation_SymmetricCanonicalOrder tests that both insert orders of
+// a symmetric relation are stored source-first in ID order
+func TestCreateRelation_SymmetricCanonicalOrder(t *testing.T) {
+	for _, order := range [][2]string{{"pump", "valve"}, {"valve", "pump"}} {
+		store, err := NewStore(":memory:")
+		require.NoError(t, err)
+		createTestAssets(t, store, "pump", "valve")
+
+		relation := &AssetRelation{ID: "r1", SourceAssetID: order[0], TargetAssetID: order[1], RelationType: RelationConnectedTo, CreatedAt: time.Now()}
+		require.NoError(t, store.CreateRelation(relation))
+		assert.Equal(t, "pump", relation.SourceAssetID)
+
+		stored, err := store.GetRelation("r1")
+		require.NoError(t, err)
+		assert.Equal(t, "pump", stored.SourceAssetID)
+		assert.Equal(t, "valve", stored.TargetAssetID)
+
+		// Asymmetric relations keep their direction
+		measures := &AssetRelation{ID: "r2", SourceAssetID: "valve", TargetAssetID: "pump", RelationType: RelationMeasures, CreatedAt: time.Now()}
+		require.NoError(t, store.CreateRelation(measures))
+		assert.Equal(t, "valve", measures.SourceAssetID)
+		store.Close()
+	}
+}
+
+// TestCanonicalizeRelations tests that reversed symmetric rows written around
+// the store are swapped, or deleted when their canonical twin exists
+func TestCanonicalizeRelations(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+	createTestAssets(t, store, "a", "b", "c")
+
+	insert := func(id, source, target string, rt RelationType) {
+		_, err := store.db.Exec(`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at) VALUES (?, ?, ?, ?, ?)`,
+			id, source, target, rt, time.Now())
+		require.NoError(t, err)
+	}
+	insert("r1", "b", "a", RelationConnectedTo) // reversed
+	insert("r2", "c", "a", RelationConnectedTo) // reversed, canonical twin r3
+	insert("r3", "a", "c", RelationConnectedTo)
+	insert("r4", "c", "b", RelationMeasures) // asymmetric
+
+	changed, err := store.CanonicalizeRelations()
+	require.NoError(t, err)
+	assert.Equal(t, 2, changed)
+
+	relations, _, err := store.ListRelations(0, 0)
+	require.NoError(t, err)
+	got := make(map[string][2]string)
+	for _, r := range relations {
+		got[r.ID] = [2]string{r.SourceAssetID, r.TargetAssetID}
+	}
+	assert.Equal(t, map[string][2]string{
+		"r1": {"a", "b"},
+		"r3": {"a", "c"},
+		"r4": {"c", "b"},
+	}, got)
+
+	changed, err = store.CanonicalizeRelations()
+	require.NoError(t, err)
+	assert.Zero(t, changed)
+}
+
+// TestCreateRelation_InvalidSourceAsset tests creation with non-existent source
+func TestCreateRelation_InvalidSourceAsset(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Create only target asset
+	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
+	require.NoError(t, store.CreateAsset(targetAsset))
+
+	// Try to create relation with invalid source
+	relation := &AssetRelation{
+		ID:            "rel-001",
+		SourceAssetID: "non-existent",
+		TargetAssetID: "asset-002",
+		RelationType:  RelationPartOf,
+		CreatedAt:     time.Now(),
+	}
+
+	err = store.CreateRelation(relation)
+	assert.Error(t, err, "relation with invalid source should fail")
+}
+
+// TestCreateRelation_InvalidTargetAsset tests creation with non-existent target
+func TestCreateRelation_InvalidTargetAsset(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Create only source asset
+	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
+	require.NoError(t, store.CreateAsset(sourceAsset))
+
+	// Try to create relation with invalid target
+	relation := &AssetRelation{
+		ID:            "rel-001",
+		SourceAssetID: "asset-001",
+		TargetAssetID: "non-existent",
+		RelationType:  RelationPartOf,
+		CreatedAt:     time.Now(),
+	}
+
+	err = store.CreateRelation(relation)
+	assert.Error(t, err, "relation with invalid target should fail")
+}
+
+// TestGetRelation_Found tests successful relation retrieval
+func TestGetRelation_Found(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Create assets and relation
+	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
+	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
+	require.NoError(t, store.CreateAsset(sourceAsset))
+	require.NoError(t, store.CreateAsset(targetAsset))
+
+	relation := &AssetRelation{
+		ID:            "rel-001",
+		SourceAssetID: "asset-001",
+		TargetAssetID: "asset-002",
+		RelationType:  RelationConnectedTo,
+		CreatedAt:     time.Now(),
+	}
+	require.NoError(t, store.CreateRelation(relation))
+
+	// Retrieve relation
+	retrieved, err := store.GetRelation("rel-001")
+	require.NoError(t, err)
+	require.NotNil(t, retrieved)
+	assert.Equal(t, "rel-001", retrieved.ID)
+}
+
+// TestGetRelation_NotFound tests retrieval of non-existent relation
+func TestGetRelation_NotFound(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	retrieved, err := store.GetRelation("non-existent")
+	require.NoError(t, err)
+	assert.Nil(t, retrieved)
+}
+
+// TestGetRelationsBySourceAsset tests retrieval by source asset
+func TestGetRelationsBySourceAsset(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Create assets
+	source := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
+	target1 := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
+	target2 := &Asset{ID: "asset-003", Name: "equipment-2", CreatedAt: time.Now()}
+	require.NoError(t, store.CreateAsset(source))
+	require.NoError(t, store.CreateAsset(target1))
+	require.NoError(t, store.CreateAsset(target2))
+
+	// Create relations from source
+	rel1 := &AssetRelation{
+		ID:            "rel-001",
+		SourceAssetID: "asset-001",
+		TargetAssetID: "asset-002",
+		RelationType:  RelationPartOf,
+		CreatedAt:     time.Now(),
+	}
+	rel2 := &AssetRelation{
+		ID:            "rel-002",
+		SourceAssetID: "asset-001",
+		TargetAssetID: "asset-003",
+		RelationType:  RelationConnectedTo,
+		CreatedAt:     time.Now(),
+	}
+	require.NoError(t, store.CreateRelation(rel1))
+	require.NoError(t, store.CreateRelation(rel2))
+
+	// Get relations by source
+	relations, err := store.GetRelationsBySourceAsset("asset-001")
+	require.NoError(t, err)
+	assert.Len(t, relations, 2)
+}
+
+// TestGetRelationsByTargetAsset tests retrieval by target asset
+func TestGetRelationsByTargetAsset(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Create assets
+	source1 := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
+	source2 := &Asset{ID: "asset-002", Name: "sensor-2", CreatedAt: time.Now()}
+	target := &Asset{ID: "asset-003", Name: "equipment-1", CreatedAt: time.Now()}
+	require.NoError(t, store.CreateAsset(source1))
+	require.NoError(t, store.CreateAsset(source2))
+	require.NoError(t, store.CreateAsset(target))
+
+	// Create relations to target
 	rel1 := &AssetRelation{
 		ID:            "rel-001",
 		SourceAssetID: "asset-001",
@@ -412,62 +1222,637 @@ func TestGetRelationsByTargetAsset(t *testing.T) {
 		RelationType:  RelationPartOf,
 		CreatedAt:     time.Now(),
 	}
-	rel2 := &AssetRelation{
-		ID:            "rel-002",
-		SourceAssetID: "asset-002",
-		TargetAssetID: "asset-003",
-		RelationType:  RelationPartOf,
-		CreatedAt:     time.Now(),
+	rel2 := &AssetRelation{
+		ID:            "rel-002",
+		SourceAssetID: "asset-002",
+		TargetAssetID: "asset-003",
+		RelationType:  RelationPartOf,
+		CreatedAt:     time.Now(),
+	}
+	require.NoError(t, store.CreateRelation(rel1))
+	require.NoError(t, store.CreateRelation(rel2))
+
+	// Get relations by target
+	relations, err := store.GetRelationsByTargetAsset("asset-003")
+	require.NoError(t, err)
+	assert.Len(t, relations, 2)
+}
+
+// TestQueryRelations_TypeAndTimeRange tests type-only listing and created_at bounds
+func TestQueryRelations_TypeAndTimeRange(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	createTestAssets(t, store, "a", "b", "c")
+
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	recent := time.Now().Add(-1 * time.Hour)
+	relations := []*AssetRelation{
+		{ID: "rel-old", SourceAssetID: "a", TargetAssetID: "b", RelationType: RelationLocatedIn, CreatedAt: old},
+		{ID: "rel-new", SourceAssetID: "b", TargetAssetID: "c", RelationType: RelationLocatedIn, CreatedAt: recent},
+		{ID: "rel-conn", SourceAssetID: "a", TargetAssetID: "c", RelationType: RelationConnectedTo, CreatedAt: recent},
+	}
+	for _, rel := range relations {
+		require.NoError(t, store.CreateRelation(rel))
+	}
+
+	byType, err := store.GetRelationsByType(RelationLocatedIn)
+	require.NoError(t, err)
+	require.Len(t, byType, 2)
+	assert.Equal(t, "rel-new", byType[0].ID, "newest first")
+
+	weekAgo := time.Now().Add(-7 * 24 * time.Hour).Unix()
+	thisWeek, err := store.QueryRelations(RelationQuery{RelationType: RelationLocatedIn, CreatedAfter: weekAgo})
+	require.NoError(t, err)
+	require.Len(t, thisWeek, 1)
+	assert.Equal(t, "rel-new", thisWeek[0].ID)
+
+	older, err := store.QueryRelations(RelationQuery{CreatedBefore: weekAgo})
+	require.NoError(t, err)
+	require.Len(t, older, 1)
+	assert.Equal(t, "rel-old", older[0].ID)
+
+	fromA, err := store.QueryRelations(RelationQuery{SourceAssetID: "a", CreatedAfter: weekAgo})
+	require.NoError(t, err)
+	require.Len(t, fromA, 1)
+	assert.Equal(t, "rel-conn", fromA[0].ID)
+}
+
+// TestDeleteRelation_Success tests relation deletion
+func TestDeleteRelation_Success(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Create assets and relation
+	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
+	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
+	require.NoError(t, store.CreateAsset(sourceAsset))
+	require.NoError(t, store.CreateAsset(targetAsset))
+
+	relation := &AssetRelation{
+		ID:            "rel-001",
+		SourceAssetID: "asset-001",
+		TargetAssetID: "asset-002",
+		RelationType:  RelationPartOf,
+		CreatedAt:     time.Now(),
+	}
+	require.NoError(t, store.CreateRelation(relation))
+
+	// Delete relation
+	err = store.DeleteRelation("rel-001")
+	require.NoError(t, err)
+
+	// Verify deletion
+	retrieved, err := store.GetRelation("rel-001")
+	require.NoError(t, err)
+	assert.Nil(t, retrieved)
+}
+
+// TestDeleteRelation_NotFound tests deletion of non-existent relation
+func TestDeleteRelation_NotFound(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	err = store.DeleteRelation("non-existent")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "relation not found")
+}
+
+// TestUpdateRelationMetadata tests that metadata is replaced and the rest kept
+func TestUpdateRelationMetadata(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	createTestAssets(t, store, "line", "machine")
+	require.NoError(t, store.CreateRelation(&AssetRelation{
+		ID:            "rel-001",
+		SourceAssetID: "machine",
+		TargetAssetID: "line",
+		RelationType:  RelationPartOf,
+		Metadata:      map[string]string{"slot": "1", "side": "left"},
+		CreatedAt:     time.Now(),
+	}))
+	before, err := store.GetRelation("rel-001")
+	require.NoError(t, err)
+
+	updated, err := store.UpdateRelationMetadata("rel-001", map[string]string{"slot": "2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"slot": "2"}, updated.Metadata)
+	assert.Equal(t, "machine", updated.SourceAssetID)
+	assert.Equal(t, "line", updated.TargetAssetID)
+	assert.Equal(t, RelationPartOf, updated.RelationType)
+	assert.Equal(t, before.CreatedAt.Unix(), updated.CreatedAt.Unix())
+
+	// nil clears the metadata
+	updated, err = store.UpdateRelationMetadata("rel-001", nil)
+	require.NoError(t, err)
+	assert.Nil(t, updated.Metadata)
+
+	_, err = store.UpdateRelationMetadata("non-existent", map[string]string{"slot": "3"})
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+// TestRelationWeight_RoundTrip tests that weights are stored, updated and
+// omitted when unset
+func TestRelationWeight_RoundTrip(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	createTestAssets(t, store, "pump", "tank", "valve")
+	weight := 150.0
+	require.NoError(t, store.CreateRelation(&AssetRelation{ID: "weighted", SourceAssetID: "pump", TargetAssetID: "tank", RelationType: RelationConnectedTo, Weight: &weight, CreatedAt: time.Now()}))
+	require.NoError(t, store.CreateRelation(&AssetRelation{ID: "plain", SourceAssetID: "pump", TargetAssetID: "valve", RelationType: RelationConnectedTo, CreatedAt: time.Now()}))
+
+	weighted, err := store.GetRelation("weighted")
+	require.NoError(t, err)
+	require.NotNil(t, weighted.Weight)
+	assert.Equal(t, 150.0, *weighted.Weight)
+
+	plain, err := store.GetRelation("plain")
+	require.NoError(t, err)
+	assert.Nil(t, plain.Weight)
+	encoded, err := json.Marshal(plain)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "weight")
+
+	relations, _, err := store.ListRelations(10, 0)
+	require.NoError(t, err)
+	require.Len(t, relations, 2)
+	for _, relation := range relations {
+		assert.Equal(t, relation.ID == "weighted", relation.Weight != nil)
+	}
+
+	// Replacing the metadata alone keeps the weight
+	updated, err := store.UpdateRelationMetadata("weighted", map[string]string{"diameter_unit": "mm"})
+	require.NoError(t, err)
+	require.NotNil(t, updated.Weight)
+	assert.Equal(t, 150.0, *updated.Weight)
+
+	// So does a full update that omits the weight
+	updated, err = store.UpdateRelation("weighted", map[string]string{"slot": "2"}, nil, nil, false)
+	require.NoError(t, err)
+	require.NotNil(t, updated.Weight)
+	assert.Equal(t, 150.0, *updated.Weight)
+	assert.Equal(t, map[string]string{"slot": "2"}, updated.Metadata)
+
+	weight = 0
+	updated, err = store.UpdateRelation("plain", nil, nil, &weight, false)
+	require.NoError(t, err)
+	require.NotNil(t, updated.Weight)
+	assert.Equal(t, 0.0, *updated.Weight)
+
+	_, err = store.UpdateRelation("weighted", nil, nil, &weight, true)
+	assert.True(t, errors.Is(err, ErrInvalid))
+
+	updated, err = store.UpdateRelation("weighted", nil, nil, nil, true)
+	require.NoError(t, err)
+	assert.Nil(t, updated.Weight)
+
+	nan := math.NaN()
+	err = store.CreateRelation(&AssetRelation{ID: "nan", SourceAssetID: "tank", TargetAssetID: "valve", RelationType: RelationConnectedTo, Weight: &nan, CreatedAt: time.Now()})
+	assert.True(t, errors.Is(err, ErrInvalid))
+}
+
+// TestRelationAttributes_RoundTrip tests that typed attributes keep numbers,
+// strings and booleans apart, are kept by updates that omit them and reject
+// nested values
+func TestRelationAttributes_RoundTrip(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	createTestAssets(t, store, "pump", "tank")
+	require.NoError(t, store.CreateRelation(&AssetRelation{
+		ID:            "pipe",
+		SourceAssetID: "pump",
+		TargetAssetID: "tank",
+		RelationType:  RelationConnectedTo,
+		Metadata:      map[string]string{"slot": "1"},
+		Attributes:    map[string]any{"diameter": 150, "material": "steel", "code": "150", "insulated": true},
+		CreatedAt:     time.Now(),
+	}))
+
+	relation, err := store.GetRelation("pipe")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"diameter": 150.0, "material": "steel", "code": "150", "insulated": true}, relation.Attributes)
+	assert.Equal(t, map[string]string{"slot": "1"}, relation.Metadata)
+
+	encoded, err := json.Marshal(relation)
+	require.NoError(t, err)
+	var decoded AssetRelation
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, relation.Attributes, decoded.Attributes)
+
+	// nil attributes keep the stored ones, an empty map clears them
+	updated, err := store.UpdateRelation("pipe", nil, nil, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, 150.0, updated.Attributes["diameter"])
+
+	updated, err = store.UpdateRelation("pipe", nil, map[string]any{"diameter": 200.5}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"diameter": 200.5}, updated.Attributes)
+
+	updated, err = store.UpdateRelation("pipe", nil, map[string]any{}, nil, false)
+	require.NoError(t, err)
+	assert.Empty(t, updated.Attributes)
+
+	for _, attributes := range []map[string]any{
+		{"nested": map[string]any{"a": 1}},
+		{"list": []int{1, 2}},
+		{"null": nil},
+		{"": "empty key"},
+	} {
+		_, err = store.UpdateRelation("pipe", nil, attributes, nil, false)
+		assert.True(t, errors.Is(err, ErrInvalid), "%v", attributes)
+	}
+}
+
+// TestCloneAsset tests that a clone gets the template, labels and attributes
+// of its source and, on request, copies of its outgoing relations
+func TestCloneAsset(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	lat, lon := 37.5, 127.0
+	require.NoError(t, store.CreateAsset(&Asset{
+		ID:           "pump-01",
+		Name:         "pump-01",
+		TemplateName: "pump",
+		Labels:       []string{"site:a"},
+		Attributes:   map[string]string{"vendor": "acme"},
+		ExternalIDs:  map[string]string{"erp": "P-1"},
+		Latitude:     &lat,
+		Longitude:    &lon,
+		CreatedAt:    time.Now(),
+	}))
+	createTestAssets(t, store, "line", "tank", "sensor")
+	require.NoError(t, createTestRelation(t, store, "pump-01", "line", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "pump-01", "tank", RelationConnectedTo))
+	require.NoError(t, createTestRelation(t, store, "sensor", "pump-01", RelationMeasures))
+
+	clone := &Asset{ID: "pump-02", Name: "pump-02", CreatedAt: time.Now()}
+	relations, err := store.CloneAsset("pump-01", clone, false)
+	require.NoError(t, err)
+	assert.Empty(t, relations)
+
+	got, err := store.GetAsset("pump-02")
+	require.NoError(t, err)
+	assert.Equal(t, "pump", got.TemplateName)
+	assert.Equal(t, []string{"site:a"}, got.Labels)
+	assert.Equal(t, map[string]string{"vendor": "acme"}, got.Attributes)
+	assert.Empty(t, got.ExternalIDs)
+	assert.Nil(t, got.Latitude)
+	incoming, outgoing, err := store.CountRelations("pump-02")
+	require.NoError(t, err)
+	assert.Zero(t, incoming+outgoing)
+
+	// connectedTo is stored from pump-01 to tank but counts as outgoing
+	// either way; the measures relation points at the source and is not copied
+	clone = &Asset{ID: "pump-03", Name: "pump-03", CreatedAt: time.Now()}
+	relations, err = store.CloneAsset("pump-01", clone, true)
+	require.NoError(t, err)
+	assert.Len(t, relations, 2)
+	exists, err := store.RelationExists("pump-03", "line", RelationPartOf)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = store.RelationExists("pump-03", "tank", RelationConnectedTo)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = store.RelationExists("sensor", "pump-03", RelationMeasures)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// A taken name or a missing source leaves nothing behind
+	_, err = store.CloneAsset("pump-01", &Asset{ID: "pump-04", Name: "line", CreatedAt: time.Now()}, true)
+	assert.True(t, errors.Is(err, ErrDuplicate))
+	_, err = store.CloneAsset("missing", &Asset{ID: "pump-05", Name: "pump-05", CreatedAt: time.Now()}, false)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	for _, id := range []string{"pump-04", "pump-05"} {
+		got, err := store.GetAsset(id)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	}
+}
+
+// createTestAssets creates assets with the given IDs (name == ID)
+func createTestAssets(t *testing.T, store *Store, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		require.NoError(t, store.CreateAsset(&Asset{ID: id, Name: id, CreatedAt: time.Now()}))
+	}
+}
+
+// createTestRelation creates a relation with a generated ID
+func createTestRelation(t *testing.T, store *Store, source, target string, rt RelationType) error {
+	t.Helper()
+	return store.CreateRelation(&AssetRelation{
+		ID:            fmt.Sprintf("%s-%s-%s", source, rt, target),
+		SourceAssetID: source,
+		TargetAssetID: target,
+		RelationType:  rt,
+		CreatedAt:     time.Now(),
+	})
+}
+
+// TestCreateRelation_CycleDetection tests that hierarchical cycles are rejected
+func TestCreateRelation_CycleDetection(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	createTestAssets(t, store, "a", "b", "c")
+
+	// a partOf b partOf c
+	require.NoError(t, createTestRelation(t, store, "a", "b", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "b", "c", RelationPartOf))
+
+	// c partOf a closes the loop
+	err = createTestRelation(t, store, "c", "a", RelationPartOf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "relation would create a cycle")
+
+	// Direct reverse is a cycle too
+	err = createTestRelation(t, store, "b", "a", RelationPartOf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "relation would create a cycle")
+
+	// Self-reference
+	err = createTestRelation(t, store, "a", "a", RelationLocatedIn)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "relation would create a cycle")
+
+	// Cycles are checked per relation type
+	require.NoError(t, createTestRelation(t, store, "c", "a", RelationLocatedIn))
+
+	// Non-hierarchical types are exempt
+	require.NoError(t, createTestRelation(t, store, "c", "a", RelationMeasures))
+	require.NoError(t, createTestRelation(t, store, "a", "c", RelationMeasures))
+}
+
+// TestCreateRelationsBatch tests atomic batch creation with per-entry validation
+func TestCreateRelationsBatch(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	createTestAssets(t, store, "plant", "line", "machine")
+
+	newRelation := func(id, source, target string, rt RelationType) *AssetRelation {
+		return &AssetRelation{ID: id, SourceAssetID: source, TargetAssetID: target, RelationType: rt, CreatedAt: time.Now()}
+	}
+
+	require.NoError(t, store.CreateRelationsBatch([]*AssetRelation{
+		newRelation("r1", "line", "plant", RelationPartOf),
+		newRelation("r2", "machine", "line", RelationPartOf),
+	}))
+	descendants, err := store.GetDescendants("plant", RelationPartOf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"line", "machine"}, assetIDs(descendants))
+
+	// Every invalid entry is reported and nothing is written
+	err = store.CreateRelationsBatch([]*AssetRelation{
+		newRelation("r3", "machine", "plant", RelationLocatedIn),
+		newRelation("r4", "ghost", "plant", RelationPartOf),
+		newRelation("r5", "machine", "plant", "owns"),
+		newRelation("r6", "machine", "ghost", RelationPartOf),
+	})
+	var batchErr *RelationBatchError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []BatchItemError{
+		{Index: 1, Error: "source asset not found: ghost"},
+		{Index: 2, Error: "invalid relation type: owns"},
+		{Index: 3, Error: "target asset not found: ghost"},
+	}, batchErr.Items)
+
+	relation, err := store.GetRelation("r3")
+	require.NoError(t, err)
+	assert.Nil(t, relation)
+
+	// A cycle closed within the batch rolls back the earlier entries
+	err = store.CreateRelationsBatch([]*AssetRelation{
+		newRelation("r7", "machine", "plant", RelationConnectedTo),
+		newRelation("r8", "plant", "machine", RelationPartOf),
+	})
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, []BatchItemError{{Index: 1, Error: "relation would create a cycle"}}, batchErr.Items)
+	assert.Equal(t, "relation batch rejected: relation 1: relation would create a cycle", err.Error())
+
+	relation, err = store.GetRelation("r7")
+	require.NoError(t, err)
+	assert.Nil(t, relation)
+}
+
+// TestWouldCreateCycle_Diamond tests that shared ancestors are not cycles
+func TestWouldCreateCycle_Diamond(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	createTestAssets(t, store, "a", "b", "c", "d")
+
+	// a -> b -> d, a -> c -> d
+	require.NoError(t, createTestRelation(t, store, "a", "b", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "a", "c", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "b", "d", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "c", "d", RelationPartOf))
+
+	cycle, err := wouldCreateCycle(store.db, "b", "c", RelationPartOf)
+	require.NoError(t, err)
+	assert.False(t, cycle)
+
+	cycle, err = wouldCreateCycle(store.db, "d", "a", RelationPartOf)
+	require.NoError(t, err)
+	assert.True(t, cycle)
+}
+
+// TestScanAssetData tests the time range, asset filter and order of a scan
+func TestScanAssetData(t *testing.T) {
+	store := newTestStore(t)
+	for _, data := range []*AssetData{
+		{AssetID: "pump", Timestamp: baseMillis + 3000},
+		{AssetID: "fan", Timestamp: baseMillis + 1000},
+		{AssetID: "pump", Timestamp: baseMillis + 2000},
+		{AssetID: "pump", Timestamp: baseMillis + 4000},
+	} {
+		require.NoError(t, store.InsertAssetData(data))
+	}
+
+	scan := func(assetID string) []int64 {
+		var timestamps []int64
+		require.NoError(t, store.ScanAssetData(assetID, baseMillis+1000, baseMillis+4000, func(data *AssetData) error {
+			timestamps = append(timestamps, data.Timestamp-baseMillis)
+			return nil
+		}))
+		return timestamps
 	}
-	require.NoError(t, store.CreateRelation(rel1))
-	require.NoError(t, store.CreateRelation(rel2))
+	assert.Equal(t, []int64{1000, 2000, 3000}, scan(""))
+	assert.Equal(t, []int64{2000, 3000}, scan("pump"))
 
-	// Get relations by target
-	relations, err := store.GetRelationsByTargetAsset("asset-003")
+	stop := errors.New("stop")
+	err := store.ScanAssetData("", baseMillis, baseMillis+5000, func(*AssetData) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
+
+// TestGetRelationsBetween tests that relations in both directions are
+// returned with their direction as seen from the first asset
+func TestGetRelationsBetween(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "pump", "line", "sensor")
+	require.NoError(t, createTestRelation(t, store, "pump", "line", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "line", "pump", RelationConnectedTo))
+	require.NoError(t, createTestRelation(t, store, "sensor", "pump", RelationMeasures))
+
+	relations, err := store.GetRelationsBetween("pump", "line")
 	require.NoError(t, err)
-	assert.Len(t, relations, 2)
+	require.Len(t, relations, 2)
+	assert.Equal(t, "pump-partOf-line", relations[0].ID)
+	assert.Equal(t, "outgoing", relations[0].Direction)
+	assert.Equal(t, "line-connectedTo-pump", relations[1].ID)
+	assert.Equal(t, "incoming", relations[1].Direction)
+
+	relations, err = store.GetRelationsBetween("line", "pump")
+	require.NoError(t, err)
+	require.Len(t, relations, 2)
+	assert.Equal(t, "incoming", relations[0].Direction)
+	assert.Equal(t, "outgoing", relations[1].Direction)
+
+	relations, err = store.GetRelationsBetween("line", "sensor")
+	require.NoError(t, err)
+	assert.Empty(t, relations)
 }
 
-// TestDeleteRelation_Success tests relation deletion
-func TestDeleteRelation_Success(t *testing.T) {
+// TestCountRelations tests incoming and outgoing relation counts
+func TestCountRelations(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "line", "machine", "sensor", "hall")
+	require.NoError(t, createTestRelation(t, store, "machine", "line", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "sensor", "machine", RelationMeasures))
+	require.NoError(t, createTestRelation(t, store, "sensor", "line", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "line", "hall", RelationLocatedIn))
+
+	tests := []struct {
+		assetID            string
+		incoming, outgoing int
+	}{
+		{"line", 2, 1},
+		{"machine", 1, 1},
+		{"sensor", 0, 2},
+		{"hall", 1, 0},
+		{"missing", 0, 0},
+	}
+	for _, tt := range tests {
+		incoming, outgoing, err := store.CountRelations(tt.assetID)
+		require.NoError(t, err)
+		assert.Equal(t, tt.incoming, incoming, tt.assetID)
+		assert.Equal(t, tt.outgoing, outgoing, tt.assetID)
+	}
+}
+
+// TestRelationExists tests duplicate detection matching CreateRelation
+func TestRelationExists(t *testing.T) {
+	store := newTestStore(t)
+	createTestAssets(t, store, "line", "machine", "pump", "valve")
+	require.NoError(t, createTestRelation(t, store, "machine", "line", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "pump", "valve", RelationConnectedTo))
+
+	tests := []struct {
+		source, target string
+		rt             RelationType
+		want           string
+	}{
+		{"machine", "line", RelationPartOf, "machine-partOf-line"},
+		{"line", "machine", RelationPartOf, ""},
+		{"machine", "line", RelationConnectedTo, ""},
+		// symmetric relations match in either direction
+		{"valve", "pump", RelationConnectedTo, "pump-connectedTo-valve"},
+	}
+	for _, tt := range tests {
+		id, err := store.FindRelation(tt.source, tt.target, tt.rt)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, id, "%s -%s-> %s", tt.source, tt.rt, tt.target)
+
+		exists, err := store.RelationExists(tt.source, tt.target, tt.rt)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want != "", exists)
+
+		// A reported relation is exactly what CreateRelation rejects
+		err = store.CreateRelation(&AssetRelation{ID: "probe", SourceAssetID: tt.source, TargetAssetID: tt.target, RelationType: tt.rt})
+		assert.Equal(t, exists, errors.Is(err, ErrDuplicate), err)
+		if err == nil {
+			require.NoError(t, store.DeleteRelation("probe"))
+		}
+	}
+}
+
+// assetIDs extracts IDs for order-sensitive assertions
+func assetIDs(assets []*Asset) []string {
+	ids := make([]string, 0, len(assets))
+	for _, a := range assets {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+// TestTraverseRelations tests descendants/ancestors in breadth-first order
+func TestTraverseRelations(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	// Create assets and relation
-	sourceAsset := &Asset{ID: "asset-001", Name: "sensor-1", CreatedAt: time.Now()}
-	targetAsset := &Asset{ID: "asset-002", Name: "equipment-1", CreatedAt: time.Now()}
-	require.NoError(t, store.CreateAsset(sourceAsset))
-	require.NoError(t, store.CreateAsset(targetAsset))
+	// plant <- line-1 <- {machine-1, machine-2}; machine-1 <- sensor-1
+	createTestAssets(t, store, "plant", "line-1", "machine-1", "machine-2", "sensor-1")
+	require.NoError(t, createTestRelation(t, store, "line-1", "plant", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "machine-1", "line-1", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "machine-2", "line-1", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "sensor-1", "machine-1", RelationPartOf))
 
-	relation := &AssetRelation{
-		ID:            "rel-001",
-		SourceAssetID: "asset-001",
-		TargetAssetID: "asset-002",
-		RelationType:  RelationPartOf,
-		CreatedAt:     time.Now(),
-	}
-	require.NoError(t, store.CreateRelation(relation))
+	descendants, err := store.GetDescendants("plant", RelationPartOf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"line-1", "machine-1", "machine-2", "sensor-1"}, assetIDs(descendants))
 
-	// Delete relation
-	err = store.DeleteRelation("rel-001")
+	ancestors, err := store.GetAncestors("sensor-1", RelationPartOf)
 	require.NoError(t, err)
+	assert.Equal(t, []string{"machine-1", "line-1", "plant"}, assetIDs(ancestors))
 
-	// Verify deletion
-	retrieved, err := store.GetRelation("rel-001")
+	limited, err := store.TraverseRelations("plant", RelationPartOf, TreeDescendants, 2)
 	require.NoError(t, err)
-	assert.Nil(t, retrieved)
+	assert.Equal(t, []string{"line-1", "machine-1", "machine-2"}, assetIDs(limited))
+
+	// Other relation types are not followed
+	none, err := store.GetDescendants("plant", RelationLocatedIn)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	_, err = store.TraverseRelations("plant", RelationPartOf, "sideways", 0)
+	assert.Error(t, err)
 }
 
-// TestDeleteRelation_NotFound tests deletion of non-existent relation
-func TestDeleteRelation_NotFound(t *testing.T) {
+// TestTraverseRelations_CycleGuard tests that a malformed cyclic graph terminates
+func TestTraverseRelations_CycleGuard(t *testing.T) {
 	store, err := NewStore(":memory:")
 	require.NoError(t, err)
 	defer store.Close()
 
-	err = store.DeleteRelation("non-existent")
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "relation not found")
+	createTestAssets(t, store, "a", "b", "c")
+	require.NoError(t, createTestRelation(t, store, "a", "b", RelationPartOf))
+	require.NoError(t, createTestRelation(t, store, "b", "c", RelationPartOf))
+
+	// Bypass cycle detection to simulate a corrupted graph
+	_, err = store.db.Exec(
+		`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at) VALUES (?, ?, ?, ?, ?)`,
+		"bad", "c", "a", RelationPartOf, time.Now(),
+	)
+	require.NoError(t, err)
+
+	ancestors, err := store.GetAncestors("a", RelationPartOf)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, assetIDs(ancestors))
 }
 
 // TestCascadeDelete_WhenAssetDeleted tests cascade deletion
@@ -526,6 +1911,26 @@ func TestGetAsset_InvalidLabelsJSON(t *testing.T) {
 	}
 }
 
+// TestGetAsset_InvalidAttributesJSON tests error handling when attributes JSON is malformed
+func TestGetAsset_InvalidAttributesJSON(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.db.Exec(
+		`INSERT INTO assets (id, name, template_name, labels, attributes, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		"asset-001", "test-sensor", "temperature", "[]", "{invalid-json", time.Now(),
+	)
+	require.NoError(t, err)
+
+	asset, err := store.GetAsset("asset-001")
+	assert.Error(t, err, "should return error for malformed attributes JSON")
+	assert.Nil(t, asset)
+	if err != nil {
+		assert.Contains(t, err.Error(), "unmarshal asset attributes")
+	}
+}
+
 // TestGetAssetByName_InvalidLabelsJSON tests error handling when labels JSON is malformed
 func TestGetAssetByName_InvalidLabelsJSON(t *testing.T) {
 	store, err := NewStore(":memory:")
@@ -666,3 +2071,549 @@ func TestGetRelationsByTargetAsset_InvalidMetadataJSON(t *testing.T) {
 	assert.Error(t, err, "should return error when any relation has malformed metadata JSON")
 	assert.Nil(t, relations)
 }
+
+// ==================== AssetData Tests ====================
+
+// TestInsertAndQueryAssetData tests persistence and time-range retrieval
+func TestInsertAndQueryAssetData(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	for i := int64(1); i <= 5; i++ {
+		value := float64(i) * 1.5
+		data := &AssetData{
+			AssetID:   "sensor-001",
+			Timestamp: baseMillis + i*100,
+			Values:    []TagValue{{Name: "temperature", Number: &value, Unit: "celsius"}},
+		}
+		require.NoError(t, store.InsertAssetData(data))
+	}
+	other := "on"
+	require.NoError(t, store.InsertAssetData(&AssetData{
+		AssetID:   "sensor-002",
+		Timestamp: baseMillis + 200,
+		Values:    []TagValue{{Name: "state", Text: &other}},
+		Metadata:  map[string]string{"source": "test"},
+	}))
+
+	count, err := store.CountAssetData()
+	require.NoError(t, err)
+	assert.Equal(t, 6, count)
+
+	result, err := store.QueryAssetData("sensor-001", baseMillis+200, baseMillis+400)
+	require.NoError(t, err)
+	require.Len(t, result, 3)
+	assert.Equal(t, baseMillis+200, result[0].Timestamp)
+	assert.Equal(t, baseMillis+400, result[2].Timestamp)
+	require.NotNil(t, result[0].Values[0].Number)
+	assert.Equal(t, 3.0, *result[0].Values[0].Number)
+
+	result, err = store.QueryAssetData("sensor-002", baseMillis, baseMillis+1000)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "test", result[0].Metadata["source"])
+
+	result, err = store.QueryAssetData("sensor-001", baseMillis+1000, baseMillis+2000)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+// TestGetLatestData tests retrieval of an asset's newest reading through the index
+func TestGetLatestData(t *testing.T) {
+	store := newTestStore(t)
+
+	_, err := store.GetLatestData("sensor-001")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	for i, ts := range []int64{300, 100, 300, 200} {
+		require.NoError(t, store.InsertAssetData(&AssetData{
+			AssetID:   "sensor-001",
+			Timestamp: baseMillis + ts,
+			Metadata:  map[string]string{"seq": fmt.Sprint(i)},
+		}))
+	}
+	require.NoError(t, store.InsertAssetData(&AssetData{AssetID: "sensor-002", Timestamp: baseMillis + 900}))
+
+	latest, err := store.GetLatestData("sensor-001")
+	require.NoError(t, err)
+	assert.Equal(t, baseMillis+300, latest.Timestamp)
+	assert.Equal(t, "2", latest.Metadata["seq"], "the last stored reading wins a tie")
+
+	// The plan seeks the (asset_id, timestamp) index without sorting
+	rows, err := store.db.Query(`EXPLAIN QUERY PLAN SELECT asset_id, timestamp, tag_values, metadata FROM asset_data
+		WHERE asset_id = ? ORDER BY timestamp DESC, id DESC LIMIT 1`, "sensor-001")
+	require.NoError(t, err)
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		require.NoError(t, rows.Scan(&id, &parent, &notused, &detail))
+		plan = append(plan, detail)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"SEARCH asset_data USING INDEX idx_asset_data_asset_ts (asset_id=?)"}, plan)
+}
+
+// TestGetLatestData_MixedUnits tests that a reading stamped in unix seconds
+// is ordered by its time, not by its raw value
+func TestGetLatestData_MixedUnits(t *testing.T) {
+	store := newTestStore(t)
+
+	now := time.Now().Truncate(time.Second)
+	require.NoError(t, store.InsertAssetData(&AssetData{AssetID: "sensor-001", Timestamp: now.Add(-time.Minute).UnixMilli()}))
+	require.NoError(t, store.InsertAssetData(&AssetData{AssetID: "sensor-001", Timestamp: now.Unix()}))
+	require.NoError(t, store.InsertAssetData(&AssetData{AssetID: "sensor-001", Timestamp: now.Add(-time.Hour).Unix()}))
+
+	latest, err := store.GetLatestData("sensor-001")
+	require.NoError(t, err)
+	assert.Equal(t, now.UnixMilli(), latest.Timestamp)
+}
+
+// TestAggregateData tests per-bucket aggregates with explicit gaps
+func TestAggregateData(t *testing.T) {
+	store := newTestStore(t)
+
+	insert := func(ts int64, values ...TagValue) {
+		require.NoError(t, store.InsertAssetData(&AssetData{AssetID: "sensor-001", Timestamp: baseMillis + ts, Values: values}))
+	}
+	number := func(name string, v float64) TagValue { return TagValue{Name: name, Number: &v} }
+	state := "on"
+
+	insert(1000, number("temperature", 10), number("humidity", 50))
+	insert(1500, number("temperature", 20))
+	insert(1999, number("temperature", 30), TagValue{Name: "state", Text: &state})
+	// 2000-2999 is a gap
+	insert(3000, number("temperature", 5))
+	insert(4000, number("temperature", 99)) // outside [from, to)
+	require.NoError(t, store.InsertAssetData(&AssetData{AssetID: "sensor-002", Timestamp: baseMillis + 1000, Values: []TagValue{number("temperature", -1)}}))
+
+	buckets, err := store.AggregateData("sensor-001", "temperature", baseMillis+1000, baseMillis+4000, time.Second)
+	require.NoError(t, err)
+	require.Len(t, buckets, 3)
+
+	assert.Equal(t, baseMillis+1000, buckets[0].Start)
+	assert.Equal(t, 3, buckets[0].Count)
+	assert.Equal(t, 10.0, *buckets[0].Min)
+	assert.Equal(t, 30.0, *buckets[0].Max)
+	assert.Equal(t, 20.0, *buckets[0].Avg)
+
+	assert.Equal(t, DataBucket{Start: baseMillis + 2000}, buckets[1])
+
+	assert.Equal(t, baseMillis+3000, buckets[2].Start)
+	assert.Equal(t, 1, buckets[2].Count)
+	assert.Equal(t, 5.0, *buckets[2].Avg)
+
+	// A partial last bucket is still returned
+	buckets, err = store.AggregateData("sensor-001", "state", baseMillis+1000, baseMillis+2500, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []DataBucket{{Start: baseMillis + 1000}, {Start: baseMillis + 2000}}, buckets, "TEXT tags have no aggregates")
+
+	for _, tt := range []struct {
+		from, to int64
+		bucket   time.Duration
+	}{
+		{1000, 4000, 0},
+		{1000, 4000, time.Microsecond},
+		{4000, 1000, time.Second},
+		{0, MaxAggregateBuckets + 1, time.Millisecond},
+		{-5e18, 5e18, time.Millisecond},
+		{math.MinInt64, math.MaxInt64, time.Millisecond},
+		{math.MinInt64, math.MaxInt64, time.Duration(math.MaxInt64)},
+	} {
+		_, err := store.AggregateData("sensor-001", "temperature", tt.from, tt.to, tt.bucket)
+		assert.True(t, errors.Is(err, ErrInvalid), "%+v: %v", tt, err)
+	}
+}
+
+// TestAggregateData_Seconds tests that readings stamped in unix seconds are
+// bucketed by their time in milliseconds
+func TestAggregateData_Seconds(t *testing.T) {
+	store := newTestStore(t)
+
+	start := time.Now().Truncate(time.Minute)
+	for i, v := range []float64{10, 20, 30} {
+		data := &AssetData{AssetID: "sensor-001", Timestamp: start.Add(time.Duration(i) * 20 * time.Second).Unix()}
+		data.Values = []TagValue{{Name: "temperature", Number: &v}}
+		if i == 2 {
+			// The tag's own timestamp falls in the next minute
+			ts := start.Add(time.Minute).Unix()
+			data.Values[0].Timestamp = &ts
+		}
+		require.NoError(t, store.InsertAssetData(data))
+	}
+
+	buckets, err := store.AggregateData("sensor-001", "temperature", start.UnixMilli(), start.Add(time.Minute).UnixMilli(), time.Minute)
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, 3, buckets[0].Count)
+	assert.Equal(t, 20.0, *buckets[0].Avg)
+
+	stored, err := store.QueryAssetData("sensor-001", start.UnixMilli(), start.Add(time.Minute).UnixMilli())
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	assert.Equal(t, start.Add(40*time.Second).UnixMilli(), stored[2].Timestamp)
+	assert.Equal(t, start.Add(time.Minute).UnixMilli(), *stored[2].Values[0].Timestamp)
+}
+
+// TestWithTx_CommitAndRollback tests that WithTx commits on success and rolls back on error
+func TestWithTx_CommitAndRollback(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	insert := func(tx *sql.Tx, id string) error {
+		_, err := tx.Exec(`INSERT INTO assets (id, name, labels, created_at) VALUES (?, ?, '[]', ?)`, id, id, time.Now())
+		return err
+	}
+
+	err = store.WithTx(func(tx *sql.Tx) error { return insert(tx, "committed") })
+	require.NoError(t, err)
+
+	errBoom := errors.New("boom")
+	err = store.WithTx(func(tx *sql.Tx) error {
+		require.NoError(t, insert(tx, "rolled-back"))
+		return errBoom
+	})
+	assert.ErrorIs(t, err, errBoom)
+
+	exists, err := store.AssetExists("committed")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = store.AssetExists("rolled-back")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+// TestWithTx_IsolatesFromConcurrentDelete tests that a source asset deleted mid-transaction cannot
+// slip between the existence check and the relation insert
+func TestWithTx_IsolatesFromConcurrentDelete(t *testing.T) {
+	// A file database so the concurrent delete runs on its own connection
+	store, err := NewStore(filepath.Join(t.TempDir(), "metadata.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	createTestAssets(t, store, "src", "tgt")
+
+	deleteDone := make(chan error, 1)
+	deletedDuringTx := false
+	err = store.WithTx(func(tx *sql.Tx) error {
+		exists, err := assetExists(tx, "src")
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		// Delete the source from outside the transaction
+		go func() { deleteDone <- store.DeleteAsset("src") }()
+		select {
+		case err := <-deleteDone:
+			// The delete may only return early by failing on the lock
+			deletedDuringTx = err == nil
+			deleteDone <- err
+		case <-time.After(100 * time.Millisecond):
+			// Still waiting for the lock
+		}
+
+		_, err = tx.Exec(
+			`INSERT INTO asset_relations (id, source_asset_id, target_asset_id, relation_type, created_at) VALUES (?, ?, ?, ?, ?)`,
+			"r1", "src", "tgt", RelationPartOf, time.Now(),
+		)
+		return err
+	})
+	require.NoError(t, err)
+	assert.False(t, deletedDuringTx, "delete must not complete while the transaction is open")
+
+	deleteErr := <-deleteDone
+	exists, err := store.AssetExists("src")
+	require.NoError(t, err)
+	relation, err := store.GetRelation("r1")
+	require.NoError(t, err)
+
+	if deleteErr != nil {
+		// Delete was refused: the relation and its source both survive
+		assert.True(t, exists)
+		assert.NotNil(t, relation)
+	} else {
+		// Delete ran after commit: the relation was removed with its source
+		assert.False(t, exists)
+		assert.Nil(t, relation)
+	}
+}
+
+// TestStoreLimits tests the relation metadata and label limits of StoreOptions
+func TestStoreLimits(t *testing.T) {
+	opts := DefaultStoreOptions()
+	opts.MaxRelationMetadataBytes = 64
+	opts.MaxAssetLabels = 2
+	opts.MaxLabelLength = 8
+	store, err := NewStoreWithOptions(":memory:", opts)
+	require.NoError(t, err)
+	defer store.Close()
+
+	assertInvalid := func(err error, msg string) {
+		t.Helper()
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInvalid), err)
+		assert.Contains(t, err.Error(), msg)
+	}
+
+	// Labels on create, batch create and update
+	assertInvalid(store.CreateAsset(&Asset{ID: "a", Name: "a", Labels: []string{"x", "y", "z"}}), "3 labels, more than the maximum of 2")
+	assertInvalid(store.CreateAssetsBatch([]*Asset{{ID: "b", Name: "b", Labels: []string{"too-long-label"}}}), "label exceeds max length of 8 bytes")
+	require.NoError(t, store.CreateAsset(&Asset{ID: "line", Name: "line", Labels: []string{"hall-a", "floor-1"}}))
+	assertInvalid(store.UpdateAsset(&Asset{ID: "line", Labels: []string{"x", "y", "z"}}, []string{AssetFieldLabels}), "more than the maximum")
+	createTestAssets(t, store, "machine")
+
+	// Relation metadata on create, batch create and update
+	big := map[string]string{"note": strings.Repeat("x", 64)}
+	assertInvalid(store.CreateRelation(&AssetRelation{ID: "r1", SourceAssetID: "machine", TargetAssetID: "line", RelationType: RelationPartOf, Metadata: big}),
+		"relation metadata exceeds max size")
+	assertInvalid(store.CreateRelationsBatch([]*AssetRelation{{ID: "r1", SourceAssetID: "machine", TargetAssetID: "line", RelationType: RelationPartOf, Metadata: big}}),
+		"relation metadata exceeds max size")
+	require.NoError(t, store.CreateRelation(&AssetRelation{ID: "r1", SourceAssetID: "machine", TargetAssetID: "line", RelationType: RelationPartOf,
+		Metadata: map[string]string{"slot": "1"}}))
+	_, err = store.UpdateRelationMetadata("r1", big)
+	assertInvalid(err, "relation metadata exceeds max size")
+
+	// Snapshot imports are held to the same limits
+	snapshot, err := json.Marshal(Snapshot{Version: SnapshotVersion, Assets: []*Asset{{ID: "c", Name: "c", Labels: []string{"x", "y", "z"}}}})
+	require.NoError(t, err)
+	assertInvalid(store.ImportSnapshot(snapshot, SnapshotMerge), "asset c: asset has 3 labels")
+
+	// Zero disables the limits
+	unlimited, err := NewStoreWithOptions(":memory:", StoreOptions{})
+	require.NoError(t, err)
+	defer unlimited.Close()
+	require.NoError(t, unlimited.CreateAsset(&Asset{ID: "a", Name: "a", Labels: []string{"x", "y", strings.Repeat("z", 1000)}}))
+}
+
+// TestNewStoreWithOptions_WAL tests that the journal mode option is applied to file databases
+func TestNewStoreWithOptions_WAL(t *testing.T) {
+	store, err := NewStoreWithOptions(filepath.Join(t.TempDir(), "metadata.db"), DefaultStoreOptions())
+	require.NoError(t, err)
+	defer store.Close()
+
+	var mode string
+	require.NoError(t, store.db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+
+	var timeout int
+	require.NoError(t, store.db.QueryRow("PRAGMA busy_timeout").Scan(&timeout))
+	assert.Equal(t, 5000, timeout)
+}
+
+// TestStore_ConcurrentWrites tests that concurrent inserts wait for the lock instead of failing
+func TestStore_ConcurrentWrites(t *testing.T) {
+	store, err := NewStore(filepath.Join(t.TempDir(), "metadata.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	const workers = 20
+	const perWorker = 25
+
+	errs := make(chan error, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id := fmt.Sprintf("asset-%d-%d", w, i)
+				errs <- store.CreateAsset(&Asset{ID: id, Name: id, CreatedAt: time.Now()})
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	stats, err := store.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, workers*perWorker, stats.TotalAssets)
+}
+
+// TestMigrations_AppliedOnce tests that a new store records every migration and reopening applies nothing twice
+func TestMigrations_AppliedOnce(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "metadata.db")
+
+	store, err := NewStore(dbPath)
+	require.NoError(t, err)
+	version, err := store.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+	createTestAssets(t, store, "asset-001")
+	require.NoError(t, store.Close())
+
+	store, err = NewStore(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	var applied int
+	require.NoError(t, store.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied))
+	assert.Equal(t, len(migrations), applied)
+
+	exists, err := store.AssetExists("asset-001")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+// TestMigrations_AdoptExistingSchema tests that a database created before migrations adopts v1 without losing data
+func TestMigrations_AdoptExistingSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "metadata.db")
+
+	// Simulate a pre-migration database
+	db, err := sql.Open("sqlite3", dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+	CREATE TABLE assets (
+		id TEXT PRIMARY KEY,
+		name TEXT UNIQUE NOT NULL,
+		template_name TEXT,
+		labels TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO assets (id, name, template_name, labels) VALUES ('legacy', 'legacy', '', '[]');`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	store, err := NewStore(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	version, err := store.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+
+	asset, err := store.GetAsset("legacy")
+	require.NoError(t, err)
+	require.NotNil(t, asset)
+}
+
+// TestMillisDataTimestamps tests that data stored in unix seconds is
+// converted to milliseconds and data already in milliseconds is kept
+func TestMillisDataTimestamps(t *testing.T) {
+	store := newTestStore(t)
+
+	now := time.Now().Truncate(time.Second)
+	for _, row := range []struct {
+		ts     int64
+		values string
+	}{
+		{now.Unix(), `[{"name":"temperature","number":1}]`},
+		{now.UnixMilli(), fmt.Sprintf(`[{"name":"temperature","number":2,"timestamp":%d}]`, now.Add(-time.Second).Unix())},
+		{now.UnixMilli(), `[{"name":"temperature","number":3}]`},
+	} {
+		_, err := store.db.Exec(`INSERT INTO asset_data (asset_id, timestamp, tag_values) VALUES ('sensor-001', ?, ?)`, row.ts, row.values)
+		require.NoError(t, err)
+	}
+
+	tx, err := store.db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, millisDataTimestamps(tx))
+	require.NoError(t, tx.Commit())
+
+	stored, err := store.QueryAssetData("sensor-001", now.UnixMilli(), now.UnixMilli())
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	for _, data := range stored {
+		assert.Equal(t, now.UnixMilli(), data.Timestamp)
+	}
+	assert.Nil(t, stored[0].Values[0].Timestamp)
+	require.NotNil(t, stored[1].Values[0].Timestamp)
+	assert.Equal(t, now.Add(-time.Second).UnixMilli(), *stored[1].Values[0].Timestamp)
+	assert.Equal(t, 3.0, *stored[2].Values[0].Number)
+}
+
+// TestMigrations_FailureRollsBack tests that a failing migration leaves the schema version unchanged
+func TestMigrations_FailureRollsBack(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "metadata.db")
+	store, err := NewStore(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	original := migrations
+	t.Cleanup(func() { migrations = original })
+	next := original[len(original)-1].version + 1
+	migrations = append(append([]migration{}, original...),
+		migration{version: next, name: "add table", up: execSQL(`CREATE TABLE extra (id TEXT)`)},
+		migration{version: next + 1, name: "broken", up: execSQL(`NOT VALID SQL`)},
+	)
+
+	_, err = NewStore(dbPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+
+	migrations = original
+	store, err = NewStore(dbPath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	version, err := store.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, next-1, version)
+
+	var tables int
+	require.NoError(t, store.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'extra'`).Scan(&tables))
+	assert.Equal(t, 0, tables)
+}
+
+// TestListRelations_Pagination tests paging over every relation with total count
+func TestListRelations_Pagination(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+	createTestAssets(t, store, "a", "b", "c", "d")
+
+	base := time.Now()
+	for i, target := range []string{"b", "c", "d"} {
+		require.NoError(t, store.CreateRelation(&AssetRelation{
+			ID:            fmt.Sprintf("rel-%d", i+1),
+			SourceAssetID: "a",
+			TargetAssetID: target,
+			RelationType:  RelationConnectedTo,
+			CreatedAt:     base.Add(time.Duration(i) * time.Second),
+		}))
+	}
+
+	page, total, err := store.ListRelations(2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, page, 2)
+	assert.Equal(t, "rel-2", page[0].ID)
+	assert.Equal(t, "rel-1", page[1].ID)
+
+	// Zero limit falls back to the default
+	all, total, err := store.ListRelations(0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, all, 3)
+}
+
+// TestInsertAssetDataOnce tests that a repeated content hash is a no-op while
+// unhashed inserts never conflict
+func TestInsertAssetDataOnce(t *testing.T) {
+	store, err := NewStore(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	data := &AssetData{AssetID: "sensor-001", Timestamp: 1000, Values: []TagValue{}}
+
+	inserted, err := store.InsertAssetDataOnce(data, "hash-1")
+	require.NoError(t, err)
+	assert.True(t, inserted)
+
+	inserted, err = store.InsertAssetDataOnce(data, "hash-1")
+	require.NoError(t, err)
+	assert.False(t, inserted)
+
+	require.NoError(t, store.InsertAssetData(data))
+	require.NoError(t, store.InsertAssetData(data))
+
+	count, err := store.CountAssetData()
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
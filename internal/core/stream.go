@@ -0,0 +1,126 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nats-io/nats.go"
+)
+
+// Stream settings
+const (
+	streamBuffer       = 256              // messages queued per client before dropping
+	streamWriteTimeout = 10 * time.Second // a client slower than this is disconnected
+	streamPingInterval = 30 * time.Second
+)
+
+// streamUpgrader accepts any origin: the feed is read-only, like /metrics,
+// and dashboards are usually served from another host
+var streamUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// StreamHandler serves /stream: it upgrades to a WebSocket and forwards each
+// message on subject, SubjectDataValidated or its prefixed form, as a JSON
+// text frame, optionally only those of the asset named by the asset_id
+// query parameter. Clients that fall behind lose messages, counted in
+// StreamDropped, so a slow client never blocks the NATS subscription.
+func StreamHandler(nc *nats.Conn, subject string, metrics *Metrics) http.Handler {
+	var clients atomic.Int64
+	metrics.RegisterGauge("edg_stream_clients", "WebSocket clients connected to /stream.", func() float64 {
+		return float64(clients.Load())
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assetID := r.URL.Query().Get("asset_id")
+
+		conn, err := streamUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already replied with an HTTP error
+			coreLog().Warn("stream upgrade failed", "remote", r.RemoteAddr, "error", err)
+			return
+		}
+		defer conn.Close()
+
+		clients.Add(1)
+		defer clients.Add(-1)
+
+		log := coreLog().With("remote", r.RemoteAddr, "asset_id", assetID)
+		var dropped atomic.Uint64
+		queue := make(chan []byte, streamBuffer)
+		sub, err := nc.Subscribe(subject, func(msg *nats.Msg) {
+			data, err := DecodePayload(msg)
+			if err != nil {
+				log.Warn("dropping undecodable message", "error", err)
+				return
+			}
+			if assetID != "" && messageAssetID(data) != assetID {
+				return
+			}
+			select {
+			case queue <- data:
+			default:
+				metrics.StreamDropped.Inc()
+				if dropped.Add(1) == 1 {
+					log.Warn("stream client too slow, dropping messages")
+				}
+			}
+		})
+		if err != nil {
+			log.Error("failed to subscribe stream", "error", err)
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "subscribe failed"),
+				time.Now().Add(streamWriteTimeout))
+			return
+		}
+		defer sub.Unsubscribe()
+		log.Info("stream client connected")
+
+		// The read loop handles control frames and notices the client going away
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		ping := time.NewTicker(streamPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-closed:
+				log.Info("stream client disconnected", "dropped", dropped.Load())
+				return
+			case data := <-queue:
+				conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+				if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+					log.Info("stream client disconnected", "dropped", dropped.Load(), "error", err)
+					return
+				}
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+					log.Info("stream client disconnected", "dropped", dropped.Load(), "error", err)
+					return
+				}
+			}
+		}
+	})
+}
+
+// messageAssetID returns the asset_id of an AssetData payload, or "" if it
+// cannot be parsed
+func messageAssetID(data []byte) string {
+	var msg struct {
+		AssetID string `json:"asset_id"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return ""
+	}
+	return msg.AssetID
+}
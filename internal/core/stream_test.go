@@ -0,0 +1,80 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialStream connects a WebSocket client to a /stream server and waits for
+// its NATS subscription
+func dialStream(t *testing.T, nc *nats.Conn, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	require.Eventually(t, func() bool { return nc.NumSubscriptions() == 1 }, 5*time.Second, 10*time.Millisecond)
+	return conn
+}
+
+// scrape returns the Prometheus exposition of m
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, m.WritePrometheus(&buf))
+	return buf.String()
+}
+
+// TestStreamHandler_ForwardsValidatedData tests forwarding with the asset_id filter
+func TestStreamHandler_ForwardsValidatedData(t *testing.T) {
+	_, nc, _ := startTestNATSServer(t, false)
+	server := httptest.NewServer(StreamHandler(nc, SubjectDataValidated, NewMetrics()))
+	defer server.Close()
+
+	conn := dialStream(t, nc, server.URL+"/stream?asset_id=sensor-002")
+
+	for _, id := range []string{"sensor-001", "sensor-002"} {
+		payload, err := json.Marshal(AssetData{AssetID: id, Timestamp: 1})
+		require.NoError(t, err)
+		require.NoError(t, nc.Publish(SubjectDataValidated, payload))
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msgType, raw, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, msgType)
+
+	var data AssetData
+	require.NoError(t, json.Unmarshal(raw, &data))
+	assert.Equal(t, "sensor-002", data.AssetID)
+}
+
+// TestStreamHandler_UnsubscribesOnDisconnect tests cleanup when the client goes away
+func TestStreamHandler_UnsubscribesOnDisconnect(t *testing.T) {
+	_, nc, _ := startTestNATSServer(t, false)
+	metrics := NewMetrics()
+	server := httptest.NewServer(StreamHandler(nc, SubjectDataValidated, metrics))
+	defer server.Close()
+
+	conn := dialStream(t, nc, server.URL+"/stream")
+	assert.Contains(t, scrape(t, metrics), "edg_stream_clients 1")
+
+	require.NoError(t, conn.Close())
+	require.Eventually(t, func() bool { return nc.NumSubscriptions() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, scrape(t, metrics), "edg_stream_clients 0")
+}
+
+// TestMessageAssetID tests asset ID extraction from payloads
+func TestMessageAssetID(t *testing.T) {
+	assert.Equal(t, "sensor-001", messageAssetID([]byte(`{"asset_id":"sensor-001","values":[]}`)))
+	assert.Equal(t, "", messageAssetID([]byte(`not json`)))
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateSubjectPrefix checks that prefix is usable as the leading tokens
+// of a subject: dot-separated, non-empty tokens without wildcards or spaces
+func ValidateSubjectPrefix(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("subject prefix is empty")
+	}
+	for _, token := range strings.Split(prefix, ".") {
+		if token == "" || strings.ContainsAny(token, "*> \t\r\n") {
+			return fmt.Errorf("invalid subject prefix %q", prefix)
+		}
+	}
+	return nil
+}
+
+// DataStreamNameFor returns the JetStream stream capturing the data subjects
+// under prefix: DataStreamName for the default, otherwise the prefix in
+// upper case with non-alphanumerics replaced, plus _DATA
+func DataStreamNameFor(prefix string) string {
+	if prefix == "" || prefix == DefaultSubjectPrefix {
+		return DataStreamName
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, prefix)
+	return name + "_DATA"
+}
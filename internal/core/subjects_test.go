@@ -0,0 +1,83 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPrefixSubject tests moving subject constants under a prefix
+func TestPrefixSubject(t *testing.T) {
+	assert.Equal(t, SubjectDataAsset, PrefixSubject("", SubjectDataAsset))
+	assert.Equal(t, SubjectDataAsset, PrefixSubject(DefaultSubjectPrefix, SubjectDataAsset))
+	assert.Equal(t, "site-a.data.asset", PrefixSubject("site-a", SubjectDataAsset))
+	assert.Equal(t, "acme.site-a.meta.asset.list", PrefixSubject("acme.site-a", SubjectAssetList))
+
+	assert.Equal(t, DataStreamName, DataStreamNameFor(DefaultSubjectPrefix))
+	assert.Equal(t, "ACME_SITE-A_DATA", DataStreamNameFor("acme.site-a"))
+
+	assert.NoError(t, ValidateSubjectPrefix("acme.site-a"))
+	for _, prefix := range []string{"", "site.", ".site", "site.*", "site.>", "site a"} {
+		assert.Error(t, ValidateSubjectPrefix(prefix), prefix)
+	}
+}
+
+// TestMetaHandler_SubjectPrefix tests that meta subjects register under the prefix
+func TestMetaHandler_SubjectPrefix(t *testing.T) {
+	_, nc, _ := startTestNATSServer(t, false)
+
+	store := newTestStore(t)
+	createTestAssets(t, store, "sensor-001")
+
+	handler := NewMetaHandler(store, NewTemplateLoader())
+	handler.SetSubjectPrefix("site-a")
+	require.NoError(t, handler.RegisterHandlers(nc))
+	require.NoError(t, nc.Flush())
+
+	resp := request(t, nc, "site-a.meta.asset.get", map[string]string{"id": "sensor-001"})
+	assert.True(t, resp.Success, resp.Error)
+
+	_, err := nc.Request(SubjectAssetGet, []byte(`{"id": "sensor-001"}`), 200*time.Millisecond)
+	assert.ErrorIs(t, err, nats.ErrNoResponders)
+}
+
+// TestHandleAssetData_SubjectPrefix tests that validated data is published under the prefix
+func TestHandleAssetData_SubjectPrefix(t *testing.T) {
+	_, nc, js := startTestNATSServer(t, true)
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     DataStreamNameFor("site-a"),
+		Subjects: []string{"site-a.data.>"},
+		Storage:  nats.MemoryStorage,
+	})
+	require.NoError(t, err)
+
+	received := make(chan *nats.Msg, 1)
+	sub, err := nc.Subscribe("site-a.data.validated", func(msg *nats.Msg) {
+		received <- msg
+	})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	handler := NewDataHandler(js, nil)
+	handler.SetSubjectPrefix("site-a")
+
+	value := 25.5
+	data, err := json.Marshal(&AssetData{
+		AssetID:   "sensor-001",
+		Timestamp: 1234567890,
+		Values:    []TagValue{{Name: "temperature", Number: &value, Quality: QualityGood}},
+	})
+	require.NoError(t, err)
+	handler.HandleAssetData(&nats.Msg{Subject: "site-a.data.asset", Data: data})
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, data, msg.Data)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for published message")
+	}
+}
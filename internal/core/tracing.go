@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of spans created by this package.
+// Spans go to the global tracer provider, which is a no-op until cmd/core
+// installs an exporter.
+const tracerName = "github.com/e7217/edg/internal/core"
+
+// Span attribute keys
+const (
+	attrSubject   = attribute.Key("messaging.destination.name")
+	attrAssetID   = attribute.Key("edg.asset_id")
+	attrTagCount  = attribute.Key("edg.tag_count")
+	attrBatchSize = attribute.Key("edg.batch_size")
+)
+
+// natsHeaderCarrier adapts NATS message headers for trace context propagation
+type natsHeaderCarrier nats.Header
+
+func (c natsHeaderCarrier) Get(key string) string { return nats.Header(c).Get(key) }
+
+func (c natsHeaderCarrier) Set(key, value string) { nats.Header(c).Set(key, value) }
+
+func (c natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// startMessageSpan starts a span for handling msg. It continues the trace
+// named by a W3C traceparent header on the message, and starts a new trace
+// when there is none.
+func startMessageSpan(msg *nats.Msg, kind trace.SpanKind) (context.Context, trace.Span) {
+	ctx := context.Background()
+	if msg.Header != nil {
+		ctx = propagation.TraceContext{}.Extract(ctx, natsHeaderCarrier(msg.Header))
+	}
+	return otel.Tracer(tracerName).Start(ctx, "process "+msg.Subject,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attrSubject.String(msg.Subject),
+		),
+	)
+}
+
+// traced wraps a request handler so each request runs inside a span
+func traced(handler nats.MsgHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		_, span := startMessageSpan(msg, trace.SpanKindServer)
+		defer span.End()
+		handler(msg)
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that records ended spans for the
+// duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// spanAttrs returns the attributes of span as a map
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+// TestHandleAssetData_Span tests span attributes and trace propagation from
+// the traceparent header
+func TestHandleAssetData_Span(t *testing.T) {
+	recorder := recordSpans(t)
+	handler := NewDataHandler(nil, nil)
+	payload := []byte(`{"asset_id":"sensor-001","timestamp":1768467600000,"values":[{"name":"a","number":1},{"name":"b","number":2}]}`)
+
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: payload})
+
+	header := nats.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.HandleAssetData(&nats.Msg{Subject: SubjectDataAsset, Data: payload, Header: header})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	root := spans[0]
+	assert.Equal(t, "process "+SubjectDataAsset, root.Name())
+	assert.Equal(t, trace.SpanKindConsumer, root.SpanKind())
+	assert.False(t, root.Parent().IsValid())
+	attrs := spanAttrs(root)
+	assert.Equal(t, SubjectDataAsset, attrs[attrSubject].AsString())
+	assert.Equal(t, "sensor-001", attrs[attrAssetID].AsString())
+	assert.Equal(t, int64(2), attrs[attrTagCount].AsInt64())
+
+	child := spans[1]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", child.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", child.Parent().SpanID().String())
+	assert.True(t, child.Parent().IsRemote())
+}
+
+// TestMetaHandler_Span tests that meta requests run inside a server span
+func TestMetaHandler_Span(t *testing.T) {
+	recorder := recordSpans(t)
+	_, nc := newTestMetaHandler(t)
+
+	resp := request(t, nc, SubjectTemplateList, struct{}{})
+	require.True(t, resp.Success, resp.Error)
+
+	require.Eventually(t, func() bool { return len(recorder.Ended()) == 1 }, time.Second, 10*time.Millisecond)
+	span := recorder.Ended()[0]
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, SubjectTemplateList, spanAttrs(span)[attrSubject].AsString())
+}
@@ -1,19 +1,26 @@
 package core
 
-// AssetData represents data collected from an asset
-type AssetData struct {
-	AssetID   string            `json:"asset_id"`
-	Timestamp int64             `json:"timestamp"`
-	Values    []TagValue        `json:"values"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
-}
+import "fmt"
+
+// SchemaPolicy decides what happens to data in a schema version newer or
+// otherwise unknown to this build
+type SchemaPolicy string
+
+// Schema policies
+const (
+	// SchemaPolicyReject publishes the message to SubjectDataRejected
+	SchemaPolicyReject SchemaPolicy = "reject"
+	// SchemaPolicyAccept logs the message and processes it as
+	// CurrentSchemaVersion, relying on newer fields being additive
+	SchemaPolicyAccept SchemaPolicy = "accept"
+)
 
-// TagValue represents an individual tag value
-type TagValue struct {
-	Name    string   `json:"name"`
-	Number  *float64 `json:"number,omitempty"`
-	Text    *string  `json:"text,omitempty"`
-	Flag    *bool    `json:"flag,omitempty"`
-	Unit    string   `json:"unit,omitempty"`
-	Quality string   `json:"quality"`
+// ParseSchemaPolicy validates a policy name
+func ParseSchemaPolicy(s string) (SchemaPolicy, error) {
+	switch policy := SchemaPolicy(s); policy {
+	case SchemaPolicyReject, SchemaPolicyAccept:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown schema policy %q (expected reject or accept)", s)
+	}
 }
@@ -91,3 +91,90 @@ func TestTagValue_Text(t *testing.T) {
 	assert.Nil(t, decoded.Number)
 	assert.Nil(t, decoded.Flag)
 }
+
+// TestTagValue_TimestampAndStatusCode tests per-value timestamp and status code JSON round-trip
+func TestTagValue_TimestampAndStatusCode(t *testing.T) {
+	value := 42.0
+	ts := int64(1234567891)
+	status := uint32(0x40000000)
+	tag := TagValue{
+		Name:       "temperature",
+		Number:     &value,
+		Timestamp:  &ts,
+		StatusCode: &status,
+	}
+
+	jsonData, err := json.Marshal(tag)
+	require.NoError(t, err)
+
+	var decoded TagValue
+	err = json.Unmarshal(jsonData, &decoded)
+	require.NoError(t, err)
+
+	require.NotNil(t, decoded.Timestamp)
+	assert.Equal(t, ts, *decoded.Timestamp)
+	require.NotNil(t, decoded.StatusCode)
+	assert.Equal(t, status, *decoded.StatusCode)
+
+	// Optional fields are omitted when unset
+	jsonData, err = json.Marshal(TagValue{Name: "temperature", Number: &value})
+	require.NoError(t, err)
+	assert.NotContains(t, string(jsonData), "timestamp")
+	assert.NotContains(t, string(jsonData), "status_code")
+}
+
+// TestNormalizeQuality tests canonical levels for mixed spellings
+func TestNormalizeQuality(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  Quality
+		known bool
+	}{
+		{"good", QualityGood, true},
+		{"GOOD", QualityGood, true},
+		{" Uncertain ", QualityUncertain, true},
+		{"bad", QualityBad, true},
+		{"", QualityGood, true},
+		{"questionable", QualityUncertain, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, known := NormalizeQuality(tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.known, known)
+		})
+	}
+}
+
+// TestQuality_AtLeast tests the ordering good > uncertain > bad
+func TestQuality_AtLeast(t *testing.T) {
+	assert.True(t, QualityGood.AtLeast(QualityUncertain))
+	assert.True(t, QualityUncertain.AtLeast(QualityUncertain))
+	assert.False(t, QualityBad.AtLeast(QualityUncertain))
+	assert.False(t, QualityUncertain.AtLeast(QualityGood))
+	assert.True(t, QualityBad.AtLeast(QualityBad))
+}
+
+// TestTagValue_EffectiveQuality tests that StatusCode takes precedence over Quality
+func TestTagValue_EffectiveQuality(t *testing.T) {
+	code := func(c uint32) *uint32 { return &c }
+
+	tests := []struct {
+		name string
+		tag  TagValue
+		want Quality
+	}{
+		{"quality only", TagValue{Quality: "good"}, "good"},
+		{"unnormalized quality", TagValue{Quality: "BAD"}, QualityBad},
+		{"good status", TagValue{Quality: "bad", StatusCode: code(0x00000000)}, QualityGood},
+		{"uncertain status", TagValue{Quality: "good", StatusCode: code(0x40000000)}, QualityUncertain},
+		{"bad status", TagValue{Quality: "good", StatusCode: code(0x80340000)}, QualityBad},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.tag.EffectiveQuality())
+		})
+	}
+}
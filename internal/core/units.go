@@ -0,0 +1,50 @@
+package core
+
+import (
+	_ "embed"
+	"sort"
+	"strings"
+)
+
+// qudtUnitsFile is the allowlist of QUDT unit symbols, one per line with the
+// QUDT local name after a tab
+//
+//go:embed qudt_units.txt
+var qudtUnitsFile string
+
+var qudtUnits = parseUnitList(qudtUnitsFile)
+
+// parseUnitList collects the first field of every non-blank, non-comment line
+func parseUnitList(list string) map[string]bool {
+	units := make(map[string]bool)
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		symbol, _, _ := strings.Cut(line, "\t")
+		units[symbol] = true
+	}
+	return units
+}
+
+// IsKnownUnit reports whether symbol is in the embedded QUDT unit allowlist
+func IsKnownUnit(symbol string) bool {
+	return qudtUnits[symbol]
+}
+
+// unknownUnits returns the declared units of a template that are not known
+// QUDT symbols, sorted and without duplicates
+func unknownUnits(template *AssetTemplate) []string {
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, res := range template.Resources {
+		if res.Unit == "" || IsKnownUnit(res.Unit) || seen[res.Unit] {
+			continue
+		}
+		seen[res.Unit] = true
+		unknown = append(unknown, res.Unit)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,525 @@
+// Package sdk provides a typed NATS client for the EDG platform subjects.
+//
+// The client wraps the platform.meta.* request/reply subjects and the
+// platform.data.asset publish subject, so adapters do not need to build the
+// JSON envelopes by hand.
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/e7217/edg/api"
+)
+
+// Platform types, defined in package api
+type (
+	Asset           = api.Asset
+	AssetTemplate   = api.AssetTemplate
+	AssetData       = api.AssetData
+	TagValue        = api.TagValue
+	AssetRelation   = api.AssetRelation
+	RelationType    = api.RelationType
+	StoreStats      = api.StoreStats
+	IntegrityReport = api.IntegrityReport
+	IntegrityIssues = api.IntegrityIssues
+	Snapshot        = api.Snapshot
+	DataBucket      = api.DataBucket
+	JSONSchema      = api.JSONSchema
+
+	CreateAssetRequest           = api.CreateAssetRequest
+	BatchCreateAssetsResponse    = api.BatchCreateAssetsResponse
+	TemplateReloadResult         = api.TemplateReloadResult
+	ListAssetsRequest            = api.ListAssetsRequest
+	ListAssetsResponse           = api.ListAssetsResponse
+	SearchAssetsRequest          = api.SearchAssetsRequest
+	StaleAssetsRequest           = api.StaleAssetsRequest
+	LatestDataRequest            = api.LatestDataRequest
+	AggregateDataRequest         = api.AggregateDataRequest
+	UpdateAssetRequest           = api.UpdateAssetRequest
+	CreateRelationRequest        = api.CreateRelationRequest
+	BatchCreateRelationsResponse = api.BatchCreateRelationsResponse
+	ListRelationsRequest         = api.ListRelationsRequest
+	RelationExistsRequest        = api.RelationExistsRequest
+	RelationExistsResponse       = api.RelationExistsResponse
+	RelationsBetweenRequest      = api.RelationsBetweenRequest
+	RelationBetween              = api.RelationBetween
+	RelationCount                = api.RelationCount
+	AssetWithDegree              = api.AssetWithDegree
+	UpdateRelationRequest        = api.UpdateRelationRequest
+	ListAllRelationsRequest      = api.ListAllRelationsRequest
+	ListAllRelationsResponse     = api.ListAllRelationsResponse
+	RelationTreeRequest          = api.RelationTreeRequest
+	ValidateDataRequest          = api.ValidateDataRequest
+)
+
+// Snapshot import modes
+const (
+	SnapshotMerge   = api.SnapshotMerge
+	SnapshotReplace = api.SnapshotReplace
+)
+
+// DefaultSubjectPrefix is the subject prefix of an instance started without
+// -subject-prefix
+const DefaultSubjectPrefix = api.DefaultSubjectPrefix
+
+// DefaultTimeout bounds requests whose context has no deadline
+const DefaultTimeout = 5 * time.Second
+
+// Error is returned when the platform answers with success=false
+type Error struct {
+	Subject string
+	Message string
+	Code    string          // stable error code, e.g. api.ErrCodeNotFound
+	Data    json.RawMessage // optional details, e.g. a BatchItemError
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Subject, e.Message)
+}
+
+// response mirrors api.Response with the payload left undecoded
+type response struct {
+	Success   bool            `json:"success"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	ErrorCode string          `json:"error_code,omitempty"`
+}
+
+// Client issues typed requests over an existing NATS connection
+type Client struct {
+	nc      *nats.Conn
+	timeout time.Duration
+	prefix  string // subject prefix of the platform instance
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout sets the timeout applied when a context has no deadline
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithSubjectPrefix addresses a platform instance started with
+// -subject-prefix instead of the default "platform"
+func WithSubjectPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.prefix = prefix
+	}
+}
+
+// NewClient creates a client on top of nc
+func NewClient(nc *nats.Conn, opts ...Option) *Client {
+	c := &Client{nc: nc, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// withDeadline applies the client timeout when ctx has no deadline
+func (c *Client) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// request sends req on subject and decodes the response data into out
+// (skipped when out is nil)
+func (c *Client) request(ctx context.Context, subject string, req, out any) error {
+	subject = api.PrefixSubject(c.prefix, subject)
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", subject, err)
+	}
+
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+
+	msg, err := c.nc.RequestWithContext(ctx, subject, payload)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", subject, err)
+	}
+
+	var resp response
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		return fmt.Errorf("invalid %s response: %w", subject, err)
+	}
+	if !resp.Success {
+		return &Error{Subject: subject, Message: resp.Error, Code: resp.ErrorCode, Data: resp.Data}
+	}
+
+	if out != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			return fmt.Errorf("invalid %s response data: %w", subject, err)
+		}
+	}
+	return nil
+}
+
+// ==================== Asset Methods ====================
+
+// CreateAsset registers a new asset
+func (c *Client) CreateAsset(ctx context.Context, req CreateAssetRequest) (*Asset, error) {
+	var asset Asset
+	if err := c.request(ctx, api.SubjectAssetCreate, req, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// CreateAssets registers several assets atomically
+func (c *Client) CreateAssets(ctx context.Context, reqs []CreateAssetRequest) (*BatchCreateAssetsResponse, error) {
+	var resp BatchCreateAssetsResponse
+	if err := c.request(ctx, api.SubjectAssetBatch, api.BatchCreateAssetsRequest{Assets: reqs}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetAsset returns the asset with the given ID
+func (c *Client) GetAsset(ctx context.Context, id string) (*Asset, error) {
+	var asset Asset
+	if err := c.request(ctx, api.SubjectAssetGet, api.GetAssetRequest{ID: id}, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// GetAssetWithDegree returns the asset with the given ID and its relation
+// counts
+func (c *Client) GetAssetWithDegree(ctx context.Context, id string) (*AssetWithDegree, error) {
+	var asset AssetWithDegree
+	if err := c.request(ctx, api.SubjectAssetGet, api.GetAssetRequest{ID: id, IncludeDegree: true}, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// GetAssetByName returns the asset with the given name
+func (c *Client) GetAssetByName(ctx context.Context, name string) (*Asset, error) {
+	var asset Asset
+	if err := c.request(ctx, api.SubjectAssetGet, api.GetAssetRequest{Name: name}, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// GetAssetByExternalID returns the asset whose external id for scheme is value
+func (c *Client) GetAssetByExternalID(ctx context.Context, scheme, value string) (*Asset, error) {
+	var asset Asset
+	if err := c.request(ctx, api.SubjectAssetGet, api.GetAssetRequest{Scheme: scheme, ExternalID: value}, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// ListAssets returns a page of assets matching the request filters
+func (c *Client) ListAssets(ctx context.Context, req ListAssetsRequest) (*ListAssetsResponse, error) {
+	var resp ListAssetsResponse
+	if err := c.request(ctx, api.SubjectAssetList, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SearchAssets returns assets matching the requested labels or name prefix
+func (c *Client) SearchAssets(ctx context.Context, req SearchAssetsRequest) ([]*Asset, error) {
+	var assets []*Asset
+	if err := c.request(ctx, api.SubjectAssetSearch, req, &assets); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// ListStaleAssets returns assets with no data accepted within threshold
+func (c *Client) ListStaleAssets(ctx context.Context, threshold time.Duration) ([]*Asset, error) {
+	var assets []*Asset
+	req := StaleAssetsRequest{Threshold: threshold.String()}
+	if err := c.request(ctx, api.SubjectAssetStale, req, &assets); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// GetLatestData returns the most recent reading stored for an asset
+func (c *Client) GetLatestData(ctx context.Context, assetID string) (*AssetData, error) {
+	var data AssetData
+	if err := c.request(ctx, api.SubjectDataLatest, LatestDataRequest{AssetID: assetID}, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// AggregateData returns min, max and average of a NUMBER tag per bucket,
+// empty buckets included
+func (c *Client) AggregateData(ctx context.Context, req AggregateDataRequest) ([]DataBucket, error) {
+	var buckets []DataBucket
+	if err := c.request(ctx, api.SubjectDataAggregate, req, &buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// UpdateAsset applies the non-nil fields of req
+func (c *Client) UpdateAsset(ctx context.Context, req UpdateAssetRequest) (*Asset, error) {
+	var asset Asset
+	if err := c.request(ctx, api.SubjectAssetUpdate, req, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// DeleteAsset soft-deletes the asset with the given ID; see RestoreAsset
+func (c *Client) DeleteAsset(ctx context.Context, id string) error {
+	return c.request(ctx, api.SubjectAssetDelete, api.DeleteAssetRequest{ID: id}, nil)
+}
+
+// HardDeleteAsset permanently removes an asset and its relations
+func (c *Client) HardDeleteAsset(ctx context.Context, id string) error {
+	return c.request(ctx, api.SubjectAssetDelete, api.DeleteAssetRequest{ID: id, Hard: true}, nil)
+}
+
+// RestoreAsset undoes a soft delete
+func (c *Client) RestoreAsset(ctx context.Context, id string) (*Asset, error) {
+	var asset Asset
+	if err := c.request(ctx, api.SubjectAssetRestore, api.RestoreAssetRequest{ID: id}, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// CloneAsset creates an asset named name with the template, labels and
+// attributes of the asset id. With copyRelations its outgoing relations are
+// copied to start at the new asset.
+func (c *Client) CloneAsset(ctx context.Context, id, name string, copyRelations bool) (*Asset, error) {
+	var asset Asset
+	req := api.CloneAssetRequest{ID: id, Name: name, CopyRelations: copyRelations}
+	if err := c.request(ctx, api.SubjectAssetClone, req, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// ListTemplates returns every loaded asset template
+func (c *Client) ListTemplates(ctx context.Context) ([]*AssetTemplate, error) {
+	var templates []*AssetTemplate
+	if err := c.request(ctx, api.SubjectTemplateList, struct{}{}, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// ReloadTemplates reloads the templates from the service's template
+// directory. Files that failed to load are listed in the result and keep
+// their previous version.
+func (c *Client) ReloadTemplates(ctx context.Context) (*TemplateReloadResult, error) {
+	var result TemplateReloadResult
+	if err := c.request(ctx, api.SubjectTemplateReload, struct{}{}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ValidateData checks data against a template without storing or
+// publishing it. A validation failure is an *Error with code
+// api.ErrCodeValidation whose message names the problem.
+func (c *Client) ValidateData(ctx context.Context, templateName string, data *AssetData) error {
+	return c.request(ctx, api.SubjectValidate, ValidateDataRequest{TemplateName: templateName, Data: data}, nil)
+}
+
+// Stats returns asset and relation counts
+func (c *Client) Stats(ctx context.Context) (*StoreStats, error) {
+	var stats StoreStats
+	if err := c.request(ctx, api.SubjectStats, struct{}{}, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// CheckIntegrity asks EDG Core to look for dangling relations, cycles and
+// unknown templates, listing at most limit IDs per category; zero uses the
+// server default
+func (c *Client) CheckIntegrity(ctx context.Context, limit int) (*IntegrityReport, error) {
+	var report IntegrityReport
+	if err := c.request(ctx, api.SubjectCheck, api.CheckRequest{Limit: limit}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetSchema returns the JSON Schema of the named API type, e.g.
+// CreateAssetRequest
+func (c *Client) GetSchema(ctx context.Context, typeName string) (*JSONSchema, error) {
+	var schema JSONSchema
+	if err := c.request(ctx, api.SubjectSchema, api.SchemaRequest{Type: typeName}, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// ListSchemas returns the JSON Schema of every API type, by type name
+func (c *Client) ListSchemas(ctx context.Context) (map[string]*JSONSchema, error) {
+	var schemas map[string]*JSONSchema
+	if err := c.request(ctx, api.SubjectSchema, api.SchemaRequest{}, &schemas); err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+// ==================== Relation Methods ====================
+
+// CreateRelation links two assets
+func (c *Client) CreateRelation(ctx context.Context, req CreateRelationRequest) (*AssetRelation, error) {
+	var relation AssetRelation
+	if err := c.request(ctx, api.SubjectRelationCreate, req, &relation); err != nil {
+		return nil, err
+	}
+	return &relation, nil
+}
+
+// CreateRelations links several asset pairs atomically. On rejection the
+// returned *Error carries a list of api.BatchItemError in Data.
+func (c *Client) CreateRelations(ctx context.Context, reqs []CreateRelationRequest) (*BatchCreateRelationsResponse, error) {
+	var resp BatchCreateRelationsResponse
+	if err := c.request(ctx, api.SubjectRelationBatch, api.BatchCreateRelationsRequest{Relations: reqs}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetRelation returns the relation with the given ID
+func (c *Client) GetRelation(ctx context.Context, id string) (*AssetRelation, error) {
+	var relation AssetRelation
+	if err := c.request(ctx, api.SubjectRelationGet, api.GetRelationRequest{ID: id}, &relation); err != nil {
+		return nil, err
+	}
+	return &relation, nil
+}
+
+// RelationExists reports whether a relation from source to target of type
+// rt exists, and its ID if so
+func (c *Client) RelationExists(ctx context.Context, source, target string, rt RelationType) (*RelationExistsResponse, error) {
+	var resp RelationExistsResponse
+	req := RelationExistsRequest{SourceAssetID: source, TargetAssetID: target, RelationType: rt}
+	if err := c.request(ctx, api.SubjectRelationExists, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// RelationsBetween returns the relations between assets a and b in either
+// direction, each tagged "outgoing" when it points from a to b and
+// "incoming" otherwise
+func (c *Client) RelationsBetween(ctx context.Context, a, b string) ([]*RelationBetween, error) {
+	var relations []*RelationBetween
+	req := RelationsBetweenRequest{AssetID: a, OtherAssetID: b}
+	if err := c.request(ctx, api.SubjectRelationBetween, req, &relations); err != nil {
+		return nil, err
+	}
+	return relations, nil
+}
+
+// CountRelations returns how many relations point to and from assetID
+func (c *Client) CountRelations(ctx context.Context, assetID string) (*RelationCount, error) {
+	var count RelationCount
+	if err := c.request(ctx, api.SubjectRelationCount, api.RelationCountRequest{AssetID: assetID}, &count); err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// ListRelations returns relations matching the request filters
+func (c *Client) ListRelations(ctx context.Context, req ListRelationsRequest) ([]*AssetRelation, error) {
+	var relations []*AssetRelation
+	if err := c.request(ctx, api.SubjectRelationList, req, &relations); err != nil {
+		return nil, err
+	}
+	return relations, nil
+}
+
+// ListAllRelations returns a page of every relation, newest first
+func (c *Client) ListAllRelations(ctx context.Context, req ListAllRelationsRequest) (*ListAllRelationsResponse, error) {
+	var resp ListAllRelationsResponse
+	if err := c.request(ctx, api.SubjectRelationListAll, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteRelation deletes the relation with the given ID
+func (c *Client) DeleteRelation(ctx context.Context, id string) error {
+	return c.request(ctx, api.SubjectRelationDelete, api.DeleteRelationRequest{ID: id}, nil)
+}
+
+// UpdateRelation replaces the metadata of an existing relation
+func (c *Client) UpdateRelation(ctx context.Context, req UpdateRelationRequest) (*AssetRelation, error) {
+	var relation AssetRelation
+	if err := c.request(ctx, api.SubjectRelationUpdate, req, &relation); err != nil {
+		return nil, err
+	}
+	return &relation, nil
+}
+
+// RelationTree returns the ancestors or descendants of an asset
+func (c *Client) RelationTree(ctx context.Context, req RelationTreeRequest) ([]*Asset, error) {
+	var assets []*Asset
+	if err := c.request(ctx, api.SubjectRelationTree, req, &assets); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// ExportJSONLD returns the asset graph as a JSON-LD document
+func (c *Client) ExportJSONLD(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	if err := c.request(ctx, api.SubjectExportJSONLD, struct{}{}, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ExportSnapshot returns a JSON snapshot of every asset and relation
+func (c *Client) ExportSnapshot(ctx context.Context) (json.RawMessage, error) {
+	var snapshot json.RawMessage
+	if err := c.request(ctx, api.SubjectSnapshotExport, struct{}{}, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// ImportSnapshot restores a snapshot made by ExportSnapshot. mode is
+// SnapshotMerge or SnapshotReplace.
+func (c *Client) ImportSnapshot(ctx context.Context, snapshot []byte, mode string) error {
+	return c.request(ctx, api.SubjectSnapshotImport, api.ImportSnapshotRequest{Mode: mode, Snapshot: snapshot}, nil)
+}
+
+// ==================== Data Methods ====================
+
+// PublishData publishes asset data to the platform and waits until the
+// server has received it
+func (c *Client) PublishData(ctx context.Context, data *AssetData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal asset data: %w", err)
+	}
+
+	if err := c.nc.Publish(api.PrefixSubject(c.prefix, api.SubjectDataAsset), payload); err != nil {
+		return fmt.Errorf("failed to publish asset data: %w", err)
+	}
+
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+	if err := c.nc.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush asset data: %w", err)
+	}
+	return nil
+}
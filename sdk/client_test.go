@@ -0,0 +1,220 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// startTestPlatform runs an embedded NATS server with the core handlers registered
+func startTestPlatform(t *testing.T) (*Client, *core.DataHandler) {
+	t.Helper()
+
+	ns, err := natsserver.NewServer(&natsserver.Options{Port: -1})
+	require.NoError(t, err)
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+
+	store, err := core.NewStore(":memory:")
+	require.NoError(t, err)
+
+	loader := core.NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFile("../internal/core/testdata/valid_template.yaml"))
+
+	require.NoError(t, core.NewMetaHandler(store, loader).RegisterHandlers(nc))
+
+	dataHandler := core.NewDataHandler(nil, store)
+	_, err = nc.Subscribe(core.SubjectDataAsset, dataHandler.HandleAssetData)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		nc.Close()
+		store.Close()
+		ns.Shutdown()
+	})
+
+	return NewClient(nc, WithTimeout(2*time.Second)), dataHandler
+}
+
+// TestClient_AssetRoundTrip tests asset create, get, list, update and delete
+func TestClient_AssetRoundTrip(t *testing.T) {
+	client, _ := startTestPlatform(t)
+	ctx := context.Background()
+
+	created, err := client.CreateAsset(ctx, CreateAssetRequest{Name: "sensor-1", TemplateName: "test-sensor", Labels: []string{"line-1"}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+
+	got, err := client.GetAsset(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "sensor-1", got.Name)
+
+	got, err = client.GetAssetByName(ctx, "sensor-1")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, got.ID)
+
+	page, err := client.ListAssets(ctx, ListAssetsRequest{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+
+	found, err := client.SearchAssets(ctx, SearchAssetsRequest{Labels: []string{"line-1"}})
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	name := "sensor-renamed"
+	updated, err := client.UpdateAsset(ctx, UpdateAssetRequest{ID: created.ID, Name: &name})
+	require.NoError(t, err)
+	assert.Equal(t, "sensor-renamed", updated.Name)
+
+	stats, err := client.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.AssetsByTemplate["test-sensor"])
+
+	templates, err := client.ListTemplates(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, templates)
+
+	require.NoError(t, client.DeleteAsset(ctx, created.ID))
+	_, err = client.GetAsset(ctx, created.ID)
+	assert.Error(t, err)
+}
+
+// TestClient_ErrorResponse tests that success=false becomes a typed error
+func TestClient_ErrorResponse(t *testing.T) {
+	client, _ := startTestPlatform(t)
+	ctx := context.Background()
+
+	_, err := client.CreateAsset(ctx, CreateAssetRequest{Name: "dup"})
+	require.NoError(t, err)
+
+	_, err = client.CreateAsset(ctx, CreateAssetRequest{Name: "dup"})
+	var platformErr *Error
+	require.True(t, errors.As(err, &platformErr))
+	assert.Equal(t, core.SubjectAssetCreate, platformErr.Subject)
+	assert.Equal(t, "asset name already exists", platformErr.Message)
+	assert.Equal(t, core.ErrCodeDuplicate, platformErr.Code)
+
+	// Batch rejections carry the offending item in Data
+	_, err = client.CreateAssets(ctx, []CreateAssetRequest{{Name: "new"}, {Name: "dup"}})
+	require.True(t, errors.As(err, &platformErr))
+	var item core.BatchItemError
+	require.NoError(t, json.Unmarshal(platformErr.Data, &item))
+	assert.Equal(t, 1, item.Index)
+}
+
+// TestClient_Relations tests relation helpers
+func TestClient_Relations(t *testing.T) {
+	client, _ := startTestPlatform(t)
+	ctx := context.Background()
+
+	batch, err := client.CreateAssets(ctx, []CreateAssetRequest{{Name: "line"}, {Name: "machine"}})
+	require.NoError(t, err)
+	require.Equal(t, 2, batch.Created)
+	line, machine := batch.Assets[0], batch.Assets[1]
+
+	relation, err := client.CreateRelation(ctx, CreateRelationRequest{
+		SourceAssetID: machine.ID,
+		TargetAssetID: line.ID,
+		RelationType:  core.RelationPartOf,
+	})
+	require.NoError(t, err)
+
+	got, err := client.GetRelation(ctx, relation.ID)
+	require.NoError(t, err)
+	assert.Equal(t, machine.ID, got.SourceAssetID)
+
+	relations, err := client.ListRelations(ctx, ListRelationsRequest{AssetID: line.ID})
+	require.NoError(t, err)
+	assert.Len(t, relations, 1)
+
+	all, err := client.ListAllRelations(ctx, ListAllRelationsRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, all.Total)
+
+	lineParents, err := client.RelationTree(ctx, RelationTreeRequest{AssetID: line.ID, Direction: core.TreeAncestors})
+	require.NoError(t, err)
+	assert.Empty(t, lineParents)
+
+	parents, err := client.RelationTree(ctx, RelationTreeRequest{AssetID: machine.ID, Direction: core.TreeAncestors})
+	require.NoError(t, err)
+	require.Len(t, parents, 1)
+	assert.Equal(t, line.ID, parents[0].ID)
+
+	doc, err := client.ExportJSONLD(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, string(doc), "@graph")
+
+	require.NoError(t, client.DeleteRelation(ctx, relation.ID))
+
+	batchRelations, err := client.CreateRelations(ctx, []CreateRelationRequest{
+		{SourceAssetID: machine.ID, TargetAssetID: line.ID, RelationType: core.RelationPartOf},
+		{SourceAssetID: machine.ID, TargetAssetID: line.ID, RelationType: core.RelationConnectedTo},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, batchRelations.Created)
+	assert.NotEmpty(t, batchRelations.Relations[1].ID)
+}
+
+// TestClient_PublishData tests publishing asset data to the data subject
+func TestClient_PublishData(t *testing.T) {
+	client, dataHandler := startTestPlatform(t)
+
+	temperature := 21.5
+	err := client.PublishData(context.Background(), &AssetData{
+		AssetID:   "sensor-001",
+		Timestamp: time.Now().UnixMilli(),
+		Values:    []TagValue{{Name: "temperature", Number: &temperature, Quality: "good"}},
+	})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return dataHandler.GetDataCount() == 1 }, 2*time.Second, 10*time.Millisecond)
+}
+
+// TestClient_NoResponder tests that requests to an unserved subject fail instead of hanging
+func TestClient_NoResponder(t *testing.T) {
+	ns, err := natsserver.NewServer(&natsserver.Options{Port: -1})
+	require.NoError(t, err)
+	go ns.Start()
+	defer ns.Shutdown()
+	require.True(t, ns.ReadyForConnections(5*time.Second))
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	_, err = NewClient(nc, WithTimeout(200*time.Millisecond)).GetAsset(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
+// TestDependencies tests that the SDK links neither the core service nor
+// its storage and server dependencies
+func TestDependencies(t *testing.T) {
+	out, err := exec.Command("go", "list", "-deps", ".").Output()
+	if err != nil {
+		t.Skipf("go list failed: %v", err)
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		if strings.HasPrefix(dep, "github.com/e7217/edg/internal/") ||
+			strings.HasPrefix(dep, "github.com/mattn/go-sqlite3") ||
+			strings.HasPrefix(dep, "github.com/nats-io/nats-server") ||
+			strings.HasPrefix(dep, "go.opentelemetry.io/") {
+			t.Errorf("sdk depends on %s", dep)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+// Package templates embeds the default asset templates, so EDG Core has a
+// baseline set even when no template directory is deployed next to it.
+package templates
+
+import "embed"
+
+// FS holds the template files of this directory at its root
+//
+//go:embed *.yaml
+var FS embed.FS
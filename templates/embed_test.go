@@ -0,0 +1,19 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/e7217/edg/internal/core"
+)
+
+// TestFS tests that every embedded template loads and passes the self-check
+func TestFS(t *testing.T) {
+	loader := core.NewTemplateLoader()
+	require.NoError(t, loader.LoadFromFS(FS, "."))
+	assert.True(t, loader.Exists("temp-sensor"))
+	assert.True(t, loader.Exists("vibration-sensor"))
+	assert.Empty(t, loader.Validate())
+}
@@ -125,8 +125,9 @@ import (
 )
 
 type TestData struct {
-	AssetID string      `json:"asset_id"`
-	Values  []TestValue `json:"values"`
+	AssetID   string      `json:"asset_id"`
+	Timestamp int64       `json:"timestamp"`
+	Values    []TestValue `json:"values"`
 }
 
 type TestValue struct {
@@ -148,7 +149,8 @@ func main() {
 		humidity := 50.0 + float64(i)*5.0
 
 		testData := TestData{
-			AssetID: fmt.Sprintf("sensor-%03d", i+1),
+			AssetID:   fmt.Sprintf("sensor-%03d", i+1),
+			Timestamp: time.Now().UnixMilli(),
 			Values: []TestValue{
 				{
 					Name:    "temperature",